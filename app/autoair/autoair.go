@@ -1,5 +1,7 @@
 /*
-Auto-Air opens the air-cleaner automatically when the pm2.5 >= 130.
+Auto-Air opens the air-cleaner automatically when the pm2.5 >= 120.
+The thresholds and the night time can be changed in the menu on an oled,
+they're saved in settings.json.
 */
 
 package main
@@ -7,14 +9,17 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"image"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/shanghuiyang/rpi-devices/base"
 	"github.com/shanghuiyang/rpi-devices/dev"
 	"github.com/shanghuiyang/rpi-devices/iot"
+	"github.com/shanghuiyang/rpi-devices/ui"
 	"github.com/stianeikeland/go-rpio"
 )
 
@@ -25,6 +30,16 @@ const (
 const (
 	trigOnPM25  = 120
 	trigOffPm25 = 100
+	// the air-cleaner is disabled at night unless the pm2.5 is too high
+	nightFrom       = 20
+	nightTo         = 8
+	nightMaxPM25    = 400
+	settingsFile    = "settings.json"
+	keyOnPM25       = "on_pm25"
+	keyOffPM25      = "off_pm25"
+	keyNightFrom    = "night_from"
+	keyNightTo      = "night_to"
+	oledMenuRefresh = 200 * time.Millisecond
 )
 
 var (
@@ -41,11 +56,23 @@ type pm25Response struct {
 }
 
 type autoAir struct {
-	sg      *dev.SG90
-	cloud   iot.Cloud
-	state   bool        // true: turn on, false: turn off
-	chClean chan uint16 // for turning on/off the air-cleaner
-	chCloud chan uint16 // for pushing to iot cloud
+	sg       *dev.SG90
+	cloud    iot.Cloud
+	settings *base.Settings
+	state    bool        // true: turn on, false: turn off
+	chClean  chan uint16 // for turning on/off the air-cleaner
+	chCloud  chan uint16 // for pushing to iot cloud
+
+	mu        sync.Mutex
+	onPM25    int
+	offPM25   int
+	nightFrom int
+	nightTo   int
+
+	screen *ui.Screen
+	inputs *ui.MenuInputs
+	pm25   *ui.Readout
+	status *ui.Label
 }
 
 func main() {
@@ -62,7 +89,12 @@ func main() {
 	}
 	cloud := iot.NewCloud(onenetCfg)
 
-	autoair = newAutoAir(sg, cloud)
+	autoair = newAutoAir(sg, cloud, base.LoadSettings(settingsFile))
+	if cfg, err := base.LoadConfig(); err == nil && cfg.OLED != nil && cfg.Menu != nil {
+		if err := autoair.setupMenu(cfg); err != nil {
+			log.Printf("[autoair]failed to setup the menu, error: %v", err)
+		}
+	}
 	base.WaitQuit(func() {
 		autoair.stop()
 		rpio.Close()
@@ -70,14 +102,134 @@ func main() {
 	autoair.start()
 }
 
-func newAutoAir(sg *dev.SG90, cloud iot.Cloud) *autoAir {
-	return &autoAir{
-		sg:      sg,
-		cloud:   cloud,
-		state:   false,
-		chClean: make(chan uint16, 4),
-		chCloud: make(chan uint16, 4),
+func newAutoAir(sg *dev.SG90, cloud iot.Cloud, settings *base.Settings) *autoAir {
+	a := &autoAir{
+		sg:        sg,
+		cloud:     cloud,
+		settings:  settings,
+		state:     false,
+		chClean:   make(chan uint16, 4),
+		chCloud:   make(chan uint16, 4),
+		onPM25:    trigOnPM25,
+		offPM25:   trigOffPm25,
+		nightFrom: nightFrom,
+		nightTo:   nightTo,
 	}
+	settings.Get(keyOnPM25, &a.onPM25)
+	settings.Get(keyOffPM25, &a.offPM25)
+	settings.Get(keyNightFrom, &a.nightFrom)
+	settings.Get(keyNightTo, &a.nightTo)
+	log.Printf("[autoair]on: %v, off: %v, night: %v-%v", a.onPM25, a.offPM25, a.nightFrom, a.nightTo)
+	return a
+}
+
+// setupMenu shows the pm2.5 on the oled, and the menu for the thresholds and the night time
+func (a *autoAir) setupMenu(cfg *base.Config) error {
+	oled, err := dev.NewSSD1306(cfg.OLED)
+	if err != nil {
+		return err
+	}
+	b := oled.Bounds()
+	a.pm25 = ui.NewReadout(image.Rect(0, 0, b.Dx(), 13), ui.DefaultFace, "PM2.5", "", 0)
+	a.status = ui.NewLabel(image.Rect(0, 16, b.Dx(), b.Dy()), ui.DefaultFace, ui.AlignLeft)
+	a.updateStatus()
+
+	hour := func(title string, v *int, key string) *ui.MenuItem {
+		return &ui.MenuItem{
+			Title:  title,
+			Editor: a.setting(v, key, 0, 23, 1, "h", nil),
+		}
+	}
+	menu := ui.NewMenu(b, ui.DefaultFace, &ui.MenuItem{
+		Title: "Auto-Air",
+		Items: []*ui.MenuItem{
+			{
+				Title: "On at",
+				Editor: a.setting(&a.onPM25, keyOnPM25, 10, 500, 5, "", func(v int) error {
+					if v <= a.offPM25 {
+						return fmt.Errorf("must > off %v", a.offPM25)
+					}
+					return nil
+				}),
+			},
+			{
+				Title: "Off at",
+				Editor: a.setting(&a.offPM25, keyOffPM25, 0, 500, 5, "", func(v int) error {
+					if v >= a.onPM25 {
+						return fmt.Errorf("must < on %v", a.onPM25)
+					}
+					return nil
+				}),
+			},
+			{
+				Title: "Night",
+				Items: []*ui.MenuItem{
+					hour("From", &a.nightFrom, keyNightFrom),
+					hour("To", &a.nightTo, keyNightTo),
+				},
+			},
+		},
+	})
+
+	a.screen = ui.NewScreen(oled)
+	a.screen.AddPage("home", a.pm25, a.status)
+	a.screen.AddPage("menu", menu)
+	menu.OnOpen(func() { a.screen.ShowPage("menu") })
+	menu.OnClose(func() {
+		a.updateStatus()
+		a.screen.ShowPage("home")
+	})
+
+	inputs, err := ui.NewMenuInputsFromConfig(menu, cfg)
+	if err != nil {
+		return err
+	}
+	a.inputs = inputs
+	a.inputs.Start()
+	a.screen.Start(oledMenuRefresh)
+	return nil
+}
+
+// setting creates an editor of the int setting, it's applied at once and saved
+func (a *autoAir) setting(v *int, key string, min, max, inc float64, unit string, check func(v int) error) *ui.Number {
+	return &ui.Number{
+		Get: func() float64 {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			return float64(*v)
+		},
+		Set: func(f float64) error {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			n := int(f)
+			if check != nil {
+				if err := check(n); err != nil {
+					return err
+				}
+			}
+			*v = n
+			log.Printf("[autoair]%v: %v", key, n)
+			return a.settings.Set(key, n)
+		},
+		Min:  min,
+		Max:  max,
+		Inc:  inc,
+		Unit: unit,
+	}
+}
+
+func (a *autoAir) updateStatus() {
+	if a.status == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state := "off"
+	if a.state {
+		state = "on"
+	}
+	a.status.SetText(fmt.Sprintf("cleaner: %v\non: %v off: %v\nnight: %02d-%02d",
+		state, a.onPM25, a.offPM25, a.nightFrom, a.nightTo))
 }
 
 func (a *autoAir) start() {
@@ -99,6 +251,9 @@ func (a *autoAir) detect() {
 		}
 		log.Printf("[autoair]pm2.5: %v ug/m3", pm25)
 
+		if a.pm25 != nil {
+			a.pm25.SetValue(float64(pm25))
+		}
 		a.chClean <- pm25
 		time.Sleep(60 * time.Second)
 	}
@@ -106,36 +261,54 @@ func (a *autoAir) detect() {
 
 func (a *autoAir) clean() {
 	for pm25 := range a.chClean {
-		hour := time.Now().Hour()
-		if pm25 < 400 && (hour >= 20 || hour < 8) {
-			// disable at 20:00-08:00
-			log.Printf("[autoair]auto air-cleaner was disabled at 20:00-08:00")
-			if a.state {
+		a.mu.Lock()
+		on, off := a.onPM25, a.offPM25
+		state := a.state
+		night := a.isNight(time.Now().Hour())
+		a.mu.Unlock()
+
+		if pm25 < nightMaxPM25 && night {
+			// disable at night
+			log.Printf("[autoair]auto air-cleaner was disabled at night")
+			if state {
 				a.off()
 			}
+			a.updateStatus()
 			continue
 		}
 
-		if !a.state && pm25 >= trigOnPM25 {
+		if !state && int(pm25) >= on {
 			a.on()
 			log.Printf("[autoair]air-cleaner was turned on")
-			continue
-		}
-		if a.state && pm25 < trigOffPm25 {
+		} else if state && int(pm25) < off {
 			a.off()
 			log.Printf("[autoair]air-cleaner was turned off")
-			continue
 		}
+		a.updateStatus()
 	}
 }
 
+// isNight returns whether the hour is in the night, e.g. 20:00-08:00
+func (a *autoAir) isNight(hour int) bool {
+	if a.nightFrom == a.nightTo {
+		return false
+	}
+	if a.nightFrom < a.nightTo {
+		return hour >= a.nightFrom && hour < a.nightTo
+	}
+	return hour >= a.nightFrom || hour < a.nightTo
+}
+
 // push state to cloud
 func (a *autoAir) push() {
 	for {
 		time.Sleep(60 * time.Second)
+		a.mu.Lock()
+		state := a.state
+		a.mu.Unlock()
 		v := &iot.Value{
 			Device: "air-cleaner",
-			Value:  bool2int[a.state],
+			Value:  bool2int[state],
 		}
 		if err := a.cloud.Push(v); err != nil {
 			log.Printf("[autoair]push: failed to push the state of air-cleaner to cloud, error: %v", err)
@@ -171,16 +344,26 @@ func (a *autoAir) on() {
 	a.sg.Roll(0)
 	time.Sleep(1 * time.Second)
 	a.sg.Roll(-45)
+	a.mu.Lock()
 	a.state = true
+	a.mu.Unlock()
 }
 
 func (a *autoAir) off() {
 	a.sg.Roll(0)
 	time.Sleep(1 * time.Second)
 	a.sg.Roll(45)
+	a.mu.Lock()
 	a.state = false
+	a.mu.Unlock()
 }
 
 func (a *autoAir) stop() {
+	if a.inputs != nil {
+		a.inputs.Stop()
+	}
+	if a.screen != nil {
+		a.screen.Close()
+	}
 	a.sg.Roll(45)
 }
@@ -62,6 +62,14 @@ const (
 	relayPin           = 7
 	intervalTime       = 1 * time.Minute
 	triggerTemperature = 27.3
+
+	// keep the fan running or stopped for a while at least,
+	// it avoids switching the fan every minute around the trigger temperature.
+	minOnTime  = 5 * time.Minute
+	minOffTime = 3 * time.Minute
+
+	relayFan   = "fan"
+	relayState = "relays.json"
 )
 
 func main() {
@@ -77,26 +85,31 @@ func main() {
 		return
 	}
 
-	r := dev.NewRelay(relayPin)
-	if r == nil {
-		log.Printf("[autofan]failed to new a relay")
+	relays := dev.NewRelayManager(relayState)
+	opts := dev.RelayOptions{
+		MinOn:  minOnTime,
+		MinOff: minOffTime,
+	}
+	if err := relays.AddChannel(relayFan, dev.NewRelay(relayPin), opts); err != nil {
+		log.Printf("[autofan]failed to add the relay of fan, error: %v", err)
 		return
 	}
 
 	f := &autoFan{
-		temp:  temp,
-		relay: r,
+		temp:   temp,
+		relays: relays,
 	}
 	base.WaitQuit(func() {
-		f.off()
+		f.relays.AllOff()
+		f.relays.Close()
 		rpio.Close()
 	})
 	f.start()
 }
 
 type autoFan struct {
-	temp  *dev.DS18B20
-	relay *dev.Relay
+	temp   *dev.DS18B20
+	relays *dev.RelayManager
 }
 
 func (f *autoFan) start() {
@@ -112,13 +125,18 @@ func (f *autoFan) start() {
 		} else {
 			f.off()
 		}
+		f.relays.Save()
 	}
 }
 
 func (f *autoFan) on() {
-	f.relay.On()
+	if err := f.relays.On(relayFan); err != nil {
+		log.Printf("[autofan]failed to turn on the fan, error: %v", err)
+	}
 }
 
 func (f *autoFan) off() {
-	f.relay.Off()
+	if err := f.relays.Off(relayFan); err != nil {
+		log.Printf("[autofan]failed to turn off the fan, error: %v", err)
+	}
 }
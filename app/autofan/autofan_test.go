@@ -2,11 +2,46 @@ package main
 
 import (
 	"testing"
+	"time"
 
+	"github.com/shanghuiyang/rpi-devices/dev"
 	"github.com/stretchr/testify/assert"
 )
 
+type fakeFan struct {
+	on bool
+}
+
+func (f *fakeFan) On() {
+	f.on = true
+}
+
+func (f *fakeFan) Off() {
+	f.on = false
+}
+
 func TestStart(t *testing.T) {
 	fan := autoFan{}
 	assert.NotNil(t, fan)
 }
+
+func TestMinOnTime(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	relays := dev.NewRelayManager("")
+	relays.SetClock(func() time.Time {
+		return now
+	})
+	fan := &fakeFan{}
+	relays.AddChannel(relayFan, fan, dev.RelayOptions{MinOn: minOnTime, MinOff: minOffTime})
+	f := &autoFan{relays: relays}
+
+	f.on()
+	assert.True(t, fan.on)
+	// it keeps running even the temperature drops in a minute
+	now = now.Add(intervalTime)
+	f.off()
+	assert.True(t, fan.on)
+	now = now.Add(minOnTime)
+	f.off()
+	assert.False(t, fan.on)
+}
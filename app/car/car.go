@@ -28,9 +28,25 @@ const (
 	pinENB       = 19
 	pinBzr       = 10
 	pinSG        = 18
-	pinEncoder   = 6
+	pinEncoderL  = 6  // the encoder on the left wheel, the right one is in the config
 	pinCSwaitchL = 20 // the collision switch on left
 	pinCSwaitchR = 12 // the collision switch on right
+	pinIR        = 24 // the infrared receiver
+
+	// the wheels and the encoders, for the motion controller
+	wheelDiameter = 6.5 // cm
+	trackWidth    = 13  // cm, the distance between the left and right wheels
+	encoderTicks  = 20  // the slots of the encoder disk
+
+	addrMPU6050 = 0x68
+	addrINA219  = 0x40
+
+	// 2S li-ion pack, measured by an ina219 with a 0.1 ohm shunt
+	batteryCells    = 2
+	batteryLow      = 20
+	batteryCritical = 5
+	shuntOhms       = 0.1
+	maxCurrent      = 3.2
 
 	// use this rpio as 3.3v pin
 	// if all 3.3v pins were used
@@ -40,12 +56,40 @@ const (
 	selfDrivingState   = "((selfdriving-state))"
 	selfTrackingState  = "((selftracking-state))"
 	speechDrivingState = "((speechdriving-state))"
+	eStopState         = "((estop-state))"
 
 	selfDrivingEnabled   = "((selfdriving-enabled))"
 	selfTrackingEnabled  = "((selftracking-enabled))"
 	speechDrivingEnabled = "((speechdriving-enabled))"
+
+	batteryPattern      = "((battery))"
+	batteryColorPattern = "((battery-color))"
 )
 
+// the keys of the remote to the ops of the car,
+// the remote is the 21-key "Car MP3" one, see dev.NewCarMP3KeyMap().
+var irOps = map[string]dev.CarOp{
+	"2":    "forward",
+	"8":    "backward",
+	"4":    "left",
+	"6":    "right",
+	"5":    "stop",
+	"play": "beep",
+	"prev": "servoleft",
+	"next": "servoright",
+	"ch":   "servoahead",
+	"ch+":  "lighton",
+	"ch-":  "lightoff",
+	"0":    "estop",
+	"eq":   "reset",
+}
+
+// holding these keys keeps the car turning
+var irRepeatable = map[string]bool{
+	"4": true,
+	"6": true,
+}
+
 type carServer struct {
 	car         *dev.Car
 	pageContext []byte
@@ -68,12 +112,15 @@ func main() {
 		os.Exit(1)
 	}
 
-	ult := dev.NewUS100()
-	if ult == nil {
-		log.Printf("[carapp]failed to new a HCSR04, will build a car without ultrasonic distance meter")
+	// keep ult nil if there isn't a us100, a nil *US100 isn't a nil DistanceMeter
+	var ult dev.DistanceMeter
+	if us100 := dev.NewUS100(); us100 != nil {
+		ult = us100
+	} else {
+		log.Printf("[carapp]failed to new a US100, will build a car without ultrasonic distance meter")
 	}
 
-	encoder := dev.NewEncoder(pinEncoder)
+	encoder := dev.NewEncoder(pinEncoderL)
 	if encoder == nil {
 		log.Printf("[carapp]failed to new a encoder, will build a car without encoder")
 	}
@@ -87,9 +134,19 @@ func main() {
 	if cswitchL == nil {
 		log.Printf("[carapp]failed to new a collision switch, will build a car without collision switchs")
 	}
-	cswitchs := []*dev.CollisionSwitch{cswitchL, cswitchR}
+	cswitchs := []dev.CollisionDetector{cswitchL, cswitchR}
 
-	horn := dev.NewBuzzer(pinBzr)
+	var (
+		bzrCfg *base.BuzzerConfig
+		camCfg *base.CameraConfig
+		carCfg *base.CarConfig
+	)
+	if cfg, err := base.LoadConfig(); err == nil {
+		bzrCfg = cfg.Buzzer
+		camCfg = cfg.Camera
+		carCfg = cfg.Car
+	}
+	horn := dev.NewBuzzerFromConfig(bzrCfg, pinBzr)
 	if horn == nil {
 		log.Printf("[carapp]failed to new a buzzer, will build a car without horns")
 	}
@@ -108,9 +165,45 @@ func main() {
 	if servo == nil {
 		log.Printf("[carapp]failed to new a sg90, will build a car without servo")
 	}
-	cam := dev.NewCamera()
-	if cam == nil {
-		log.Printf("[carapp]failed to new a camera, will build a car without cameras")
+	cam, err := dev.NewCameraFromConfig(camCfg)
+	if err != nil {
+		log.Printf("[carapp]failed to new a camera, will build a car without cameras, error: %v", err)
+	}
+
+	var imu *dev.IMU
+	mpu, err := dev.NewMPU6050(addrMPU6050)
+	if err != nil {
+		log.Printf("[carapp]failed to new a mpu6050, will build a car without imu, error: %v", err)
+	} else {
+		imu = dev.NewIMU(mpu)
+		if err := imu.Start(); err != nil {
+			log.Printf("[carapp]failed to start imu, will build a car without imu, error: %v", err)
+			imu = nil
+		}
+	}
+
+	// the car turns by the lookup of the angles with only the left encoder
+	var motion *dev.MotionController
+	if carCfg != nil && carCfg.EncoderR > 0 {
+		encoderR := dev.NewEncoder(carCfg.EncoderR)
+		motion = dev.NewMotionController(eng, encoder, encoderR, wheelDiameter, trackWidth, encoderTicks)
+		if imu != nil {
+			motion.SetGyro(imu)
+		}
+	} else {
+		log.Printf("[carapp]no encoder on the right wheel, will build a car without the motion controller")
+	}
+
+	var battery *dev.Battery
+	ina, err := dev.NewINA219(addrINA219, shuntOhms, maxCurrent)
+	if err != nil {
+		log.Printf("[carapp]failed to new a ina219, will build a car without battery monitor, error: %v", err)
+	} else {
+		battery, err = dev.NewBattery(ina, dev.LiIon, batteryCells, batteryLow, batteryCritical)
+		if err != nil {
+			log.Fatalf("[carapp]failed to new a battery, error: %v", err)
+		}
+		battery.Start(5 * time.Second)
 	}
 
 	car := dev.NewCar(
@@ -120,17 +213,29 @@ func main() {
 		dev.WithEncoder(encoder),
 		dev.WithCSwitchs(cswitchs),
 		dev.WithHorn(horn),
+		dev.WithAlerts(map[string]string{
+			"beep":       bzrCfg.Alert("beep", dev.AlertDoorbell),
+			"lowbattery": bzrCfg.Alert("lowbattery", dev.AlertLowBattery),
+		}),
 		dev.WithLed(led),
 		dev.WithLight(light),
 		dev.WithCamera(cam),
+		dev.WithIMU(imu),
+		dev.WithMotion(motion),
+		dev.WithBattery(battery),
 	)
 	if car == nil {
 		log.Fatal("failed to new a car")
 		return
 	}
 
+	ir := dev.NewIRReceiver(pinIR)
+	ir.Start()
+
 	server := newCarServer(car)
+	go server.remote(ir, dev.NewCarMP3KeyMap())
 	base.WaitQuit(func() {
+		ir.Stop()
 		server.stop()
 		rpio.Close()
 	})
@@ -164,6 +269,24 @@ func (s *carServer) stop() error {
 	return s.car.Stop()
 }
 
+// remote drives the car with an infrared remote
+func (s *carServer) remote(ir *dev.IRReceiver, keys *dev.IRKeyMap) {
+	for code := range ir.Codes() {
+		key, repeat, ok := keys.Key(code)
+		if !ok {
+			log.Printf("[carapp]unknown ir code: %v", code)
+			continue
+		}
+		op, ok := irOps[key]
+		if !ok || (repeat && !irRepeatable[key]) {
+			continue
+		}
+		if err := s.car.Do(op); err != nil {
+			log.Printf("[carapp]failed to %v, error: %v", op, err)
+		}
+	}
+}
+
 func (s *carServer) loadHomePage(w http.ResponseWriter, r *http.Request) error {
 	if len(s.pageContext) == 0 {
 		var err error
@@ -187,16 +310,44 @@ func (s *carServer) loadHomePage(w http.ResponseWriter, r *http.Request) error {
 		}
 		sline := string(line)
 
-		disabled := false
-		selfDriving, selfTracking, speechDriving := s.car.GetState()
-		if selfDriving || selfTracking || speechDriving {
-			disabled = true
+		mode := s.car.Mode()
+		selfDriving := mode == dev.ModeSelfDriving
+		selfTracking := mode == dev.ModeTracking
+		speechDriving := mode == dev.ModeSpeech
+		eStop := mode == dev.ModeEStop
+		disabled := selfDriving || selfTracking || speechDriving || eStop
+		lowBattery := false
+		if state, _, ok := s.car.GetBattery(); ok && state != dev.BatteryNormal {
+			lowBattery = true
 		}
 
 		if strings.Index(sline, ipPattern) >= 0 {
 			sline = strings.Replace(sline, ipPattern, ip, 1)
 		}
 
+		if strings.Index(sline, batteryPattern) >= 0 {
+			text := "N/A"
+			if _, soc, ok := s.car.GetBattery(); ok {
+				text = fmt.Sprintf("%.0f%%", soc)
+			}
+			sline = strings.Replace(sline, batteryPattern, text, 1)
+		}
+
+		if strings.Index(sline, batteryColorPattern) >= 0 {
+			color := "lightgray"
+			if state, _, ok := s.car.GetBattery(); ok {
+				switch state {
+				case dev.BatteryNormal:
+					color = "green"
+				case dev.BatteryLow:
+					color = "orange"
+				case dev.BatteryCritical:
+					color = "red"
+				}
+			}
+			sline = strings.Replace(sline, batteryColorPattern, color, 1)
+		}
+
 		if strings.Index(sline, selfDrivingState) >= 0 {
 			state := "unchecked"
 			if selfDriving {
@@ -205,7 +356,7 @@ func (s *carServer) loadHomePage(w http.ResponseWriter, r *http.Request) error {
 			sline = strings.Replace(sline, selfDrivingState, state, 1)
 
 			able := "enabled"
-			if state == "unchecked" && disabled {
+			if state == "unchecked" && (disabled || lowBattery) {
 				able = "disabled"
 			}
 			sline = strings.Replace(sline, selfDrivingEnabled, able, 1)
@@ -219,7 +370,7 @@ func (s *carServer) loadHomePage(w http.ResponseWriter, r *http.Request) error {
 			sline = strings.Replace(sline, selfTrackingState, state, 1)
 
 			able := "enabled"
-			if state == "unchecked" && disabled {
+			if state == "unchecked" && (disabled || lowBattery) {
 				able = "disabled"
 			}
 			sline = strings.Replace(sline, selfTrackingEnabled, able, 1)
@@ -239,6 +390,14 @@ func (s *carServer) loadHomePage(w http.ResponseWriter, r *http.Request) error {
 			sline = strings.Replace(sline, speechDrivingEnabled, able, 1)
 		}
 
+		if strings.Index(sline, eStopState) >= 0 {
+			state := "unchecked"
+			if eStop {
+				state = "checked"
+			}
+			sline = strings.Replace(sline, eStopState, state, 1)
+		}
+
 		wbuf.Write([]byte(sline))
 	}
 	w.Write(wbuf.Bytes())
@@ -251,7 +410,9 @@ func (s *carServer) handler(w http.ResponseWriter, r *http.Request) {
 		s.loadHomePage(w, r)
 	case "POST":
 		op := r.FormValue("op")
-		s.car.Do(dev.CarOp(op))
+		if err := s.car.Do(dev.CarOp(op)); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+		}
 	}
 }
 
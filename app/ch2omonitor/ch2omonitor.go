@@ -11,7 +11,6 @@ and it also be pushed to iot cloud for drawing a line chart.
 package main
 
 import (
-	"fmt"
 	"log"
 	"math"
 	"time"
@@ -48,8 +47,42 @@ func main() {
 
 	sensor := dev.NewZE08CH2O()
 	led := dev.NewLed(pinLed)
-	bzr := dev.NewBuzzer(pinBzr)
-	dsp := dev.NewLedDisplay(dioPin, rclkPin, sclkPin)
+	var (
+		bzrCfg  *base.BuzzerConfig
+		oledCfg *base.OLEDConfig
+	)
+	dspCfg := &base.DisplayConfig{
+		DIO:  dioPin,
+		RCLK: rclkPin,
+		SCLK: sclkPin,
+	}
+	if cfg, err := base.LoadConfig(); err == nil {
+		bzrCfg = cfg.Buzzer
+		if cfg.Display != nil {
+			dspCfg = cfg.Display
+		}
+		oledCfg = cfg.OLED
+	}
+	bzr := dev.NewBuzzerFromConfig(bzrCfg, pinBzr)
+	var (
+		dsp  dev.TextDisplay
+		dash *dashboard
+	)
+	if dspCfg.Type == "oled" {
+		oled, err := dev.NewOLEDFromConfig(oledCfg)
+		if err != nil {
+			log.Fatalf("[ch2omonitor]failed to create oled, error: %v", err)
+			return
+		}
+		dash = newDashboard(oled)
+	} else {
+		var err error
+		dsp, err = dev.NewTextDisplayFromConfig(dspCfg)
+		if err != nil {
+			log.Fatalf("[ch2omonitor]failed to create display, error: %v", err)
+			return
+		}
+	}
 
 	wsnCfg := &base.WsnConfig{
 		Token: base.WsnToken,
@@ -57,7 +90,7 @@ func main() {
 	}
 	cloud := iot.NewCloud(wsnCfg)
 
-	m := newCH2OMonitor(sensor, led, bzr, dsp, cloud)
+	m := newCH2OMonitor(sensor, led, bzr, bzrCfg.Alert("ch2o", dev.AlertAlarm), dsp, dash, cloud)
 	// m.setMode(base.DevMode)
 	base.WaitQuit(func() {
 		m.stop()
@@ -68,9 +101,11 @@ func main() {
 
 type ch2oMonitor struct {
 	sensor    *dev.ZE08CH2O
-	led       *dev.Led
+	led       *dev.LedController
 	buzzer    *dev.Buzzer
-	dsp       *dev.LedDisplay
+	alertName string
+	dsp       dev.TextDisplay
+	dash      *dashboard // the ch2o on an oled instead of dsp
 	cloud     iot.Cloud
 	mode      base.Mode
 	chAlert   chan float64 // for alerting
@@ -78,12 +113,14 @@ type ch2oMonitor struct {
 	chCloud   chan float64 // for pushing to iot cloud
 }
 
-func newCH2OMonitor(sensor *dev.ZE08CH2O, led *dev.Led, buzzer *dev.Buzzer, dsp *dev.LedDisplay, cloud iot.Cloud) *ch2oMonitor {
+func newCH2OMonitor(sensor *dev.ZE08CH2O, led *dev.Led, buzzer *dev.Buzzer, alertName string, dsp dev.TextDisplay, dash *dashboard, cloud iot.Cloud) *ch2oMonitor {
 	return &ch2oMonitor{
 		sensor:    sensor,
-		led:       led,
+		led:       dev.NewLedController(led),
 		buzzer:    buzzer,
+		alertName: alertName,
 		dsp:       dsp,
+		dash:      dash,
 		cloud:     cloud,
 		mode:      base.PrdMode,
 		chAlert:   make(chan float64, 4),
@@ -162,14 +199,24 @@ func (m *ch2oMonitor) alert() {
 		}
 
 		if ch2o >= alertCH2O {
-			go m.buzzer.Beep(1, 200)
-			go m.led.Blink(1, 200)
+			m.led.Play("alert", dev.BlinkPattern(dev.White, 200*time.Millisecond, 200*time.Millisecond).Repeat(1), 0, 0)
+			if p, err := m.buzzer.Alert(m.alertName); err != nil {
+				log.Printf("[ch2omonitor]failed to play alert %v, error: %v", m.alertName, err)
+				go m.buzzer.Beep(1, 200)
+			} else {
+				p.Wait()
+			}
 		}
 		time.Sleep(1 * time.Second)
 	}
 }
 
 func (m *ch2oMonitor) display() {
+	if m.dash != nil {
+		m.displayDashboard()
+		return
+	}
+
 	var ch2o float64
 	m.dsp.Open()
 	opened := true
@@ -203,16 +250,41 @@ func (m *ch2oMonitor) display() {
 		}
 		text := "----"
 		if ch2o > 0 {
-			text = fmt.Sprintf("%.3f", ch2o)
+			text = dev.FormatFloat(ch2o, 4)
 		}
 		m.dsp.Display(text)
+		if b, ok := m.dsp.(dev.Blinker); ok {
+			if ch2o >= alertCH2O {
+				b.SetBlink(500 * time.Millisecond)
+			} else {
+				b.SetBlink(0)
+			}
+		}
 		time.Sleep(3 * time.Second)
 	}
 }
 
+func (m *ch2oMonitor) displayDashboard() {
+	m.dash.start()
+	for {
+		select {
+		case ch2o := <-m.chDisplay:
+			m.dash.set(ch2o)
+		case <-time.After(1 * time.Second):
+			// update the clock
+		}
+		m.dash.setTime(time.Now())
+	}
+}
+
 func (m *ch2oMonitor) stop() {
 	m.sensor.Close()
-	m.led.Off()
+	m.led.Close()
+	m.buzzer.Stop()
 	m.buzzer.Off()
+	if m.dash != nil {
+		m.dash.close()
+		return
+	}
 	m.dsp.Close()
 }
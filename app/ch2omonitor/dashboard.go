@@ -0,0 +1,73 @@
+package main
+
+import (
+	"image"
+	"log"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/shanghuiyang/rpi-devices/ui"
+)
+
+const (
+	oledWidth  = 128
+	oledHeight = 64
+)
+
+// dashboard shows the ch2o on a 128x64 oled with the level to the alert and the trend
+type dashboard struct {
+	oled   *dev.OLED
+	screen *ui.Screen
+	time   *ui.Label
+	ch2o   *ui.Readout
+	level  *ui.ProgressBar
+	status *ui.Label
+	chart  *ui.Sparkline
+	hist   *base.History
+}
+
+func newDashboard(oled *dev.OLED) *dashboard {
+	face := ui.DefaultFace
+	d := &dashboard{
+		oled:   oled,
+		screen: ui.NewScreen(oled),
+		time:   ui.NewLabel(image.Rect(0, 0, oledWidth, 13), face, ui.AlignCenter),
+		ch2o:   ui.NewReadout(image.Rect(0, 14, oledWidth, 27), face, "CH2O", "mg", 4),
+		level:  ui.NewProgressBar(image.Rect(0, 29, 90, 37)),
+		status: ui.NewLabel(image.Rect(92, 27, oledWidth, 40), face, ui.AlignRight),
+		chart:  ui.NewSparkline(image.Rect(0, 42, oledWidth, oledHeight)),
+		hist:   base.NewHistory(oledWidth),
+	}
+	d.screen.AddPage("ch2o", d.time, d.ch2o, d.level, d.status, d.chart)
+	return d
+}
+
+func (d *dashboard) start() {
+	d.screen.Start(500 * time.Millisecond)
+}
+
+func (d *dashboard) setTime(t time.Time) {
+	d.time.SetText(t.Format("Mon Jan 2 15:04"))
+}
+
+func (d *dashboard) set(ch2o float64) {
+	d.hist.Add(ch2o)
+	d.ch2o.SetValue(ch2o)
+	d.level.SetValue(ch2o / alertCH2O)
+	alert := ch2o >= alertCH2O
+	d.ch2o.SetInverse(alert)
+	if alert {
+		d.status.SetText("HIGH")
+	} else {
+		d.status.SetText("OK")
+	}
+	if err := d.chart.SetHistory(d.hist); err != nil {
+		log.Printf("[ch2omonitor]failed to draw the chart, error: %v", err)
+	}
+}
+
+func (d *dashboard) close() {
+	d.screen.Close()
+	d.oled.Close()
+}
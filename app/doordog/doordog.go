@@ -45,7 +45,11 @@ func main() {
 	p33v.Output()
 	p33v.High()
 
-	bzr := dev.NewBuzzer(pinBzr)
+	var bzrCfg *base.BuzzerConfig
+	if cfg, err := base.LoadConfig(); err == nil {
+		bzrCfg = cfg.Buzzer
+	}
+	bzr := dev.NewBuzzerFromConfig(bzrCfg, pinBzr)
 	led := dev.NewLed(pinLed)
 	btn := dev.NewButton(pinBtn)
 	dist := dev.NewHCSR04(pinTrig, pinEcho)
@@ -54,7 +58,7 @@ func main() {
 		return
 	}
 
-	dog := newDoordog(dist, bzr, led, btn)
+	dog := newDoordog(dist, bzr, bzrCfg.Alert("intruder", dev.AlertAlarm), led, btn)
 	base.WaitQuit(func() {
 		dog.stop()
 		rpio.Close()
@@ -65,20 +69,23 @@ func main() {
 type doordog struct {
 	dist     *dev.HCSR04
 	buzzer   *dev.Buzzer
-	led      *dev.Led
+	led      *dev.LedController
 	button   *dev.Button
 	alerting bool
 	chAlert  chan bool
+	// the alert played by the buzzer when somebody comes in
+	alertName string
 }
 
-func newDoordog(dist *dev.HCSR04, buzzer *dev.Buzzer, led *dev.Led, btn *dev.Button) *doordog {
+func newDoordog(dist *dev.HCSR04, buzzer *dev.Buzzer, alertName string, led *dev.Led, btn *dev.Button) *doordog {
 	return &doordog{
-		dist:     dist,
-		buzzer:   buzzer,
-		led:      led,
-		button:   btn,
-		alerting: false,
-		chAlert:  make(chan bool, 4),
+		dist:      dist,
+		buzzer:    buzzer,
+		led:       dev.NewLedController(led),
+		button:    btn,
+		alerting:  false,
+		chAlert:   make(chan bool, 4),
+		alertName: alertName,
 	}
 }
 
@@ -114,8 +121,8 @@ func (d *doordog) alert() {
 	go func() {
 		for {
 			if d.alerting {
-				go d.buzzer.Beep(1, 200)
-				go d.led.Blink(1, 200)
+				d.led.Play("alert", dev.BlinkPattern(dev.White, 200*time.Millisecond, 200*time.Millisecond).Repeat(1), 0, 0)
+				d.playAlert()
 			}
 			time.Sleep(1 * time.Second)
 		}
@@ -135,6 +142,17 @@ func (d *doordog) alert() {
 	}
 }
 
+// playAlert plays the alert and waits for it done
+func (d *doordog) playAlert() {
+	p, err := d.buzzer.Alert(d.alertName)
+	if err != nil {
+		log.Printf("[doordog]failed to play alert %v, error: %v", d.alertName, err)
+		d.buzzer.Beep(1, 200)
+		return
+	}
+	p.Wait()
+}
+
 func (d *doordog) stopAlert() {
 	for {
 		pressed := d.button.Pressed()
@@ -142,6 +160,7 @@ func (d *doordog) stopAlert() {
 			log.Printf("[doordog]the button was pressed")
 			if d.alerting {
 				d.alerting = false
+				d.buzzer.Stop()
 			}
 			// make a dalay detecting
 			time.Sleep(1 * time.Second)
@@ -152,6 +171,7 @@ func (d *doordog) stopAlert() {
 }
 
 func (d *doordog) stop() {
+	d.buzzer.Stop()
 	d.buzzer.Off()
-	d.led.Off()
+	d.led.Close()
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"image"
+	"log"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/shanghuiyang/rpi-devices/ui"
+)
+
+const (
+	oledWidth  = 128
+	oledHeight = 64
+	clockFont  = "casio-fx-9860gii.ttf"
+)
+
+// dashboard shows all the readings on a 128x64 oled,
+// it rotates between the readings page and a big clock.
+type dashboard struct {
+	oled      *dev.OLED
+	screen    *ui.Screen
+	time      *ui.Label
+	clock     *ui.Label
+	temp      *ui.Readout
+	pm25      *ui.Readout
+	tempChart *ui.Sparkline
+	pm25Chart *ui.Sparkline
+	tempHist  *base.History
+	pm25Hist  *base.History
+}
+
+func newDashboard(oled *dev.OLED) *dashboard {
+	face := ui.DefaultFace
+	bigFace, err := ui.LoadFace(clockFont, 30)
+	if err != nil {
+		log.Printf("[homeasst]failed to load the font for the clock, error: %v", err)
+		bigFace = face
+	}
+
+	d := &dashboard{
+		oled:      oled,
+		screen:    ui.NewScreen(oled),
+		time:      ui.NewLabel(image.Rect(0, 0, oledWidth, 13), face, ui.AlignCenter),
+		clock:     ui.NewLabel(image.Rect(0, 12, oledWidth, 52), bigFace, ui.AlignCenter),
+		temp:      ui.NewReadout(image.Rect(10, 16, oledWidth, 29), face, "Temp", "C", 1),
+		pm25:      ui.NewReadout(image.Rect(10, 32, oledWidth, 45), face, "PM2.5", "ug/m3", 0),
+		tempChart: ui.NewSparkline(image.Rect(0, 48, 62, oledHeight)),
+		pm25Chart: ui.NewSparkline(image.Rect(66, 48, oledWidth, oledHeight)),
+		tempHist:  base.NewHistory(60),
+		pm25Hist:  base.NewHistory(60),
+	}
+	d.screen.AddPage("readings",
+		d.time,
+		ui.NewIcon(image.Pt(0, 18), ui.IconTemp),
+		d.temp,
+		ui.NewIcon(image.Pt(0, 34), ui.IconAir),
+		d.pm25,
+		d.tempChart,
+		d.pm25Chart,
+	)
+	d.screen.AddPage("clock", d.clock)
+	d.screen.SetRotation(10 * time.Second)
+	return d
+}
+
+func (d *dashboard) start() {
+	d.screen.Start(500 * time.Millisecond)
+}
+
+func (d *dashboard) setTime(t time.Time) {
+	d.time.SetText(t.Format("Mon Jan 2 15:04"))
+	d.clock.SetText(t.Format("15:04"))
+}
+
+func (d *dashboard) set(dt *data) {
+	var (
+		hist    *base.History
+		readout *ui.Readout
+		chart   *ui.Sparkline
+	)
+	switch dt.name {
+	case "temp":
+		hist, readout, chart = d.tempHist, d.temp, d.tempChart
+	case "pm2.5":
+		hist, readout, chart = d.pm25Hist, d.pm25, d.pm25Chart
+	default:
+		return
+	}
+
+	hist.Add(dt.value)
+	values, err := hist.Values()
+	if err != nil || len(values) == 0 {
+		log.Printf("[homeasst]invalid %v: %v", dt.name, dt.value)
+		return
+	}
+	readout.SetValue(values[len(values)-1])
+	chart.SetValues(values)
+}
+
+func (d *dashboard) close() {
+	d.screen.Close()
+	d.oled.Close()
+}
@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/shanghuiyang/rpi-devices/base"
@@ -20,6 +22,12 @@ const (
 	sclkPin = 11
 )
 
+// the labels of the data on the display
+var labels = map[string]string{
+	"temp":  "t",
+	"pm2.5": "P",
+}
+
 type data struct {
 	name  string
 	text  string
@@ -37,7 +45,8 @@ type pm25Response struct {
 }
 
 type homeAsst struct {
-	dsp       *dev.LedDisplay
+	dsp       dev.TextDisplay
+	dash      *dashboard // all the readings on an oled instead of dsp
 	cloud     iot.Cloud
 	chDisplay chan *data // for disploying on oled
 	chCloud   chan *data // for pushing to iot cloud
@@ -51,7 +60,37 @@ func main() {
 	}
 	defer rpio.Close()
 
-	dsp := dev.NewLedDisplay(dioPin, rclkPin, sclkPin)
+	dspCfg := &base.DisplayConfig{
+		DIO:  dioPin,
+		RCLK: rclkPin,
+		SCLK: sclkPin,
+	}
+	var oledCfg *base.OLEDConfig
+	if cfg, err := base.LoadConfig(); err == nil {
+		if cfg.Display != nil {
+			dspCfg = cfg.Display
+		}
+		oledCfg = cfg.OLED
+	}
+	var (
+		dsp  dev.TextDisplay
+		dash *dashboard
+	)
+	if dspCfg.Type == "oled" {
+		oled, err := dev.NewOLEDFromConfig(oledCfg)
+		if err != nil {
+			log.Fatalf("[homeasst]failed to create oled, error: %v", err)
+			return
+		}
+		dash = newDashboard(oled)
+	} else {
+		var err error
+		dsp, err = dev.NewTextDisplayFromConfig(dspCfg)
+		if err != nil {
+			log.Fatalf("[homeasst]failed to create display, error: %v", err)
+			return
+		}
+	}
 
 	onenetCfg := &base.OneNetConfig{
 		Token: base.OneNetToken,
@@ -59,7 +98,7 @@ func main() {
 	}
 	cloud := iot.NewCloud(onenetCfg)
 
-	asst := newHomeAsst(dsp, cloud)
+	asst := newHomeAsst(dsp, dash, cloud)
 	base.WaitQuit(func() {
 		asst.stop()
 		rpio.Close()
@@ -67,9 +106,10 @@ func main() {
 	asst.start()
 }
 
-func newHomeAsst(dsp *dev.LedDisplay, cloud iot.Cloud) *homeAsst {
+func newHomeAsst(dsp dev.TextDisplay, dash *dashboard, cloud iot.Cloud) *homeAsst {
 	return &homeAsst{
 		dsp:       dsp,
+		dash:      dash,
 		cloud:     cloud,
 		chDisplay: make(chan *data, 4),
 		chCloud:   make(chan *data, 4),
@@ -127,6 +167,11 @@ func (h *homeAsst) getData() {
 }
 
 func (h *homeAsst) display() {
+	if h.dash != nil {
+		h.displayDashboard()
+		return
+	}
+
 	h.dsp.Open()
 	opened := true
 	cache := map[string]*data{}
@@ -159,10 +204,32 @@ func (h *homeAsst) display() {
 			opened = true
 		}
 
-		for _, d := range cache {
-			h.dsp.Display(d.text)
-			time.Sleep(5 * time.Second)
+		// scroll all the data in one line, e.g. "t23.5  P35"
+		var names, texts []string
+		for name := range cache {
+			names = append(names, name)
 		}
+		sort.Strings(names)
+		for _, name := range names {
+			texts = append(texts, labels[name]+cache[name].text)
+		}
+		if len(texts) > 0 {
+			h.dsp.Display(strings.Join(texts, "  "))
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (h *homeAsst) displayDashboard() {
+	h.dash.start()
+	for {
+		select {
+		case d := <-h.chDisplay:
+			h.dash.set(d)
+		case <-time.After(1 * time.Second):
+			// update the clock
+		}
+		h.dash.setTime(time.Now())
 	}
 }
 
@@ -200,6 +267,10 @@ func (h *homeAsst) push() {
 // }
 
 func (h *homeAsst) stop() {
+	if h.dash != nil {
+		h.dash.close()
+		return
+	}
 	h.dsp.Close()
 }
 
@@ -10,6 +10,7 @@ import (
 	"github.com/shanghuiyang/go-speech/oauth"
 	"github.com/shanghuiyang/go-speech/speech"
 	"github.com/shanghuiyang/image-recognizer/recognizer"
+	"github.com/shanghuiyang/rpi-devices/base"
 	"github.com/shanghuiyang/rpi-devices/dev"
 )
 
@@ -30,7 +31,7 @@ var (
 	asr  *speech.ASR
 	tts  *speech.TTS
 	imgr *recognizer.Recognizer
-	cam  *dev.Camera
+	cam  dev.Camera
 )
 
 func main() {
@@ -40,19 +41,29 @@ func main() {
 	asr = speech.NewASR(speechAuth)
 	tts = speech.NewTTS(speechAuth)
 	imgr = recognizer.New(imageAuth)
-	cam = dev.NewCamera()
+
+	var camCfg *base.CameraConfig
+	if cfg, err := base.LoadConfig(); err == nil {
+		camCfg = cfg.Camera
+	}
+	c, err := dev.NewCameraFromConfig(camCfg)
+	if err != nil {
+		log.Fatalf("[imgr]failed to new a camera, error: %v", err)
+	}
+	cam = c
 
 	for {
 		log.Printf("[imgr]take photo")
-		imgf, err := cam.TakePhoto()
+		photo, err := cam.TakePhoto()
 		if err != nil {
 			log.Printf("[imgr]failed to take phote, error: %v", err)
 			os.Exit(1)
 		}
+		log.Printf("[imgr]photo: %vx%v from %v", photo.Width, photo.Height, photo.Source)
 		play(wavLetMeThink)
 
 		log.Printf("[imgr]recognize image")
-		objname, err := recognize(imgf)
+		objname, err := recognize(photo)
 		if err != nil {
 			log.Printf("[imgr]failed to recognize image, error: %v", err)
 			play(wavIDontKnow)
@@ -88,7 +99,13 @@ func play(wav string) error {
 	return nil
 }
 
-func recognize(image string) (string, error) {
+func recognize(photo *dev.Photo) (string, error) {
+	image, err := photo.SaveTemp()
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(image)
+
 	name, err := imgr.Recognize(image)
 	if err != nil {
 		return "", err
@@ -2,7 +2,6 @@ package main
 
 import (
 	"log"
-	"time"
 
 	"github.com/shanghuiyang/rpi-devices/base"
 	"github.com/shanghuiyang/rpi-devices/dev"
@@ -16,10 +15,13 @@ const (
 	d3 = 6
 
 	ledPin = 26
+	irPin  = 24
 
 	// use this rpio as 3.3v pin
 	// if all 3.3v pins were used
 	pin33v = 5
+
+	actionToggle = "light.toggle"
 )
 
 var light *rlight
@@ -45,35 +47,63 @@ func main() {
 		led:   led,
 		state: false,
 	}
-	r := dev.NewRX480E4(d0, d1, d2, d3)
+	remote, err := newRemote()
+	if err != nil {
+		log.Fatalf("[rlight]failed to new a remote, error: %v", err)
+		return
+	}
+	remote.Handle(actionToggle, func(e dev.RemoteEvent) {
+		go light.turn()
+	})
+	remote.Start()
+
+	// the light can be toggled by the "play" key of an infrared remote as well
+	ir := dev.NewIRReceiver(irPin)
+	ir.Start()
+	go light.irRemote(ir, dev.NewCarMP3KeyMap())
 
 	base.WaitQuit(func() {
+		remote.Stop()
+		ir.Stop()
 		led.Off()
 		rpio.Close()
 	})
+	select {}
+}
 
-	for {
-		if r.PressA() == true {
-			log.Printf("[rlight]pressed A")
-			go light.turn()
-			continue
-		}
-		if r.PressB() == true {
-			log.Printf("[rlight]pressed B")
-			go light.turn()
-			continue
-		}
-		if r.PressC() == true {
-			log.Printf("[rlight]pressed C")
-			go light.turn()
-			continue
-		}
-		if r.PressD() == true {
-			log.Printf("[rlight]pressed D")
-			go light.turn()
+// newRemote creates the remote with the "remote" in config.json, e.g.
+//
+//	"remote": {
+//		"name": "rlight",
+//		"mode": "momentary",
+//		"d0": 16, "d1": 20, "d2": 21, "d3": 6,
+//		"mappings": [
+//			{"remote": "rlight", "button": "A", "gesture": "click", "action": "light.toggle"}
+//		]
+//	}
+//
+// it toggles the light by clicking any button if there is no config.
+func newRemote() (*dev.Remote, error) {
+	cfg, err := base.LoadConfig()
+	if err == nil && cfg.Remote != nil {
+		return dev.NewRemoteFromConfig(cfg.Remote)
+	}
+	log.Printf("[rlight]no remote in config, use the default mappings")
+	r := dev.NewRemote("rlight", dev.NewRX480E4(d0, d1, d2, d3), dev.RXMomentary)
+	for _, b := range []dev.RXButton{dev.RXButtonA, dev.RXButtonB, dev.RXButtonC, dev.RXButtonD} {
+		r.Map(b, dev.Click, actionToggle)
+	}
+	return r, nil
+}
+
+func (r *rlight) irRemote(ir *dev.IRReceiver, keys *dev.IRKeyMap) {
+	for code := range ir.Codes() {
+		key, repeat, ok := keys.Key(code)
+		if !ok || repeat || key != "play" {
 			continue
 		}
-		time.Sleep(300 * time.Millisecond)
+		log.Printf("[rlight]pressed play on ir remote")
+		go r.turn()
 	}
 }
 
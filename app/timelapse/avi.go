@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// the layout of the headers, the offsets are from the beginning of the file
+const (
+	aviOffsetRIFFSize    = 4
+	aviOffsetTotalFrames = 48  // avih.dwTotalFrames
+	aviOffsetAvihBuffer  = 60  // avih.dwSuggestedBufferSize
+	aviOffsetLength      = 140 // strh.dwLength
+	aviOffsetStrhBuffer  = 144 // strh.dwSuggestedBufferSize
+	aviOffsetMoviSize    = 216
+	aviOffsetMovi        = 220 // the fourcc 'movi', the offsets in the index are from it
+	aviHeaderSize        = 224
+
+	aviHasIndex = 0x10
+	aviKeyFrame = 0x10
+	// the sizes are uint32 in riff
+	aviMaxSize = 1<<32 - 1
+)
+
+type aviIndex struct {
+	offset uint32
+	size   uint32
+}
+
+// aviWriter writes the jpeg frames to an avi file in mjpeg, which can be played by most players.
+// The headers are written first with the unknown sizes, and they're fixed up on Close.
+type aviWriter struct {
+	w         io.WriteSeeker
+	pos       int64
+	maxBuffer uint32
+	index     []aviIndex
+}
+
+func newAVIWriter(w io.WriteSeeker, width, height, fps int) (*aviWriter, error) {
+	a := &aviWriter{w: w}
+	if err := a.write(aviHeaders(width, height, fps)); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// aviHeaders returns the headers of an avi with a mjpeg stream
+func aviHeaders(width, height, fps int) []byte {
+	var b bytes.Buffer
+	le := func(vs ...interface{}) {
+		for _, v := range vs {
+			binary.Write(&b, binary.LittleEndian, v)
+		}
+	}
+
+	b.WriteString("RIFF")
+	le(uint32(0))
+	b.WriteString("AVI ")
+
+	b.WriteString("LIST")
+	le(uint32(192))
+	b.WriteString("hdrl")
+
+	b.WriteString("avih")
+	le(uint32(56),
+		uint32(1000000/fps), // dwMicroSecPerFrame
+		uint32(0),           // dwMaxBytesPerSec
+		uint32(0),           // dwPaddingGranularity
+		uint32(aviHasIndex), // dwFlags
+		uint32(0),           // dwTotalFrames
+		uint32(0),           // dwInitialFrames
+		uint32(1),           // dwStreams
+		uint32(0),           // dwSuggestedBufferSize
+		uint32(width),
+		uint32(height),
+		[4]uint32{}, // dwReserved
+	)
+
+	b.WriteString("LIST")
+	le(uint32(116))
+	b.WriteString("strl")
+
+	b.WriteString("strh")
+	le(uint32(56))
+	b.WriteString("vids")
+	b.WriteString("MJPG")
+	le(uint32(0), // dwFlags
+		uint16(0),          // wPriority
+		uint16(0),          // wLanguage
+		uint32(0),          // dwInitialFrames
+		uint32(1),          // dwScale
+		uint32(fps),        // dwRate
+		uint32(0),          // dwStart
+		uint32(0),          // dwLength
+		uint32(0),          // dwSuggestedBufferSize
+		uint32(0xFFFFFFFF), // dwQuality
+		uint32(0),          // dwSampleSize
+		[4]uint16{0, 0, uint16(width), uint16(height)}, // rcFrame
+	)
+
+	b.WriteString("strf")
+	le(uint32(40),
+		uint32(40), // biSize
+		int32(width),
+		int32(height),
+		uint16(1),  // biPlanes
+		uint16(24), // biBitCount
+	)
+	b.WriteString("MJPG")
+	le(uint32(width*height*3), // biSizeImage
+		[4]uint32{}, // biXPelsPerMeter, biYPelsPerMeter, biClrUsed, biClrImportant
+	)
+
+	b.WriteString("LIST")
+	le(uint32(0))
+	b.WriteString("movi")
+	return b.Bytes()
+}
+
+// AddFrame adds a jpeg as a frame
+func (a *aviWriter) AddFrame(jpeg []byte) error {
+	size := uint32(len(jpeg))
+	padded := int64(size + size%2)
+	if a.pos+8+padded+16*int64(len(a.index)+1) > aviMaxSize {
+		return errors.New("the avi is too large")
+	}
+
+	a.index = append(a.index, aviIndex{
+		offset: uint32(a.pos - aviOffsetMovi),
+		size:   size,
+	})
+	if size > a.maxBuffer {
+		a.maxBuffer = size
+	}
+
+	chunk := make([]byte, 8, 8+padded)
+	copy(chunk, "00dc")
+	binary.LittleEndian.PutUint32(chunk[4:], size)
+	chunk = append(chunk, jpeg...)
+	if size%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return a.write(chunk)
+}
+
+// Frames returns the number of the frames added
+func (a *aviWriter) Frames() int {
+	return len(a.index)
+}
+
+// Close writes the index and fixes up the sizes in the headers, it doesn't close the writer
+func (a *aviWriter) Close() error {
+	moviSize := uint32(a.pos - aviOffsetMovi)
+
+	idx := make([]byte, 8, 8+16*len(a.index))
+	copy(idx, "idx1")
+	binary.LittleEndian.PutUint32(idx[4:], uint32(16*len(a.index)))
+	for _, i := range a.index {
+		entry := make([]byte, 16)
+		copy(entry, "00dc")
+		binary.LittleEndian.PutUint32(entry[4:], aviKeyFrame)
+		binary.LittleEndian.PutUint32(entry[8:], i.offset)
+		binary.LittleEndian.PutUint32(entry[12:], i.size)
+		idx = append(idx, entry...)
+	}
+	if err := a.write(idx); err != nil {
+		return err
+	}
+
+	frames := uint32(len(a.index))
+	for _, field := range []struct {
+		offset int64
+		value  uint32
+	}{
+		{aviOffsetRIFFSize, uint32(a.pos - 8)},
+		{aviOffsetTotalFrames, frames},
+		{aviOffsetAvihBuffer, a.maxBuffer},
+		{aviOffsetLength, frames},
+		{aviOffsetStrhBuffer, a.maxBuffer},
+		{aviOffsetMoviSize, moviSize},
+	} {
+		if _, err := a.w.Seek(field.offset, io.SeekStart); err != nil {
+			return err
+		}
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], field.value)
+		if _, err := a.w.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	_, err := a.w.Seek(a.pos, io.SeekStart)
+	return err
+}
+
+func (a *aviWriter) write(data []byte) error {
+	n, err := a.w.Write(data)
+	a.pos += int64(n)
+	return err
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/shanghuiyang/rpi-devices/dev"
+)
+
+const (
+	defaultDir      = "/home/pi/timelapse"
+	defaultInterval = 60
+	defaultFPS      = 24
+	defaultPort     = 8080
+
+	framePrefix = "frame-"
+	frameExt    = ".jpg"
+	// frame-000123-20201018-153000.jpg
+	frameTimeLayout = "20060102-150405"
+	videoPrefix     = "timelapse-"
+	videoExt        = ".avi"
+)
+
+type status struct {
+	Frames     int       `json:"frames"`
+	Captured   int       `json:"captured"`
+	Skipped    int       `json:"skipped"`
+	Latest     string    `json:"latest"`
+	LatestTime time.Time `json:"latest_time"`
+	Active     bool      `json:"active"`
+	Error      string    `json:"error"`
+	Assembling bool      `json:"assembling"`
+	Progress   float64   `json:"progress"` // in [0, 1]
+	Video      string    `json:"video"`
+}
+
+type timelapse struct {
+	cam dev.Camera
+	cfg *base.TimelapseConfig
+	now func() time.Time
+
+	mu     sync.Mutex
+	seq    int
+	status status
+}
+
+func main() {
+	cfg := &base.Config{}
+	if c, err := base.LoadConfig(); err == nil {
+		cfg = c
+	}
+	cam, err := dev.NewCameraFromConfig(cfg.Camera)
+	if err != nil {
+		log.Fatalf("[timelapse]failed to create camera, error: %v", err)
+		return
+	}
+	t, err := newTimelapse(cam, cfg.Timelapse, time.Now)
+	if err != nil {
+		log.Fatalf("[timelapse]failed to create timelapse, error: %v", err)
+		return
+	}
+
+	http.HandleFunc("/", t.statusHandler)
+	http.HandleFunc("/latest.jpg", t.latestHandler)
+	http.HandleFunc("/assemble", t.assembleHandler)
+	http.HandleFunc("/video", t.videoHandler)
+	go func() {
+		addr := fmt.Sprintf(":%v", t.cfg.Port)
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			log.Fatalf("[timelapse]failed to listen and serve, error: %v", err)
+		}
+	}()
+
+	t.start()
+}
+
+func newTimelapse(cam dev.Camera, cfg *base.TimelapseConfig, now func() time.Time) (*timelapse, error) {
+	c := &base.TimelapseConfig{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.Dir == "" {
+		c.Dir = defaultDir
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.FPS <= 0 {
+		c.FPS = defaultFPS
+	}
+	if c.Port == 0 {
+		c.Port = defaultPort
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	t := &timelapse{
+		cam: cam,
+		cfg: c,
+		now: now,
+	}
+	// go on with the sequence after restarting
+	frames, err := t.frames()
+	if err != nil {
+		return nil, err
+	}
+	if n := len(frames); n > 0 {
+		t.seq = frameSeq(frames[n-1])
+		t.status.Latest = frames[n-1]
+	}
+	t.status.Frames = len(frames)
+	return t, nil
+}
+
+func (t *timelapse) start() {
+	interval := time.Duration(t.cfg.Interval) * time.Second
+	log.Printf("[timelapse]capture every %v in %v", interval, t.cfg.Dir)
+	for {
+		if err := t.capture(); err != nil {
+			log.Printf("[timelapse]failed to capture, error: %v", err)
+		}
+		if err := t.prune(); err != nil {
+			log.Printf("[timelapse]failed to prune, error: %v", err)
+		}
+		// align the captures to the interval
+		now := t.now()
+		time.Sleep(now.Truncate(interval).Add(interval).Sub(now))
+	}
+}
+
+// capture takes a photo and saves it as the next frame in the active hours, the dark photos are skipped
+func (t *timelapse) capture() error {
+	now := t.now()
+	active := isActive(now.Hour(), t.cfg.From, t.cfg.To)
+	t.mu.Lock()
+	t.status.Active = active
+	t.mu.Unlock()
+	if !active {
+		return nil
+	}
+
+	photo, err := t.cam.TakePhoto()
+	if err != nil {
+		t.setError(err)
+		return err
+	}
+	if t.cfg.MinBrightness > 0 {
+		b, err := brightness(photo.Data)
+		if err != nil {
+			t.setError(err)
+			return err
+		}
+		if b < t.cfg.MinBrightness {
+			log.Printf("[timelapse]skip the dark frame, brightness: %.1f", b)
+			t.mu.Lock()
+			t.status.Skipped++
+			t.mu.Unlock()
+			return nil
+		}
+	}
+
+	t.mu.Lock()
+	seq := t.seq + 1
+	t.mu.Unlock()
+	name := frameName(seq, photo.Time)
+	if err := photo.Save(filepath.Join(t.cfg.Dir, name)); err != nil {
+		t.setError(err)
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq = seq
+	t.status.Frames++
+	t.status.Captured++
+	t.status.Latest = name
+	t.status.LatestTime = photo.Time
+	t.status.Error = ""
+	return nil
+}
+
+// prune removes the frames older than the max age, and the oldest frames or videos taking more than the max size
+func (t *timelapse) prune() error {
+	frames, err := t.frames()
+	if err != nil {
+		return err
+	}
+	videos, err := t.videos()
+	if err != nil {
+		return err
+	}
+	files := append(append([]string{}, frames...), videos...)
+	// frames go before the videos made of them at the same time
+	sort.SliceStable(files, func(i, j int) bool {
+		return fileTime(files[i]).Before(fileTime(files[j]))
+	})
+
+	var (
+		sizes   []int64
+		total   int64
+		removed int
+	)
+	for _, f := range files {
+		fi, err := os.Stat(filepath.Join(t.cfg.Dir, f))
+		if err != nil {
+			return err
+		}
+		sizes = append(sizes, fi.Size())
+		total += fi.Size()
+	}
+
+	maxAge := time.Duration(t.cfg.MaxAge) * time.Hour
+	maxSize := t.cfg.MaxSize * 1024 * 1024
+	now := t.now()
+	for i, f := range files {
+		isFrame := frameSeq(f) > 0
+		tooOld := isFrame && maxAge > 0 && now.Sub(frameTime(f)) > maxAge
+		tooLarge := maxSize > 0 && total > maxSize
+		if !tooOld && !tooLarge {
+			// the files after it are newer
+			break
+		}
+		if err := os.Remove(filepath.Join(t.cfg.Dir, f)); err != nil {
+			return err
+		}
+		total -= sizes[i]
+		if isFrame {
+			removed++
+		} else {
+			log.Printf("[timelapse]removed the video %v", f)
+		}
+	}
+	if removed > 0 {
+		log.Printf("[timelapse]removed %v frames", removed)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Frames = len(frames) - removed
+	return nil
+}
+
+// frames returns the names of the frames in the order of the sequence
+func (t *timelapse) frames() ([]string, error) {
+	files, err := ioutil.ReadDir(t.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var frames []string
+	for _, f := range files {
+		if frameSeq(f.Name()) > 0 {
+			frames = append(frames, f.Name())
+		}
+	}
+	sort.Slice(frames, func(i, j int) bool {
+		return frameSeq(frames[i]) < frameSeq(frames[j])
+	})
+	return frames, nil
+}
+
+// videos returns the names of the videos from the oldest
+func (t *timelapse) videos() ([]string, error) {
+	files, err := ioutil.ReadDir(t.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var videos []string
+	for _, f := range files {
+		if !videoTime(f.Name()).IsZero() {
+			videos = append(videos, f.Name())
+		}
+	}
+	sort.Strings(videos)
+	return videos, nil
+}
+
+// assemble makes a video of the frames in [from, to), the zero time means no limit
+func (t *timelapse) assemble(from, to time.Time) (string, error) {
+	t.mu.Lock()
+	if t.status.Assembling {
+		t.mu.Unlock()
+		return "", fmt.Errorf("a video is being assembled")
+	}
+	t.status.Assembling = true
+	t.status.Progress = 0
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		t.status.Assembling = false
+		t.mu.Unlock()
+	}()
+
+	all, err := t.frames()
+	if err != nil {
+		return "", err
+	}
+	var frames []string
+	for _, f := range all {
+		ft := frameTime(f)
+		if (from.IsZero() || !ft.Before(from)) && (to.IsZero() || ft.Before(to)) {
+			frames = append(frames, f)
+		}
+	}
+	if len(frames) == 0 {
+		return "", fmt.Errorf("no frames")
+	}
+
+	first, err := ioutil.ReadFile(filepath.Join(t.cfg.Dir, frames[0]))
+	if err != nil {
+		return "", err
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(first))
+	if err != nil {
+		return "", err
+	}
+
+	name := videoPrefix + t.now().Format(frameTimeLayout) + videoExt
+	file := filepath.Join(t.cfg.Dir, name)
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	avi, err := newAVIWriter(f, cfg.Width, cfg.Height, t.cfg.FPS)
+	if err != nil {
+		return "", err
+	}
+	for i, frame := range frames {
+		data, err := ioutil.ReadFile(filepath.Join(t.cfg.Dir, frame))
+		if err != nil {
+			// it might be pruned
+			log.Printf("[timelapse]skip the frame %v, error: %v", frame, err)
+			continue
+		}
+		if err := avi.AddFrame(data); err != nil {
+			return "", err
+		}
+		t.mu.Lock()
+		t.status.Progress = float64(i+1) / float64(len(frames))
+		t.mu.Unlock()
+	}
+	if err := avi.Close(); err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return "", err
+	}
+	log.Printf("[timelapse]assembled %v frames to %v", avi.Frames(), name)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Video = name
+	return name, nil
+}
+
+func (t *timelapse) setError(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Error = err.Error()
+}
+
+func (t *timelapse) getStatus() status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// statusHandler responses the status in json
+func (t *timelapse) statusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(t.getStatus()); err != nil {
+		log.Printf("[timelapse]failed to write status, error: %v", err)
+	}
+}
+
+// latestHandler responses the latest frame
+func (t *timelapse) latestHandler(w http.ResponseWriter, r *http.Request) {
+	s := t.getStatus()
+	if s.Latest == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFile(w, r, filepath.Join(t.cfg.Dir, s.Latest))
+}
+
+// assembleHandler starts assembling a video in background, the progress is in the status.
+// e.g. /assemble?from=20201018-000000&to=20201019-000000
+func (t *timelapse) assembleHandler(w http.ResponseWriter, r *http.Request) {
+	var from, to time.Time
+	for _, p := range []struct {
+		key string
+		t   *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		v := r.URL.Query().Get(p.key)
+		if v == "" {
+			continue
+		}
+		tm, err := time.ParseInLocation(frameTimeLayout, v, time.Local)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %v: %v", p.key, v), http.StatusBadRequest)
+			return
+		}
+		*p.t = tm
+	}
+	if t.getStatus().Assembling {
+		http.Error(w, "a video is being assembled", http.StatusConflict)
+		return
+	}
+	go func() {
+		if _, err := t.assemble(from, to); err != nil {
+			log.Printf("[timelapse]failed to assemble, error: %v", err)
+			t.setError(err)
+		}
+	}()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// videoHandler responses the latest video
+func (t *timelapse) videoHandler(w http.ResponseWriter, r *http.Request) {
+	s := t.getStatus()
+	if s.Video == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+s.Video)
+	http.ServeFile(w, r, filepath.Join(t.cfg.Dir, s.Video))
+}
+
+// isActive checks the hour in [from, to), which may cross the midnight
+func isActive(hour, from, to int) bool {
+	switch {
+	case from == to:
+		return true
+	case from < to:
+		return hour >= from && hour < to
+	default:
+		return hour >= from || hour < to
+	}
+}
+
+func frameName(seq int, t time.Time) string {
+	return fmt.Sprintf("%v%06d-%v%v", framePrefix, seq, t.Format(frameTimeLayout), frameExt)
+}
+
+// frameSeq returns the sequence in the name, 0 means it isn't a frame
+func frameSeq(name string) int {
+	if !strings.HasPrefix(name, framePrefix) || !strings.HasSuffix(name, frameExt) {
+		return 0
+	}
+	parts := strings.SplitN(strings.TrimPrefix(name, framePrefix), "-", 2)
+	seq, err := strconv.Atoi(parts[0])
+	if err != nil || seq < 0 {
+		return 0
+	}
+	return seq
+}
+
+// frameTime returns the time in the name
+func frameTime(name string) time.Time {
+	s := strings.TrimSuffix(strings.TrimPrefix(name, framePrefix), frameExt)
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation(frameTimeLayout, parts[1], time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// videoTime returns the time in the name of a video, e.g. timelapse-20201018-153000.avi
+func videoTime(name string) time.Time {
+	if !strings.HasPrefix(name, videoPrefix) || !strings.HasSuffix(name, videoExt) {
+		return time.Time{}
+	}
+	s := strings.TrimSuffix(strings.TrimPrefix(name, videoPrefix), videoExt)
+	t, err := time.ParseInLocation(frameTimeLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// fileTime returns the time in the name of a frame or a video
+func fileTime(name string) time.Time {
+	if frameSeq(name) > 0 {
+		return frameTime(name)
+	}
+	return videoTime(name)
+}
+
+// brightness returns the mean luma of the jpeg in 0-255, it samples 1/16 of the pixels
+func brightness(data []byte) (float64, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	b := img.Bounds()
+	var (
+		sum float64
+		n   int
+	)
+	for y := b.Min.Y; y < b.Max.Y; y += 4 {
+		for x := b.Min.X; x < b.Max.X; x += 4 {
+			sum += float64(luma(img, x, y))
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return sum / float64(n), nil
+}
+
+func luma(img image.Image, x, y int) uint8 {
+	switch m := img.(type) {
+	case *image.YCbCr:
+		return m.Y[m.YOffset(x, y)]
+	case *image.Gray:
+		return m.GrayAt(x, y).Y
+	}
+	return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+}
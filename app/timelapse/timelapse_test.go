@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/stretchr/testify/assert"
+)
+
+func testJPEG(t *testing.T, w, h int, y uint8) []byte {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = y
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+// memFile is an in-memory io.WriteSeeker
+type memFile struct {
+	data []byte
+	pos  int
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if end := f.pos + len(p); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	copy(f.data[f.pos:], p)
+	f.pos += len(p)
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.pos = int(offset)
+	return offset, nil
+}
+
+func TestAVI(t *testing.T) {
+	assert.Equal(t, aviHeaderSize, len(aviHeaders(640, 480, 24)))
+
+	f := &memFile{}
+	avi, err := newAVIWriter(f, 640, 480, 24)
+	assert.NoError(t, err)
+	assert.NoError(t, avi.AddFrame([]byte{1, 2, 3}))
+	assert.NoError(t, avi.AddFrame([]byte{4, 5, 6, 7}))
+	assert.NoError(t, avi.Close())
+
+	u32 := func(off int) uint32 { return binary.LittleEndian.Uint32(f.data[off:]) }
+	assert.Equal(t, "RIFF", string(f.data[:4]))
+	assert.Equal(t, uint32(len(f.data)-8), u32(aviOffsetRIFFSize))
+	assert.Equal(t, uint32(41666), u32(32))
+	assert.Equal(t, uint32(2), u32(aviOffsetTotalFrames))
+	assert.Equal(t, uint32(2), u32(aviOffsetLength))
+	assert.Equal(t, uint32(4), u32(aviOffsetStrhBuffer))
+	assert.Equal(t, "MJPG", string(f.data[188:192]))
+
+	// the odd chunk is padded
+	assert.Equal(t, "00dc", string(f.data[224:228]))
+	assert.Equal(t, uint32(3), u32(228))
+	assert.Equal(t, "00dc", string(f.data[236:240]))
+	assert.Equal(t, uint32(4+12+12), u32(aviOffsetMoviSize))
+
+	assert.Equal(t, "idx1", string(f.data[248:252]))
+	assert.Equal(t, uint32(32), u32(252))
+	assert.Equal(t, uint32(4), u32(256+8))  // the offset of the first chunk from 'movi'
+	assert.Equal(t, uint32(16), u32(272+8)) // the second one
+	assert.Equal(t, uint32(4), u32(272+12))
+	assert.Equal(t, 288, len(f.data))
+}
+
+func TestCapture(t *testing.T) {
+	src, err := ioutil.TempDir("", "photos")
+	assert.NoError(t, err)
+	defer os.RemoveAll(src)
+	dst, err := ioutil.TempDir("", "timelapse")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dst)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "1.jpg"), testJPEG(t, 32, 16, 200), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "2.jpg"), testJPEG(t, 32, 16, 10), 0644))
+	cam, err := dev.NewDirCamera(src)
+	assert.NoError(t, err)
+
+	now := time.Date(2020, 10, 18, 7, 0, 0, 0, time.Local)
+	clock := func() time.Time { return now }
+	tl, err := newTimelapse(cam, &base.TimelapseConfig{
+		Dir:           dst,
+		From:          8,
+		To:            20,
+		MinBrightness: 50,
+	}, clock)
+	assert.NoError(t, err)
+
+	// inactive
+	assert.NoError(t, tl.capture())
+	assert.Equal(t, 0, tl.getStatus().Frames)
+
+	now = now.Add(2 * time.Hour)
+	assert.NoError(t, tl.capture())
+	// the dark one
+	assert.NoError(t, tl.capture())
+	assert.NoError(t, tl.capture())
+	s := tl.getStatus()
+	assert.Equal(t, 2, s.Frames)
+	assert.Equal(t, 1, s.Skipped)
+	frames, err := tl.frames()
+	assert.NoError(t, err)
+	assert.Len(t, frames, 2)
+	assert.Equal(t, 1, frameSeq(frames[0]))
+	assert.Equal(t, 2, frameSeq(frames[1]))
+	assert.Equal(t, frames[1], s.Latest)
+
+	video, err := tl.assemble(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(dst, video))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), binary.LittleEndian.Uint32(data[aviOffsetTotalFrames:]))
+	assert.Equal(t, uint32(32), binary.LittleEndian.Uint32(data[64:]))
+
+	// the sequence goes on after restarting
+	tl, err = newTimelapse(cam, &base.TimelapseConfig{Dir: dst}, clock)
+	assert.NoError(t, err)
+	assert.NoError(t, tl.capture())
+	frames, err = tl.frames()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, frameSeq(frames[2]))
+}
+
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timelapse")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2020, 10, 18, 12, 0, 0, 0, time.Local)
+	for i := 1; i <= 4; i++ {
+		name := frameName(i, now.Add(time.Duration(i-4)*time.Hour))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), make([]byte, 512*1024), 0644))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644))
+
+	tl, err := newTimelapse(nil, &base.TimelapseConfig{Dir: dir, MaxAge: 2}, func() time.Time { return now })
+	assert.NoError(t, err)
+	assert.NoError(t, tl.prune())
+	frames, err := tl.frames()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 4}, []int{frameSeq(frames[0]), frameSeq(frames[1]), frameSeq(frames[2])})
+
+	tl.cfg.MaxSize = 1
+	assert.NoError(t, tl.prune())
+	frames, err = tl.frames()
+	assert.NoError(t, err)
+	assert.Len(t, frames, 2)
+	assert.Equal(t, 3, frameSeq(frames[0]))
+	assert.Equal(t, 2, tl.getStatus().Frames)
+
+	// the videos take the space too, the oldest files go first
+	video := videoPrefix + now.Add(-30*time.Minute).Format(frameTimeLayout) + videoExt
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, video), make([]byte, 256*1024), 0644))
+	tl.cfg.MaxAge = 0
+	assert.NoError(t, tl.prune())
+	frames, err = tl.frames()
+	assert.NoError(t, err)
+	assert.Len(t, frames, 1)
+	assert.Equal(t, 4, frameSeq(frames[0]))
+	videos, err := tl.videos()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{video}, videos)
+
+	video2 := videoPrefix + now.Format(frameTimeLayout) + videoExt
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, video2), make([]byte, 512*1024), 0644))
+	assert.NoError(t, tl.prune())
+	videos, err = tl.videos()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{video2}, videos)
+	assert.Equal(t, 1, tl.getStatus().Frames)
+
+	assert.True(t, isActive(23, 22, 6))
+	assert.False(t, isActive(12, 22, 6))
+	assert.True(t, isActive(12, 0, 0))
+	assert.Equal(t, uint8(0x80), luma(image.NewUniform(color.Gray{0x80}), 0, 0))
+}
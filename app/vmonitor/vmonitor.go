@@ -10,10 +10,12 @@ import (
 	"net/http"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/shanghuiyang/rpi-devices/base"
 	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/shanghuiyang/rpi-devices/ui"
 	"github.com/stianeikeland/go-rpio"
 )
 
@@ -23,6 +25,18 @@ const (
 	pinLed = 21
 	pinBzr = 11
 	pinBtn = 4
+
+	// the servos are on the channels of a PCA9685 if there is one,
+	// or on the hardware pwm pins of pi.
+	addrPCA9685 = 0x40
+	chSGH       = 0
+	chSGV       = 1
+
+	// the speed of the pan/tilt servos in degree/s
+	servoSpeed = 120
+
+	settingsFile = "settings.json"
+	keyMode      = "mode"
 )
 
 const (
@@ -66,13 +80,11 @@ func main() {
 	}
 	defer rpio.Close()
 
-	hServo := dev.NewSG90(pinSGH)
+	hServo, vServo := newServos()
 	if hServo == nil {
 		log.Printf("[vmonitor]failed to new a sg90")
 		return
 	}
-
-	vServo := dev.NewSG90(pinSGV)
 	if vServo == nil {
 		log.Printf("[vmonitor]failed to new a sg90")
 		return
@@ -98,6 +110,11 @@ func main() {
 		log.Printf("[vmonitor]failed to new the video server")
 		return
 	}
+	if cfg, err := base.LoadConfig(); err == nil && cfg.OLED != nil && cfg.Menu != nil {
+		if err := server.setupMenu(cfg); err != nil {
+			log.Printf("[vmonitor]failed to setup the menu, error: %v", err)
+		}
+	}
 
 	base.WaitQuit(func() {
 		server.stop()
@@ -108,13 +125,27 @@ func main() {
 	server.start()
 }
 
+func newServos() (hServo, vServo *dev.SG90) {
+	pca, err := dev.NewPCA9685(addrPCA9685, 50)
+	if err != nil {
+		log.Printf("[vmonitor]failed to new a pca9685, will use the pwm pins of pi for servos, error: %v", err)
+		return dev.NewSG90(pinSGH), dev.NewSG90(pinSGV)
+	}
+	return dev.NewPCA9685Servo(pca, chSGH, dev.SG90Spec), dev.NewPCA9685Servo(pca, chSGV, dev.SG90Spec)
+}
+
 type videoServer struct {
 	hServo *dev.SG90
 	vServo *dev.SG90
-	led    *dev.Led
+	led    *dev.LedController
 	buzzer *dev.Buzzer
 	button *dev.Button
 
+	settings    *base.Settings
+	modeMu      sync.Mutex
+	screen      *ui.Screen
+	inputs      *ui.MenuInputs
+	status      *ui.Label
 	mode        mode
 	inServing   bool
 	hAngle      int
@@ -127,16 +158,23 @@ func newVideoServer(hServo, vServo *dev.SG90, led *dev.Led, buzzer *dev.Buzzer,
 	v := &videoServer{
 		hServo: hServo,
 		vServo: vServo,
-		led:    led,
+		led:    dev.NewLedController(led),
 		buzzer: buzzer,
 		button: button,
 
+		settings:  base.LoadSettings(settingsFile),
 		mode:      normalMode,
 		inServing: true,
 		hAngle:    0,
 		vAngle:    0,
 		chAlert:   make(chan int, 16),
 	}
+	var m string
+	if v.settings.Get(keyMode, &m) {
+		if _, ok := motionConfs[mode(m)]; ok {
+			v.mode = mode(m)
+		}
+	}
 
 	if err := v.restartMotion(); err != nil {
 		return nil
@@ -239,7 +277,13 @@ func (v *videoServer) do(op string) {
 }
 
 func (v *videoServer) stop() {
-	v.led.Off()
+	if v.inputs != nil {
+		v.inputs.Stop()
+	}
+	if v.screen != nil {
+		v.screen.Close()
+	}
+	v.led.Close()
 	close(v.chAlert)
 }
 
@@ -251,7 +295,7 @@ func (v *videoServer) left() {
 	}
 	v.hAngle = angle
 	log.Printf("[vmonitor]servo: %v", angle)
-	v.hServo.Roll(angle)
+	v.hServo.Move(float64(angle), servoSpeed, dev.EaseInOut)
 }
 
 func (v *videoServer) right() {
@@ -262,7 +306,7 @@ func (v *videoServer) right() {
 	}
 	v.hAngle = angle
 	log.Printf("[vmonitor]servo: %v", angle)
-	v.hServo.Roll(angle)
+	v.hServo.Move(float64(angle), servoSpeed, dev.EaseInOut)
 }
 
 func (v *videoServer) up() {
@@ -273,7 +317,7 @@ func (v *videoServer) up() {
 	}
 	v.vAngle = angle
 	log.Printf("[vmonitor]servo: %v", angle)
-	v.vServo.Roll(angle)
+	v.vServo.Move(float64(angle), servoSpeed, dev.EaseInOut)
 }
 
 func (v *videoServer) down() {
@@ -284,7 +328,7 @@ func (v *videoServer) down() {
 	}
 	v.vAngle = angle
 	log.Printf("[vmonitor]servo: %v", angle)
-	v.vServo.Roll(angle)
+	v.vServo.Move(float64(angle), servoSpeed, dev.EaseInOut)
 }
 
 func (v *videoServer) beep(n int, interval int) {
@@ -325,7 +369,9 @@ func (v *videoServer) alert() {
 			// do nothing
 		}
 		if conCount > 0 {
-			v.led.Blink(1, 1000)
+			v.led.Play("connected", dev.BlinkPattern(dev.White, time.Second, time.Second), 0, 0)
+		} else {
+			v.led.Stop("connected")
 		}
 		time.Sleep(1 * time.Second)
 	}
@@ -394,30 +440,98 @@ func (v *videoServer) detectingMode() {
 
 		count = 0
 		log.Printf("[vmonitor]the button was pressed")
-		go v.led.Blink(2, 100)
-		lastMode := v.mode
+		v.led.Play("button", dev.BlinkPattern(dev.White, 100*time.Millisecond, 100*time.Millisecond).Repeat(2), 1, 0)
+		next := unknownMode
 		if v.mode == normalMode {
-			v.mode = babyMode
+			next = babyMode
 		} else if v.mode == babyMode {
-			v.mode = normalMode
+			next = normalMode
 		} else {
 			// make a dalay detecting
 			time.Sleep(1 * time.Second)
 			continue
 		}
-		if err := v.loadHomePage(); err != nil {
-			log.Printf("[vmonitor]failed to load home page, error: %v", err)
-			continue
+		if err := v.setMode(next); err != nil {
+			log.Printf("[vmonitor]failed to change mode, error: %v", err)
 		}
-		if err := v.restartMotion(); err != nil {
-			log.Printf("[vmonitor]failed to restart motion, error: %v", err)
-			continue
-		}
-		go v.led.Blink(5, 100)
-		log.Printf("[vmonitor]mode changed: %v --> %v", lastMode, v.mode)
 	}
 }
 
+// setMode changes the mode, restarts motion with the config of the mode and saves it
+func (v *videoServer) setMode(m mode) error {
+	v.modeMu.Lock()
+	defer v.modeMu.Unlock()
+	if _, ok := motionConfs[m]; !ok {
+		return fmt.Errorf("invalid mode: %v", m)
+	}
+	lastMode := v.mode
+	v.mode = m
+	if err := v.loadHomePage(); err != nil {
+		v.mode = lastMode
+		return err
+	}
+	if err := v.restartMotion(); err != nil {
+		return err
+	}
+	v.led.Play("mode", dev.BlinkPattern(dev.White, 100*time.Millisecond, 100*time.Millisecond).Repeat(5), 1, 0)
+	log.Printf("[vmonitor]mode changed: %v --> %v", lastMode, v.mode)
+	v.updateStatus()
+	return v.settings.Set(keyMode, string(m))
+}
+
+// setupMenu shows the mode on the oled, and the menu for changing the mode
+func (v *videoServer) setupMenu(cfg *base.Config) error {
+	oled, err := dev.NewSSD1306(cfg.OLED)
+	if err != nil {
+		return err
+	}
+	b := oled.Bounds()
+	v.status = ui.NewLabel(b, ui.DefaultFace, ui.AlignLeft)
+	v.updateStatus()
+
+	menu := ui.NewMenu(b, ui.DefaultFace, &ui.MenuItem{
+		Title: "Video Monitor",
+		Items: []*ui.MenuItem{
+			{
+				Title: "Mode",
+				Editor: &ui.Choice{
+					Options: []string{string(normalMode), string(babyMode)},
+					Get:     func() string { return string(v.mode) },
+					Set:     func(m string) error { return v.setMode(mode(m)) },
+				},
+				Confirm: true,
+			},
+			{
+				Title:   "Restart",
+				Action:  v.restartMotion,
+				Confirm: true,
+			},
+		},
+	})
+
+	v.screen = ui.NewScreen(oled)
+	v.screen.AddPage("home", v.status)
+	v.screen.AddPage("menu", menu)
+	menu.OnOpen(func() { v.screen.ShowPage("menu") })
+	menu.OnClose(func() { v.screen.ShowPage("home") })
+
+	inputs, err := ui.NewMenuInputsFromConfig(menu, cfg)
+	if err != nil {
+		return err
+	}
+	v.inputs = inputs
+	v.inputs.Start()
+	v.screen.Start(200 * time.Millisecond)
+	return nil
+}
+
+func (v *videoServer) updateStatus() {
+	if v.status == nil {
+		return
+	}
+	v.status.SetText(fmt.Sprintf("mode: %v\nip: %v", v.mode, base.GetIP()))
+}
+
 func (v *videoServer) stopMotion() error {
 	cmd := "sudo killall motion"
 	exec.Command("bash", "-c", cmd).CombinedOutput()
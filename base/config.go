@@ -25,11 +25,20 @@ const (
 type Config struct {
 	Led       *LedConfig       `json:"led"`
 	Relay     *RelayConfig     `json:"relay"`
+	Relays    []*RelayConfig   `json:"relays"`
 	StepMotor *StepMotorConfig `json:"stepmotor"`
 	Wsn       *WsnConfig       `json:"wsn"`
 	OneNet    *OneNetConfig    `json:"onenet"`
 	Email     *EmailConfig     `json:"email"`
 	EmailTo   *EmailToConfig   `json:"emailto"`
+	Remote    *RemoteConfig    `json:"remote"`
+	Buzzer    *BuzzerConfig    `json:"buzzer"`
+	Display   *DisplayConfig   `json:"display"`
+	OLED      *OLEDConfig      `json:"oled"`
+	Menu      *MenuConfig      `json:"menu"`
+	Camera    *CameraConfig    `json:"camera"`
+	Timelapse *TimelapseConfig `json:"timelapse"`
+	Car       *CarConfig       `json:"car"`
 }
 
 // LedConfig ...
@@ -37,9 +46,14 @@ type LedConfig struct {
 	Pin uint8 `json:"pin"`
 }
 
-// RelayConfig ...
+// RelayConfig is the config of a relay, or a channel of a relay board
 type RelayConfig struct {
-	Pin uint8 `json:"pin"`
+	Name      string `json:"name"`
+	Pin       uint8  `json:"pin"`
+	ActiveLow bool   `json:"active_low"`
+	MinOn     int    `json:"min_on"`  // the min on time in second
+	MinOff    int    `json:"min_off"` // the min off time in second
+	Restore   bool   `json:"restore"` // restores the state after reboot, or turns it off
 }
 
 // StepMotorConfig ...
@@ -50,6 +64,119 @@ type StepMotorConfig struct {
 	In4 uint8 `json:"in4"`
 }
 
+// RemoteConfig is the config of a 433MHz remote receiver like RX480E4
+type RemoteConfig struct {
+	Name     string           `json:"name"`
+	Mode     string           `json:"mode"` // momentary, toggle or latched, the same as the module
+	D0       uint8            `json:"d0"`
+	D1       uint8            `json:"d1"`
+	D2       uint8            `json:"d2"`
+	D3       uint8            `json:"d3"`
+	Mappings []*RemoteMapping `json:"mappings"`
+}
+
+// RemoteMapping maps a gesture on a button of a remote to a named action
+type RemoteMapping struct {
+	Remote  string `json:"remote"`
+	Button  string `json:"button"`  // A, B, C or D
+	Gesture string `json:"gesture"` // click, doubleclick or longpress
+	Action  string `json:"action"`
+}
+
+// BuzzerConfig is the config of a buzzer and the alerts it plays for the events of an app
+type BuzzerConfig struct {
+	Pin     uint8             `json:"pin"`     // 0 means the default pin of the app
+	Passive bool              `json:"passive"` // a passive buzzer must be on a pwm pin
+	Alerts  map[string]string `json:"alerts"`  // event -> alert, e.g. {"intruder": "doorbell"}
+}
+
+// Alert returns the alert for the event, or def if it isn't in the config
+func (c *BuzzerConfig) Alert(event, def string) string {
+	if c == nil {
+		return def
+	}
+	if a, ok := c.Alerts[event]; ok {
+		return a
+	}
+	return def
+}
+
+// DisplayConfig is the config of a 7-segment display, a character lcd or a led matrix
+type DisplayConfig struct {
+	Type    string `json:"type"` // leddisplay(74HC595), tm1637, lcd(HD44780), max7219, matrix(MAX7219), or oled in the apps supporting it
+	DIO     uint8  `json:"dio"`
+	RCLK    uint8  `json:"rclk"`    // leddisplay only
+	SCLK    uint8  `json:"sclk"`    // leddisplay only
+	CLK     uint8  `json:"clk"`     // tm1637 only
+	Bus     string `json:"bus"`     // lcd: the i2c bus, /dev/i2c-1 by default; max7219 and matrix: the spi device, /dev/spidev0.0 by default
+	Addr    uint8  `json:"addr"`    // lcd only, 0x27 by default
+	Cols    int    `json:"cols"`    // lcd only, 16 by default
+	Rows    int    `json:"rows"`    // lcd only, 2 by default
+	Modules int    `json:"modules"` // max7219 and matrix only, the cascaded modules, 1 by default
+}
+
+// CameraConfig is the config of the camera taking photos
+type CameraConfig struct {
+	Type   string `json:"type"`   // motion(default), libcamera, raspistill, v4l2, or dir
+	URL    string `json:"url"`    // motion only, the snapshot action, http://localhost:8088/0/action/snapshot by default
+	File   string `json:"file"`   // motion only, the snapshot file, /var/lib/motion/lastsnap.jpg by default
+	Device string `json:"device"` // v4l2 only, /dev/video0 by default
+	Dir    string `json:"dir"`    // dir only, the directory of the jpeg files
+	Width  int    `json:"width"`  // libcamera, raspistill and v4l2, 1280 by default
+	Height int    `json:"height"` // libcamera, raspistill and v4l2, 720 by default
+}
+
+// TimelapseConfig is the config of capturing the frames of a time-lapse
+type TimelapseConfig struct {
+	Dir           string  `json:"dir"`            // the directory of the frames and the videos
+	Interval      int     `json:"interval"`       // in seconds
+	From          int     `json:"from"`           // the active hours are [from, to), the same ones mean all day
+	To            int     `json:"to"`             // in hours
+	MaxAge        int     `json:"max_age"`        // in hours, the older frames are removed, 0 means keeping them
+	MaxSize       int64   `json:"max_size"`       // in MB, the oldest frames or videos are removed if they take more, 0 means no limit
+	MinBrightness float64 `json:"min_brightness"` // in 0-255, the darker frames are skipped
+	FPS           int     `json:"fps"`            // the frame rate of the videos
+	Port          int     `json:"port"`           // the port of the http server
+}
+
+// CarConfig is the config of the self-driving car
+type CarConfig struct {
+	// the pin of the encoder on the right wheel, 0 means not connected,
+	// the car turns and drives by the motion controller only with the encoders on both wheels
+	EncoderR uint8 `json:"encoder_r"`
+}
+
+// OLEDConfig is the config of a monochrome oled on SSD1306 or SH1106
+type OLEDConfig struct {
+	Controller string `json:"controller"` // ssd1306(default) or sh1106
+	Width      int    `json:"width"`      // 128 by default
+	Height     int    `json:"height"`     // 64 by default
+	Bus        string `json:"bus"`        // i2c(default) or spi
+	Dev        string `json:"dev"`        // /dev/i2c-1 or /dev/spidev0.0 by default
+	Addr       uint8  `json:"addr"`       // i2c only, 0x3c by default
+	DC         uint8  `json:"dc"`         // spi only, the data/command pin
+	RST        uint8  `json:"rst"`        // spi only, the reset pin, 0 means not connected
+	Rotation   int    `json:"rotation"`   // 0, 90, 180 or 270 degrees
+	Contrast   uint8  `json:"contrast"`   // 1-255, 0 means the default
+}
+
+// MenuConfig is the config of the inputs of the on-device menu, any of them can be used
+type MenuConfig struct {
+	Up      uint8          `json:"up"` // the pins of the buttons, 0 means not connected
+	Down    uint8          `json:"down"`
+	Enter   uint8          `json:"enter"`
+	Back    uint8          `json:"back"`
+	Encoder *EncoderConfig `json:"encoder"`
+	Remote  bool           `json:"remote"` // the remote maps the gestures to menu.up, menu.down, menu.enter and menu.back
+}
+
+// EncoderConfig is the config of a KY-040 rotary encoder
+type EncoderConfig struct {
+	CLK uint8 `json:"clk"`
+	DT  uint8 `json:"dt"`
+	SW  uint8 `json:"sw"`
+}
+
 // WsnConfig ...
 type WsnConfig struct {
 	Token string `json:"token"`
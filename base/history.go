@@ -46,32 +46,11 @@ func (h *History) Avg() (float64, error) {
 	}
 	var sum float64
 	for _, v := range h.contains {
-		switch v.(type) {
-		case uint8:
-			sum += float64(v.(uint8))
-		case uint16:
-			sum += float64(v.(uint16))
-		case uint32:
-			sum += float64(v.(uint32))
-		case uint64:
-			sum += float64(v.(uint64))
-		case int:
-			sum += float64(v.(int))
-		case int8:
-			sum += float64(v.(int8))
-		case int16:
-			sum += float64(v.(int16))
-		case int32:
-			sum += float64(v.(int32))
-		case int64:
-			sum += float64(v.(int64))
-		case float32:
-			sum += float64(v.(float32))
-		case float64:
-			sum += v.(float64)
-		default:
+		f, ok := toFloat64(v)
+		if !ok {
 			return 0, errors.New("the element isn't numerical")
 		}
+		sum += f
 	}
 	n := h.index
 	if h.full {
@@ -79,3 +58,50 @@ func (h *History) Avg() (float64, error) {
 	}
 	return sum / float64(n), nil
 }
+
+// Values returns the numerical values from the oldest to the latest
+func (h *History) Values() ([]float64, error) {
+	var elements []interface{}
+	if h.full {
+		elements = append(elements, h.contains[h.index:]...)
+	}
+	elements = append(elements, h.contains[:h.index]...)
+
+	values := make([]float64, 0, len(elements))
+	for _, v := range elements {
+		f, ok := toFloat64(v)
+		if !ok {
+			return nil, errors.New("the element isn't numerical")
+		}
+		values = append(values, f)
+	}
+	return values, nil
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch v.(type) {
+	case uint8:
+		return float64(v.(uint8)), true
+	case uint16:
+		return float64(v.(uint16)), true
+	case uint32:
+		return float64(v.(uint32)), true
+	case uint64:
+		return float64(v.(uint64)), true
+	case int:
+		return float64(v.(int)), true
+	case int8:
+		return float64(v.(int8)), true
+	case int16:
+		return float64(v.(int16)), true
+	case int32:
+		return float64(v.(int32)), true
+	case int64:
+		return float64(v.(int64)), true
+	case float32:
+		return float64(v.(float32)), true
+	case float64:
+		return v.(float64), true
+	}
+	return 0, false
+}
@@ -139,3 +139,50 @@ func TestHistoryAvg(t *testing.T) {
 		}
 	}
 }
+
+func TestHistoryValues(t *testing.T) {
+	testCase := []struct {
+		desc     string
+		elements []interface{}
+		noError  bool
+		expected []float64
+	}{
+		{
+			desc:     "empty",
+			elements: []interface{}{},
+			noError:  true,
+			expected: []float64{},
+		},
+		{
+			desc:     "not full",
+			elements: []interface{}{uint8(1), int(2)},
+			noError:  true,
+			expected: []float64{1, 2},
+		},
+		{
+			desc:     "wrapped",
+			elements: []interface{}{int16(1), float32(2), float64(3), uint16(4), int(5)},
+			noError:  true,
+			expected: []float64{3, 4, 5},
+		},
+		{
+			desc:     "string",
+			elements: []interface{}{uint8(1), "some strings"},
+			noError:  false,
+		},
+	}
+
+	for _, test := range testCase {
+		h := NewHistory(3)
+		for _, v := range test.elements {
+			h.Add(v)
+		}
+		values, err := h.Values()
+		if test.noError {
+			assert.NoError(t, err, test.desc)
+			assert.Equal(t, test.expected, values, test.desc)
+		} else {
+			assert.Error(t, err, test.desc)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package base
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+)
+
+// Settings keeps the settings changed at the device, like the thresholds changed in the menu,
+// they're saved in a json file and loaded after reboot.
+type Settings struct {
+	file string
+
+	mu     sync.Mutex
+	values map[string]json.RawMessage
+}
+
+// LoadSettings loads the settings from the file,
+// it starts with empty settings if the file doesn't exist or it's broken.
+func LoadSettings(file string) *Settings {
+	s := &Settings{
+		file:   file,
+		values: map[string]json.RawMessage{},
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[settings]failed to read %v, the defaults will be used, error: %v", file, err)
+		}
+		return s
+	}
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		log.Printf("[settings]invalid settings in %v, the defaults will be used, error: %v", file, err)
+		return s
+	}
+	s.values = values
+	return s
+}
+
+// Get reads the setting into v, it returns false if the setting isn't saved or it's invalid
+func (s *Settings) Get(key string, v interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.values[key]
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Printf("[settings]invalid %v: %s, error: %v", key, data, err)
+		return false
+	}
+	return true
+}
+
+// Set changes the setting and saves all the settings
+func (s *Settings) Set(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = data
+	return s.save()
+}
+
+func (s *Settings) save() error {
+	data, err := json.MarshalIndent(s.values, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temp file first, so a power cut won't leave a broken file
+	tmp := s.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
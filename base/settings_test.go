@@ -0,0 +1,42 @@
+package base
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "settings.json")
+
+	s := LoadSettings(file)
+	var on int
+	assert.False(t, s.Get("on", &on))
+
+	assert.NoError(t, s.Set("on", 120))
+	assert.NoError(t, s.Set("mode", "baby"))
+	assert.True(t, s.Get("on", &on))
+	assert.Equal(t, 120, on)
+
+	// loaded after reboot
+	s = LoadSettings(file)
+	var mode string
+	assert.True(t, s.Get("on", &on))
+	assert.Equal(t, 120, on)
+	assert.True(t, s.Get("mode", &mode))
+	assert.Equal(t, "baby", mode)
+
+	// the wrong type
+	assert.False(t, s.Get("mode", &on))
+
+	// a broken file
+	assert.NoError(t, ioutil.WriteFile(file, []byte("{"), 0644))
+	s = LoadSettings(file)
+	assert.False(t, s.Get("on", &on))
+}
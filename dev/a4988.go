@@ -0,0 +1,79 @@
+/*
+Package dev ...
+
+A4988 is the driver of A4988 and DRV8825, the STEP/DIR drivers for bipolar steppers like NEMA17.
+The motor moves one step on every rising edge of STEP, and the direction is set by DIR.
+The microstepping is set by MS1-MS3 in hardware,
+please set the steps per revolution of Stepper accordingly, e.g. 200 * 16 for 1/16 microstepping.
+
+Connect to Pi:
+  - VDD:	any 3.3v pin
+  - GND:	any gnd pin
+  - STEP:	any data pin
+  - DIR:	any data pin
+  - EN:		any data pin, or GND if it's always enabled
+  - VMOT:	an external power for the motor, 8V - 35V
+  - RST:	connect to SLP
+*/
+package dev
+
+import (
+	"time"
+
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	// both A4988 and DRV8825 need at least 2us for the high and low of STEP
+	stepPulse = 2 * time.Microsecond
+)
+
+// A4988 ...
+type A4988 struct {
+	step      rpio.Pin
+	dir       rpio.Pin
+	enable    rpio.Pin
+	hasEnable bool
+}
+
+// NewA4988 creates the driver, en is the pin of EN, or 0 if EN is connected to GND
+func NewA4988(step, dir, en uint8) *A4988 {
+	a := &A4988{
+		step:      rpio.Pin(step),
+		dir:       rpio.Pin(dir),
+		enable:    rpio.Pin(en),
+		hasEnable: en != 0,
+	}
+	a.step.Output()
+	a.step.Low()
+	a.dir.Output()
+	a.dir.Low()
+	if a.hasEnable {
+		a.enable.Output()
+		// EN is active low
+		a.enable.High()
+	}
+	return a
+}
+
+// Step ...
+func (a *A4988) Step(cw bool) {
+	if a.hasEnable {
+		a.enable.Low()
+	}
+	if cw {
+		a.dir.High()
+	} else {
+		a.dir.Low()
+	}
+	a.step.High()
+	time.Sleep(stepPulse)
+	a.step.Low()
+}
+
+// Release ...
+func (a *A4988) Release() {
+	if a.hasEnable {
+		a.enable.High()
+	}
+}
@@ -0,0 +1,417 @@
+/*
+Package dev ...
+
+ADS1115 is the driver of ADS1115/ADS1015, a 4-channel analog-to-digital converter over i2c.
+The pi has no analog inputs, so analog sensors like LDR, soil moisture, MQ-x gas sensors
+and battery voltage can be read through it.
+ADS1115 is 16-bit, and ADS1015 is the 12-bit but faster one with the same registers.
+
+Spec:
+  - power supply:	2.0V - 5.5V
+  - address:		0x48(ADDR->GND), 0x49(ADDR->VDD), 0x4A(ADDR->SDA), 0x4B(ADDR->SCL)
+  - channels:		4 single-ended or 2 differential
+	 ___________________________
+    |                           |
+    |          ADS1115          |
+    |                           |
+    |___________________________|
+      |   |   |   |   |   |   |   |   |   |
+     VDD GND SCL SDA ADDR ALRT A0  A1  A2  A3
+
+Connect to Pi:
+  - VDD:	any 3.3v pin
+  - GND:	any gnd pin
+  - SCL:	pin 5 (SCL)
+  - SDA:	pin 3 (SDA)
+  - ADDR:	any gnd pin for address 0x48
+  - ALRT:	any data pin (optional)
+  - A0~A3:	the analog outputs of the sensors
+
+*/
+package dev
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	adsRegConversion = 0x00
+	adsRegConfig     = 0x01
+	adsRegLoThresh   = 0x02
+	adsRegHiThresh   = 0x03
+
+	adsOS          = 0x8000 // start a single conversion / conversion done
+	adsModeSingle  = 0x0100
+	adsCompWindow  = 0x0010
+	adsCompPolHigh = 0x0008
+	adsCompLatch   = 0x0004
+	adsCompDisable = 0x0003
+)
+
+// ADSChannel is the input of the multiplexer
+type ADSChannel uint16
+
+// ADS1115 channels
+const (
+	// ADSDiff01 is AIN0 - AIN1
+	ADSDiff01 ADSChannel = iota
+	// ADSDiff03 is AIN0 - AIN3
+	ADSDiff03
+	// ADSDiff13 is AIN1 - AIN3
+	ADSDiff13
+	// ADSDiff23 is AIN2 - AIN3
+	ADSDiff23
+	// ADSA0 is AIN0 - GND
+	ADSA0
+	// ADSA1 is AIN1 - GND
+	ADSA1
+	// ADSA2 is AIN2 - GND
+	ADSA2
+	// ADSA3 is AIN3 - GND
+	ADSA3
+)
+
+// ADSGain is the gain of the programmable amplifier
+type ADSGain uint16
+
+// ADS1115 gains, the voltage in comments is the full-scale range
+const (
+	// ADSGainTwoThirds is +/-6.144V
+	ADSGainTwoThirds ADSGain = iota
+	// ADSGain1 is +/-4.096V
+	ADSGain1
+	// ADSGain2 is +/-2.048V, the default gain
+	ADSGain2
+	// ADSGain4 is +/-1.024V
+	ADSGain4
+	// ADSGain8 is +/-0.512V
+	ADSGain8
+	// ADSGain16 is +/-0.256V
+	ADSGain16
+)
+
+var adsFullScale = map[ADSGain]float64{
+	ADSGainTwoThirds: 6.144,
+	ADSGain1:         4.096,
+	ADSGain2:         2.048,
+	ADSGain4:         1.024,
+	ADSGain8:         0.512,
+	ADSGain16:        0.256,
+}
+
+var (
+	// samples per second, indexed by the DR bits
+	ads1115Rates = []int{8, 16, 32, 64, 128, 250, 475, 860}
+	ads1015Rates = []int{128, 250, 490, 920, 1600, 2400, 3300, 3300}
+)
+
+// ADSComparator is the config of the comparator which drives the ALERT pin.
+// Low and High are the thresholds in volt.
+type ADSComparator struct {
+	// Window compares with both thresholds, or only the high one in traditional mode
+	Window bool
+	// ActiveHigh makes the ALERT pin active high, it is active low in default
+	ActiveHigh bool
+	// Latch keeps the ALERT asserted until the conversion register is read
+	Latch bool
+	// Queue is the number of successive conversions exceeding the thresholds
+	// before asserting ALERT, must be 1, 2 or 4
+	Queue int
+	Low   float64
+	High  float64
+}
+
+// ADS1115 ...
+type ADS1115 struct {
+	dev   i2cDevice
+	rates []int
+	shift uint
+
+	mu         sync.Mutex
+	gain       ADSGain
+	rate       uint16
+	comp       uint16
+	continuous bool
+	channel    ADSChannel
+
+	alert      rpio.Pin
+	alertReady bool
+}
+
+// NewADS1115 ...
+func NewADS1115(addr uint8) (*ADS1115, error) {
+	d, err := openI2C(defaultI2CBus, addr)
+	if err != nil {
+		return nil, err
+	}
+	return newADS(d, ads1115Rates, 0), nil
+}
+
+// NewADS1015 ...
+func NewADS1015(addr uint8) (*ADS1115, error) {
+	d, err := openI2C(defaultI2CBus, addr)
+	if err != nil {
+		return nil, err
+	}
+	// the 12-bit result is left-justified in the 16-bit register
+	return newADS(d, ads1015Rates, 4), nil
+}
+
+func newADS(d i2cDevice, rates []int, shift uint) *ADS1115 {
+	return &ADS1115{
+		dev:   d,
+		rates: rates,
+		shift: shift,
+		gain:  ADSGain2,
+		rate:  4, // 128 sps on ADS1115 and 1600 sps on ADS1015
+		comp:  adsCompDisable,
+	}
+}
+
+// SetGain ...
+func (a *ADS1115) SetGain(g ADSGain) error {
+	if _, ok := adsFullScale[g]; !ok {
+		return fmt.Errorf("invalid gain: %v", g)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.gain = g
+	return a.restart()
+}
+
+// SetDataRate sets the samples per second, it must be one of the rates the chip supports
+func (a *ADS1115) SetDataRate(sps int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, r := range a.rates {
+		if r == sps {
+			a.rate = uint16(i)
+			return a.restart()
+		}
+	}
+	return fmt.Errorf("unsupported data rate: %v sps, should be one of %v", sps, a.rates)
+}
+
+// Read returns the raw value of the channel.
+// it's in [-32768, 32767] for ADS1115, and [-2048, 2047] for ADS1015.
+func (a *ADS1115) Read(ch ADSChannel) (int16, error) {
+	if ch > ADSA3 {
+		return 0, fmt.Errorf("invalid channel: %v", ch)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.continuous {
+		if ch != a.channel {
+			a.channel = ch
+			if err := writeReg16(a.dev, adsRegConfig, a.config(ch, false)); err != nil {
+				return 0, err
+			}
+			// the first result of the new channel is ready after one conversion
+			time.Sleep(a.convTime())
+		}
+		return a.readConversion()
+	}
+
+	if err := writeReg16(a.dev, adsRegConfig, a.config(ch, true)|adsOS); err != nil {
+		return 0, err
+	}
+	time.Sleep(a.convTime())
+	for i := 0; i < 10; i++ {
+		cfg, err := readReg16(a.dev, adsRegConfig)
+		if err != nil {
+			return 0, err
+		}
+		if cfg&adsOS != 0 {
+			return a.readConversion()
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return 0, errors.New("ads1115 conversion timeout")
+}
+
+// Volts returns the voltage of the channel
+func (a *ADS1115) Volts(ch ADSChannel) (float64, error) {
+	v, err := a.Read(ch)
+	if err != nil {
+		return 0, err
+	}
+	return a.toVolts(v), nil
+}
+
+// StartContinuous makes the chip keep converting the channel,
+// and Read on the same channel only fetches the latest result.
+func (a *ADS1115) StartContinuous(ch ADSChannel) error {
+	if ch > ADSA3 {
+		return fmt.Errorf("invalid channel: %v", ch)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.continuous = true
+	a.channel = ch
+	return writeReg16(a.dev, adsRegConfig, a.config(ch, false))
+}
+
+// StopContinuous makes the chip back to single-shot mode, and it powers down between conversions
+func (a *ADS1115) StopContinuous() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.continuous = false
+	return writeReg16(a.dev, adsRegConfig, a.config(a.channel, true))
+}
+
+// SetComparator enables the comparator, ALERT will be asserted when the input exceeds the thresholds
+func (a *ADS1115) SetComparator(c *ADSComparator) error {
+	queue := map[int]uint16{1: 0, 2: 1, 4: 2}
+	q, ok := queue[c.Queue]
+	if !ok {
+		return fmt.Errorf("invalid comparator queue: %v, should be 1, 2 or 4", c.Queue)
+	}
+	comp := q
+	if c.Window {
+		comp |= adsCompWindow
+	}
+	if c.ActiveHigh {
+		comp |= adsCompPolHigh
+	}
+	if c.Latch {
+		comp |= adsCompLatch
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := writeReg16(a.dev, adsRegLoThresh, uint16(a.toRaw(c.Low))<<a.shift); err != nil {
+		return err
+	}
+	if err := writeReg16(a.dev, adsRegHiThresh, uint16(a.toRaw(c.High))<<a.shift); err != nil {
+		return err
+	}
+	a.comp = comp
+	return a.restart()
+}
+
+// SetConversionReady makes ALERT pulse once a conversion is completed,
+// it is an alternative to polling in continuous mode.
+func (a *ADS1115) SetConversionReady() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// the MSB of Hi_thresh = 1 and the MSB of Lo_thresh = 0 turn ALERT into the RDY pin
+	if err := writeReg16(a.dev, adsRegLoThresh, 0x0000); err != nil {
+		return err
+	}
+	if err := writeReg16(a.dev, adsRegHiThresh, 0x8000); err != nil {
+		return err
+	}
+	a.comp = 0
+	return a.restart()
+}
+
+// DisableComparator ...
+func (a *ADS1115) DisableComparator() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.comp = adsCompDisable
+	return a.restart()
+}
+
+// AttachAlert watches the ALERT pin which is connected to the data pin
+func (a *ADS1115) AttachAlert(pin uint8) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.alert = rpio.Pin(pin)
+	a.alert.Input()
+	if a.comp&adsCompPolHigh != 0 {
+		a.alert.PullDown()
+		a.alert.Detect(rpio.RiseEdge)
+	} else {
+		a.alert.PullUp()
+		a.alert.Detect(rpio.FallEdge)
+	}
+	a.alertReady = true
+}
+
+// Alerted returns true if ALERT was asserted since the last call
+func (a *ADS1115) Alerted() bool {
+	if !a.alertReady {
+		return false
+	}
+	return a.alert.EdgeDetected()
+}
+
+// Channel returns the channel as an analog input
+func (a *ADS1115) Channel(ch ADSChannel) AnalogInput {
+	return &adsInput{ads: a, ch: ch}
+}
+
+// Close ...
+func (a *ADS1115) Close() {
+	if a.alertReady {
+		a.alert.Detect(rpio.NoEdge)
+	}
+	a.dev.Close()
+}
+
+func (a *ADS1115) config(ch ADSChannel, single bool) uint16 {
+	cfg := uint16(ch)<<12 | uint16(a.gain)<<9 | a.rate<<5 | a.comp
+	if single {
+		cfg |= adsModeSingle
+	}
+	return cfg
+}
+
+// restart applies the new config if the chip is working in continuous mode,
+// the config of single-shot mode will be applied in the next Read.
+func (a *ADS1115) restart() error {
+	if !a.continuous {
+		return nil
+	}
+	return writeReg16(a.dev, adsRegConfig, a.config(a.channel, false))
+}
+
+func (a *ADS1115) readConversion() (int16, error) {
+	v, err := readReg16(a.dev, adsRegConversion)
+	if err != nil {
+		return 0, err
+	}
+	return int16(v) >> a.shift, nil
+}
+
+// convTime is the time of one conversion with 10% margin for the internal oscillator
+func (a *ADS1115) convTime() time.Duration {
+	sps := a.rates[a.rate]
+	return time.Second*11/time.Duration(sps*10) + 100*time.Microsecond
+}
+
+func (a *ADS1115) maxCode() float64 {
+	return float64(int(1) << (15 - a.shift))
+}
+
+func (a *ADS1115) toVolts(raw int16) float64 {
+	return float64(raw) * adsFullScale[a.gain] / a.maxCode()
+}
+
+func (a *ADS1115) toRaw(volts float64) int16 {
+	max := a.maxCode()
+	v := volts / adsFullScale[a.gain] * max
+	if v > max-1 {
+		v = max - 1
+	}
+	if v < -max {
+		v = -max
+	}
+	return int16(v)
+}
+
+type adsInput struct {
+	ads *ADS1115
+	ch  ADSChannel
+}
+
+func (i *adsInput) Volts() (float64, error) {
+	return i.ads.Volts(i.ch)
+}
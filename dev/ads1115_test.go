@@ -0,0 +1,139 @@
+package dev
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeI2C is an i2c device with 16-bit big-endian registers
+type fakeI2C struct {
+	regs   map[byte][]byte
+	writes [][]byte
+}
+
+func newFakeI2C() *fakeI2C {
+	return &fakeI2C{
+		regs: map[byte][]byte{},
+	}
+}
+
+func (f *fakeI2C) Read(buf []byte) error {
+	return nil
+}
+
+func (f *fakeI2C) Write(buf []byte) error {
+	f.writes = append(f.writes, append([]byte{}, buf...))
+	return nil
+}
+
+func (f *fakeI2C) ReadReg(reg byte, buf []byte) error {
+	copy(buf, f.regs[reg])
+	return nil
+}
+
+func (f *fakeI2C) WriteReg(reg byte, buf []byte) error {
+	f.regs[reg] = append([]byte{}, buf...)
+	f.writes = append(f.writes, append([]byte{reg}, buf...))
+	return nil
+}
+
+func (f *fakeI2C) Close() error {
+	return nil
+}
+
+type fakeAnalog float64
+
+func (f fakeAnalog) Volts() (float64, error) {
+	return float64(f), nil
+}
+
+func TestADS1115Read(t *testing.T) {
+	bus := newFakeI2C()
+	ads := newADS(bus, ads1115Rates, 0)
+	assert.NoError(t, ads.SetDataRate(860))
+
+	// the conversion register was written by the "chip" in advance,
+	// the OS bit in the config register was set once the driver started a conversion
+	bus.regs[adsRegConversion] = []byte{0x40, 0x00}
+	v, err := ads.Volts(ADSA1)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.024, v, 1e-6)
+
+	cfg := uint16(bus.regs[adsRegConfig][0])<<8 | uint16(bus.regs[adsRegConfig][1])
+	assert.Equal(t, uint16(adsOS), cfg&adsOS)
+	assert.Equal(t, uint16(ADSA1), cfg>>12&0x07)
+	assert.Equal(t, uint16(ADSGain2), cfg>>9&0x07)
+	assert.Equal(t, uint16(7), cfg>>5&0x07)
+	assert.Equal(t, uint16(adsCompDisable), cfg&0x1F)
+
+	assert.Error(t, ads.SetDataRate(100))
+}
+
+func TestADS1015Read(t *testing.T) {
+	bus := newFakeI2C()
+	ads := newADS(bus, ads1015Rates, 4)
+	assert.NoError(t, ads.SetGain(ADSGain1))
+
+	bus.regs[adsRegConversion] = []byte{0xF0, 0x00}
+	bus.regs[adsRegConfig] = []byte{0x80, 0x00}
+	raw, err := ads.Read(ADSDiff01)
+	assert.NoError(t, err)
+	assert.Equal(t, int16(-256), raw)
+	assert.InDelta(t, -0.512, ads.toVolts(raw), 1e-6)
+}
+
+func TestAnalogAdapters(t *testing.T) {
+	d := NewVoltageDivider(fakeAnalog(2.0), 30000, 10000)
+	v, err := d.Volts()
+	assert.NoError(t, err)
+	assert.InDelta(t, 8.0, v, 1e-6)
+
+	testCases := []struct {
+		desc     string
+		volts    float64
+		expected float64
+	}{
+		{
+			desc:     "dry",
+			volts:    2.8,
+			expected: 0,
+		},
+		{
+			desc:     "wet",
+			volts:    1.2,
+			expected: 100,
+		},
+		{
+			desc:     "half",
+			volts:    2.0,
+			expected: 50,
+		},
+		{
+			desc:     "drier than calibrated",
+			volts:    3.1,
+			expected: 0,
+		},
+	}
+	for _, test := range testCases {
+		s := NewSoilMoisture(fakeAnalog(test.volts), 2.8, 1.2)
+		p, err := s.Percent()
+		assert.NoError(t, err, test.desc)
+		assert.InDelta(t, test.expected, p, 1e-6, test.desc)
+	}
+
+	// the ldr has the same resistance as r10 at the half of vref
+	l := NewLDR(fakeAnalog(1.65), 3.3, 10000, 10000, 0.6)
+	lux, err := l.Lux()
+	assert.NoError(t, err)
+	assert.InDelta(t, 10, lux, 1e-6)
+
+	m := NewMQSensor(fakeAnalog(2.5), 5.0, 10000, 116.6, -2.769)
+	_, err = m.PPM()
+	assert.Error(t, err)
+	assert.NoError(t, m.Calibrate(3.6))
+	ppm, err := m.PPM()
+	assert.NoError(t, err)
+	assert.InDelta(t, 116.6*math.Pow(3.6, -2.769), ppm, 1e-6)
+}
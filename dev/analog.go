@@ -0,0 +1,192 @@
+/*
+Package dev ...
+
+The analog sensors are read through an ADC like ADS1115,
+and the adapters in this file turn the voltage into calibrated readings.
+*/
+package dev
+
+import (
+	"errors"
+	"math"
+)
+
+// AnalogInput is a source of voltage, like a channel of ADS1115
+type AnalogInput interface {
+	Volts() (float64, error)
+}
+
+// VoltageDivider measures a voltage higher than the range of the ADC,
+// e.g. the voltage of a battery.
+//
+//	v ---[ r1 ]---+---[ r2 ]--- gnd
+//	              |
+//	             adc
+type VoltageDivider struct {
+	in AnalogInput
+	r1 float64
+	r2 float64
+}
+
+// NewVoltageDivider ...
+func NewVoltageDivider(in AnalogInput, r1, r2 float64) *VoltageDivider {
+	return &VoltageDivider{
+		in: in,
+		r1: r1,
+		r2: r2,
+	}
+}
+
+// Volts returns the voltage before the divider
+func (d *VoltageDivider) Volts() (float64, error) {
+	v, err := d.in.Volts()
+	if err != nil {
+		return 0, err
+	}
+	return v * (d.r1 + d.r2) / d.r2, nil
+}
+
+// SoilMoisture converts the voltage of a soil moisture probe into percent.
+// dry is the voltage when the probe is in the air,
+// and wet is the voltage when it is in the water.
+type SoilMoisture struct {
+	in  AnalogInput
+	dry float64
+	wet float64
+}
+
+// NewSoilMoisture ...
+func NewSoilMoisture(in AnalogInput, dry, wet float64) *SoilMoisture {
+	return &SoilMoisture{
+		in:  in,
+		dry: dry,
+		wet: wet,
+	}
+}
+
+// Percent returns the moisture in [0, 100]
+func (s *SoilMoisture) Percent() (float64, error) {
+	if s.dry == s.wet {
+		return 0, errors.New("soil moisture isn't calibrated")
+	}
+	v, err := s.in.Volts()
+	if err != nil {
+		return 0, err
+	}
+	p := (s.dry - v) / (s.dry - s.wet) * 100
+	return math.Max(0, math.Min(100, p)), nil
+}
+
+// LDR estimates the illuminance from a photoresistor.
+// r10 is the resistance of the ldr at 10 lux, and gamma is the slope of its log(R)-log(lux) line,
+// both can be found in the datasheet, e.g. r10=10k and gamma=0.6 for GL5528.
+//
+//	vref ---[ ldr ]---+---[ fixed ]--- gnd
+//	                  |
+//	                 adc
+type LDR struct {
+	in     AnalogInput
+	vref   float64
+	fixedR float64
+	r10    float64
+	gamma  float64
+}
+
+// NewLDR ...
+func NewLDR(in AnalogInput, vref, fixedR, r10, gamma float64) *LDR {
+	return &LDR{
+		in:     in,
+		vref:   vref,
+		fixedR: fixedR,
+		r10:    r10,
+		gamma:  gamma,
+	}
+}
+
+// Lux returns the estimated illuminance in lux
+func (l *LDR) Lux() (float64, error) {
+	v, err := l.in.Volts()
+	if err != nil {
+		return 0, err
+	}
+	if v <= 0 {
+		return 0, nil
+	}
+	if v >= l.vref {
+		return math.Inf(1), nil
+	}
+	r := l.fixedR * (l.vref - v) / v
+	return 10 * math.Pow(l.r10/r, 1/l.gamma), nil
+}
+
+// MQSensor converts the output of a MQ-x gas sensor module into ppm.
+// The sensor curve from the datasheet is ppm = a * (Rs/R0)^b,
+// e.g. a=116.6, b=-2.769 for CO2 on MQ-135.
+//
+//	vc ---[ Rs ]---+---[ rl ]--- gnd
+//	               |
+//	              adc
+type MQSensor struct {
+	in AnalogInput
+	vc float64
+	rl float64
+	r0 float64
+	a  float64
+	b  float64
+}
+
+// NewMQSensor ...
+func NewMQSensor(in AnalogInput, vc, rl, a, b float64) *MQSensor {
+	return &MQSensor{
+		in: in,
+		vc: vc,
+		rl: rl,
+		a:  a,
+		b:  b,
+	}
+}
+
+// Calibrate calculates R0 in the clean air,
+// cleanAirRatio is Rs/R0 in the clean air from the datasheet, e.g. 3.6 for MQ-135.
+// A new sensor needs to burn in for 24 hours before calibrating.
+func (m *MQSensor) Calibrate(cleanAirRatio float64) error {
+	rs, err := m.rs()
+	if err != nil {
+		return err
+	}
+	m.r0 = rs / cleanAirRatio
+	return nil
+}
+
+// SetR0 sets R0 from a previous calibration
+func (m *MQSensor) SetR0(r0 float64) {
+	m.r0 = r0
+}
+
+// R0 ...
+func (m *MQSensor) R0() float64 {
+	return m.r0
+}
+
+// PPM returns the concentration of the gas
+func (m *MQSensor) PPM() (float64, error) {
+	if m.r0 <= 0 {
+		return 0, errors.New("mq sensor isn't calibrated")
+	}
+	rs, err := m.rs()
+	if err != nil {
+		return 0, err
+	}
+	return m.a * math.Pow(rs/m.r0, m.b), nil
+}
+
+func (m *MQSensor) rs() (float64, error) {
+	v, err := m.in.Volts()
+	if err != nil {
+		return 0, err
+	}
+	if v <= 0 {
+		return 0, errors.New("no output from mq sensor")
+	}
+	return m.rl * (m.vc - v) / v, nil
+}
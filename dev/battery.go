@@ -0,0 +1,253 @@
+/*
+Package dev ...
+
+Battery estimates the state of charge(SoC) of a battery pack from its voltage,
+and raises events when the battery becomes low or critical.
+The voltage can be read from any analog input,
+e.g. an INA219 on the power line, or a voltage divider on a channel of ADS1115.
+
+The SoC is looked up from the open-circuit voltage curve of the chemistry,
+so it reads lower than the truth when the motors are running,
+the readings are smoothed to avoid the events flapping with the load.
+*/
+package dev
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+)
+
+const (
+	// the number of readings for smoothing the voltage
+	batterySmoothing = 10
+	// the soc must rise above the threshold + batteryHysteresis to leave a low state
+	batteryHysteresis = 5.0
+)
+
+// Chemistry is the chemistry of a battery
+type Chemistry int
+
+// Chemistries
+const (
+	LiIon Chemistry = iota
+	LiPo
+	LiFePO4
+	NiMH
+	Alkaline
+	LeadAcid
+)
+
+// the open-circuit voltage of one cell at 0%, 10%, ..., 100% soc
+var ocvCurves = map[Chemistry][]float64{
+	LiIon:    {3.00, 3.45, 3.55, 3.62, 3.68, 3.74, 3.80, 3.87, 3.95, 4.05, 4.20},
+	LiPo:     {3.27, 3.69, 3.73, 3.75, 3.77, 3.79, 3.82, 3.87, 3.92, 4.03, 4.20},
+	LiFePO4:  {2.50, 3.00, 3.13, 3.20, 3.23, 3.25, 3.26, 3.28, 3.30, 3.33, 3.40},
+	NiMH:     {1.00, 1.12, 1.18, 1.21, 1.23, 1.25, 1.26, 1.28, 1.30, 1.33, 1.40},
+	Alkaline: {0.90, 1.05, 1.15, 1.22, 1.27, 1.31, 1.35, 1.40, 1.45, 1.50, 1.60},
+	LeadAcid: {1.75, 1.93, 1.96, 1.98, 2.01, 2.03, 2.05, 2.07, 2.08, 2.10, 2.12},
+}
+
+// BatteryState ...
+type BatteryState int
+
+// Battery states
+const (
+	BatteryNormal BatteryState = iota
+	BatteryLow
+	BatteryCritical
+)
+
+func (s BatteryState) String() string {
+	switch s {
+	case BatteryNormal:
+		return "normal"
+	case BatteryLow:
+		return "low"
+	case BatteryCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// BatteryEvent is raised when the state of the battery changed
+type BatteryEvent struct {
+	State BatteryState
+	Volts float64
+	SoC   float64
+	Time  time.Time
+}
+
+// Battery ...
+type Battery struct {
+	in       AnalogInput
+	curve    []float64
+	cells    int
+	low      float64
+	critical float64
+
+	mu      sync.Mutex
+	history *base.History
+	volts   float64
+	soc     float64
+	state   BatteryState
+	events  chan BatteryEvent
+	chQuit  chan bool
+}
+
+// NewBattery creates a battery pack with cells in series,
+// low and critical are the thresholds of soc in percent, e.g. 20 and 5.
+func NewBattery(in AnalogInput, chem Chemistry, cells int, low, critical float64) (*Battery, error) {
+	curve, ok := ocvCurves[chem]
+	if !ok {
+		return nil, fmt.Errorf("unknown chemistry: %v", chem)
+	}
+	if cells <= 0 {
+		return nil, errors.New("invalid number of cells")
+	}
+	if critical > low {
+		return nil, errors.New("the critical threshold must be lower than the low threshold")
+	}
+	return &Battery{
+		in:       in,
+		curve:    curve,
+		cells:    cells,
+		low:      low,
+		critical: critical,
+		history:  base.NewHistory(batterySmoothing),
+		soc:      100,
+		state:    BatteryNormal,
+		events:   make(chan BatteryEvent, chSize),
+	}, nil
+}
+
+// Start keeps updating the battery in background
+func (b *Battery) Start(interval time.Duration) {
+	b.chQuit = make(chan bool)
+	go func() {
+		for {
+			if _, err := b.Update(); err != nil {
+				log.Printf("[battery]failed to update, error: %v", err)
+			}
+			select {
+			case <-b.chQuit:
+				return
+			case <-time.After(interval):
+				// next reading
+			}
+		}
+	}()
+}
+
+// Stop ...
+func (b *Battery) Stop() {
+	if b.chQuit != nil {
+		close(b.chQuit)
+	}
+}
+
+// Events returns the channel of the battery events,
+// the events will be dropped if nobody reads them.
+func (b *Battery) Events() <-chan BatteryEvent {
+	return b.events
+}
+
+// Update reads the voltage and updates the soc and state,
+// it returns true if the state changed.
+func (b *Battery) Update() (bool, error) {
+	v, err := b.in.Volts()
+	if err != nil {
+		return false, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.history.Add(v)
+	avg, err := b.history.Avg()
+	if err != nil {
+		return false, err
+	}
+	b.volts = avg
+	b.soc = b.estimate(avg / float64(b.cells))
+
+	state := b.nextState(b.soc)
+	if state == b.state {
+		return false, nil
+	}
+	log.Printf("[battery]%v -> %v, %.2fV, %.0f%%", b.state, state, b.volts, b.soc)
+	b.state = state
+	select {
+	case b.events <- BatteryEvent{State: state, Volts: b.volts, SoC: b.soc, Time: time.Now()}:
+	default:
+		// nobody is listening
+	}
+	return true, nil
+}
+
+// State ...
+func (b *Battery) State() BatteryState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// SoC returns the state of charge in percent
+func (b *Battery) SoC() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.soc
+}
+
+// Volts returns the smoothed voltage of the pack
+func (b *Battery) Volts() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.volts
+}
+
+// estimate looks up the soc of a cell voltage by linear interpolation
+func (b *Battery) estimate(v float64) float64 {
+	n := len(b.curve) - 1
+	if v <= b.curve[0] {
+		return 0
+	}
+	if v >= b.curve[n] {
+		return 100
+	}
+	for i := 1; i <= n; i++ {
+		if v < b.curve[i] {
+			lo, hi := b.curve[i-1], b.curve[i]
+			return (float64(i-1) + (v-lo)/(hi-lo)) * 100 / float64(n)
+		}
+	}
+	return 100
+}
+
+func (b *Battery) nextState(soc float64) BatteryState {
+	switch b.state {
+	case BatteryCritical:
+		if soc > b.critical+batteryHysteresis {
+			break
+		}
+		return BatteryCritical
+	case BatteryLow:
+		if soc <= b.critical {
+			return BatteryCritical
+		}
+		if soc > b.low+batteryHysteresis {
+			break
+		}
+		return BatteryLow
+	}
+	switch {
+	case soc <= b.critical:
+		return BatteryCritical
+	case soc <= b.low:
+		return BatteryLow
+	}
+	return BatteryNormal
+}
@@ -0,0 +1,88 @@
+package dev
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeVolts struct {
+	v float64
+}
+
+func (f *fakeVolts) Volts() (float64, error) {
+	return f.v, nil
+}
+
+func TestBatterySoC(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		volts    float64
+		expected float64
+	}{
+		{
+			desc:     "full",
+			volts:    8.4,
+			expected: 100,
+		},
+		{
+			desc:     "empty",
+			volts:    5.8,
+			expected: 0,
+		},
+		{
+			desc:     "half",
+			volts:    7.48,
+			expected: 50,
+		},
+		{
+			desc:     "between points",
+			volts:    7.3,
+			expected: 35,
+		},
+	}
+	for _, test := range testCases {
+		b, err := NewBattery(fakeAnalog(test.volts), LiIon, 2, 20, 5)
+		assert.NoError(t, err)
+		_, err = b.Update()
+		assert.NoError(t, err, test.desc)
+		assert.InDelta(t, test.expected, b.SoC(), 1e-6, test.desc)
+	}
+}
+
+func TestBatteryState(t *testing.T) {
+	in := &fakeVolts{v: 3.87}
+	b, err := NewBattery(in, LiIon, 1, 20, 5)
+	assert.NoError(t, err)
+
+	update := func(v float64) {
+		in.v = v
+		// fill up the smoothing window
+		for i := 0; i < batterySmoothing; i++ {
+			_, err := b.Update()
+			assert.NoError(t, err)
+		}
+	}
+
+	update(3.87)
+	assert.Equal(t, BatteryNormal, b.State())
+
+	update(3.50)
+	assert.Equal(t, BatteryLow, b.State())
+	e := <-b.Events()
+	assert.Equal(t, BatteryLow, e.State)
+	assert.True(t, e.SoC <= 20)
+
+	// 22%, not enough to leave the low state
+	update(3.562)
+	assert.Equal(t, BatteryLow, b.State())
+
+	update(3.10)
+	assert.Equal(t, BatteryCritical, b.State())
+
+	update(3.74)
+	assert.Equal(t, BatteryNormal, b.State())
+
+	_, err = NewBattery(in, LiIon, 1, 5, 20)
+	assert.Error(t, err)
+}
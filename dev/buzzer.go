@@ -1,50 +1,254 @@
 /*
 Package dev ...
 
+There are two kinds of buzzers:
+  - active buzzer:	it has an oscillator inside and beeps at its own pitch when the i/o is high
+  - passive buzzer:	it needs a square wave to make a sound, the pitch is the frequency of the wave
+
+An active buzzer can only play the rhythm of a melody,
+while a passive buzzer on a pwm pin can play the tones.
+
 Connect to Pi:
- - vcc: any v3.3 pin
- - gnd: and gnd pin
- - i/o: any data pin
+  - vcc: any v3.3 pin
+  - gnd: and gnd pin
+  - i/o: any data pin, or a pwm pin(must be one of gpio 12, 13, 18, 19) for a passive buzzer
 */
 package dev
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
+	"github.com/shanghuiyang/rpi-devices/base"
 	"github.com/stianeikeland/go-rpio"
 )
 
+const (
+	// the pitch of a passive buzzer for On() and Beep()
+	defaultBuzzerFreq = 2000 // Hz
+	// the wave is generated with 64 cycles per period,
+	// which makes the pwm clock in range for the audible tones from 75Hz.
+	buzzerPWMCycle = 64
+	// the silence between two notes of a melody, it makes the repeated notes distinguishable
+	noteGap = 20 * time.Millisecond
+)
+
+// toneOutput makes the sound of a buzzer
+type toneOutput interface {
+	// Tone makes a sound in the frequency in Hz
+	Tone(freq float64)
+	Off()
+}
+
 // Buzzer ...
 type Buzzer struct {
-	pin rpio.Pin
+	out toneOutput
+
+	mu       sync.Mutex
+	playback *MelodyPlayback
 }
 
-// NewBuzzer ...
+// NewBuzzer creates an active buzzer
 func NewBuzzer(pin int8) *Buzzer {
-	b := &Buzzer{
-		pin: rpio.Pin(pin),
+	return newBuzzer(newPinToneOutput(uint8(pin)))
+}
+
+// NewPassiveBuzzer creates a passive buzzer on a pwm pin
+func NewPassiveBuzzer(pin uint8) *Buzzer {
+	return newBuzzer(newPWMToneOutput(pin))
+}
+
+// NewBuzzerFromConfig creates a buzzer from the config, pin is used if it isn't in the config
+func NewBuzzerFromConfig(cfg *base.BuzzerConfig, pin uint8) *Buzzer {
+	if cfg == nil {
+		return NewBuzzer(int8(pin))
+	}
+	if cfg.Pin != 0 {
+		pin = cfg.Pin
+	}
+	if cfg.Passive {
+		return NewPassiveBuzzer(pin)
+	}
+	return NewBuzzer(int8(pin))
+}
+
+func newBuzzer(out toneOutput) *Buzzer {
+	return &Buzzer{
+		out: out,
 	}
-	b.pin.Output()
-	return b
 }
 
 // On ...
 func (b *Buzzer) On() {
-	b.pin.High()
+	b.out.Tone(defaultBuzzerFreq)
 }
 
 // Off ...
 func (b *Buzzer) Off() {
-	b.pin.Low()
+	b.out.Off()
 }
 
 // Beep beeps [n] times with an interval in [interval] millisecond
 func (b *Buzzer) Beep(n int, interval int) {
 	d := time.Duration(interval) * time.Millisecond
 	for i := 0; i < n; i++ {
-		b.pin.High()
+		b.On()
 		time.Sleep(d)
-		b.pin.Low()
+		b.Off()
 		time.Sleep(d)
 	}
 }
+
+// Tone makes a sound in the frequency in Hz for the duration, and returns after it's done.
+// An active buzzer sounds at its own pitch, and freq <= 0 means silence.
+func (b *Buzzer) Tone(freq float64, duration time.Duration) {
+	if freq > 0 {
+		b.out.Tone(freq)
+	}
+	time.Sleep(duration)
+	b.out.Off()
+}
+
+// Play plays the melody in background, the current playback will be cancelled.
+func (b *Buzzer) Play(m *Melody) *MelodyPlayback {
+	b.Stop()
+
+	p := &MelodyPlayback{
+		chQuit: make(chan bool),
+		done:   make(chan bool),
+	}
+	b.mu.Lock()
+	b.playback = p
+	b.mu.Unlock()
+
+	go func() {
+		defer close(p.done)
+		defer b.out.Off()
+		b.play(m, p.chQuit)
+	}()
+	return p
+}
+
+// Alert plays a named alert in Alerts in background
+func (b *Buzzer) Alert(name string) (*MelodyPlayback, error) {
+	s, ok := Alerts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown alert: %v", name)
+	}
+	m, err := ParseRTTTL(s)
+	if err != nil {
+		return nil, err
+	}
+	return b.Play(m), nil
+}
+
+// Stop stops the current playback
+func (b *Buzzer) Stop() {
+	b.mu.Lock()
+	p := b.playback
+	b.playback = nil
+	b.mu.Unlock()
+	if p != nil {
+		p.Cancel()
+	}
+}
+
+func (b *Buzzer) play(m *Melody, chQuit chan bool) {
+	for _, n := range m.Notes {
+		gap := noteGap
+		if n.Duration <= 2*gap {
+			gap = n.Duration / 4
+		}
+		if n.Freq > 0 {
+			b.out.Tone(n.Freq)
+		}
+		select {
+		case <-chQuit:
+			return
+		case <-time.After(n.Duration - gap):
+			// the end of the note
+		}
+		b.out.Off()
+		select {
+		case <-chQuit:
+			return
+		case <-time.After(gap):
+			// next note
+		}
+	}
+}
+
+// MelodyPlayback is a playback of melody in background
+type MelodyPlayback struct {
+	chQuit chan bool
+	done   chan bool
+	once   sync.Once
+}
+
+// Cancel cancels the playback and waits for it quit
+func (p *MelodyPlayback) Cancel() {
+	p.once.Do(func() {
+		close(p.chQuit)
+	})
+	<-p.done
+}
+
+// Wait waits for the playback done
+func (p *MelodyPlayback) Wait() {
+	<-p.done
+}
+
+// Done returns a channel which will be closed when the playback is done
+func (p *MelodyPlayback) Done() <-chan bool {
+	return p.done
+}
+
+// pinToneOutput drives an active buzzer with a data pin
+type pinToneOutput struct {
+	pin rpio.Pin
+}
+
+func newPinToneOutput(pin uint8) *pinToneOutput {
+	o := &pinToneOutput{
+		pin: rpio.Pin(pin),
+	}
+	o.pin.Output()
+	return o
+}
+
+func (o *pinToneOutput) Tone(freq float64) {
+	o.pin.High()
+}
+
+func (o *pinToneOutput) Off() {
+	o.pin.Low()
+}
+
+// pwmToneOutput drives a passive buzzer with the hardware pwm of pi
+type pwmToneOutput struct {
+	pin   rpio.Pin
+	scale float64
+}
+
+func newPWMToneOutput(pin uint8) *pwmToneOutput {
+	o := &pwmToneOutput{
+		pin:   rpio.Pin(pin),
+		scale: 1,
+	}
+	if base.GetRpiModel() == base.Rpi4 {
+		o.scale = float64(rpi3PWMSource) / rpi4PWMSource
+	}
+	o.pin.Pwm()
+	o.pin.DutyCycle(0, buzzerPWMCycle)
+	return o
+}
+
+func (o *pwmToneOutput) Tone(freq float64) {
+	o.pin.Freq(int(freq * buzzerPWMCycle * o.scale))
+	o.pin.DutyCycle(buzzerPWMCycle/2, buzzerPWMCycle)
+}
+
+func (o *pwmToneOutput) Off() {
+	o.pin.DutyCycle(0, buzzerPWMCycle)
+}
@@ -0,0 +1,90 @@
+package dev
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeToneOutput struct {
+	mu    sync.Mutex
+	tones []float64
+	on    bool
+}
+
+func (f *fakeToneOutput) Tone(freq float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tones = append(f.tones, freq)
+	f.on = true
+}
+
+func (f *fakeToneOutput) Off() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.on = false
+}
+
+func (f *fakeToneOutput) state() ([]float64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]float64{}, f.tones...), f.on
+}
+
+func TestBuzzerPlay(t *testing.T) {
+	out := &fakeToneOutput{}
+	b := newBuzzer(out)
+	m := &Melody{
+		Notes: []Note{
+			{Freq: 440, Duration: 20 * time.Millisecond},
+			{Freq: 0, Duration: 20 * time.Millisecond},
+			{Freq: 880, Duration: 20 * time.Millisecond},
+		},
+	}
+	b.Play(m).Wait()
+	tones, on := out.state()
+	assert.Equal(t, []float64{440, 880}, tones)
+	assert.False(t, on)
+}
+
+func TestBuzzerCancel(t *testing.T) {
+	out := &fakeToneOutput{}
+	b := newBuzzer(out)
+	m := &Melody{
+		Notes: []Note{
+			{Freq: 440, Duration: time.Second},
+			{Freq: 880, Duration: time.Second},
+		},
+	}
+	p := b.Play(m)
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	p.Cancel()
+	assert.True(t, time.Since(start) < 100*time.Millisecond)
+	tones, on := out.state()
+	assert.Equal(t, []float64{440}, tones)
+	assert.False(t, on)
+
+	// a new playback cancels the current one
+	p1 := b.Play(m)
+	p2 := b.Play(m)
+	select {
+	case <-p1.Done():
+	default:
+		t.Error("the first playback wasn't cancelled")
+	}
+	b.Stop()
+	p2.Wait()
+}
+
+func TestBuzzerAlert(t *testing.T) {
+	b := newBuzzer(&fakeToneOutput{})
+	_, err := b.Alert("nothing")
+	assert.Error(t, err)
+
+	p, err := b.Alert(AlertSuccess)
+	assert.NoError(t, err)
+	p.Cancel()
+}
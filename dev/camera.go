@@ -1,39 +1,292 @@
+/*
+Package dev ...
+
+Camera is the common interface of the cameras taking photos in jpeg, there are the backends:
+  - MotionCamera:	takes the snapshot by the http api of the motion service
+  - StillCamera:	runs libcamera-still or raspistill for the camera module on the csi port
+  - V4L2Camera:		captures a frame in mjpeg from a v4l2 device, like an usb webcam
+  - DirCamera:		returns the jpeg files in a directory one by one, it's for the tests
+
+NewCameraFromConfig creates one of them from the config, so the apps don't depend on a specific backend.
+*/
 package dev
 
 import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
 )
 
 const (
-	imageFile = "/var/lib/motion/lastsnap.jpg"
+	defaultMotionURL   = "http://localhost:8088/0/action/snapshot"
+	defaultMotionFile  = "/var/lib/motion/lastsnap.jpg"
+	defaultV4L2Device  = "/dev/video0"
+	defaultPhotoWidth  = 1280
+	defaultPhotoHeight = 720
+
+	// the time waiting for a fresh snapshot of motion
+	motionTimeout  = 5 * time.Second
+	motionInterval = 100 * time.Millisecond
 )
 
+// Photo is a photo in jpeg with the metadata of capturing
+type Photo struct {
+	Data   []byte
+	Time   time.Time // when it was taken
+	Width  int
+	Height int
+	Source string // the backend taking it, e.g. "motion", "libcamera-still", "/dev/video0"
+}
+
 // Camera ...
-type Camera struct {
-	// steering *SG90
+type Camera interface {
+	// TakePhoto takes a new photo, it never returns a photo taken before
+	TakePhoto() (*Photo, error)
+}
+
+// NewCameraFromConfig creates a camera from the config, nil means a camera of motion with the default settings
+func NewCameraFromConfig(cfg *base.CameraConfig) (Camera, error) {
+	if cfg == nil {
+		cfg = &base.CameraConfig{}
+	}
+	switch cfg.Type {
+	case "", "motion":
+		return NewMotionCamera(cfg.URL, cfg.File), nil
+	case "libcamera", "raspistill":
+		return NewStillCamera(cfg.Type, cfg.Width, cfg.Height), nil
+	case "v4l2":
+		return NewV4L2Camera(cfg.Device, cfg.Width, cfg.Height), nil
+	case "dir":
+		cam, err := NewDirCamera(cfg.Dir)
+		if err != nil {
+			// don't wrap a nil *DirCamera in a non-nil Camera
+			return nil, err
+		}
+		return cam, nil
+	}
+	return nil, fmt.Errorf("invalid camera type: %v", cfg.Type)
+}
+
+// NewCamera creates a camera of motion with the default settings
+func NewCamera() Camera {
+	return NewMotionCamera("", "")
+}
+
+// Save saves the photo to the file
+func (p *Photo) Save(file string) error {
+	return ioutil.WriteFile(file, p.Data, 0644)
+}
+
+// SaveTemp saves the photo to a new temp file, the caller should remove it after using
+func (p *Photo) SaveTemp() (string, error) {
+	f, err := ioutil.TempFile("", "photo-*.jpg")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(p.Data); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// newPhoto checks the jpeg and reads its size
+func newPhoto(data []byte, t time.Time, source string) (*Photo, error) {
+	if !completeJPEG(data) {
+		return nil, fmt.Errorf("incomplete jpeg from %v", source)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid jpeg from %v, error: %v", source, err)
+	}
+	return &Photo{
+		Data:   data,
+		Time:   t,
+		Width:  cfg.Width,
+		Height: cfg.Height,
+		Source: source,
+	}, nil
 }
 
-// NewCamera ...
-func NewCamera() *Camera {
-	return &Camera{}
+// completeJPEG checks the SOI and EOI markers, a file being written doesn't have the EOI
+func completeJPEG(data []byte) bool {
+	n := len(data)
+	return n > 4 && data[0] == 0xFF && data[1] == 0xD8 && data[n-2] == 0xFF && data[n-1] == 0xD9
 }
 
-// TakePhoto take a photo using motion service.
-// in default, the created photo file will be in folder: /var/lib/motion
-// you can change the directory in the config of motion.
-// the config file is in /etc/motion/motion.conf
-// and you need to change the 'snapshot_filename' to 'lastsnap.jpg'
-// after changing, this will be look like:
+// MotionCamera takes photos by the motion service,
+// the snapshot file is the 'snapshot_filename' in the target_dir of /etc/motion/motion.conf,
+// and it should be a fixed name or the link to the last snapshot, like lastsnap.jpg:
 // -----------------------------------------
 // target_dir /var/lib/motion
-// snapshot_filename lastsnap.jpg
+// snapshot_filename lastsnap
 // -----------------------------------------
-func (c *Camera) TakePhoto() (string, error) {
-	// curl -s -o /dev/null http://localhost:8088/0/action/snapshot
-	cmd := exec.Command("curl", "-s", "-o", "/dev/null", "http://localhost:8088/0/action/snapshot")
-	_, err := cmd.CombinedOutput()
+type MotionCamera struct {
+	url     string
+	file    string
+	timeout time.Duration
+	get     func(url string) error
+}
+
+// NewMotionCamera creates a camera with the url of the snapshot action and the snapshot file,
+// the empty ones mean http://localhost:8088/0/action/snapshot and /var/lib/motion/lastsnap.jpg.
+func NewMotionCamera(url, file string) *MotionCamera {
+	if url == "" {
+		url = defaultMotionURL
+	}
+	if file == "" {
+		file = defaultMotionFile
+	}
+	return &MotionCamera{
+		url:     url,
+		file:    file,
+		timeout: motionTimeout,
+		get:     httpGet,
+	}
+}
+
+// TakePhoto triggers a snapshot, and waits for motion refreshing the snapshot file
+func (c *MotionCamera) TakePhoto() (*Photo, error) {
+	var last time.Time
+	if fi, err := os.Stat(c.file); err == nil {
+		last = fi.ModTime()
+	}
+	if err := c.get(c.url); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(c.timeout)
+	for time.Now().Before(deadline) {
+		fi, err := os.Stat(c.file)
+		if err == nil && fi.ModTime().After(last) {
+			data, err := ioutil.ReadFile(c.file)
+			if err == nil && completeJPEG(data) {
+				return newPhoto(data, fi.ModTime(), "motion")
+			}
+		}
+		time.Sleep(motionInterval)
+	}
+	return nil, fmt.Errorf("timeout waiting for the snapshot: %v", c.file)
+}
+
+func httpGet(url string) error {
+	client := &http.Client{Timeout: motionTimeout}
+	resp, err := client.Get(url)
 	if err != nil {
-		return "", err
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get %v, status: %v", url, resp.Status)
+	}
+	return nil
+}
+
+// StillCamera takes photos by libcamera-still on the new os, or raspistill on the legacy os
+type StillCamera struct {
+	cmd    string
+	width  int
+	height int
+	run    func(name string, args ...string) ([]byte, error)
+}
+
+// NewStillCamera creates a camera with the command, "libcamera" or "raspistill",
+// and the size of the photos, 0 means 1280x720.
+func NewStillCamera(cmd string, width, height int) *StillCamera {
+	return newStillCamera(cmd, width, height, func(name string, args ...string) ([]byte, error) {
+		var stderr bytes.Buffer
+		c := exec.Command(name, args...)
+		c.Stderr = &stderr
+		out, err := c.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%v, output: %v", err, strings.TrimSpace(stderr.String()))
+		}
+		return out, nil
+	})
+}
+
+func newStillCamera(cmd string, width, height int, run func(name string, args ...string) ([]byte, error)) *StillCamera {
+	if width == 0 || height == 0 {
+		width, height = defaultPhotoWidth, defaultPhotoHeight
+	}
+	return &StillCamera{
+		cmd:    cmd,
+		width:  width,
+		height: height,
+		run:    run,
+	}
+}
+
+// TakePhoto ...
+func (c *StillCamera) TakePhoto() (*Photo, error) {
+	name, args := c.command()
+	t := time.Now()
+	data, err := c.run(name, args...)
+	if err != nil {
+		return nil, err
+	}
+	return newPhoto(data, t, name)
+}
+
+// command returns the command writing a jpeg to stdout without preview
+func (c *StillCamera) command() (string, []string) {
+	w, h := fmt.Sprint(c.width), fmt.Sprint(c.height)
+	if c.cmd == "raspistill" {
+		return "raspistill", []string{"-n", "-t", "1000", "-w", w, "-h", h, "-o", "-"}
+	}
+	return "libcamera-still", []string{"-n", "-t", "1000", "--width", w, "--height", h, "-e", "jpg", "-o", "-"}
+}
+
+// DirCamera returns the jpeg files in the directory in the order of names, and starts over after the last one
+type DirCamera struct {
+	dir   string
+	mu    sync.Mutex
+	files []string
+	next  int
+}
+
+// NewDirCamera ...
+func NewDirCamera(dir string) (*DirCamera, error) {
+	var files []string
+	for _, pattern := range []string{"*.jpg", "*.jpeg", "*.JPG"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no jpeg files in %v", dir)
+	}
+	sort.Strings(files)
+	return &DirCamera{
+		dir:   dir,
+		files: files,
+	}, nil
+}
+
+// TakePhoto ...
+func (c *DirCamera) TakePhoto() (*Photo, error) {
+	c.mu.Lock()
+	file := c.files[c.next]
+	c.next = (c.next + 1) % len(c.files)
+	c.mu.Unlock()
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
 	}
-	return imageFile, nil
+	return newPhoto(data, time.Now(), file)
 }
@@ -0,0 +1,132 @@
+package dev
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/stretchr/testify/assert"
+)
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)), nil)
+	assert.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestNewPhoto(t *testing.T) {
+	data := testJPEG(t, 32, 24)
+	now := time.Now()
+	p, err := newPhoto(data, now, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, 32, p.Width)
+	assert.Equal(t, 24, p.Height)
+	assert.Equal(t, now, p.Time)
+	assert.Equal(t, "test", p.Source)
+
+	// being written
+	_, err = newPhoto(data[:len(data)/2], now, "test")
+	assert.Error(t, err)
+	_, err = newPhoto([]byte{0xFF, 0xD8, 0x00, 0x00, 0xFF, 0xD9}, now, "test")
+	assert.Error(t, err)
+
+	file, err := p.SaveTemp()
+	assert.NoError(t, err)
+	defer os.Remove(file)
+	saved, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, data, saved)
+}
+
+func TestMotionCamera(t *testing.T) {
+	dir, err := ioutil.TempDir("", "motion")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "lastsnap.jpg")
+	old := testJPEG(t, 8, 8)
+	assert.NoError(t, ioutil.WriteFile(file, old, 0644))
+	past := time.Now().Add(-time.Minute)
+	assert.NoError(t, os.Chtimes(file, past, past))
+
+	c := NewMotionCamera("http://motion/snapshot", file)
+	var urls []string
+	fresh := testJPEG(t, 16, 8)
+	c.get = func(url string) error {
+		urls = append(urls, url)
+		// motion writes the snapshot a bit later
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			ioutil.WriteFile(file, fresh, 0644)
+		}()
+		return nil
+	}
+	p, err := c.TakePhoto()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://motion/snapshot"}, urls)
+	assert.Equal(t, fresh, p.Data)
+	assert.Equal(t, 16, p.Width)
+
+	// the snapshot isn't refreshed
+	c.timeout = 200 * time.Millisecond
+	c.get = func(url string) error { return nil }
+	_, err = c.TakePhoto()
+	assert.Error(t, err)
+}
+
+func TestStillCamera(t *testing.T) {
+	data := testJPEG(t, 8, 8)
+	var cmds [][]string
+	run := func(name string, args ...string) ([]byte, error) {
+		cmds = append(cmds, append([]string{name}, args...))
+		return data, nil
+	}
+
+	p, err := newStillCamera("libcamera", 640, 480, run).TakePhoto()
+	assert.NoError(t, err)
+	assert.Equal(t, "libcamera-still", p.Source)
+	_, err = newStillCamera("raspistill", 0, 0, run).TakePhoto()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"libcamera-still", "-n", "-t", "1000", "--width", "640", "--height", "480", "-e", "jpg", "-o", "-"},
+		{"raspistill", "-n", "-t", "1000", "-w", "1280", "-h", "720", "-o", "-"},
+	}, cmds)
+
+	_, err = newStillCamera("libcamera", 0, 0, func(name string, args ...string) ([]byte, error) {
+		return []byte("no camera"), nil
+	}).TakePhoto()
+	assert.Error(t, err)
+}
+
+func TestDirCamera(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photos")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cam, err := NewCameraFromConfig(&base.CameraConfig{Type: "dir", Dir: dir})
+	assert.Error(t, err)
+	assert.True(t, cam == nil)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.jpg"), testJPEG(t, 2, 2), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.jpg"), testJPEG(t, 1, 1), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c.txt"), []byte("c"), 0644))
+	cam, err = NewCameraFromConfig(&base.CameraConfig{Type: "dir", Dir: dir})
+	assert.NoError(t, err)
+
+	var widths []int
+	for i := 0; i < 3; i++ {
+		p, err := cam.TakePhoto()
+		assert.NoError(t, err)
+		widths = append(widths, p.Width)
+	}
+	assert.Equal(t, []int{1, 2, 1}, widths)
+
+	_, err = NewCameraFromConfig(&base.CameraConfig{Type: "webcam"})
+	assert.Error(t, err)
+}
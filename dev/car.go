@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
+	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -60,8 +63,39 @@ const (
 
 	speechdrivingon  CarOp = "speechdrivingon"
 	speechdrivingoff CarOp = "speechdrivingoff"
+
+	estop CarOp = "estop"
+	reset CarOp = "reset"
+)
+
+// the modes of the car
+const (
+	// ModeIdle is the mode the car stays still and waits for the ops
+	ModeIdle CarMode = iota
+	// ModeManual is the mode the car is driven by hand
+	ModeManual
+	ModeSelfDriving
+	ModeTracking
+	ModeSpeech
+	// ModeEStop is the mode after an emergency stop, the car refuses to move until it's reset
+	ModeEStop
 )
 
+// ErrCarStopped is returned by the ops after the car was stopped
+var ErrCarStopped = errors.New("the car was stopped")
+
+// carTransitions are the allowed transitions between the modes,
+// the autonomous modes have to be turned off or stopped before driving by hand,
+// and only the reset can bring the car out of the e-stop.
+var carTransitions = map[CarMode][]CarMode{
+	ModeIdle:        {ModeManual, ModeSelfDriving, ModeTracking, ModeSpeech, ModeEStop},
+	ModeManual:      {ModeIdle, ModeSelfDriving, ModeTracking, ModeSpeech, ModeEStop},
+	ModeSelfDriving: {ModeIdle, ModeTracking, ModeSpeech, ModeEStop},
+	ModeTracking:    {ModeIdle, ModeSelfDriving, ModeSpeech, ModeEStop},
+	ModeSpeech:      {ModeIdle, ModeSelfDriving, ModeTracking, ModeEStop},
+	ModeEStop:       {ModeIdle},
+}
+
 var (
 	scanningAngles  = []int{-90, -75, -60, -45, -30, 30, 45, 60, 75, 90}
 	turnAngleCounts = map[int]int{
@@ -79,6 +113,23 @@ var (
 	aheadAngles = []int{0, -15, 0, 15}
 )
 
+const (
+	// stop turning when the imu says the turned angle is close enough to the target
+	turnTolerance = 3.0
+	turnTimeout   = 5 * time.Second
+	// the car will stop when it was tilted more than maxTiltAngle in degree
+	maxTiltAngle = 30.0
+
+	// the speed of turning the servo by hand in degree/s
+	servoSpeed = 180
+	// the angle of turning left or right by hand with the motion controller
+	manualTurnAngle = 15
+
+	normalSpeed = 30
+	// the speed is limited when the battery is low
+	lowBatterySpeed = 20
+)
+
 var (
 	// the hsv of a tennis
 	lh = float64(33)
@@ -92,40 +143,105 @@ var (
 type (
 	// CarOp ...
 	CarOp string
+	// CarMode ...
+	CarMode int
 	// Option ...
 	Option func(c *Car)
 )
 
+// CarEngine drives the car, like L298N
+type CarEngine interface {
+	Forward()
+	Backward()
+	Left()
+	Right()
+	Stop()
+	Speed(s uint32)
+}
+
+// CarServo turns the distance meter of the car, like SG90
+type CarServo interface {
+	Roll(angle int)
+	Move(angle, speed float64, easing Easing)
+}
+
+// DistanceMeter measures the distance in cm, like US100
+type DistanceMeter interface {
+	Dist() float64
+}
+
+// CollisionDetector detects the collisions, like CollisionSwitch
+type CollisionDetector interface {
+	Collided() bool
+}
+
+func (m CarMode) String() string {
+	switch m {
+	case ModeIdle:
+		return "idle"
+	case ModeManual:
+		return "manual"
+	case ModeSelfDriving:
+		return "self-driving"
+	case ModeTracking:
+		return "self-tracking"
+	case ModeSpeech:
+		return "speech-driving"
+	case ModeEStop:
+		return "e-stop"
+	}
+	return fmt.Sprintf("unknown(%d)", int(m))
+}
+
+// autonomous returns true if the car drives itself in the mode
+func (m CarMode) autonomous() bool {
+	return m == ModeSelfDriving || m == ModeTracking || m == ModeSpeech
+}
+
+// CarEvent is raised when the mode of the car changed
+type CarEvent struct {
+	From   CarMode
+	To     CarMode
+	Reason string
+	Time   time.Time
+}
+
+// carCmd is an op sent to the worker, gen is the generation of the mode when the op was accepted
+type carCmd struct {
+	op  CarOp
+	gen int
+}
+
 // WithEngine ...
-func WithEngine(engine *L298N) Option {
+func WithEngine(engine CarEngine) Option {
 	return func(c *Car) {
 		c.engine = engine
 	}
 }
 
 // WithServo ...
-func WithServo(servo *SG90) Option {
+func WithServo(servo CarServo) Option {
 	return func(c *Car) {
 		c.servo = servo
 	}
 }
 
 // WithUlt ...
-func WithUlt(ult *US100) Option {
+func WithUlt(ult DistanceMeter) Option {
 	return func(c *Car) {
 		c.ult = ult
 	}
 }
 
 // WithEncoder ...
-func WithEncoder(e *Encoder) Option {
+func WithEncoder(e WheelEncoder) Option {
 	return func(c *Car) {
 		c.encoder = e
 	}
 }
 
 // WithCSwitchs ...
-func WithCSwitchs(cswitchs []*CollisionSwitch) Option {
+func WithCSwitchs(cswitchs []CollisionDetector) Option {
 	return func(c *Car) {
 		c.cswitchs = cswitchs
 	}
@@ -138,6 +254,14 @@ func WithHorn(horn *Buzzer) Option {
 	}
 }
 
+// WithAlerts sets the alerts played by the horn for the events "beep" and "lowbattery",
+// e.g. {"beep": "doorbell"}, the horn beeps for an event without an alert.
+func WithAlerts(alerts map[string]string) Option {
+	return func(c *Car) {
+		c.alerts = alerts
+	}
+}
+
 // WithLed ...
 func WithLed(led *Led) Option {
 	return func(c *Car) {
@@ -153,42 +277,83 @@ func WithLight(light *Led) Option {
 }
 
 // WithCamera ...
-func WithCamera(cam *Camera) Option {
+func WithCamera(cam Camera) Option {
 	return func(c *Car) {
 		c.camera = cam
 	}
 }
 
+// WithMotion turns and drives the car by the motion controller,
+// instead of the timed pulses and the lookup of the turning angles.
+func WithMotion(m *MotionController) Option {
+	return func(c *Car) {
+		c.motion = m
+	}
+}
+
+// WithClock runs the car on the clock instead of the wall clock
+func WithClock(clock Clock) Option {
+	return func(c *Car) {
+		c.clock = clock
+	}
+}
+
+// WithIMU ...
+func WithIMU(imu *IMU) Option {
+	return func(c *Car) {
+		c.imu = imu
+	}
+}
+
+// WithBattery ...
+func WithBattery(battery *Battery) Option {
+	return func(c *Car) {
+		c.battery = battery
+	}
+}
+
 // Car ...
 type Car struct {
-	engine   *L298N
-	servo    *SG90
-	ult      *US100
-	encoder  *Encoder
-	cswitchs []*CollisionSwitch
+	engine   CarEngine
+	servo    CarServo
+	ult      DistanceMeter
+	encoder  WheelEncoder
+	cswitchs []CollisionDetector
 	horn     *Buzzer
+	alerts   map[string]string
 	led      *Led
+	leds     *LedController
 	light    *Led
-	camera   *Camera
-
-	asr     *speech.ASR
-	tts     *speech.TTS
-	imgr    *recognizer.Recognizer
-	tracker *cv.Tracker
-
-	servoAngle    int
-	selfdriving   bool
-	speechdriving bool
-	selftracking  bool
-	chOp          chan CarOp
+	camera   Camera
+	imu      *IMU
+	battery  *Battery
+	motion   *MotionController
+	clock    Clock
+
+	speechOnce sync.Once
+	asr        *speech.ASR
+	tts        *speech.TTS
+	imgr       *recognizer.Recognizer
+
+	// mu guards the fields below, the loops of a mode run until gen changed
+	mu         sync.Mutex
+	mode       CarMode
+	gen        int
+	stopped    bool
+	servoAngle int
+	events     chan CarEvent
+	chOp       chan carCmd
+	chQuit     chan bool // closed by Stop to end the watchers
 }
 
 // NewCar ...
 func NewCar(opts ...Option) *Car {
 	car := &Car{
-		servoAngle:  0,
-		selfdriving: false,
-		chOp:        make(chan CarOp, chSize),
+		mode:   ModeIdle,
+		clock:  realClock{},
+		events: make(chan CarEvent, chSize),
+		chOp:   make(chan carCmd, chSize),
+		chQuit: make(chan bool),
 	}
 	for _, opt := range opts {
 		opt(car)
@@ -199,40 +364,268 @@ func NewCar(opts ...Option) *Car {
 // Start ...
 func (c *Car) Start() error {
 	go c.start()
-	go c.servo.Roll(0)
-	go c.blink()
+	if c.servo != nil {
+		go c.servo.Roll(0)
+	}
+	if c.led != nil {
+		c.leds = NewLedController(c.led)
+		c.showLed("idle", BlinkPattern(White, time.Second, time.Second), 0)
+	}
+	if c.imu != nil {
+		go c.guard()
+	}
+	if c.battery != nil {
+		go c.watchBattery()
+	}
 	return nil
 }
 
-// Do ...
-func (c *Car) Do(op CarOp) {
-	c.chOp <- op
+// Do checks the op against the current mode and runs it in background,
+// it returns an error if the op isn't allowed in the mode, or the car was stopped.
+// It's safe to call Do from multiple goroutines.
+func (c *Car) Do(op CarOp) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return ErrCarStopped
+	}
+	to, err := c.nextMode(op)
+	if err != nil {
+		log.Printf("[car]refused %v, error: %v", op, err)
+		return err
+	}
+	// only Do sends to chOp while holding the lock, so the send never blocks
+	if len(c.chOp) == cap(c.chOp) {
+		return errors.New("too many pending ops")
+	}
+	if to != c.mode {
+		c.transit(to, string(op))
+	}
+	c.chOp <- carCmd{op: op, gen: c.gen}
+	return nil
 }
 
-// Stop ...
+// Stop stops the car and the loops of the current mode, the ops are refused after Stop.
 func (c *Car) Stop() error {
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return nil
+	}
+	c.stopped = true
+	c.gen++
 	close(c.chOp)
-	c.engine.Stop()
+	close(c.chQuit)
+	c.mu.Unlock()
+
+	if c.motion != nil {
+		c.motion.Cancel()
+	}
+	if c.engine != nil {
+		c.engine.Stop()
+	}
+	if c.leds != nil {
+		c.leds.Close()
+	}
 	return nil
 }
 
+// Mode ...
+func (c *Car) Mode() CarMode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mode
+}
+
+// Events returns the channel of the mode transitions,
+// the events will be dropped if nobody reads them.
+func (c *Car) Events() <-chan CarEvent {
+	return c.events
+}
+
 // GetState ...
 func (c *Car) GetState() (selfDriving, selfTracking, speechDriving bool) {
-	return c.selfdriving, c.selftracking, c.speechdriving
+	m := c.Mode()
+	return m == ModeSelfDriving, m == ModeTracking, m == ModeSpeech
+}
+
+// GetBattery returns the state and the soc in percent of the battery,
+// ok is false if the car doesn't have a battery monitor.
+func (c *Car) GetBattery() (state BatteryState, soc float64, ok bool) {
+	if c.battery == nil {
+		return BatteryNormal, 0, false
+	}
+	return c.battery.State(), c.battery.SoC(), true
+}
+
+// nextMode returns the mode the car goes into by the op, it must be called with the lock held
+func (c *Car) nextMode(op CarOp) (CarMode, error) {
+	if name, _, ok := parseOp(op); ok {
+		if c.motion == nil {
+			return c.mode, fmt.Errorf("can't %v without the motion controller", op)
+		}
+		op = name
+	} else if op == turn {
+		return c.mode, errors.New("turn needs an angle, e.g. turn 90")
+	}
+
+	var to CarMode
+	switch op {
+	case forward, backward, left, right, turn:
+		to = ModeManual
+	case stop:
+		// stop always halts the car, it ends the autonomous modes too
+		to = ModeIdle
+		if c.mode == ModeEStop {
+			to = ModeEStop
+		}
+	case beep, lighton, lightoff:
+		to = c.mode
+	case servoleft, servoright, servoahead:
+		if c.mode != ModeIdle && c.mode != ModeManual {
+			return c.mode, fmt.Errorf("can't roll the servo in %v", c.mode)
+		}
+		to = c.mode
+	case selfdrivingon, selftrackingon, speechdrivingon:
+		to = map[CarOp]CarMode{
+			selfdrivingon:   ModeSelfDriving,
+			selftrackingon:  ModeTracking,
+			speechdrivingon: ModeSpeech,
+		}[op]
+		if c.mode == to {
+			return c.mode, fmt.Errorf("already in %v", to)
+		}
+		if to != ModeSpeech && c.batteryLow() {
+			go c.beep()
+			return c.mode, fmt.Errorf("battery is low, refuse to %v", to)
+		}
+	case selfdrivingoff, selftrackingoff, speechdrivingoff:
+		from := map[CarOp]CarMode{
+			selfdrivingoff:   ModeSelfDriving,
+			selftrackingoff:  ModeTracking,
+			speechdrivingoff: ModeSpeech,
+		}[op]
+		if c.mode != from {
+			return c.mode, fmt.Errorf("not in %v", from)
+		}
+		to = ModeIdle
+	case estop:
+		to = ModeEStop
+	case reset:
+		if c.mode != ModeEStop {
+			return c.mode, fmt.Errorf("not in %v", ModeEStop)
+		}
+		to = ModeIdle
+	default:
+		return c.mode, fmt.Errorf("invalid op: %v", op)
+	}
+
+	if to != c.mode && !canTransit(c.mode, to) {
+		return c.mode, fmt.Errorf("can't go from %v to %v", c.mode, to)
+	}
+	return to, nil
+}
+
+// parseOp parses the ops with an argument, e.g. "turn 37", "turn -90°" and "forward 50cm",
+// the angle is in degree, and the distance is in cm.
+func parseOp(op CarOp) (name CarOp, arg float64, ok bool) {
+	fields := strings.Fields(string(op))
+	if len(fields) != 2 {
+		return op, 0, false
+	}
+	name = CarOp(fields[0])
+	var unit string
+	switch name {
+	case turn:
+		unit = "°"
+	case forward, backward:
+		unit = "cm"
+	default:
+		return op, 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(fields[1], unit), 64)
+	if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+		return op, 0, false
+	}
+	if name == backward {
+		v = -v
+	}
+	return name, v, true
+}
+
+func canTransit(from, to CarMode) bool {
+	for _, m := range carTransitions[from] {
+		if m == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transit changes the mode and ends the loops of the last mode, it must be called with the lock held
+func (c *Car) transit(to CarMode, reason string) {
+	log.Printf("[car]%v -> %v (%v)", c.mode, to, reason)
+	e := CarEvent{From: c.mode, To: to, Reason: reason, Time: c.clock.Now()}
+	c.mode = to
+	c.gen++
+	// a motion belongs to the mode it was started in
+	if c.motion != nil {
+		c.motion.Cancel()
+	}
+	select {
+	case c.events <- e:
+	default:
+		// nobody is listening
+	}
+}
+
+// leave goes back to idle if the car is still in the mode of gen,
+// it's used by the modes which quit by themselves.
+func (c *Car) leave(gen int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped || c.gen != gen {
+		return
+	}
+	c.transit(ModeIdle, reason)
+}
+
+// running returns true if the car is still in the mode of gen
+func (c *Car) running(gen int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.stopped && c.gen == gen
+}
+
+// emergencyStop stops the car and all the loops,
+// the car stays in e-stop until it's reset.
+func (c *Car) emergencyStop(reason string) {
+	c.mu.Lock()
+	if c.stopped || c.mode == ModeEStop {
+		c.mu.Unlock()
+		return
+	}
+	c.transit(ModeEStop, reason)
+	c.mu.Unlock()
+	c.stop()
 }
 
 func (c *Car) start() {
-	for op := range c.chOp {
-		switch op {
+	for cmd := range c.chOp {
+		if name, arg, ok := parseOp(cmd.op); ok {
+			c.move(cmd.gen, name, arg)
+			continue
+		}
+		switch cmd.op {
 		case forward:
-			c.forward()
+			c.forward(cmd.gen)
 		case backward:
-			c.backward()
+			c.backward(cmd.gen)
 		case left:
-			c.left()
+			c.left(cmd.gen)
 		case right:
-			c.right()
-		case stop:
+			c.right(cmd.gen)
+		case stop, reset:
 			c.stop()
 		case beep:
 			go c.beep()
@@ -247,78 +640,171 @@ func (c *Car) start() {
 		case lightoff:
 			go c.lightOff()
 		case selfdrivingon:
-			go c.selfDrivingOn()
-		case selfdrivingoff:
-			go c.selfDrivingOff()
+			go c.selfDrivingOn(cmd.gen)
 		case selftrackingon:
-			go c.selfTrackingOn()
-		case selftrackingoff:
-			go c.selfTrackingOff()
+			go c.selfTrackingOn(cmd.gen)
 		case speechdrivingon:
-			go c.speechDrivingOn()
-		case speechdrivingoff:
-			go c.speechDrivingOff()
-		default:
-			log.Printf("[car]invalid op")
+			go c.speechDrivingOn(cmd.gen)
+		case selfdrivingoff, selftrackingoff, speechdrivingoff:
+			// the loops quit by themselves after the mode changed
+			log.Printf("[car]%v", cmd.op)
+		case estop:
+			c.stop()
+			if c.horn != nil {
+				go c.horn.Beep(2, 100)
+			}
 		}
 	}
 }
 
 // forward ...
-func (c *Car) forward() {
+func (c *Car) forward(gen int) {
 	log.Printf("[car]forward")
-	c.engine.Forward()
+	if c.motion != nil {
+		c.runMotion(gen, c.motion.drive, math.Inf(1))
+		return
+	}
+	if c.running(gen) {
+		c.engine.Forward()
+	}
 }
 
 // backward ...
-func (c *Car) backward() {
+func (c *Car) backward(gen int) {
 	log.Printf("[car]backward")
-	c.engine.Backward()
+	if c.motion != nil {
+		c.runMotion(gen, c.motion.drive, math.Inf(-1))
+		return
+	}
+	if c.running(gen) {
+		c.engine.Backward()
+	}
 }
 
 // left ...
-func (c *Car) left() {
+func (c *Car) left(gen int) {
 	log.Printf("[car]left")
+	if c.motion != nil {
+		c.runMotion(gen, c.motion.turn, -manualTurnAngle)
+		return
+	}
+	if !c.running(gen) {
+		return
+	}
 	c.engine.Left()
 	c.delay(250)
 	c.engine.Stop()
 }
 
 // right ...
-func (c *Car) right() {
+func (c *Car) right(gen int) {
 	log.Printf("[car]right")
+	if c.motion != nil {
+		c.runMotion(gen, c.motion.turn, manualTurnAngle)
+		return
+	}
+	if !c.running(gen) {
+		return
+	}
 	c.engine.Right()
 	c.delay(250)
 	c.engine.Stop()
 }
 
+// move turns by the angle or drives the distance by the motion controller
+func (c *Car) move(gen int, op CarOp, arg float64) {
+	log.Printf("[car]%v %v", op, arg)
+	if op == turn {
+		c.runMotion(gen, c.motion.turn, arg)
+		return
+	}
+	c.runMotion(gen, c.motion.drive, arg)
+}
+
+// runMotion runs the motion in background, it's canceled by the next motion or stop
+func (c *Car) runMotion(gen int, motion func(v float64, gen int) error, v float64) {
+	mgen, ok := c.restartMotion(gen)
+	if !ok {
+		return
+	}
+	go func() {
+		if err := motion(v, mgen); err != nil && err != ErrMotionCanceled {
+			log.Printf("[car]failed to move, error: %v", err)
+		}
+	}()
+}
+
+// restartMotion cancels the running motion, and returns the generation for the next one,
+// ok is false if the car isn't in the mode of gen anymore, e.g. an e-stop happened after the op was accepted.
+func (c *Car) restartMotion(gen int) (mgen int, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped || c.gen != gen {
+		return 0, false
+	}
+	return c.motion.restart(), true
+}
+
 // stop ...
 func (c *Car) stop() {
 	log.Printf("[car]stop")
+	if c.motion != nil {
+		c.motion.Cancel()
+	}
 	c.engine.Stop()
 }
 
 func (c *Car) speed(s uint32) {
 	c.engine.Speed(s)
+	if c.motion != nil {
+		c.motion.SetSpeed(float64(s), float64(s)/2)
+	}
 }
 
 // beep ...
 func (c *Car) beep() {
 	log.Printf("[car]beep")
+	c.honk("beep", 5, 100)
+}
+
+// honk plays the alert for the event by the horn,
+// or beeps n times with the interval in millisecond if there isn't an alert for it.
+func (c *Car) honk(event string, n, interval int) {
 	if c.horn == nil {
 		return
 	}
-	c.horn.Beep(5, 100)
+	if name, ok := c.alerts[event]; ok {
+		p, err := c.horn.Alert(name)
+		if err == nil {
+			p.Wait()
+			return
+		}
+		log.Printf("[car]failed to play alert %v, error: %v", name, err)
+	}
+	c.horn.Beep(n, interval)
 }
 
-func (c *Car) blink() {
-	for {
-		if c.speechdriving {
-			c.delay(2000)
-			continue
-		}
-		c.led.Blink(1, 1000)
+// beepHorn beeps n times with the interval in millisecond if the car has a horn
+func (c *Car) beepHorn(n, interval int) {
+	if c.horn == nil {
+		return
+	}
+	c.horn.Beep(n, interval)
+}
+
+// showLed shows the pattern on the led, the pattern with higher priority is shown first
+func (c *Car) showLed(name string, p LedPattern, priority int) {
+	if c.leds == nil {
+		return
+	}
+	c.leds.Play(name, p, priority, 0)
+}
+
+func (c *Car) hideLed(name string) {
+	if c.leds == nil {
+		return
 	}
+	c.leds.Stop(name)
 }
 
 func (c *Car) lightOn() {
@@ -338,38 +824,38 @@ func (c *Car) lightOff() {
 }
 
 func (c *Car) servoLeft() {
-	angle := c.servoAngle - 15
-	if angle < -90 {
-		angle = -90
-	}
-	c.servoAngle = angle
-	log.Printf("[car]servo roll %v", angle)
-	if c.servo == nil {
-		return
-	}
-	c.servo.Roll(angle)
+	c.rollServo(-15)
 }
 
 func (c *Car) servoRight() {
-	angle := c.servoAngle + 15
+	c.rollServo(15)
+}
+
+func (c *Car) servoAhead() {
+	c.mu.Lock()
+	c.servoAngle = 0
+	c.mu.Unlock()
+	c.rollServo(0)
+}
+
+// rollServo rolls the servo by delta in degree from the current angle, in [-90, 90]
+func (c *Car) rollServo(delta int) {
+	c.mu.Lock()
+	angle := c.servoAngle + delta
+	if angle < -90 {
+		angle = -90
+	}
 	if angle > 90 {
 		angle = 90
 	}
 	c.servoAngle = angle
+	c.mu.Unlock()
+
 	log.Printf("[car]servo roll %v", angle)
 	if c.servo == nil {
 		return
 	}
-	c.servo.Roll(angle)
-}
-
-func (c *Car) servoAhead() {
-	c.servoAngle = 0
-	log.Printf("[car]servo roll %v", 0)
-	if c.servo == nil {
-		return
-	}
-	c.servo.Roll(0)
+	c.servo.Move(float64(angle), servoSpeed, EaseInOut)
 }
 
 /*
@@ -397,14 +883,15 @@ func (c *Car) servoAhead() {
 
 
 */
-func (c *Car) selfDriving() {
+func (c *Car) selfDriving(gen int, tracker *cv.Tracker) {
 	if c.ult == nil {
 		log.Printf("[car]can't self-driving without the distance sensor")
+		c.leave(gen, "no distance sensor")
 		return
 	}
 
 	// make a warning before running into self-driving mode
-	c.horn.Beep(3, 300)
+	c.beepHorn(3, 300)
 
 	var (
 		fwd       bool
@@ -415,9 +902,10 @@ func (c *Car) selfDriving() {
 		maxd      float64
 		op        = forward
 		chOp      = make(chan CarOp, 4)
+		wg        sync.WaitGroup
 	)
 
-	for c.selfdriving || c.selftracking {
+	for c.running(gen) {
 		select {
 		case p := <-chOp:
 			op = p
@@ -435,7 +923,7 @@ func (c *Car) selfDriving() {
 			fwd = false
 			c.stop()
 			c.delay(20)
-			c.backward()
+			c.backward(gen)
 			c.delay(500)
 			chOp <- stop
 			continue
@@ -458,15 +946,19 @@ func (c *Car) selfDriving() {
 			retry = 0
 		case turn:
 			fwd = false
-			c.turn(maxdAngle)
+			c.turn(gen, maxdAngle)
 			c.delay(150)
 			chOp <- forward
 			continue
 		case forward:
 			if !fwd {
-				c.forward()
+				c.forward(gen)
 				fwd = true
-				go c.detecting(chOp)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					c.detecting(gen, chOp, tracker)
+				}()
 			}
 			c.delay(50)
 			continue
@@ -477,11 +969,13 @@ func (c *Car) selfDriving() {
 		}
 	}
 	c.stop()
+	// the detectors may still send to chOp until they quit
+	wg.Wait()
 	c.delay(1000)
 	close(chOp)
 }
 
-func (c *Car) speechDriving() {
+func (c *Car) speechDriving(gen int) {
 	var (
 		op   = stop
 		fwd  = false
@@ -490,8 +984,8 @@ func (c *Car) speechDriving() {
 	)
 
 	wg.Add(1)
-	go c.detectSpeech(chOp, &wg)
-	for c.speechdriving {
+	go c.detectSpeech(gen, chOp, &wg)
+	for c.running(gen) {
 		select {
 		case p := <-chOp:
 			op = p
@@ -507,9 +1001,13 @@ func (c *Car) speechDriving() {
 		switch op {
 		case forward:
 			if !fwd {
-				c.forward()
+				c.forward(gen)
 				fwd = true
-				go c.detecting(chOp)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					c.detecting(gen, chOp, nil)
+				}()
 			}
 			c.delay(50)
 			continue
@@ -517,7 +1015,7 @@ func (c *Car) speechDriving() {
 			fwd = false
 			c.stop()
 			c.delay(20)
-			c.backward()
+			c.backward(gen)
 			c.delay(600)
 			chOp <- stop
 			continue
@@ -525,7 +1023,7 @@ func (c *Car) speechDriving() {
 			fwd = false
 			c.stop()
 			c.delay(20)
-			c.turn(-90)
+			c.turn(gen, -90)
 			c.delay(20)
 			chOp <- forward
 			continue
@@ -533,7 +1031,7 @@ func (c *Car) speechDriving() {
 			fwd = false
 			c.stop()
 			c.delay(20)
-			c.turn(90)
+			c.turn(gen, 90)
 			c.delay(20)
 			chOp <- forward
 			continue
@@ -549,98 +1047,76 @@ func (c *Car) speechDriving() {
 	close(chOp)
 }
 
-func (c *Car) selfDrivingOn() {
-	if c.selfdriving {
+func (c *Car) selfDrivingOn(gen int) {
+	c.delay(1000) // wait for the loops of the last mode quit
+	if !c.running(gen) {
 		return
 	}
-	c.selftracking = false
-	c.speechdriving = false
-	c.delay(1000) // wait for self-tracking and speech-driving quit
-
-	c.selfdriving = true
 	log.Printf("[car]self-drving on")
-	c.selfDriving()
-}
-
-func (c *Car) selfDrivingOff() {
-	c.selfdriving = false
+	c.selfDriving(gen, nil)
 	log.Printf("[car]self-drving off")
 }
 
-func (c *Car) selfTrackingOn() {
-	if c.selftracking {
+func (c *Car) selfTrackingOn(gen int) {
+	c.stopMotion()
+	c.delay(1000) // wait for the loops of the last mode quit
+	defer func() {
+		if err := c.startMotion(); err != nil {
+			log.Printf("[car]failed to start motion, error: %v", err)
+		}
+	}()
+	if !c.running(gen) {
 		return
 	}
-	c.stopMotion()
-	c.selfdriving = false
-	c.speechdriving = false
-	c.delay(1000) // wait to quit self-driving & speech-driving
 
 	// start slef-tracking
 	t, err := cv.NewTracker(lh, ls, lv, hh, hs, hv)
 	if err != nil {
 		log.Printf("[carapp]failed to create a tracker, error: %v", err)
+		c.leave(gen, "no tracker")
 		return
 	}
-	c.tracker = t
-	c.selftracking = true
 	log.Printf("[car]self-tracking on")
-	c.selfDriving()
-}
-
-func (c *Car) selfTrackingOff() {
-	c.selftracking = false
-	c.tracker.Close()
+	c.selfDriving(gen, t)
+	t.Close()
 	c.delay(500)
-
-	if err := c.startMotion(); err != nil {
-		log.Printf("[car]failed to start motion, error: %v", err)
-	}
 	log.Printf("[car]self-tracking off")
 }
 
-func (c *Car) speechDrivingOn() {
-	if c.speechdriving {
+func (c *Car) speechDrivingOn(gen int) {
+	c.delay(1000) // wait for the loops of the last mode quit
+	if !c.running(gen) {
 		return
 	}
-	c.selfdriving = false
-	c.selftracking = false
-	c.delay(1000) // wait for self-driving and self-tracking quit
-
-	c.speechdriving = true
 	log.Printf("[car]speech-drving on")
-	c.speechDriving()
-}
-
-func (c *Car) speechDrivingOff() {
-	c.speechdriving = false
+	c.speechDriving(gen)
 	log.Printf("[car]speech-drving off")
 }
 
-func (c *Car) detecting(chOp chan CarOp) {
+func (c *Car) detecting(gen int, chOp chan CarOp, tracker *cv.Tracker) {
 
 	chQuit := make(chan bool, 4)
 	var wg sync.WaitGroup
 
 	wg.Add(1)
-	go c.detectCollision(chOp, chQuit, &wg)
+	go c.detectCollision(gen, chOp, chQuit, &wg)
 
 	wg.Add(1)
-	go c.detectObstacles(chOp, chQuit, &wg)
+	go c.detectObstacles(gen, chOp, chQuit, &wg)
 
-	if c.selftracking {
+	if tracker != nil {
 		wg.Add(1)
-		go c.trackingObj(chOp, chQuit, &wg)
+		go c.trackingObj(gen, tracker, chOp, chQuit, &wg)
 	}
 
 	wg.Wait()
 	close(chQuit)
 }
 
-func (c *Car) detectObstacles(chOp chan CarOp, chQuit chan bool, wg *sync.WaitGroup) {
+func (c *Car) detectObstacles(gen int, chOp chan CarOp, chQuit chan bool, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	for c.selfdriving || c.selftracking || c.speechdriving {
+	for c.running(gen) {
 		for _, angle := range aheadAngles {
 			select {
 			case quit := <-chQuit:
@@ -669,10 +1145,10 @@ func (c *Car) detectObstacles(chOp chan CarOp, chQuit chan bool, wg *sync.WaitGr
 	}
 }
 
-func (c *Car) detectCollision(chOp chan CarOp, chQuit chan bool, wg *sync.WaitGroup) {
+func (c *Car) detectCollision(gen int, chOp chan CarOp, chQuit chan bool, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	for c.selfdriving || c.selftracking || c.speechdriving {
+	for c.running(gen) {
 		select {
 		case quit := <-chQuit:
 			if quit {
@@ -684,7 +1160,7 @@ func (c *Car) detectCollision(chOp chan CarOp, chQuit chan bool, wg *sync.WaitGr
 		for _, cswitch := range c.cswitchs {
 			if cswitch.Collided() {
 				chOp <- backward
-				go c.horn.Beep(1, 100)
+				go c.beepHorn(1, 100)
 				log.Printf("[car]crashed")
 				chQuit <- true
 				chQuit <- true
@@ -695,10 +1171,10 @@ func (c *Car) detectCollision(chOp chan CarOp, chQuit chan bool, wg *sync.WaitGr
 	}
 }
 
-func (c *Car) trackingObj(chOp chan CarOp, chQuit chan bool, wg *sync.WaitGroup) {
+func (c *Car) trackingObj(gen int, tracker *cv.Tracker, chOp chan CarOp, chQuit chan bool, wg *sync.WaitGroup) {
 	defer wg.Done()
 	angle := 0
-	for c.selftracking {
+	for c.running(gen) {
 		select {
 		case quit := <-chQuit:
 			if quit {
@@ -708,7 +1184,7 @@ func (c *Car) trackingObj(chOp chan CarOp, chQuit chan bool, wg *sync.WaitGroup)
 			// do nothing
 		}
 
-		ok, _ := c.tracker.Locate()
+		ok, _ := tracker.Locate()
 		if !ok {
 			continue
 		}
@@ -721,14 +1197,14 @@ func (c *Car) trackingObj(chOp chan CarOp, chQuit chan bool, wg *sync.WaitGroup)
 		c.stop()
 
 		firstTime := true // see a ball at the first time
-		for c.selftracking {
-			ok, rect := c.tracker.Locate()
+		for c.running(gen) {
+			ok, rect := tracker.Locate()
 			if !ok {
 				// lost the ball, looking for it by turning 360 degree
 				log.Printf("[car]lost the ball")
 				firstTime = true
 				if angle < 360 {
-					c.turn(30)
+					c.turn(gen, 30)
 					angle += 30
 					c.delay(200)
 					continue
@@ -739,14 +1215,14 @@ func (c *Car) trackingObj(chOp chan CarOp, chQuit chan bool, wg *sync.WaitGroup)
 			angle = 0
 			if rect.Max.Y > 580 {
 				c.stop()
-				c.horn.Beep(1, 300)
+				c.beepHorn(1, 300)
 				continue
 			}
 			if firstTime {
-				go c.horn.Beep(2, 100)
+				go c.beepHorn(2, 100)
 			}
 			firstTime = false
-			x, y := c.tracker.MiddleXY(rect)
+			x, y := tracker.MiddleXY(rect)
 			log.Printf("[car]found a ball at: (%v, %v)", x, y)
 			if x < 200 {
 				log.Printf("[car]turn right to the ball")
@@ -771,17 +1247,22 @@ func (c *Car) trackingObj(chOp chan CarOp, chQuit chan bool, wg *sync.WaitGroup)
 	}
 }
 
-func (c *Car) detectSpeech(chOp chan CarOp, wg *sync.WaitGroup) {
+func (c *Car) detectSpeech(gen int, chOp chan CarOp, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	speechAuth := oauth.New(baiduSpeechAppKey, baiduSpeechSecretKey, oauth.NewCacheMan())
-	c.asr = speech.NewASR(speechAuth)
-	c.tts = speech.NewTTS(speechAuth)
+	c.speechOnce.Do(func() {
+		speechAuth := oauth.New(baiduSpeechAppKey, baiduSpeechSecretKey, oauth.NewCacheMan())
+		c.asr = speech.NewASR(speechAuth)
+		c.tts = speech.NewTTS(speechAuth)
 
-	imgAuth := oauth.New(baiduImgRecognitionAppKey, baiduImgRecognitionSecretKey, oauth.NewCacheMan())
-	c.imgr = recognizer.New(imgAuth)
+		imgAuth := oauth.New(baiduImgRecognitionAppKey, baiduImgRecognitionSecretKey, oauth.NewCacheMan())
+		c.imgr = recognizer.New(imgAuth)
+	})
 
-	for c.speechdriving {
+	// the led is off except recording in speech-driving
+	c.showLed("speechdriving", SolidPattern(Black), 1)
+	defer c.hideLed("speechdriving")
+	for c.running(gen) {
 		// -D:			device
 		// -d 3:		3 seconds
 		// -t wav:		wav type
@@ -790,13 +1271,13 @@ func (c *Car) detectSpeech(chOp chan CarOp, wg *sync.WaitGroup) {
 		// -f S16_LE:	Signed 16 bit Little Endian
 		cmd := `sudo arecord -D "plughw:1,0" -d 2 -t wav -r 16000 -c 1 -f S16_LE car.wav`
 		log.Printf("[car]start recording")
-		go c.led.On()
+		c.showLed("recording", SolidPattern(White), 2)
 		_, err := exec.Command("bash", "-c", cmd).CombinedOutput()
+		c.hideLed("recording")
 		if err != nil {
 			log.Printf("[car]failed to record the speech: %v", err)
 			continue
 		}
-		go c.led.Off()
 		log.Printf("[car]stop recording")
 
 		text, err := c.asr.ToText("car.wav")
@@ -836,8 +1317,9 @@ func (c *Car) scan() (mind, maxd float64, mindAngle, maxdAngle int) {
 	mind = 9999
 	maxd = -9999
 	for _, ang := range scanningAngles {
+		// roll returns after the servo arrived, wait a little for the ultrasonic
 		c.servo.Roll(ang)
-		c.delay(120)
+		c.delay(60)
 		d := c.ult.Dist()
 		for i := 0; d < 0 && i < 3; i++ {
 			c.delay(120)
@@ -861,7 +1343,25 @@ func (c *Car) scan() (mind, maxd float64, mindAngle, maxdAngle int) {
 	return
 }
 
-func (c *Car) turn(angle int) {
+func (c *Car) turn(gen, angle int) {
+	if c.motion != nil {
+		mgen, ok := c.restartMotion(gen)
+		if !ok {
+			return
+		}
+		if err := c.motion.turn(float64(angle), mgen); err != nil {
+			log.Printf("[car]failed to turn %v degree, error: %v", angle, err)
+		}
+		return
+	}
+	if !c.running(gen) {
+		return
+	}
+	if c.imu != nil {
+		c.turnByIMU(angle)
+		return
+	}
+
 	n, ok := turnAngleCounts[angle]
 	if !ok {
 		log.Printf("[car]invalid angle: %d", angle)
@@ -876,24 +1376,117 @@ func (c *Car) turn(angle int) {
 	c.encoder.Start()
 	defer c.encoder.Stop()
 
-	for i := 0; i < n; {
+	start := c.clock.Now()
+	for i := 0; i < n && c.running(gen); {
+		if c.clock.Now().Sub(start) > turnTimeout {
+			log.Printf("[car]turn timeout, counted %v of %v", i, n)
+			break
+		}
 		i += c.encoder.Count1()
 	}
 	c.stop()
 	return
 }
 
+// turnByIMU turns the car by the angle measured by the imu,
+// angle < 0: left, angle > 0: right
+func (c *Car) turnByIMU(angle int) {
+	if angle < 0 {
+		c.engine.Left()
+	} else {
+		c.engine.Right()
+	}
+
+	target := math.Abs(float64(angle)) - turnTolerance
+	turned := 0.0
+	last := c.imu.Heading()
+	start := c.clock.Now()
+	for turned < target {
+		if c.clock.Now().Sub(start) > turnTimeout {
+			log.Printf("[car]turn timeout, turned %.0f of %v degree", turned, angle)
+			break
+		}
+		c.delay(5)
+		h := c.imu.Heading()
+		turned += math.Abs(normalizeAngle(h - last))
+		last = h
+	}
+	c.stop()
+}
+
+// guard stops the car when it was tilted or lifted
+func (c *Car) guard() {
+	tilted := false
+	for {
+		select {
+		case <-c.chQuit:
+			return
+		default:
+			// do nothing
+		}
+		t := c.imu.Lifted() || c.imu.Tilted(maxTiltAngle)
+		if t && !tilted {
+			log.Printf("[car]tilted or lifted, stop")
+			c.emergencyStop("tilted or lifted")
+			if c.horn != nil {
+				go c.horn.Beep(2, 100)
+			}
+		}
+		tilted = t
+		c.delay(100)
+	}
+}
+
+// watchBattery limits the speed when the battery is low,
+// and stops the car when it is critical to protect the battery and the pi.
+func (c *Car) watchBattery() {
+	for {
+		var e BatteryEvent
+		select {
+		case <-c.chQuit:
+			return
+		case e = <-c.battery.Events():
+		}
+		log.Printf("[car]battery %v, %.2fV, %.0f%%", e.State, e.Volts, e.SoC)
+		switch e.State {
+		case BatteryNormal:
+			c.speed(normalSpeed)
+		case BatteryLow:
+			c.speed(lowBatterySpeed)
+			go c.honk("lowbattery", 3, 500)
+		case BatteryCritical:
+			c.emergencyStop("battery critical")
+			if c.horn != nil {
+				go c.horn.Beep(5, 500)
+			}
+		}
+	}
+}
+
+func (c *Car) batteryLow() bool {
+	return c.battery != nil && c.battery.State() != BatteryNormal
+}
+
 func (c *Car) delay(ms int) {
-	time.Sleep(time.Duration(ms) * time.Millisecond)
+	c.clock.Sleep(time.Duration(ms) * time.Millisecond)
 }
 
 func (c *Car) recognize() error {
+	if c.camera == nil {
+		return errors.New("invalid camera")
+	}
 	log.Printf("[car]take photo")
-	imagef, err := c.camera.TakePhoto()
+	photo, err := c.camera.TakePhoto()
 	if err != nil {
 		log.Printf("[car]failed to take phote, error: %v", err)
 		return err
 	}
+	imagef, err := photo.SaveTemp()
+	if err != nil {
+		log.Printf("[car]failed to save photo, error: %v", err)
+		return err
+	}
+	defer os.Remove(imagef)
 	c.play(letMeThinkWav)
 
 	log.Printf("[car]recognize image")
@@ -0,0 +1,337 @@
+package dev
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// drainOps drops the ops accepted by the car, instead of running them on the devices
+func drainOps(c *Car) {
+	for len(c.chOp) > 0 {
+		<-c.chOp
+	}
+}
+
+func TestCarTransitions(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		ops      []CarOp
+		op       CarOp
+		expected CarMode
+		err      bool
+	}{
+		{
+			desc:     "drive by hand",
+			op:       forward,
+			expected: ModeManual,
+		},
+		{
+			desc:     "stop driving",
+			ops:      []CarOp{forward},
+			op:       stop,
+			expected: ModeIdle,
+		},
+		{
+			desc:     "beep doesn't change the mode",
+			ops:      []CarOp{left},
+			op:       beep,
+			expected: ModeManual,
+		},
+		{
+			desc:     "self-driving",
+			ops:      []CarOp{forward},
+			op:       selfdrivingon,
+			expected: ModeSelfDriving,
+		},
+		{
+			desc:     "self-driving twice",
+			ops:      []CarOp{selfdrivingon},
+			op:       selfdrivingon,
+			expected: ModeSelfDriving,
+			err:      true,
+		},
+		{
+			desc:     "drive by hand in self-driving",
+			ops:      []CarOp{selfdrivingon},
+			op:       backward,
+			expected: ModeSelfDriving,
+			err:      true,
+		},
+		{
+			desc:     "stop in self-driving",
+			ops:      []CarOp{selfdrivingon},
+			op:       stop,
+			expected: ModeIdle,
+		},
+		{
+			desc:     "stop in speech-driving",
+			ops:      []CarOp{speechdrivingon},
+			op:       stop,
+			expected: ModeIdle,
+		},
+		{
+			desc:     "roll the servo in speech-driving",
+			ops:      []CarOp{speechdrivingon},
+			op:       servoleft,
+			expected: ModeSpeech,
+			err:      true,
+		},
+		{
+			desc:     "switch to self-tracking",
+			ops:      []CarOp{selfdrivingon},
+			op:       selftrackingon,
+			expected: ModeTracking,
+		},
+		{
+			desc:     "turn off the other mode",
+			ops:      []CarOp{selftrackingon},
+			op:       speechdrivingoff,
+			expected: ModeTracking,
+			err:      true,
+		},
+		{
+			desc:     "turn off self-tracking",
+			ops:      []CarOp{selftrackingon},
+			op:       selftrackingoff,
+			expected: ModeIdle,
+		},
+		{
+			desc:     "e-stop in self-driving",
+			ops:      []CarOp{selfdrivingon},
+			op:       estop,
+			expected: ModeEStop,
+		},
+		{
+			desc:     "drive in e-stop",
+			ops:      []CarOp{estop},
+			op:       forward,
+			expected: ModeEStop,
+			err:      true,
+		},
+		{
+			desc:     "self-driving in e-stop",
+			ops:      []CarOp{estop},
+			op:       selfdrivingon,
+			expected: ModeEStop,
+			err:      true,
+		},
+		{
+			desc:     "light in e-stop",
+			ops:      []CarOp{estop},
+			op:       lighton,
+			expected: ModeEStop,
+		},
+		{
+			desc:     "reset",
+			ops:      []CarOp{forward, estop},
+			op:       reset,
+			expected: ModeIdle,
+		},
+		{
+			desc:     "reset without e-stop",
+			ops:      []CarOp{forward},
+			op:       reset,
+			expected: ModeManual,
+			err:      true,
+		},
+		{
+			desc:     "invalid op",
+			op:       "fly",
+			expected: ModeIdle,
+			err:      true,
+		},
+		{
+			desc:     "internal op",
+			op:       scan,
+			expected: ModeIdle,
+			err:      true,
+		},
+	}
+	for _, test := range testCases {
+		c := NewCar()
+		for _, op := range test.ops {
+			assert.NoError(t, c.Do(op), test.desc)
+			drainOps(c)
+		}
+		err := c.Do(test.op)
+		drainOps(c)
+		if test.err {
+			assert.Error(t, err, test.desc)
+		} else {
+			assert.NoError(t, err, test.desc)
+		}
+		assert.Equal(t, test.expected, c.Mode(), test.desc)
+	}
+}
+
+func TestCarEvents(t *testing.T) {
+	c := NewCar()
+	assert.NoError(t, c.Do(forward))
+	assert.NoError(t, c.Do(forward))
+	assert.NoError(t, c.Do(estop))
+	assert.NoError(t, c.Do(reset))
+	drainOps(c)
+
+	expected := []CarEvent{
+		{From: ModeIdle, To: ModeManual, Reason: "forward"},
+		{From: ModeManual, To: ModeEStop, Reason: "estop"},
+		{From: ModeEStop, To: ModeIdle, Reason: "reset"},
+	}
+	assert.Len(t, c.Events(), len(expected))
+	for _, e := range expected {
+		got := <-c.Events()
+		assert.Equal(t, e.From, got.From)
+		assert.Equal(t, e.To, got.To)
+		assert.Equal(t, e.Reason, got.Reason)
+		assert.False(t, got.Time.IsZero())
+	}
+}
+
+func TestCarStop(t *testing.T) {
+	c := NewCar()
+	go c.start()
+	assert.NoError(t, c.Do(beep))
+	assert.NoError(t, c.Stop())
+	assert.NoError(t, c.Stop())
+	assert.NotPanics(t, func() {
+		assert.Equal(t, ErrCarStopped, c.Do(beep))
+	})
+	// the watchers of the imu and the battery quit
+	_, ok := <-c.chQuit
+	assert.False(t, ok)
+}
+
+func TestCarTooManyOps(t *testing.T) {
+	c := NewCar()
+	for i := 0; i < chSize; i++ {
+		assert.NoError(t, c.Do(lighton))
+	}
+	assert.Error(t, c.Do(lighton))
+	drainOps(c)
+	assert.NoError(t, c.Do(lighton))
+}
+
+// TestCarConcurrency runs with -race, the ops here don't need any devices
+func TestCarConcurrency(t *testing.T) {
+	c := NewCar()
+	go c.start()
+
+	ops := []CarOp{beep, lighton, lightoff, servoleft, servoright, servoahead, selfdrivingon, selfdrivingoff}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				c.Do(ops[(i+j)%len(ops)])
+				c.GetState()
+				c.Mode()
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			select {
+			case <-c.Events():
+			default:
+			}
+		}
+	}()
+	wg.Wait()
+
+	assert.NoError(t, c.Stop())
+	assert.Equal(t, ErrCarStopped, c.Do(beep))
+}
+
+func TestParseOp(t *testing.T) {
+	testCases := []struct {
+		op   CarOp
+		name CarOp
+		arg  float64
+		ok   bool
+	}{
+		{op: "turn 37", name: turn, arg: 37, ok: true},
+		{op: "turn -90°", name: turn, arg: -90, ok: true},
+		{op: "forward 50cm", name: forward, arg: 50, ok: true},
+		{op: "backward 20", name: backward, arg: -20, ok: true},
+		{op: "forward", name: forward},
+		{op: "turn", name: turn},
+		{op: "turn left", name: "turn left"},
+		{op: "turn 37cm", name: "turn 37cm"},
+		{op: "forward Inf", name: "forward Inf"},
+		{op: "beep 3", name: "beep 3"},
+	}
+	for _, test := range testCases {
+		name, arg, ok := parseOp(test.op)
+		assert.Equal(t, test.ok, ok, test.op)
+		assert.Equal(t, test.name, name, test.op)
+		assert.Equal(t, test.arg, arg, test.op)
+	}
+}
+
+func TestCarMotionOps(t *testing.T) {
+	c := NewCar()
+	assert.Error(t, c.Do("turn 37"))
+	assert.Error(t, c.Do(turn))
+
+	m, _ := newTestMotion(0.2, 0.2)
+	c = NewCar(WithMotion(m))
+	assert.NoError(t, c.Do("turn 37°"))
+	assert.Equal(t, ModeManual, c.Mode())
+	assert.NoError(t, c.Do("forward 50cm"))
+	assert.Error(t, c.Do("turn 3x"))
+	drainOps(c)
+
+	assert.NoError(t, c.Do(selfdrivingon))
+	assert.Error(t, c.Do("forward 50"))
+	drainOps(c)
+}
+
+func TestCarStopMotion(t *testing.T) {
+	stopped := func(w *fakeWheels) func() bool {
+		return func() bool {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			return w.braked && w.speeds == [2]float64{}
+		}
+	}
+	moving := func(w *fakeWheels) func() bool {
+		return func() bool {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			return w.speeds != [2]float64{}
+		}
+	}
+
+	testCases := []struct {
+		desc string
+		stop func(c *Car)
+	}{
+		{
+			desc: "by Stop",
+			stop: func(c *Car) { c.Stop() },
+		},
+		{
+			desc: "by leaving manual",
+			stop: func(c *Car) { c.Do(selfdrivingon) },
+		},
+	}
+	for _, test := range testCases {
+		m, w := newTestMotion(1, 1)
+		c := NewCar(WithMotion(m))
+		go c.start()
+
+		assert.NoError(t, c.Do(forward), test.desc)
+		assert.Eventually(t, moving(w), time.Second, time.Millisecond, test.desc)
+		test.stop(c)
+		assert.Eventually(t, stopped(w), 500*time.Millisecond, time.Millisecond, test.desc)
+		time.Sleep(50 * time.Millisecond)
+		assert.True(t, stopped(w)(), test.desc)
+		c.Stop()
+	}
+}
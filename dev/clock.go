@@ -0,0 +1,22 @@
+package dev
+
+import (
+	"time"
+)
+
+// Clock is the time a car runs on, e.g. the simulator runs a car faster than the real time
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
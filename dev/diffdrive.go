@@ -0,0 +1,188 @@
+/*
+Package dev ...
+
+DiffDrive drives a differential-drive car, which steers by the difference of the speeds of the left and right wheels.
+It takes a linear and an angular velocity, both are normalized into [-1, 1]:
+  - linear > 0:		forward
+  - linear < 0:		backward
+  - angular > 0:	turn right
+  - angular < 0:	turn left
+
+e.g.
+	linear=1, angular=0:	full speed forward
+	linear=0.5, angular=0.2:	a gentle right arc
+	linear=0, angular=0.5:	spin left at half speed
+
+The speeds are ramped by the acceleration to start and stop softly,
+and the trim corrects a car drifting to one side.
+*/
+package dev
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	diffDriveInterval = 20 * time.Millisecond
+)
+
+// MotorDriver drives the left and right motors of a car, like L298N
+type MotorDriver interface {
+	// SetSpeeds sets the signed speeds in percent [-100, 100]
+	SetSpeeds(left, right float64)
+	Brake()
+	Coast()
+}
+
+// DiffDrive ...
+type DiffDrive struct {
+	drv MotorDriver
+
+	mu       sync.Mutex
+	accel    float64
+	trim     float64
+	deadband float64
+	target   [2]float64
+	current  [2]float64
+	chQuit   chan bool
+}
+
+// NewDiffDrive creates a differential drive,
+// accel is the max change of the normalized speed per second, e.g. 2 means 0 to full speed in 0.5s,
+// accel <= 0 means no ramps.
+func NewDiffDrive(drv MotorDriver, accel float64) *DiffDrive {
+	d := newDiffDrive(drv, accel)
+	go d.start()
+	return d
+}
+
+func newDiffDrive(drv MotorDriver, accel float64) *DiffDrive {
+	return &DiffDrive{
+		drv:    drv,
+		accel:  accel,
+		chQuit: make(chan bool),
+	}
+}
+
+// SetTrim corrects the car drifting to one side, trim is in [-1, 1].
+// Increase trim if the car drifts to the right when going straight, it slows down the left wheel,
+// and decrease trim if it drifts to the left.
+func (d *DiffDrive) SetTrim(trim float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.trim = math.Max(-1, math.Min(1, trim))
+}
+
+// SetDeadband sets the min duty in percent which makes the motors turn,
+// the speeds are mapped into [deadband, 100] so that a low speed still moves the car.
+func (d *DiffDrive) SetDeadband(minDuty float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deadband = math.Max(0, math.Min(100, minDuty))
+}
+
+// SetAccel ...
+func (d *DiffDrive) SetAccel(accel float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.accel = accel
+}
+
+// Drive sets the linear and angular velocity
+func (d *DiffDrive) Drive(linear, angular float64) {
+	left, right := linear+angular, linear-angular
+	// keep the ratio of the speeds if any one is out of range
+	if m := math.Max(math.Abs(left), math.Abs(right)); m > 1 {
+		left /= m
+		right /= m
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.target = [2]float64{left, right}
+}
+
+// Stop slows down to stop by the ramp, and the motors coast at last
+func (d *DiffDrive) Stop() {
+	d.Drive(0, 0)
+}
+
+// Brake stops the motors fast without the ramp
+func (d *DiffDrive) Brake() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.target = [2]float64{}
+	d.current = [2]float64{}
+	d.drv.Brake()
+}
+
+// Coast lets the motors run freely without the ramp
+func (d *DiffDrive) Coast() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.target = [2]float64{}
+	d.current = [2]float64{}
+	d.drv.Coast()
+}
+
+// Speeds returns the current normalized speeds of the left and right wheels
+func (d *DiffDrive) Speeds() (left, right float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.current[0], d.current[1]
+}
+
+// Close ...
+func (d *DiffDrive) Close() {
+	close(d.chQuit)
+	d.Coast()
+}
+
+func (d *DiffDrive) start() {
+	last := time.Now()
+	for {
+		select {
+		case <-d.chQuit:
+			return
+		case now := <-time.After(diffDriveInterval):
+			d.step(now.Sub(last).Seconds())
+			last = now
+		}
+	}
+}
+
+// step ramps the current speeds to the targets in dt seconds, and applies them
+func (d *DiffDrive) step(dt float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.current == d.target {
+		return
+	}
+	for i := range d.current {
+		diff := d.target[i] - d.current[i]
+		if limit := d.accel * dt; d.accel > 0 && math.Abs(diff) > limit {
+			diff = math.Copysign(limit, diff)
+		}
+		d.current[i] += diff
+	}
+	if d.current == [2]float64{} {
+		d.drv.Coast()
+		return
+	}
+	d.drv.SetSpeeds(d.duty(d.current[0], 0), d.duty(d.current[1], 1))
+}
+
+// duty converts a normalized speed to the duty in percent with the trim and deadband
+func (d *DiffDrive) duty(v float64, side int) float64 {
+	if side == 0 && d.trim > 0 {
+		v *= 1 - d.trim
+	}
+	if side == 1 && d.trim < 0 {
+		v *= 1 + d.trim
+	}
+	if v == 0 {
+		return 0
+	}
+	return math.Copysign(d.deadband+math.Abs(v)*(100-d.deadband), v)
+}
@@ -0,0 +1,81 @@
+package dev
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMotorDriver struct {
+	left, right float64
+	braked      bool
+	coasted     bool
+}
+
+func (f *fakeMotorDriver) SetSpeeds(left, right float64) {
+	f.left, f.right = left, right
+	f.braked, f.coasted = false, false
+}
+
+func (f *fakeMotorDriver) Brake() {
+	f.left, f.right = 0, 0
+	f.braked = true
+}
+
+func (f *fakeMotorDriver) Coast() {
+	f.left, f.right = 0, 0
+	f.coasted = true
+}
+
+func TestDiffDriveRamp(t *testing.T) {
+	drv := &fakeMotorDriver{}
+	d := newDiffDrive(drv, 2)
+
+	d.Drive(1, 0)
+	d.step(0.1)
+	assert.InDelta(t, 20, drv.left, 1e-6)
+	assert.InDelta(t, 20, drv.right, 1e-6)
+	for i := 0; i < 10; i++ {
+		d.step(0.1)
+	}
+	assert.InDelta(t, 100, drv.left, 1e-6)
+
+	// ramp down and coast at last
+	d.Stop()
+	d.step(0.25)
+	assert.InDelta(t, 50, drv.left, 1e-6)
+	d.step(0.25)
+	assert.True(t, drv.coasted)
+
+	d.Drive(0.5, 0)
+	d.step(1)
+	d.Brake()
+	assert.True(t, drv.braked)
+	l, r := d.Speeds()
+	assert.Equal(t, 0.0, l)
+	assert.Equal(t, 0.0, r)
+}
+
+func TestDiffDriveSteering(t *testing.T) {
+	drv := &fakeMotorDriver{}
+	d := newDiffDrive(drv, 0)
+
+	// a right arc
+	d.Drive(0.5, 0.2)
+	d.step(0.02)
+	assert.InDelta(t, 70, drv.left, 1e-6)
+	assert.InDelta(t, 30, drv.right, 1e-6)
+
+	// spin left, and keep the ratio when saturated
+	d.Drive(0.5, -1)
+	d.step(0.02)
+	assert.InDelta(t, -100.0/3, drv.left, 1e-6)
+	assert.InDelta(t, 100, drv.right, 1e-6)
+
+	d.SetTrim(0.1)
+	d.SetDeadband(20)
+	d.Drive(0.5, 0)
+	d.step(0.02)
+	assert.InDelta(t, 20+0.45*80, drv.left, 1e-6)
+	assert.InDelta(t, 60, drv.right, 1e-6)
+}
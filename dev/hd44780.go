@@ -0,0 +1,433 @@
+/*
+Package dev ...
+
+HD44780 is the driver of the 16x2 and 20x4 character lcd modules with a PCF8574 i2c backpack.
+The lcd works in 4-bit mode, the pins of PCF8574 are:
+  - P0: RS
+  - P1: RW
+  - P2: E
+  - P3: backlight
+  - P4-P7: D4-D7
+
+The lcd has 8 custom glyphs in its CGRAM, the glyphs of '°', 'µ' and '³' are defined by default,
+so "23.5°C" and "35µg/m³" can be displayed as they are. The chars which aren't supported are displayed as '?'.
+
+It implements TextDisplay, the text is split into lines by '\n',
+and the line longer than the lcd scrolls like a marquee.
+
+Spec:
+  - power supply:	5V
+  - chars:			16x2 or 20x4
+  - i2c address:	0x27, or 0x3f on PCF8574A
+
+Connect to Pi:
+  - VCC:	any 5v pin
+  - GND:	any gnd pin
+  - SDA:	pin 3 (SDA)
+  - SCL:	pin 5 (SCL)
+*/
+package dev
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHD44780Addr ...
+	DefaultHD44780Addr = 0x27
+
+	lcdRS        = 0x01
+	lcdEnable    = 0x04
+	lcdBacklight = 0x08
+
+	lcdCmdClear        = 0x01
+	lcdCmdHome         = 0x02
+	lcdCmdEntryMode    = 0x06 // move the cursor to the right
+	lcdCmdDisplay      = 0x08
+	lcdDisplayOn       = 0x04
+	lcdCursorOn        = 0x02
+	lcdBlinkOn         = 0x01
+	lcdCmdShiftLeft    = 0x18
+	lcdCmdShiftRight   = 0x1C
+	lcdCmdFunction4Bit = 0x28 // 4-bit, 2 lines, 5x8 dots
+	lcdCmdCGRAM        = 0x40
+	lcdCmdDDRAM        = 0x80
+
+	lcdGlyphs   = 8
+	lcdUnknown  = '?'
+	lcdFrame    = 100 * time.Millisecond
+	lcdMarquee  = 400 * time.Millisecond
+	lcdLineGap  = "   "
+	lcdSlowCmd  = 2 * time.Millisecond
+	lcdInitWait = 50 * time.Millisecond
+)
+
+// the ddram address of the first char in each row
+var lcdRowOffsets = []byte{0x00, 0x40, 0x14, 0x54}
+
+// the default glyphs in 5x8 dots
+var (
+	// GlyphDegree is '°'
+	GlyphDegree = [8]byte{0x06, 0x09, 0x09, 0x06, 0x00, 0x00, 0x00, 0x00}
+	// GlyphMicro is 'µ'
+	GlyphMicro = [8]byte{0x00, 0x00, 0x11, 0x11, 0x11, 0x13, 0x1D, 0x10}
+	// GlyphCubed is '³'
+	GlyphCubed = [8]byte{0x1C, 0x04, 0x0C, 0x04, 0x1C, 0x00, 0x00, 0x00}
+)
+
+// HD44780 ...
+type HD44780 struct {
+	dev  i2cDevice
+	cols int
+	rows int
+
+	mu        sync.Mutex
+	backlight byte
+	display   byte // the display, cursor and blink bits
+	glyphs    map[rune]byte
+	lines     []string
+	start     time.Time
+	scroll    time.Duration
+	shown     [][]byte
+	chQuit    chan bool
+	chDone    chan bool
+}
+
+// NewHD44780 creates a cols x rows lcd at the address on the bus, e.g. NewHD44780("", 0x27, 16, 2)
+func NewHD44780(bus string, addr uint8, cols, rows int) (*HD44780, error) {
+	if addr == 0 {
+		addr = DefaultHD44780Addr
+	}
+	dev, err := openI2C(bus, addr)
+	if err != nil {
+		return nil, err
+	}
+	l, err := newHD44780(dev, cols, rows)
+	if err != nil {
+		dev.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func newHD44780(dev i2cDevice, cols, rows int) (*HD44780, error) {
+	if cols <= 0 || rows <= 0 || rows > len(lcdRowOffsets) {
+		return nil, fmt.Errorf("invalid size: %vx%v", cols, rows)
+	}
+	l := &HD44780{
+		dev:       dev,
+		cols:      cols,
+		rows:      rows,
+		backlight: lcdBacklight,
+		glyphs:    map[rune]byte{},
+		start:     time.Now(),
+		scroll:    lcdMarquee,
+	}
+	if err := l.init(); err != nil {
+		return nil, err
+	}
+	for i, r := range []rune{'°', 'µ', '³'} {
+		g := [][8]byte{GlyphDegree, GlyphMicro, GlyphCubed}[i]
+		if err := l.DefineGlyph(r, g); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// init switches the lcd to 4-bit mode from any state,
+// see figure 24 in the datasheet of HD44780.
+func (l *HD44780) init() error {
+	time.Sleep(lcdInitWait)
+	for _, d := range []time.Duration{5 * time.Millisecond, 1 * time.Millisecond, 1 * time.Millisecond} {
+		if err := l.writeNibble(0x30, 0); err != nil {
+			return err
+		}
+		time.Sleep(d)
+	}
+	if err := l.writeNibble(0x20, 0); err != nil {
+		return err
+	}
+	for _, cmd := range []byte{lcdCmdFunction4Bit, lcdCmdDisplay, lcdCmdClear, lcdCmdEntryMode} {
+		if err := l.command(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefineGlyph defines a custom char in 5x8 dots for the rune, the rows are from the top,
+// and the 5 lowest bits of a row are the dots from the left. There are 8 glyphs at most.
+func (l *HD44780) DefineGlyph(r rune, glyph [8]byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	loc, ok := l.glyphs[r]
+	if !ok {
+		if len(l.glyphs) >= lcdGlyphs {
+			return fmt.Errorf("too many glyphs")
+		}
+		loc = byte(len(l.glyphs))
+	}
+	if err := l.command(lcdCmdCGRAM | loc<<3); err != nil {
+		return err
+	}
+	for _, row := range glyph {
+		if err := l.write(row&0x1F, lcdRS); err != nil {
+			return err
+		}
+	}
+	l.glyphs[r] = loc
+	// the chars shown with the glyph will be rewritten
+	l.shown = nil
+	// the address is in CGRAM now, move it back to DDRAM, or Print would write into the glyphs
+	return l.setCursor(0, 0)
+}
+
+// Clear clears the lcd and moves the cursor home
+func (l *HD44780) Clear() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.clear()
+}
+
+// SetCursor moves the cursor to the col and the row from 0
+func (l *HD44780) SetCursor(col, row int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.setCursor(col, row)
+}
+
+// Print writes the text at the cursor
+func (l *HD44780) Print(text string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.shown = nil
+	for _, c := range l.encode(text) {
+		if err := l.write(c, lcdRS); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShowCursor shows or hides the cursor, and the blinking block at the cursor
+func (l *HD44780) ShowCursor(on, blink bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.display &^= lcdCursorOn | lcdBlinkOn
+	if on {
+		l.display |= lcdCursorOn
+	}
+	if blink {
+		l.display |= lcdBlinkOn
+	}
+	return l.command(lcdCmdDisplay | l.display)
+}
+
+// SetBacklight turns on or off the backlight
+func (l *HD44780) SetBacklight(on bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.backlight = 0
+	if on {
+		l.backlight = lcdBacklight
+	}
+	return l.dev.Write([]byte{l.backlight})
+}
+
+// ScrollLeft shifts the whole display to the left by a char without changing the ram
+func (l *HD44780) ScrollLeft() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.command(lcdCmdShiftLeft)
+}
+
+// ScrollRight shifts the whole display to the right by a char without changing the ram
+func (l *HD44780) ScrollRight() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.command(lcdCmdShiftRight)
+}
+
+// SetScroll sets the interval of scrolling a char for the long lines
+func (l *HD44780) SetScroll(interval time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.scroll = interval
+}
+
+// Display displays the lines split by '\n', the line longer than the lcd scrolls
+func (l *HD44780) Display(text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if text == strings.Join(l.lines, "\n") {
+		return
+	}
+	l.lines = strings.Split(text, "\n")
+	l.start = time.Now()
+}
+
+// Open turns on the display and the backlight, and keeps updating the text in background
+func (l *HD44780) Open() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.chQuit != nil {
+		return
+	}
+	l.backlight = lcdBacklight
+	l.display |= lcdDisplayOn
+	if err := l.command(lcdCmdDisplay | l.display); err != nil {
+		log.Printf("[hd44780]failed to turn on the display, error: %v", err)
+	}
+	l.shown = nil
+	l.chQuit = make(chan bool)
+	l.chDone = make(chan bool)
+	go l.update(l.chQuit, l.chDone)
+}
+
+// Close clears and turns off the display and the backlight
+func (l *HD44780) Close() {
+	l.mu.Lock()
+	chQuit, chDone := l.chQuit, l.chDone
+	l.chQuit, l.chDone = nil, nil
+	l.mu.Unlock()
+	if chQuit != nil {
+		close(chQuit)
+		<-chDone
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clear()
+	l.display &^= lcdDisplayOn
+	l.backlight = 0
+	l.command(lcdCmdDisplay | l.display)
+}
+
+func (l *HD44780) update(chQuit, chDone chan bool) {
+	defer close(chDone)
+	for {
+		l.mu.Lock()
+		if err := l.render(time.Since(l.start)); err != nil {
+			log.Printf("[hd44780]failed to display, error: %v", err)
+			l.shown = nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-chQuit:
+			return
+		case <-time.After(lcdFrame):
+			// next frame
+		}
+	}
+}
+
+// render writes the chars of the lines changed since the last frame
+func (l *HD44780) render(elapsed time.Duration) error {
+	if l.shown == nil {
+		l.shown = make([][]byte, l.rows)
+	}
+	for row := 0; row < l.rows; row++ {
+		var line []byte
+		if row < len(l.lines) {
+			line = l.frame(l.encode(l.lines[row]), elapsed)
+		}
+		for len(line) < l.cols {
+			line = append(line, ' ')
+		}
+
+		shown := l.shown[row]
+		for col := 0; col < l.cols; {
+			if shown != nil && line[col] == shown[col] {
+				col++
+				continue
+			}
+			// write the changed chars from here, the cursor moves right by itself
+			if err := l.setCursor(col, row); err != nil {
+				return err
+			}
+			for ; col < l.cols && (shown == nil || line[col] != shown[col]); col++ {
+				if err := l.write(line[col], lcdRS); err != nil {
+					return err
+				}
+			}
+		}
+		l.shown[row] = line
+	}
+	return nil
+}
+
+// frame returns the visible part of the line, it scrolls if the line is longer than the lcd
+func (l *HD44780) frame(line []byte, elapsed time.Duration) []byte {
+	if len(line) <= l.cols || l.scroll <= 0 {
+		if len(line) > l.cols {
+			line = line[:l.cols]
+		}
+		return line
+	}
+	loop := append(append(append([]byte{}, line...), lcdLineGap...), line...)
+	pos := int(elapsed/l.scroll) % (len(line) + len(lcdLineGap))
+	return loop[pos : pos+l.cols]
+}
+
+// encode converts the text to the chars of the lcd
+func (l *HD44780) encode(text string) []byte {
+	var chars []byte
+	for _, r := range text {
+		if g, ok := l.glyphs[r]; ok {
+			chars = append(chars, g)
+			continue
+		}
+		if r < 0x20 || r > 0x7D || r == '\\' {
+			// '\' and '~' are '¥' and '→' in the rom
+			r = lcdUnknown
+		}
+		chars = append(chars, byte(r))
+	}
+	return chars
+}
+
+func (l *HD44780) clear() error {
+	l.shown = nil
+	if err := l.command(lcdCmdClear); err != nil {
+		return err
+	}
+	time.Sleep(lcdSlowCmd)
+	return nil
+}
+
+func (l *HD44780) setCursor(col, row int) error {
+	if col < 0 || col >= l.cols || row < 0 || row >= l.rows {
+		return fmt.Errorf("invalid position: (%v, %v)", col, row)
+	}
+	return l.command(lcdCmdDDRAM | (lcdRowOffsets[row] + byte(col)))
+}
+
+func (l *HD44780) command(cmd byte) error {
+	if err := l.write(cmd, 0); err != nil {
+		return err
+	}
+	if cmd == lcdCmdClear || cmd == lcdCmdHome {
+		time.Sleep(lcdSlowCmd)
+	}
+	return nil
+}
+
+// write sends a byte in 2 nibbles, the high one goes first
+func (l *HD44780) write(b, mode byte) error {
+	hi := b&0xF0 | mode | l.backlight
+	lo := b<<4 | mode | l.backlight
+	// E latches the nibble on the falling edge
+	return l.dev.Write([]byte{hi | lcdEnable, hi, lo | lcdEnable, lo})
+}
+
+// writeNibble sends the high 4 bits only, it's used in the initialization
+func (l *HD44780) writeNibble(b, mode byte) error {
+	n := b&0xF0 | mode | l.backlight
+	return l.dev.Write([]byte{n | lcdEnable, n})
+}
@@ -0,0 +1,148 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lcdOp is a byte sent to the lcd, or a nibble in the initialization
+type lcdOp struct {
+	data   bool
+	nibble bool
+	b      byte
+}
+
+// decodeLCD decodes the writes to PCF8574 into the bytes sent to the lcd
+func decodeLCD(writes [][]byte) []lcdOp {
+	var ops []lcdOp
+	for _, w := range writes {
+		switch len(w) {
+		case 2:
+			ops = append(ops, lcdOp{nibble: true, b: w[1] & 0xF0})
+		case 4:
+			ops = append(ops, lcdOp{data: w[1]&lcdRS != 0, b: w[1]&0xF0 | w[3]>>4})
+		}
+	}
+	return ops
+}
+
+func lcdCmds(ops []lcdOp) []byte {
+	var cmds []byte
+	for _, op := range ops {
+		if !op.data && !op.nibble {
+			cmds = append(cmds, op.b)
+		}
+	}
+	return cmds
+}
+
+func lcdData(ops []lcdOp) []byte {
+	var data []byte
+	for _, op := range ops {
+		if op.data {
+			data = append(data, op.b)
+		}
+	}
+	return data
+}
+
+func TestHD44780Init(t *testing.T) {
+	bus := newFakeI2C()
+	l, err := newHD44780(bus, 16, 2)
+	assert.NoError(t, err)
+
+	ops := decodeLCD(bus.writes)
+	assert.Equal(t, []lcdOp{
+		{nibble: true, b: 0x30},
+		{nibble: true, b: 0x30},
+		{nibble: true, b: 0x30},
+		{nibble: true, b: 0x20},
+	}, ops[:4])
+	// the function set, display off, clear, entry mode, and the 3 default glyphs back to DDRAM
+	assert.Equal(t, []byte{0x28, 0x08, 0x01, 0x06, 0x40, 0x80, 0x48, 0x80, 0x50, 0x80}, lcdCmds(ops))
+	assert.Equal(t, GlyphDegree[:], lcdData(ops)[:8])
+	// the backlight is on
+	for _, w := range bus.writes {
+		assert.NotZero(t, w[0]&lcdBacklight)
+	}
+
+	assert.Equal(t, []byte{'2', '3', '.', '5', 0, 'C'}, l.encode("23.5°C"))
+	assert.Equal(t, []byte{'3', '5', 1, 'g', '/', 'm', 2}, l.encode("35µg/m³"))
+	assert.Equal(t, []byte{'?', 'a'}, l.encode("€a"))
+
+	_, err = newHD44780(bus, 16, 5)
+	assert.Error(t, err)
+}
+
+func TestHD44780Glyph(t *testing.T) {
+	bus := newFakeI2C()
+	l, err := newHD44780(bus, 16, 2)
+	assert.NoError(t, err)
+
+	for i := 3; i < lcdGlyphs; i++ {
+		assert.NoError(t, l.DefineGlyph(rune('a'+i), [8]byte{}))
+	}
+	assert.Error(t, l.DefineGlyph('z', [8]byte{}))
+
+	// redefining a glyph reuses its location
+	bus.writes = nil
+	assert.NoError(t, l.DefineGlyph('°', [8]byte{0x1F}))
+	ops := decodeLCD(bus.writes)
+	assert.Equal(t, []byte{0x40, 0x80}, lcdCmds(ops))
+	assert.Equal(t, []byte{0x1F, 0, 0, 0, 0, 0, 0, 0}, lcdData(ops))
+}
+
+func TestHD44780Print(t *testing.T) {
+	bus := newFakeI2C()
+	l, err := newHD44780(bus, 16, 2)
+	assert.NoError(t, err)
+
+	// the chars go to DDRAM after the glyphs were defined in New
+	assert.NoError(t, l.Print("a°"))
+	ops := decodeLCD(bus.writes)
+	cmds := lcdCmds(ops)
+	assert.Equal(t, byte(lcdCmdDDRAM), cmds[len(cmds)-1])
+	assert.Equal(t, []lcdOp{{data: true, b: 'a'}, {data: true, b: 0}}, ops[len(ops)-2:])
+}
+
+func TestHD44780Render(t *testing.T) {
+	bus := newFakeI2C()
+	l, err := newHD44780(bus, 8, 2)
+	assert.NoError(t, err)
+
+	bus.writes = nil
+	l.Display("T:23°C\nH:45%")
+	assert.NoError(t, l.render(0))
+	ops := decodeLCD(bus.writes)
+	assert.Equal(t, []byte{0x80, 0xC0}, lcdCmds(ops))
+	assert.Equal(t, "T:23\x00C  H:45%   ", string(lcdData(ops)))
+
+	// only the changed chars are sent
+	bus.writes = nil
+	l.Display("T:24°C\nH:45%")
+	assert.NoError(t, l.render(0))
+	ops = decodeLCD(bus.writes)
+	assert.Equal(t, []byte{0x83}, lcdCmds(ops))
+	assert.Equal(t, []byte{'4'}, lcdData(ops))
+
+	bus.writes = nil
+	assert.NoError(t, l.render(0))
+	assert.Empty(t, bus.writes)
+}
+
+func TestHD44780Marquee(t *testing.T) {
+	l := &HD44780{cols: 4, scroll: 100 * time.Millisecond}
+	line := []byte("abcdef")
+	assert.Equal(t, "abcd", string(l.frame(line, 0)))
+	assert.Equal(t, "bcde", string(l.frame(line, 150*time.Millisecond)))
+	assert.Equal(t, "f   ", string(l.frame(line, 500*time.Millisecond)))
+	assert.Equal(t, "  ab", string(l.frame(line, 700*time.Millisecond)))
+	// it loops
+	assert.Equal(t, "abcd", string(l.frame(line, 900*time.Millisecond)))
+	assert.Equal(t, "ab", string(l.frame([]byte("ab"), time.Second)))
+
+	l.scroll = 0
+	assert.Equal(t, "abcd", string(l.frame(line, time.Second)))
+}
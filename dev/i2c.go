@@ -0,0 +1,56 @@
+/*
+Package dev ...
+
+I2C devices share the bus helpers in this file.
+
+Config Your Pi:
+1. $ sudo raspi-config
+2. 	-> [5 interface options] -> [p5 i2c] ->[yes] -> [ok]
+3. $ sudo reboot now
+4. check: $ sudo i2cdetect -y 1
+	the address of every connected i2c device should be listed
+*/
+package dev
+
+import (
+	"golang.org/x/exp/io/i2c"
+)
+
+const (
+	defaultI2CBus = "/dev/i2c-1"
+)
+
+// i2cDevice is the subset of *i2c.Device used by the i2c drivers,
+// it makes the drivers possible to work on a fake bus in tests.
+type i2cDevice interface {
+	Read(buf []byte) error
+	Write(buf []byte) error
+	ReadReg(reg byte, buf []byte) error
+	WriteReg(reg byte, buf []byte) error
+	Close() error
+}
+
+func openI2C(bus string, addr uint8) (i2cDevice, error) {
+	if bus == "" {
+		bus = defaultI2CBus
+	}
+	d, err := i2c.Open(&i2c.Devfs{Dev: bus}, int(addr))
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// readReg16 reads a big-endian 16-bit register
+func readReg16(d i2cDevice, reg byte) (uint16, error) {
+	var buf [2]byte
+	if err := d.ReadReg(reg, buf[:]); err != nil {
+		return 0, err
+	}
+	return uint16(buf[0])<<8 | uint16(buf[1]), nil
+}
+
+// writeReg16 writes a big-endian 16-bit register
+func writeReg16(d i2cDevice, reg byte, v uint16) error {
+	return d.WriteReg(reg, []byte{byte(v >> 8), byte(v)})
+}
@@ -0,0 +1,193 @@
+/*
+Package dev ...
+
+IMU fuses the samples of MPU6050 into the attitude with a complementary filter.
+The gyroscope is accurate in a short time but drifts, and the accelerometer is noisy but never drifts,
+so roll and pitch trust the gyroscope in high frequency and the accelerometer in low frequency.
+There is no magnetometer, so yaw is only integrated from the gyroscope.
+*/
+package dev
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	imuInterval = 10 * time.Millisecond
+	// the weight of gyroscope in the complementary filter
+	imuAlpha = 0.98
+	// it was lifted if the acceleration differs from 1g more than liftThreshold
+	liftThreshold = 0.35
+	liftHold      = 1 * time.Second
+)
+
+// Attitude is roll, pitch and yaw in degree
+type Attitude struct {
+	Roll  float64
+	Pitch float64
+	Yaw   float64
+}
+
+// ComplementaryFilter ...
+type ComplementaryFilter struct {
+	alpha  float64
+	att    Attitude
+	inited bool
+}
+
+// NewComplementaryFilter ...
+func NewComplementaryFilter(alpha float64) *ComplementaryFilter {
+	return &ComplementaryFilter{
+		alpha: alpha,
+	}
+}
+
+// Update updates the attitude with a sample, dt is the seconds since the last sample
+func (f *ComplementaryFilter) Update(s *IMUSample, dt float64) Attitude {
+	accRoll := math.Atan2(s.Ay, s.Az) * 180 / math.Pi
+	accPitch := math.Atan2(-s.Ax, math.Sqrt(s.Ay*s.Ay+s.Az*s.Az)) * 180 / math.Pi
+	if !f.inited {
+		f.att.Roll = accRoll
+		f.att.Pitch = accPitch
+		f.inited = true
+		return f.att
+	}
+	f.att.Roll = f.alpha*(f.att.Roll+s.Gx*dt) + (1-f.alpha)*accRoll
+	f.att.Pitch = f.alpha*(f.att.Pitch+s.Gy*dt) + (1-f.alpha)*accPitch
+	f.att.Yaw = normalizeAngle(f.att.Yaw + s.Gz*dt)
+	return f.att
+}
+
+// ResetYaw ...
+func (f *ComplementaryFilter) ResetYaw() {
+	f.att.Yaw = 0
+}
+
+// IMU ...
+type IMU struct {
+	mpu    *MPU6050
+	filter *ComplementaryFilter
+
+	mu       sync.Mutex
+	att      Attitude
+	liftedAt time.Time
+	chQuit   chan bool
+	once     sync.Once
+}
+
+// NewIMU ...
+func NewIMU(mpu *MPU6050) *IMU {
+	return &IMU{
+		mpu:    mpu,
+		filter: NewComplementaryFilter(imuAlpha),
+	}
+}
+
+// Start calibrates the gyroscope and keeps updating the attitude in background,
+// please keep the car still when starting.
+func (i *IMU) Start() error {
+	if err := i.mpu.CalibrateGyro(100); err != nil {
+		return err
+	}
+	i.chQuit = make(chan bool)
+	go i.start()
+	return nil
+}
+
+// Stop ...
+func (i *IMU) Stop() {
+	if i.chQuit == nil {
+		return
+	}
+	i.once.Do(func() {
+		close(i.chQuit)
+	})
+}
+
+func (i *IMU) start() {
+	last := time.Now()
+	for {
+		select {
+		case <-i.chQuit:
+			return
+		default:
+			// do nothing
+		}
+
+		s, err := i.mpu.Read()
+		if err != nil {
+			log.Printf("[imu]failed to read mpu6050, error: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		now := time.Now()
+		dt := now.Sub(last).Seconds()
+		last = now
+
+		i.mu.Lock()
+		i.att = i.filter.Update(s, dt)
+		a := math.Sqrt(s.Ax*s.Ax + s.Ay*s.Ay + s.Az*s.Az)
+		if math.Abs(a-1) > liftThreshold {
+			i.liftedAt = now
+		}
+		i.mu.Unlock()
+
+		time.Sleep(imuInterval)
+	}
+}
+
+// Attitude ...
+func (i *IMU) Attitude() Attitude {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.att
+}
+
+// Heading returns the heading in degree [0, 360) since the last reset,
+// it increases when turning right, like a compass.
+func (i *IMU) Heading() float64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	h := math.Mod(-i.att.Yaw, 360)
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// ResetHeading makes current heading to be 0
+func (i *IMU) ResetHeading() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.filter.ResetYaw()
+	i.att.Yaw = 0
+}
+
+// Tilted returns true if roll or pitch is more than maxAngle in degree
+func (i *IMU) Tilted(maxAngle float64) bool {
+	att := i.Attitude()
+	return math.Abs(att.Roll) > maxAngle || math.Abs(att.Pitch) > maxAngle
+}
+
+// Lifted returns true if there was a vertical acceleration in the last second,
+// it's the way to detect somebody picked up the car.
+func (i *IMU) Lifted() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return time.Since(i.liftedAt) < liftHold
+}
+
+// normalizeAngle normalizes an angle in degree into (-180, 180]
+func normalizeAngle(a float64) float64 {
+	a = math.Mod(a, 360)
+	if a > 180 {
+		a -= 360
+	}
+	if a <= -180 {
+		a += 360
+	}
+	return a
+}
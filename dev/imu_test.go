@@ -0,0 +1,62 @@
+package dev
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComplementaryFilter(t *testing.T) {
+	f := NewComplementaryFilter(0.98)
+
+	// lying flat and still
+	att := f.Update(&IMUSample{Az: 1}, 0.01)
+	assert.InDelta(t, 0, att.Roll, 1e-6)
+	assert.InDelta(t, 0, att.Pitch, 1e-6)
+
+	// rotating 90 degree/s around z-axis for 1 second
+	for i := 0; i < 100; i++ {
+		att = f.Update(&IMUSample{Az: 1, Gz: 90}, 0.01)
+	}
+	assert.InDelta(t, 90, att.Yaw, 1e-6)
+
+	// tilted 30 degree around x-axis, the gyroscope says nothing,
+	// the accelerometer pulls roll to 30 degree slowly
+	for i := 0; i < 500; i++ {
+		att = f.Update(&IMUSample{Ay: 0.5, Az: 0.866}, 0.01)
+	}
+	assert.InDelta(t, 30, att.Roll, 0.1)
+	assert.InDelta(t, 0, att.Pitch, 0.1)
+
+	f.ResetYaw()
+	att = f.Update(&IMUSample{Ay: 0.5, Az: 0.866}, 0.01)
+	assert.InDelta(t, 0, att.Yaw, 1e-6)
+}
+
+func TestNormalizeAngle(t *testing.T) {
+	testCases := []struct {
+		angle    float64
+		expected float64
+	}{
+		{0, 0},
+		{180, 180},
+		{-180, 180},
+		{270, -90},
+		{-270, 90},
+		{725, 5},
+	}
+	for _, test := range testCases {
+		assert.InDelta(t, test.expected, normalizeAngle(test.angle), 1e-9)
+	}
+}
+
+func TestIMUStop(t *testing.T) {
+	i := NewIMU(nil)
+	assert.NotPanics(t, i.Stop)
+
+	i.chQuit = make(chan bool)
+	assert.NotPanics(t, i.Stop)
+	assert.NotPanics(t, i.Stop)
+	_, ok := <-i.chQuit
+	assert.False(t, ok)
+}
@@ -0,0 +1,161 @@
+/*
+Package dev ...
+
+INA219 is the driver of INA219, a current and power monitor over i2c.
+It measures the voltage of the bus and the current through a shunt resistor,
+so it can tell the voltage and the consumption of the battery.
+
+Spec:
+  - power supply:	3.0V - 5.5V
+  - bus voltage:	0 - 26V
+  - address:		0x40 - 0x4F, 0x40 in default
+  - shunt:			0.1 ohm on the most modules
+
+Connect to Pi:
+  - VCC:	any 3.3v pin
+  - GND:	any gnd pin
+  - SCL:	pin 5 (SCL)
+  - SDA:	pin 3 (SDA)
+  - VIN+:	the battery(+)
+  - VIN-:	the load(+), e.g. the power input of L298N
+*/
+package dev
+
+import (
+	"errors"
+	"math"
+	"sync"
+)
+
+const (
+	inaRegConfig      = 0x00
+	inaRegShuntVolt   = 0x01
+	inaRegBusVolt     = 0x02
+	inaRegPower       = 0x03
+	inaRegCurrent     = 0x04
+	inaRegCalibration = 0x05
+
+	// 32V bus range, +/-320mV shunt range, 12-bit with 128 samples averaging, continuous
+	inaConfig = 0x3FFF
+	inaReset  = 0x8000
+)
+
+// INA219 ...
+type INA219 struct {
+	dev        i2cDevice
+	mu         sync.Mutex
+	cal        uint16
+	currentLSB float64
+	powerLSB   float64
+}
+
+// NewINA219 creates an INA219 with the resistance of the shunt in ohm,
+// and the max expected current in ampere which determines the resolution of current.
+func NewINA219(addr uint8, shunt, maxCurrent float64) (*INA219, error) {
+	d, err := openI2C(defaultI2CBus, addr)
+	if err != nil {
+		return nil, err
+	}
+	ina := &INA219{
+		dev: d,
+	}
+	if err := writeReg16(d, inaRegConfig, inaReset); err != nil {
+		d.Close()
+		return nil, err
+	}
+	if err := writeReg16(d, inaRegConfig, inaConfig); err != nil {
+		d.Close()
+		return nil, err
+	}
+	if err := ina.Calibrate(shunt, maxCurrent); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return ina, nil
+}
+
+// Calibrate writes the calibration register,
+// see "Programming the Calibration Register" in the datasheet.
+func (ina *INA219) Calibrate(shunt, maxCurrent float64) error {
+	if shunt <= 0 || maxCurrent <= 0 {
+		return errors.New("invalid shunt or max current")
+	}
+	currentLSB := maxCurrent / 32768
+	cal := math.Trunc(0.04096 / (currentLSB * shunt))
+	if cal > 0xFFFE {
+		return errors.New("the max current is too small for the shunt")
+	}
+
+	ina.mu.Lock()
+	defer ina.mu.Unlock()
+	// the lowest bit is always 0
+	ina.cal = uint16(cal) &^ 1
+	ina.currentLSB = currentLSB
+	ina.powerLSB = 20 * currentLSB
+	return writeReg16(ina.dev, inaRegCalibration, ina.cal)
+}
+
+// BusVoltage returns the voltage between VIN- and GND in volt
+func (ina *INA219) BusVoltage() (float64, error) {
+	v, err := readReg16(ina.dev, inaRegBusVolt)
+	if err != nil {
+		return 0, err
+	}
+	if v&0x01 != 0 {
+		return 0, errors.New("ina219 math overflow")
+	}
+	// bit 15~3, 4mV per bit
+	return float64(v>>3) * 0.004, nil
+}
+
+// Volts is same as BusVoltage, makes INA219 can be used as an analog input
+func (ina *INA219) Volts() (float64, error) {
+	return ina.BusVoltage()
+}
+
+// ShuntVoltage returns the voltage across the shunt in volt
+func (ina *INA219) ShuntVoltage() (float64, error) {
+	v, err := readReg16(ina.dev, inaRegShuntVolt)
+	if err != nil {
+		return 0, err
+	}
+	// 10uV per bit
+	return float64(int16(v)) * 0.00001, nil
+}
+
+// Current returns the current in ampere
+func (ina *INA219) Current() (float64, error) {
+	ina.mu.Lock()
+	defer ina.mu.Unlock()
+	// the chip resets the calibration register on a brownout, which is common with motors,
+	// so write it again before reading.
+	if err := writeReg16(ina.dev, inaRegCalibration, ina.cal); err != nil {
+		return 0, err
+	}
+	v, err := readReg16(ina.dev, inaRegCurrent)
+	if err != nil {
+		return 0, err
+	}
+	return float64(int16(v)) * ina.currentLSB, nil
+}
+
+// Power returns the power in watt
+func (ina *INA219) Power() (float64, error) {
+	ina.mu.Lock()
+	defer ina.mu.Unlock()
+	if err := writeReg16(ina.dev, inaRegCalibration, ina.cal); err != nil {
+		return 0, err
+	}
+	v, err := readReg16(ina.dev, inaRegPower)
+	if err != nil {
+		return 0, err
+	}
+	return float64(v) * ina.powerLSB, nil
+}
+
+// Close ...
+func (ina *INA219) Close() {
+	// power-down mode
+	writeReg16(ina.dev, inaRegConfig, inaConfig&^0x07)
+	ina.dev.Close()
+}
@@ -0,0 +1,356 @@
+/*
+Package dev ...
+
+IRReceiver decodes the frames of an infrared remote control from a receiver like TSOP38238 or VS1838B.
+The receiver demodulates the 38kHz carrier, its output goes low when it sees the carrier(a mark),
+and keeps high in the spaces. The driver timestamps the edges on the pin,
+and decodes the marks and spaces with NEC and RC5 protocols,
+which are used by the most cheap remotes.
+
+Connect to Pi:
+  - VCC:	any 3.3v pin
+  - GND:	any gnd pin
+  - OUT:	any data pin
+
+NEC frame:
+	9ms mark, 4.5ms space, 32 bits, 562.5us mark
+	bit 0: 562.5us mark, 562.5us space
+	bit 1: 562.5us mark, 1687.5us space
+	bits: address, ^address, command, ^command, lsb first
+	repeat: 9ms mark, 2.25ms space, 562.5us mark, every 108ms when a key is held
+
+RC5 frame, manchester coded with 1778us per bit:
+	bit 0: 889us mark, 889us space
+	bit 1: 889us space, 889us mark
+	bits: start(1), field(inverted bit 6 of command), toggle, 5-bit address, 6-bit command, msb first
+*/
+package dev
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	// the frame ends if there was no edge in irFrameGap
+	irFrameGap = 20 * time.Millisecond
+	// the interval of polling the pin when it's idle
+	irIdleInterval = 100 * time.Microsecond
+	// a duration matches the expected one within irTolerance
+	irTolerance = 0.35
+
+	necLeadMark     = 9000 * time.Microsecond
+	necLeadSpace    = 4500 * time.Microsecond
+	necRepeatSpace  = 2250 * time.Microsecond
+	necBitMark      = 562 * time.Microsecond
+	necZeroSpace    = 562 * time.Microsecond
+	necOneSpace     = 1687 * time.Microsecond
+	rc5HalfBit      = 889 * time.Microsecond
+	rc5Bits         = 14
+	irRepeatTimeout = 250 * time.Millisecond
+)
+
+// IRProtocol ...
+type IRProtocol string
+
+// IR protocols
+const (
+	NEC IRProtocol = "nec"
+	RC5 IRProtocol = "rc5"
+)
+
+// IRCode is a decoded frame
+type IRCode struct {
+	Protocol IRProtocol
+	Address  uint16
+	Command  uint16
+	// Repeat is true for the repeat code of NEC, Address and Command are empty in the repeat code
+	Repeat bool
+	// Toggle flips on every new key press in RC5
+	Toggle bool
+	Time   time.Time
+}
+
+func (c IRCode) String() string {
+	if c.Repeat {
+		return fmt.Sprintf("%v repeat", c.Protocol)
+	}
+	return fmt.Sprintf("%v address=0x%02X command=0x%02X", c.Protocol, c.Address, c.Command)
+}
+
+// IRReceiver ...
+type IRReceiver struct {
+	pin    rpio.Pin
+	codes  chan IRCode
+	chQuit chan bool
+}
+
+// NewIRReceiver ...
+func NewIRReceiver(pin uint8) *IRReceiver {
+	r := &IRReceiver{
+		pin:   rpio.Pin(pin),
+		codes: make(chan IRCode, chSize),
+	}
+	r.pin.Input()
+	r.pin.PullUp()
+	return r
+}
+
+// Start keeps receiving the frames in background
+func (r *IRReceiver) Start() {
+	r.chQuit = make(chan bool)
+	go r.start()
+}
+
+// Stop ...
+func (r *IRReceiver) Stop() {
+	if r.chQuit != nil {
+		close(r.chQuit)
+	}
+}
+
+// Codes returns the channel of the decoded codes,
+// the codes will be dropped if nobody reads them.
+func (r *IRReceiver) Codes() <-chan IRCode {
+	return r.codes
+}
+
+func (r *IRReceiver) start() {
+	var (
+		durations []time.Duration
+		level     = rpio.High
+		lastEdge  time.Time
+	)
+	for {
+		select {
+		case <-r.chQuit:
+			return
+		default:
+			// do nothing
+		}
+
+		l := r.pin.Read()
+		now := time.Now()
+		if l != level {
+			// the first edge of a frame is the falling edge of the leading mark
+			if len(durations) > 0 || l == rpio.High {
+				durations = append(durations, now.Sub(lastEdge))
+			}
+			level = l
+			lastEdge = now
+			continue
+		}
+
+		if level == rpio.High && len(durations) > 0 && now.Sub(lastEdge) > irFrameGap {
+			code, err := DecodeIR(durations)
+			durations = durations[:0]
+			if err == nil {
+				code.Time = now
+				select {
+				case r.codes <- code:
+				default:
+					// nobody is listening
+				}
+			}
+			continue
+		}
+
+		if level == rpio.High && len(durations) == 0 {
+			time.Sleep(irIdleInterval)
+		}
+	}
+}
+
+// DecodeIR decodes the durations of the marks and spaces in a frame,
+// the durations begin with a mark, and the trailing space isn't included.
+func DecodeIR(durations []time.Duration) (IRCode, error) {
+	if code, err := DecodeNEC(durations); err == nil {
+		return code, nil
+	}
+	return DecodeRC5(durations)
+}
+
+// DecodeNEC decodes a frame or a repeat code of NEC
+func DecodeNEC(d []time.Duration) (IRCode, error) {
+	if len(d) < 3 || !irMatch(d[0], necLeadMark) {
+		return IRCode{}, errors.New("not a nec frame")
+	}
+	if len(d) == 3 && irMatch(d[1], necRepeatSpace) && irMatch(d[2], necBitMark) {
+		return IRCode{Protocol: NEC, Repeat: true}, nil
+	}
+	if len(d) != 67 || !irMatch(d[1], necLeadSpace) {
+		return IRCode{}, errors.New("not a nec frame")
+	}
+
+	var bits uint32
+	for i := 0; i < 32; i++ {
+		mark, space := d[2+2*i], d[3+2*i]
+		if !irMatch(mark, necBitMark) {
+			return IRCode{}, fmt.Errorf("invalid mark of bit %v", i)
+		}
+		switch {
+		case irMatch(space, necZeroSpace):
+			// bit 0
+		case irMatch(space, necOneSpace):
+			bits |= 1 << uint(i)
+		default:
+			return IRCode{}, fmt.Errorf("invalid space of bit %v", i)
+		}
+	}
+
+	addr, naddr := uint8(bits), uint8(bits>>8)
+	cmd, ncmd := uint8(bits>>16), uint8(bits>>24)
+	if cmd != ^ncmd {
+		return IRCode{}, errors.New("nec command check failed")
+	}
+	code := IRCode{
+		Protocol: NEC,
+		Address:  uint16(addr),
+		Command:  uint16(cmd),
+	}
+	if addr != ^naddr {
+		// extended nec with 16-bit address
+		code.Address = uint16(naddr)<<8 | uint16(addr)
+	}
+	return code, nil
+}
+
+// DecodeRC5 decodes a frame of RC5 or RC5X
+func DecodeRC5(d []time.Duration) (IRCode, error) {
+	// the first half of the start bit is a space which can't be seen
+	halves := []bool{false}
+	mark := true
+	for _, v := range d {
+		n := int(math.Round(float64(v) / float64(rc5HalfBit)))
+		if n < 1 || n > 2 || !irMatch(v, time.Duration(n)*rc5HalfBit) {
+			return IRCode{}, errors.New("not a rc5 frame")
+		}
+		for i := 0; i < n; i++ {
+			halves = append(halves, mark)
+		}
+		mark = !mark
+	}
+	// the last half is a space which can't be seen if the last bit is 0
+	if len(halves) == 2*rc5Bits-1 {
+		halves = append(halves, false)
+	}
+	if len(halves) != 2*rc5Bits {
+		return IRCode{}, errors.New("not a rc5 frame")
+	}
+
+	var bits uint16
+	for i := 0; i < rc5Bits; i++ {
+		first, second := halves[2*i], halves[2*i+1]
+		if first == second {
+			return IRCode{}, errors.New("invalid manchester code")
+		}
+		bits <<= 1
+		if second {
+			bits |= 1
+		}
+	}
+
+	code := IRCode{
+		Protocol: RC5,
+		Toggle:   bits>>11&0x01 == 1,
+		Address:  bits >> 6 & 0x1F,
+		Command:  bits & 0x3F,
+	}
+	if bits>>12&0x01 == 0 {
+		code.Command |= 0x40
+	}
+	return code, nil
+}
+
+func irMatch(d, expected time.Duration) bool {
+	diff := math.Abs(float64(d - expected))
+	return diff <= float64(expected)*irTolerance
+}
+
+// IRKeyMap maps the codes of a remote to the names of keys
+type IRKeyMap struct {
+	mu      sync.Mutex
+	keys    map[IRCode]string
+	lastKey string
+	lastAt  time.Time
+	toggle  bool
+}
+
+// NewIRKeyMap ...
+func NewIRKeyMap() *IRKeyMap {
+	return &IRKeyMap{
+		keys: map[IRCode]string{},
+	}
+}
+
+// Add maps a key to the address and command of a protocol
+func (m *IRKeyMap) Add(key string, protocol IRProtocol, addr, cmd uint16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[IRCode{Protocol: protocol, Address: addr, Command: cmd}] = key
+}
+
+// Key returns the name of the key of a code,
+// repeat is true if the key is being held.
+func (m *IRKeyMap) Key(code IRCode) (key string, repeat bool, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	held := m.lastKey != "" && code.Time.Sub(m.lastAt) < irRepeatTimeout
+	if code.Repeat {
+		if !held {
+			return "", false, false
+		}
+		m.lastAt = code.Time
+		return m.lastKey, true, true
+	}
+
+	key, ok = m.keys[IRCode{Protocol: code.Protocol, Address: code.Address, Command: code.Command}]
+	if !ok {
+		m.lastKey = ""
+		return "", false, false
+	}
+	// rc5 repeats the same frame with the same toggle bit when a key is held
+	repeat = held && code.Protocol == RC5 && key == m.lastKey && code.Toggle == m.toggle
+	m.lastKey = key
+	m.lastAt = code.Time
+	m.toggle = code.Toggle
+	return key, repeat, true
+}
+
+// NewCarMP3KeyMap creates the key map of the 21-key "Car MP3" remote,
+// which comes with the most infrared receiver kits.
+func NewCarMP3KeyMap() *IRKeyMap {
+	m := NewIRKeyMap()
+	for key, cmd := range map[string]uint16{
+		"ch-":  0x45,
+		"ch":   0x46,
+		"ch+":  0x47,
+		"prev": 0x44,
+		"next": 0x40,
+		"play": 0x43,
+		"vol-": 0x07,
+		"vol+": 0x15,
+		"eq":   0x09,
+		"0":    0x16,
+		"100+": 0x19,
+		"200+": 0x0D,
+		"1":    0x0C,
+		"2":    0x18,
+		"3":    0x5E,
+		"4":    0x08,
+		"5":    0x1C,
+		"6":    0x5A,
+		"7":    0x42,
+		"8":    0x52,
+		"9":    0x4A,
+	} {
+		m.Add(key, NEC, 0x00, cmd)
+	}
+	return m
+}
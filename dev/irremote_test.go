@@ -0,0 +1,135 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func necFrame(addr, naddr, cmd byte) []time.Duration {
+	d := []time.Duration{9000 * time.Microsecond, 4500 * time.Microsecond}
+	bits := uint32(addr) | uint32(naddr)<<8 | uint32(cmd)<<16 | uint32(^cmd)<<24
+	for i := 0; i < 32; i++ {
+		// make the timings a little off like a real receiver
+		d = append(d, 600*time.Microsecond)
+		if bits>>uint(i)&0x01 == 1 {
+			d = append(d, 1650*time.Microsecond)
+		} else {
+			d = append(d, 520*time.Microsecond)
+		}
+	}
+	return append(d, 600*time.Microsecond)
+}
+
+func rc5Frame(toggle bool, addr, cmd uint16) []time.Duration {
+	bits := uint16(1)<<13 | addr<<6 | cmd&0x3F
+	if cmd&0x40 == 0 {
+		bits |= 1 << 12
+	}
+	if toggle {
+		bits |= 1 << 11
+	}
+	var halves []bool
+	for i := rc5Bits - 1; i >= 0; i-- {
+		one := bits>>uint(i)&0x01 == 1
+		halves = append(halves, !one, one)
+	}
+	// drop the leading and trailing spaces, and merge the same levels
+	for len(halves) > 0 && !halves[0] {
+		halves = halves[1:]
+	}
+	for len(halves) > 0 && !halves[len(halves)-1] {
+		halves = halves[:len(halves)-1]
+	}
+	var d []time.Duration
+	for i, h := range halves {
+		if i > 0 && h == halves[i-1] {
+			d[len(d)-1] += rc5HalfBit
+			continue
+		}
+		d = append(d, rc5HalfBit)
+	}
+	return d
+}
+
+func TestDecodeNEC(t *testing.T) {
+	code, err := DecodeIR(necFrame(0x00, 0xFF, 0x18))
+	assert.NoError(t, err)
+	assert.Equal(t, NEC, code.Protocol)
+	assert.Equal(t, uint16(0x00), code.Address)
+	assert.Equal(t, uint16(0x18), code.Command)
+	assert.False(t, code.Repeat)
+
+	// extended address
+	code, err = DecodeIR(necFrame(0x04, 0xFC, 0x08))
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0xFC04), code.Address)
+
+	code, err = DecodeIR([]time.Duration{9000 * time.Microsecond, 2250 * time.Microsecond, 560 * time.Microsecond})
+	assert.NoError(t, err)
+	assert.True(t, code.Repeat)
+
+	bad := necFrame(0x00, 0xFF, 0x18)
+	bad[20] = 3000 * time.Microsecond
+	_, err = DecodeNEC(bad)
+	assert.Error(t, err)
+}
+
+func TestDecodeRC5(t *testing.T) {
+	testCases := []struct {
+		toggle bool
+		addr   uint16
+		cmd    uint16
+	}{
+		{false, 0x00, 0x0C},
+		{true, 0x05, 0x35},
+		{false, 0x1F, 0x3F},
+		{true, 0x10, 0x41},
+	}
+	for _, test := range testCases {
+		code, err := DecodeIR(rc5Frame(test.toggle, test.addr, test.cmd))
+		assert.NoError(t, err)
+		assert.Equal(t, RC5, code.Protocol)
+		assert.Equal(t, test.toggle, code.Toggle)
+		assert.Equal(t, test.addr, code.Address)
+		assert.Equal(t, test.cmd, code.Command)
+	}
+
+	_, err := DecodeRC5([]time.Duration{rc5HalfBit, 3 * rc5HalfBit})
+	assert.Error(t, err)
+}
+
+func TestIRKeyMap(t *testing.T) {
+	m := NewIRKeyMap()
+	m.Add("up", NEC, 0x00, 0x18)
+	m.Add("power", RC5, 0x00, 0x0C)
+	now := time.Now()
+
+	key, repeat, ok := m.Key(IRCode{Protocol: NEC, Address: 0x00, Command: 0x18, Time: now})
+	assert.True(t, ok)
+	assert.False(t, repeat)
+	assert.Equal(t, "up", key)
+
+	key, repeat, ok = m.Key(IRCode{Protocol: NEC, Repeat: true, Time: now.Add(108 * time.Millisecond)})
+	assert.True(t, ok)
+	assert.True(t, repeat)
+	assert.Equal(t, "up", key)
+
+	// a repeat code long after the key
+	_, _, ok = m.Key(IRCode{Protocol: NEC, Repeat: true, Time: now.Add(2 * time.Second)})
+	assert.False(t, ok)
+
+	_, _, ok = m.Key(IRCode{Protocol: NEC, Address: 0x00, Command: 0x19, Time: now})
+	assert.False(t, ok)
+
+	now = now.Add(5 * time.Second)
+	_, repeat, ok = m.Key(IRCode{Protocol: RC5, Command: 0x0C, Toggle: true, Time: now})
+	assert.True(t, ok)
+	assert.False(t, repeat)
+	_, repeat, _ = m.Key(IRCode{Protocol: RC5, Command: 0x0C, Toggle: true, Time: now.Add(114 * time.Millisecond)})
+	assert.True(t, repeat)
+	// pressed again
+	_, repeat, _ = m.Key(IRCode{Protocol: RC5, Command: 0x0C, Toggle: false, Time: now.Add(300 * time.Millisecond)})
+	assert.False(t, repeat)
+}
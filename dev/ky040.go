@@ -0,0 +1,167 @@
+/*
+Package dev ...
+
+KY040 is the driver of KY-040 rotary encoder module with a push button.
+CLK and DT output the quadrature signals, a detent goes through 4 states,
+and the button pulls SW low when it's pressed.
+Turning clockwise is positive.
+
+Connect to Pi:
+  - +:		any 3.3v pin
+  - GND:	any gnd pin
+  - CLK:	any data pin
+  - DT:		any data pin
+  - SW:		any data pin
+*/
+package dev
+
+import (
+	"sync"
+	"time"
+
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	ky040Interval  = 1 * time.Millisecond
+	ky040Debounce  = 20 * time.Millisecond
+	ky040PerDetent = 4
+)
+
+// ky040Steps is the direction from a state to the next one, the index is prev<<2 | cur,
+// a state is clk<<1 | dt, and 0 means an invalid or no transition.
+var ky040Steps = [16]int{0, -1, 1, 0, 1, 0, 0, -1, -1, 0, 0, 1, 0, 1, -1, 0}
+
+// KY040 ...
+type KY040 struct {
+	clk rpio.Pin
+	dt  rpio.Pin
+	sw  rpio.Pin
+
+	mu          sync.Mutex
+	onTurn      func(steps int)
+	onPress     func()
+	onLongPress func()
+	chQuit      chan bool
+	once        sync.Once
+
+	inited    bool
+	state     int
+	count     int
+	pressed   bool
+	changedAt time.Time
+	pressedAt time.Time
+	longFired bool
+}
+
+// NewKY040 ...
+func NewKY040(clk, dt, sw uint8) *KY040 {
+	k := &KY040{
+		clk: rpio.Pin(clk),
+		dt:  rpio.Pin(dt),
+		sw:  rpio.Pin(sw),
+	}
+	for _, p := range []rpio.Pin{k.clk, k.dt, k.sw} {
+		p.Input()
+		p.PullUp()
+	}
+	return k
+}
+
+// OnTurn sets the handler of turning, steps is the detents turned, it's negative for anticlockwise
+func (k *KY040) OnTurn(h func(steps int)) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.onTurn = h
+}
+
+// OnPress sets the handler of pressing the button, it's called on release if it isn't a long press
+func (k *KY040) OnPress(h func()) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.onPress = h
+}
+
+// OnLongPress sets the handler of holding the button
+func (k *KY040) OnLongPress(h func()) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.onLongPress = h
+}
+
+// Start keeps polling the encoder in background
+func (k *KY040) Start() {
+	k.chQuit = make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-k.chQuit:
+				return
+			case <-time.After(ky040Interval):
+				k.update(k.clk.Read() == rpio.High, k.dt.Read() == rpio.High, k.sw.Read() == rpio.Low, time.Now())
+			}
+		}
+	}()
+}
+
+// Stop ...
+func (k *KY040) Stop() {
+	if k.chQuit == nil {
+		return
+	}
+	k.once.Do(func() {
+		close(k.chQuit)
+	})
+}
+
+func (k *KY040) update(clk, dt, pressed bool, now time.Time) {
+	k.mu.Lock()
+	var handlers []func()
+
+	state := 0
+	if clk {
+		state |= 2
+	}
+	if dt {
+		state |= 1
+	}
+	if !k.inited {
+		k.inited = true
+		k.state = state
+		k.pressed = pressed
+		k.mu.Unlock()
+		return
+	}
+	k.count += ky040Steps[k.state<<2|state]
+	k.state = state
+	// a detent ends at the resting state
+	if state == 3 && k.count != 0 {
+		if steps := k.count / ky040PerDetent; steps != 0 && k.onTurn != nil {
+			h := k.onTurn
+			handlers = append(handlers, func() { h(steps) })
+		}
+		k.count = 0
+	}
+
+	if pressed != k.pressed && now.Sub(k.changedAt) >= ky040Debounce {
+		k.pressed = pressed
+		k.changedAt = now
+		if pressed {
+			k.pressedAt = now
+			k.longFired = false
+		} else if !k.longFired && k.onPress != nil {
+			handlers = append(handlers, k.onPress)
+		}
+	}
+	if k.pressed && !k.longFired && now.Sub(k.pressedAt) >= longPressTime {
+		k.longFired = true
+		if k.onLongPress != nil {
+			handlers = append(handlers, k.onLongPress)
+		}
+	}
+	k.mu.Unlock()
+
+	for _, h := range handlers {
+		h()
+	}
+}
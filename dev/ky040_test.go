@@ -0,0 +1,79 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKY040Turn(t *testing.T) {
+	k := &KY040{}
+	var steps []int
+	k.OnTurn(func(n int) {
+		steps = append(steps, n)
+	})
+
+	now := time.Now()
+	feed := func(states ...int) {
+		for _, s := range states {
+			now = now.Add(ky040Interval)
+			k.update(s&2 != 0, s&1 != 0, false, now)
+		}
+	}
+
+	feed(3)
+	// clockwise
+	feed(1, 0, 2, 3)
+	assert.Equal(t, []int{1}, steps)
+	// anticlockwise
+	feed(2, 0, 1, 3)
+	assert.Equal(t, []int{1, -1}, steps)
+	// bouncing back and forth isn't a step
+	feed(1, 3, 1, 0, 1, 3)
+	assert.Equal(t, []int{1, -1}, steps)
+	// the same state is repeated in polling
+	feed(1, 1, 0, 0, 2, 2, 3, 3)
+	assert.Equal(t, []int{1, -1, 1}, steps)
+}
+
+func TestKY040Button(t *testing.T) {
+	k := &KY040{}
+	presses, longPresses := 0, 0
+	k.OnPress(func() { presses++ })
+	k.OnLongPress(func() { longPresses++ })
+
+	now := time.Now()
+	k.update(true, true, false, now)
+
+	// press and release
+	k.update(true, true, true, now.Add(100*time.Millisecond))
+	// the bouncing is ignored
+	k.update(true, true, false, now.Add(105*time.Millisecond))
+	k.update(true, true, true, now.Add(110*time.Millisecond))
+	k.update(true, true, false, now.Add(300*time.Millisecond))
+	assert.Equal(t, 1, presses)
+	assert.Equal(t, 0, longPresses)
+
+	// hold
+	k.update(true, true, true, now.Add(1*time.Second))
+	k.update(true, true, true, now.Add(1500*time.Millisecond))
+	assert.Equal(t, 0, longPresses)
+	k.update(true, true, true, now.Add(1800*time.Millisecond))
+	assert.Equal(t, 1, longPresses)
+	k.update(true, true, true, now.Add(2500*time.Millisecond))
+	k.update(true, true, false, now.Add(3*time.Second))
+	assert.Equal(t, 1, presses)
+	assert.Equal(t, 1, longPresses)
+}
+
+func TestKY040Stop(t *testing.T) {
+	k := &KY040{}
+	assert.NotPanics(t, k.Stop)
+
+	k.chQuit = make(chan bool)
+	assert.NotPanics(t, k.Stop)
+	assert.NotPanics(t, k.Stop)
+	_, ok := <-k.chQuit
+	assert.False(t, ok)
+}
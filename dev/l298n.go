@@ -28,45 +28,88 @@ Pins:
  - EN1: enable pin for motor A
  - EN2: enable pin for motor B
 
+Motor A is the left motor of the car, and motor B is the right one.
 */
 package dev
 
 import (
+	"log"
+	"math"
+	"sync"
+
 	"github.com/stianeikeland/go-rpio"
 )
 
+// L298NMotor is one of the two motors
+type L298NMotor int
+
+// L298N motors
+const (
+	MotorA L298NMotor = iota
+	MotorB
+)
+
+// l298nPin is an input pin of L298N
+type l298nPin interface {
+	High()
+	Low()
+}
+
+// l298nPWM is an enable pin of L298N, the duty cycle controls the speed
+type l298nPWM interface {
+	DutyCycle(dutyLen, cycleLen uint32)
+}
+
 // L298N ...
 type L298N struct {
-	in1 rpio.Pin
-	in2 rpio.Pin
-	in3 rpio.Pin
-	in4 rpio.Pin
-	ena rpio.Pin
-	enb rpio.Pin
+	in1 l298nPin
+	in2 l298nPin
+	in3 l298nPin
+	in4 l298nPin
+	ena l298nPWM
+	enb l298nPWM
+
+	mu sync.Mutex
+	// the duty set by Speed, Forward, Backward, Left and Right run at it
+	duty uint32
 }
 
 // NewL298N ...
 func NewL298N(in1, in2, in3, in4, ena, enb uint8) *L298N {
+	ins := []rpio.Pin{rpio.Pin(in1), rpio.Pin(in2), rpio.Pin(in3), rpio.Pin(in4)}
+	for _, in := range ins {
+		in.Output()
+		in.Low()
+	}
+	ens := []rpio.Pin{rpio.Pin(ena), rpio.Pin(enb)}
+	for _, en := range ens {
+		en.Pwm()
+		en.Freq(64000)
+	}
+	return newL298N(ins[0], ins[1], ins[2], ins[3], ens[0], ens[1])
+}
+
+// NewL298NWithPWM creates a L298N whose enable pins are driven by the pwm outputs, e.g. the channels of PCA9685.
+// It's needed for SetSpeeds if the enable pins are on the same pwm channel of pi, like gpio 13 and 19,
+// since they always run at the same duty.
+func NewL298NWithPWM(in1, in2, in3, in4 uint8, ena, enb PWMWriter) *L298N {
+	ins := []rpio.Pin{rpio.Pin(in1), rpio.Pin(in2), rpio.Pin(in3), rpio.Pin(in4)}
+	for _, in := range ins {
+		in.Output()
+		in.Low()
+	}
+	return newL298N(ins[0], ins[1], ins[2], ins[3], &pwmEnable{ena}, &pwmEnable{enb})
+}
+
+func newL298N(in1, in2, in3, in4 l298nPin, ena, enb l298nPWM) *L298N {
 	l := &L298N{
-		in1: rpio.Pin(in1),
-		in2: rpio.Pin(in2),
-		in3: rpio.Pin(in3),
-		in4: rpio.Pin(in4),
-		ena: rpio.Pin(ena),
-		enb: rpio.Pin(enb),
+		in1: in1,
+		in2: in2,
+		in3: in3,
+		in4: in4,
+		ena: ena,
+		enb: enb,
 	}
-	l.in1.Output()
-	l.in2.Output()
-	l.in3.Output()
-	l.in4.Output()
-	l.in1.Low()
-	l.in2.Low()
-	l.in3.Low()
-	l.in4.Low()
-	l.ena.Pwm()
-	l.enb.Pwm()
-	l.ena.Freq(64000)
-	l.enb.Freq(64000)
 	l.Speed(30)
 	return l
 }
@@ -77,6 +120,7 @@ func (l *L298N) Forward() {
 	l.in2.Low()
 	l.in3.High()
 	l.in4.Low()
+	l.enable()
 }
 
 // Backward ...
@@ -85,6 +129,7 @@ func (l *L298N) Backward() {
 	l.in2.High()
 	l.in3.Low()
 	l.in4.High()
+	l.enable()
 }
 
 // Left ...
@@ -93,6 +138,7 @@ func (l *L298N) Left() {
 	l.in2.High()
 	l.in3.High()
 	l.in4.Low()
+	l.enable()
 }
 
 // Right ...
@@ -101,6 +147,7 @@ func (l *L298N) Right() {
 	l.in2.Low()
 	l.in3.Low()
 	l.in4.High()
+	l.enable()
 }
 
 // Stop ...
@@ -111,8 +158,89 @@ func (l *L298N) Stop() {
 	l.in4.Low()
 }
 
-// Speed ...
+// Speed sets the same speed in percent for both motors,
+// it's kept for Forward, Backward, Left and Right after the speeds were set by SetMotor, Brake or Coast.
 func (l *L298N) Speed(s uint32) {
-	l.ena.DutyCycle(s, 100)
-	l.enb.DutyCycle(s, 100)
+	l.mu.Lock()
+	l.duty = s
+	l.mu.Unlock()
+	l.enable()
+}
+
+// enable restores the duty set by Speed on both motors
+func (l *L298N) enable() {
+	l.mu.Lock()
+	duty := l.duty
+	l.mu.Unlock()
+	l.ena.DutyCycle(duty, 100)
+	l.enb.DutyCycle(duty, 100)
+}
+
+// SetMotor sets the signed speed in percent [-100, 100] of a motor,
+// speed > 0: forward, speed < 0: backward, speed = 0: coast.
+func (l *L298N) SetMotor(m L298NMotor, speed float64) {
+	in1, in2, en := l.pins(m)
+	speed = math.Max(-100, math.Min(100, speed))
+	switch {
+	case speed > 0:
+		in1.High()
+		in2.Low()
+	case speed < 0:
+		in1.Low()
+		in2.High()
+	default:
+		in1.Low()
+		in2.Low()
+	}
+	en.DutyCycle(uint32(math.Round(math.Abs(speed))), 100)
+}
+
+// BrakeMotor stops a motor fast by shorting it
+func (l *L298N) BrakeMotor(m L298NMotor) {
+	in1, in2, en := l.pins(m)
+	in1.Low()
+	in2.Low()
+	en.DutyCycle(100, 100)
+}
+
+// CoastMotor lets a motor run freely until it stops
+func (l *L298N) CoastMotor(m L298NMotor) {
+	_, _, en := l.pins(m)
+	en.DutyCycle(0, 100)
+}
+
+// SetSpeeds sets the signed speeds in percent of the left(A) and right(B) motors
+func (l *L298N) SetSpeeds(left, right float64) {
+	l.SetMotor(MotorA, left)
+	l.SetMotor(MotorB, right)
+}
+
+// Brake stops both motors fast
+func (l *L298N) Brake() {
+	l.BrakeMotor(MotorA)
+	l.BrakeMotor(MotorB)
+}
+
+// Coast lets both motors run freely
+func (l *L298N) Coast() {
+	l.CoastMotor(MotorA)
+	l.CoastMotor(MotorB)
+}
+
+func (l *L298N) pins(m L298NMotor) (in1, in2 l298nPin, en l298nPWM) {
+	if m == MotorB {
+		return l.in3, l.in4, l.enb
+	}
+	return l.in1, l.in2, l.ena
+}
+
+// pwmEnable makes a pwm output to be an enable pin of L298N
+type pwmEnable struct {
+	out PWMWriter
+}
+
+func (e *pwmEnable) DutyCycle(dutyLen, cycleLen uint32) {
+	if err := e.out.SetDuty(float64(dutyLen) / float64(cycleLen)); err != nil {
+		log.Printf("[l298n]failed to set duty, error: %v", err)
+	}
 }
@@ -0,0 +1,82 @@
+package dev
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeL298NPin struct {
+	high bool
+}
+
+func (p *fakeL298NPin) High() {
+	p.high = true
+}
+
+func (p *fakeL298NPin) Low() {
+	p.high = false
+}
+
+type fakeL298NPWM struct {
+	duty uint32
+}
+
+func (p *fakeL298NPWM) DutyCycle(dutyLen, cycleLen uint32) {
+	p.duty = dutyLen * 100 / cycleLen
+}
+
+func TestL298NSpeed(t *testing.T) {
+	in := [4]*fakeL298NPin{{}, {}, {}, {}}
+	ena, enb := &fakeL298NPWM{}, &fakeL298NPWM{}
+	l := newL298N(in[0], in[1], in[2], in[3], ena, enb)
+	assert.Equal(t, uint32(30), ena.duty)
+
+	// braking shorts the motors at full duty
+	l.Brake()
+	assert.Equal(t, uint32(100), ena.duty)
+	assert.Equal(t, uint32(100), enb.duty)
+	l.Forward()
+	assert.Equal(t, uint32(30), ena.duty)
+	assert.Equal(t, uint32(30), enb.duty)
+	assert.True(t, in[0].high)
+	assert.False(t, in[1].high)
+
+	l.Speed(50)
+	l.Coast()
+	assert.Equal(t, uint32(0), ena.duty)
+	l.Left()
+	assert.Equal(t, uint32(50), ena.duty)
+	assert.Equal(t, uint32(50), enb.duty)
+
+	l.SetSpeeds(80, -20)
+	assert.Equal(t, uint32(80), ena.duty)
+	assert.Equal(t, uint32(20), enb.duty)
+	l.Right()
+	assert.Equal(t, uint32(50), ena.duty)
+	assert.Equal(t, uint32(50), enb.duty)
+}
+
+func TestL298NWithPCA9685(t *testing.T) {
+	bus := newFakeI2C()
+	pca := newPCA9685(bus)
+	in := [4]*fakeL298NPin{{}, {}, {}, {}}
+	l := newL298N(in[0], in[1], in[2], in[3], &pwmEnable{pca.Channel(0)}, &pwmEnable{pca.Channel(1)})
+	off := func(ch int) uint16 {
+		v := bus.regs[pcaRegLed0OnL+byte(4*ch)]
+		return uint16(v[2]) | uint16(v[3])<<8
+	}
+	// 30% of 4096
+	assert.Equal(t, uint16(1229), off(0))
+	assert.Equal(t, uint16(1229), off(1))
+
+	l.SetSpeeds(80, -20)
+	assert.Equal(t, uint16(3277), off(0))
+	assert.Equal(t, uint16(819), off(1))
+	assert.False(t, in[2].high)
+	assert.True(t, in[3].high)
+
+	// full on
+	l.Brake()
+	assert.Equal(t, []byte{0x00, 0x10, 0x00, 0x00}, bus.regs[pcaRegLed0OnL])
+}
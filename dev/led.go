@@ -41,6 +41,16 @@ func (l *Led) Off() {
 	l.pin.Low()
 }
 
+// SetColor turns on the led if the brightness of the color >= 0.5, or turns it off
+func (l *Led) SetColor(c Color) error {
+	if c.Brightness() >= 0.5 {
+		l.On()
+	} else {
+		l.Off()
+	}
+	return nil
+}
+
 // Blink is let led blink n time, interval Millisecond each time
 func (l *Led) Blink(n int, interval int) {
 	d := time.Duration(interval) * time.Millisecond
@@ -0,0 +1,180 @@
+/*
+Package dev ...
+
+LedController owns an led and runs one named pattern at a time in background,
+so that the callers don't block on blinking and don't fight over the pin.
+
+Every pattern is played with a priority and an expiry:
+  - the pattern with the highest priority is shown, the latest one wins on the same priority
+  - the others are kept, and will be shown when the higher ones are stopped or expired
+  - a pattern without loop is removed after its steps are done
+  - the led turns off if there isn't any pattern
+
+e.g.
+	leds := NewLedController(led)
+	leds.Play("idle", HeartbeatPattern(White), 0, 0)
+	leds.Play("alert", BlinkPattern(White, 100*time.Millisecond, 100*time.Millisecond), 10, 5*time.Second)
+*/
+package dev
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	ledControllerFrame = 20 * time.Millisecond
+)
+
+// LedOutput shows a color, a single color led shows the brightness of the color
+type LedOutput interface {
+	SetColor(c Color) error
+}
+
+// ledJob is a pattern played by LedController
+type ledJob struct {
+	pattern  LedPattern
+	priority int
+	start    time.Time
+	expire   time.Time
+	seq      int
+}
+
+// LedController ...
+type LedController struct {
+	out LedOutput
+	now func() time.Time
+
+	mu      sync.Mutex
+	jobs    map[string]*ledJob
+	seq     int
+	current string
+	color   Color
+	shown   bool
+	chQuit  chan bool
+}
+
+// NewLedController creates an led controller and starts it
+func NewLedController(out LedOutput) *LedController {
+	c := newLedController(out, time.Now)
+	go c.start()
+	return c
+}
+
+func newLedController(out LedOutput, now func() time.Time) *LedController {
+	return &LedController{
+		out:    out,
+		now:    now,
+		jobs:   map[string]*ledJob{},
+		chQuit: make(chan bool),
+	}
+}
+
+// Play plays the pattern with the name, it replaces the pattern with the same name.
+// The pattern with higher priority is shown first, and it will be stopped after expiry,
+// expiry <= 0 means never expired.
+func (c *LedController) Play(name string, p LedPattern, priority int, expiry time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	j := &ledJob{
+		pattern:  p,
+		priority: priority,
+		start:    c.now(),
+		seq:      c.seq,
+	}
+	if expiry > 0 {
+		j.expire = j.start.Add(expiry)
+	}
+	c.jobs[name] = j
+}
+
+// Stop stops the pattern with the name
+func (c *LedController) Stop(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.jobs, name)
+}
+
+// Current returns the name of the pattern being shown, it's empty if there isn't any
+func (c *LedController) Current() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+// Close stops all the patterns and turns off the led
+func (c *LedController) Close() {
+	close(c.chQuit)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jobs = map[string]*ledJob{}
+	c.current = ""
+	c.show(Black)
+}
+
+func (c *LedController) start() {
+	for {
+		select {
+		case <-c.chQuit:
+			return
+		case <-time.After(ledControllerFrame):
+			c.update()
+		}
+	}
+}
+
+// update removes the done patterns, and shows the color of the current pattern
+func (c *LedController) update() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for {
+		name, j := c.top(now)
+		if j == nil {
+			c.current = ""
+			c.show(Black)
+			return
+		}
+		color, done := j.pattern.colorAt(now.Sub(j.start))
+		if done {
+			delete(c.jobs, name)
+			continue
+		}
+		c.current = name
+		c.show(color)
+		return
+	}
+}
+
+// top returns the pattern with the highest priority, the expired ones are removed
+func (c *LedController) top(now time.Time) (string, *ledJob) {
+	var (
+		name string
+		top  *ledJob
+	)
+	for n, j := range c.jobs {
+		if !j.expire.IsZero() && !now.Before(j.expire) {
+			delete(c.jobs, n)
+			continue
+		}
+		if top == nil || j.priority > top.priority || (j.priority == top.priority && j.seq > top.seq) {
+			name, top = n, j
+		}
+	}
+	return name, top
+}
+
+func (c *LedController) show(color Color) {
+	if c.shown && color == c.color {
+		return
+	}
+	if err := c.out.SetColor(color); err != nil {
+		log.Printf("[%v]failed to set color, error: %v", logTagLed, err)
+		return
+	}
+	c.color = color
+	c.shown = true
+}
@@ -0,0 +1,153 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLedOutput struct {
+	colors []Color
+}
+
+func (f *fakeLedOutput) SetColor(c Color) error {
+	f.colors = append(f.colors, c)
+	return nil
+}
+
+func (f *fakeLedOutput) last() Color {
+	return f.colors[len(f.colors)-1]
+}
+
+type fakeDuty float64
+
+func (f *fakeDuty) SetDuty(duty float64) error {
+	*f = fakeDuty(duty)
+	return nil
+}
+
+func TestLedPatterns(t *testing.T) {
+	ms := time.Millisecond
+
+	blink := BlinkPattern(Red, 100*ms, 200*ms)
+	for _, test := range []struct {
+		elapsed time.Duration
+		color   Color
+	}{
+		{0, Red},
+		{99 * ms, Red},
+		{100 * ms, Black},
+		{299 * ms, Black},
+		{300 * ms, Red},
+		{1050 * ms, Black},
+	} {
+		c, done := blink.colorAt(test.elapsed)
+		assert.False(t, done)
+		assert.Equal(t, test.color, c, test.elapsed)
+	}
+
+	breathe := BreathePattern(White, time.Second)
+	c, _ := breathe.colorAt(250 * ms)
+	assert.InDelta(t, 0.5, c.Brightness(), 1e-6)
+	c, _ = breathe.colorAt(500 * ms)
+	assert.InDelta(t, 1, c.Brightness(), 1e-6)
+	c, _ = breathe.colorAt(750 * ms)
+	assert.InDelta(t, 0.5, c.Brightness(), 1e-6)
+
+	once := blink.Repeat(2)
+	assert.Equal(t, 600*ms, once.Duration())
+	_, done := once.colorAt(599 * ms)
+	assert.False(t, done)
+	_, done = once.colorAt(600 * ms)
+	assert.True(t, done)
+
+	// cross-fade from red to blue
+	fade := LedPattern{
+		Steps: []LedStep{
+			{Color: Red, Duration: 100 * ms},
+			{Color: Blue, Duration: 100 * ms, Fade: true},
+		},
+	}
+	c, _ = fade.colorAt(150 * ms)
+	assert.Equal(t, Color{0.5, 0, 0.5}, c)
+}
+
+func TestMorsePattern(t *testing.T) {
+	unit := 100 * time.Millisecond
+	// S: 3 dots, O: 3 dashes
+	p := MorsePattern(White, "sos", unit)
+	assert.Len(t, p.Steps, 18)
+	// (1+1)*3 + 2 + (3+1)*3 + 2 + (1+1)*3 + 6
+	assert.Equal(t, 34*unit, p.Duration())
+
+	// the unknown characters are skipped
+	assert.Equal(t, p.Duration(), MorsePattern(White, " s~o~s ", unit).Duration())
+	assert.Empty(t, MorsePattern(White, "~", unit).Steps)
+}
+
+func TestLedController(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		return now
+	}
+	out := &fakeLedOutput{}
+	c := newLedController(out, clock)
+
+	c.update()
+	assert.Equal(t, "", c.Current())
+	assert.Equal(t, Black, out.last())
+
+	c.Play("idle", SolidPattern(Green), 0, 0)
+	c.update()
+	assert.Equal(t, "idle", c.Current())
+	assert.Equal(t, Green, out.last())
+
+	// the higher priority wins
+	c.Play("alert", BlinkPattern(Red, time.Second, time.Second), 10, 3*time.Second)
+	c.Play("info", SolidPattern(Blue), 5, 0)
+	c.update()
+	assert.Equal(t, "alert", c.Current())
+	assert.Equal(t, Red, out.last())
+
+	// it doesn't write the same color again
+	n := len(out.colors)
+	c.update()
+	assert.Equal(t, n, len(out.colors))
+
+	// the alert is expired
+	now = now.Add(3 * time.Second)
+	c.update()
+	assert.Equal(t, "info", c.Current())
+	assert.Equal(t, Blue, out.last())
+
+	c.Stop("info")
+	c.update()
+	assert.Equal(t, "idle", c.Current())
+
+	// a one-shot pattern is removed after it's done
+	c.Play("flash", BlinkPattern(White, time.Second, time.Second).Repeat(1), 1, 0)
+	c.update()
+	assert.Equal(t, "flash", c.Current())
+	now = now.Add(2 * time.Second)
+	c.update()
+	assert.Equal(t, "idle", c.Current())
+	assert.Equal(t, Green, out.last())
+}
+
+func TestRGBLed(t *testing.T) {
+	var r, g, b fakeDuty
+	l := NewRGBLed(&r, &g, &b, false)
+	assert.NoError(t, l.SetColor(Color{1, 0, 0.5}))
+	assert.InDelta(t, 1, float64(r), 1e-6)
+	assert.InDelta(t, 0, float64(g), 1e-6)
+	assert.InDelta(t, 0.2176, float64(b), 1e-4)
+
+	// the channels are inverted for common anode
+	l = NewRGBLed(&r, &g, &b, true)
+	assert.NoError(t, l.SetColor(Color{1, 0, 0.5}))
+	assert.InDelta(t, 0, float64(r), 1e-6)
+	assert.InDelta(t, 1, float64(g), 1e-6)
+	assert.InDelta(t, 0.7824, float64(b), 1e-4)
+	assert.Equal(t, Color{1, 0, 0.5}, l.Color())
+}
@@ -2,15 +2,19 @@
 Package dev ...
 
 LedDisplay let you display text on an led digital module which bases on the 74HC595 dirver.
-Please note that I only test it on a 4-bit led digital module.
+It works with the 4-digit and 8-digit modules, and the modules daisy-chained by DIO-OUT -> DIO.
 Andy Only following chars were supported. The any char which didn't be spported will be displayed as '-'.
 ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 0 1 2 3 4 5 6 7 8 9
-A B C D E F H I J L O P R S U Y Z
-a b c d h i j l o p q s t u y
+A B C D E F G H I J L O P R S U Y Z
+a b c d g h i j l n o p q r s t u y
 . - _ =
 (and blank char ' ')
 ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+The font is shared with TM1637.
+A lowercase char is displayed as its uppercase one if it isn't supported, and vice versa.
+A dot is merged into the char before it, so "23.5" takes 3 digits.
+The text longer than the display scrolls like a marquee.
 
 Connect to Pi:
  - VCC: 	any v3.3 pin
@@ -18,99 +22,74 @@ Connect to Pi:
  - DIO: 	any data pin
  - SCLK:	any data pin
  - RCLK:	any data pin
+ - OE:		optional, any pwm pin(must be one of gpio 12, 13, 18, 19) for the brightness
 */
 package dev
 
 import (
+	"fmt"
+	"math"
+	"sync"
 	"time"
 
-	"github.com/shanghuiyang/rpi-devices/base"
 	"github.com/stianeikeland/go-rpio"
 )
 
 const (
 	refreshFrequency = 3 * time.Millisecond
-	closedSignal     = "*.*.*.*"
-)
+	// the default speed of the marquee
+	defaultScrollInterval = 300 * time.Millisecond
 
-var ledchars = map[byte]uint8{
-	'0': 0x03,
-	'1': 0x9F,
-	'2': 0x25,
-	'3': 0x0D,
-	'4': 0x99,
-	'5': 0x49,
-	'6': 0x41,
-	'7': 0x1B,
-	'8': 0x01,
-	'9': 0x09,
-
-	'A': 0x11,
-	'B': 0x01,
-	'C': 0x63,
-	'D': 0x03,
-	'E': 0x61,
-	'F': 0x71,
-	'H': 0x91,
-	'I': 0x9F,
-	'J': 0x8F,
-	'L': 0xE3,
-	'O': 0x03,
-	'P': 0x31,
-	'R': 0x11,
-	'S': 0x49,
-	'U': 0x83,
-	'Y': 0x89,
-	'Z': 0x25,
-
-	'a': 0x05,
-	'b': 0xC1,
-	'c': 0x79,
-	'd': 0x85,
-	'h': 0xD1,
-	'i': 0xBF,
-	'j': 0xBD,
-	'l': 0xFB,
-	'o': 0x39,
-	'p': 0x31,
-	'q': 0x19,
-	's': 0x49,
-	't': 0xE1,
-	'u': 0xB9,
-	'y': 0x89,
-
-	'.': 0xFE,
-	'-': 0xFD,
-	'_': 0xEF,
-	'=': 0x7D,
-
-	' ': 0xFF,
-}
+	ledBlank = 0xFF
+
+	ledOECycle = 100
+	ledOEFreq  = 100000
+)
 
 // LedDisplay ...
 type LedDisplay struct {
 	dioPin  rpio.Pin
 	rclkPin rpio.Pin
 	sclkPin rpio.Pin
+	oePin   rpio.Pin
+	hasOE   bool
+	modules int
+	digits  int // digits per module
 
-	// on    bool
 	state rpio.State
-	data  uint8
 
-	chText chan string
+	mu         sync.Mutex
+	cells      []uint8
+	start      time.Time
+	brightness float64
+	scroll     time.Duration
+	blink      time.Duration
+
+	chQuit chan bool
 	chDone chan bool
 	opened bool
 }
 
-// NewLedDisplay ...
+// NewLedDisplay creates a 4-digit display
 func NewLedDisplay(dioPin, rclkPin, sclkPin uint8) *LedDisplay {
+	return NewLedDisplayChain(dioPin, rclkPin, sclkPin, 1, 4)
+}
+
+// NewLedDisplayChain creates a display of the daisy-chained modules, each module has the digits,
+// the first module on the pins is the leftmost one.
+// e.g. NewLedDisplayChain(dio, rclk, sclk, 1, 8) for an 8-digit module.
+func NewLedDisplayChain(dioPin, rclkPin, sclkPin uint8, modules, digits int) *LedDisplay {
 	d := &LedDisplay{
-		dioPin:  rpio.Pin(dioPin),
-		rclkPin: rpio.Pin(rclkPin),
-		sclkPin: rpio.Pin(sclkPin),
-		chText:  make(chan string, 4),
-		chDone:  make(chan bool),
-		opened:  false,
+		dioPin:     rpio.Pin(dioPin),
+		rclkPin:    rpio.Pin(rclkPin),
+		sclkPin:    rpio.Pin(sclkPin),
+		modules:    modules,
+		digits:     digits,
+		cells:      encodeSegments("----"),
+		start:      time.Now(),
+		brightness: 1,
+		scroll:     defaultScrollInterval,
+		opened:     false,
 	}
 
 	d.dioPin.Output()
@@ -125,6 +104,140 @@ func NewLedDisplay(dioPin, rclkPin, sclkPin uint8) *LedDisplay {
 	return d
 }
 
+// SetOEPin uses the pwm on OE pin for the brightness instead of duty cycling
+func (d *LedDisplay) SetOEPin(pin uint8) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.oePin = rpio.Pin(pin)
+	d.hasOE = true
+	d.oePin.Pwm()
+	d.oePin.Freq(ledOEFreq)
+	d.setOE(d.brightness)
+}
+
+// SetBrightness sets the brightness in [0, 1]
+func (d *LedDisplay) SetBrightness(b float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.brightness = math.Max(0, math.Min(1, b))
+	if d.hasOE {
+		d.setOE(d.brightness)
+	}
+}
+
+// SetScroll sets the interval of scrolling a char for the long text
+func (d *LedDisplay) SetScroll(interval time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.scroll = interval
+}
+
+// SetBlink makes the display blink in the interval, 0 means no blinking
+func (d *LedDisplay) SetBlink(interval time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.blink == interval {
+		return
+	}
+	d.blink = interval
+	d.start = time.Now()
+}
+
+// Display displays the text, a short text is aligned to the right, and a long text scrolls
+func (d *LedDisplay) Display(text string) {
+	cells := encodeSegments(text)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if equalSegments(cells, d.cells) {
+		return
+	}
+	d.cells = cells
+	d.start = time.Now()
+}
+
+// Open ...
+func (d *LedDisplay) Open() {
+	if d.opened {
+		return
+	}
+	d.chQuit = make(chan bool)
+	d.chDone = make(chan bool)
+	go d.display()
+	d.opened = true
+}
+
+// Close ...
+func (d *LedDisplay) Close() {
+	if !d.opened {
+		return
+	}
+	close(d.chQuit)
+	<-d.chDone
+	d.clear()
+	d.opened = false
+}
+
+func (d *LedDisplay) display() {
+	defer close(d.chDone)
+	n := d.modules * d.digits
+	for {
+		select {
+		case <-d.chQuit:
+			return
+		default:
+		}
+
+		d.mu.Lock()
+		frame := segmentFrame(d.cells, n, time.Since(d.start), d.scroll, d.blink)
+		on := refreshFrequency
+		if !d.hasOE {
+			// duty cycling for the brightness
+			on = time.Duration(float64(refreshFrequency) * d.brightness)
+		}
+		d.mu.Unlock()
+
+		for i, seg := range frame {
+			if on > 0 {
+				d.show(i, seg)
+				time.Sleep(on)
+			}
+			if on < refreshFrequency {
+				d.clear()
+				time.Sleep(refreshFrequency - on)
+			}
+		}
+	}
+}
+
+// show lights the digit at the position from the left
+func (d *LedDisplay) show(pos int, seg uint8) {
+	m, digit := pos/d.digits, pos%d.digits
+	// the data of the farthest module goes first
+	for i := d.modules - 1; i >= 0; i-- {
+		if i == m {
+			d.sendByte(ledSegments(seg))
+			d.sendByte(uint8(0x80) >> uint(d.digits-1-digit))
+		} else {
+			d.sendByte(ledBlank)
+			d.sendByte(0x00)
+		}
+	}
+	d.flushStcp()
+}
+
+func (d *LedDisplay) clear() {
+	for i := 0; i < d.modules; i++ {
+		d.sendByte(ledBlank)
+		d.sendByte(0x00)
+	}
+	d.flushStcp()
+}
+
+func (d *LedDisplay) setOE(b float64) {
+	// OE is active-low
+	d.oePin.DutyCycle(uint32(math.Round((1-b)*ledOECycle)), ledOECycle)
+}
+
 // flushShcp Flush the Shcp pin
 // call after each individual data write
 func (d *LedDisplay) flushShcp() {
@@ -145,70 +258,53 @@ func (d *LedDisplay) setBit(bit rpio.State) {
 	d.flushShcp()
 }
 
-// sendData sends the bytes to the shiftregister
-func (d *LedDisplay) sendData(data uint8) {
-	d.data = data
+// sendByte shifts a byte into the shiftregister
+func (d *LedDisplay) sendByte(data uint8) {
 	for i := uint(0); i < 8; i++ {
-		d.setBit(rpio.State((d.data >> i) & 0x01))
+		d.setBit(rpio.State((data >> i) & 0x01))
 	}
-	d.flushStcp()
 }
 
-func (d *LedDisplay) display() {
-	text := "----"
-	for {
-		select {
-		case txt := <-d.chText:
-			text = base.Reverse(txt)
-		default:
-			// do nothing, just use the latest text for displaying
-		}
-
-		if text == closedSignal {
-			break
-		}
-
-		dot := 0
-		for i, c := range text {
-			ledc, ok := ledchars[byte(c)]
-			if !ok {
-				ledc = ledchars['-']
-			}
-
-			if i > 0 && text[i-1] == '.' {
-				dot++
-			}
-			pos := uint8(0x80) >> uint(i-dot)
-			d.sendData(ledc)
-			d.sendData(pos)
-			time.Sleep(refreshFrequency)
+// ledSegments converts the segments in the font to the bits of the module,
+// which are active-low, and the bits are abcdefg and dp from the high bit.
+func ledSegments(seg uint8) uint8 {
+	var b uint8
+	for i := uint(0); i < 8; i++ {
+		if seg&(1<<i) != 0 {
+			b |= 0x80 >> i
 		}
 	}
-	d.chDone <- true
+	return ^b
 }
 
-// Display ...
-func (d *LedDisplay) Display(text string) {
-	d.chText <- text
+// FormatFloat formats the number with as many decimals as it fits in the digits,
+// e.g. FormatFloat(23.456, 4) is "23.46", FormatFloat(-1.5, 4) is "-1.50".
+func FormatFloat(v float64, digits int) string {
+	for prec := digits - 1; prec > 0; prec-- {
+		s := fmt.Sprintf("%.*f", prec, v)
+		if len(s)-1 <= digits {
+			return s
+		}
+	}
+	return fmt.Sprintf("%.0f", v)
 }
 
-// Open ...
-func (d *LedDisplay) Open() {
-	if d.opened {
-		return
+// FormatClock formats the time as "hh.mm", the dot is the colon of a clock,
+// blink the colon by showing it in every other second.
+func FormatClock(t time.Time, colon bool) string {
+	if colon {
+		return t.Format("15.04")
 	}
-	go d.display()
-	d.opened = true
+	return t.Format("1504")
 }
 
-// Close ...
-func (d *LedDisplay) Close() {
-	if !d.opened {
-		return
+// FormatDuration formats a duration as "mm.ss" if it's shorter than an hour, or "hh.mm"
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
 	}
-	d.chText <- closedSignal
-	<-d.chDone
-	d.sendData(0xFF)
-	d.sendData(0xF0)
-	d.opened = false
+	if d < time.Hour {
+		return fmt.Sprintf("%02d.%02d", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%02d.%02d", int(d.Hours()), int(d.Minutes())%60)
 }
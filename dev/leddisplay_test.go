@@ -0,0 +1,80 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeSegments(t *testing.T) {
+	// the dot is merged into 3
+	cells := encodeSegments("23.5")
+	assert.Equal(t, []uint8{segmentFont['2'], segmentFont['3'] | segDot, segmentFont['5']}, cells)
+
+	// a leading dot and a second dot take their own digits
+	cells = encodeSegments(".5")
+	assert.Equal(t, []uint8{segmentFont['.'], segmentFont['5']}, cells)
+	cells = encodeSegments("1..2")
+	assert.Equal(t, []uint8{segmentFont['1'] | segDot, segmentFont['.'], segmentFont['2']}, cells)
+
+	// the other case is used for an unsupported char, or '-'
+	cells = encodeSegments("Mm")
+	assert.Equal(t, []uint8{segmentFont['-'], segmentFont['-']}, cells)
+	cells = encodeSegments("eT")
+	assert.Equal(t, []uint8{segmentFont['E'], segmentFont['t']}, cells)
+}
+
+func TestSegmentFrame(t *testing.T) {
+	cells := encodeSegments("12")
+	frame := segmentFrame(cells, 4, 0, 0, 0)
+	assert.Equal(t, []uint8{segBlank, segBlank, segmentFont['1'], segmentFont['2']}, frame)
+
+	// blink
+	frame = segmentFrame(cells, 4, 1500*time.Millisecond, 0, time.Second)
+	assert.Equal(t, []uint8{segBlank, segBlank, segBlank, segBlank}, frame)
+	frame = segmentFrame(cells, 4, 2500*time.Millisecond, 0, time.Second)
+	assert.Equal(t, segmentFont['2'], frame[3])
+
+	// scroll
+	cells = encodeSegments("123456")
+	scroll := 100 * time.Millisecond
+	frame = segmentFrame(cells, 4, 0, scroll, 0)
+	assert.Equal(t, encodeSegments("1234"), frame)
+	frame = segmentFrame(cells, 4, 250*time.Millisecond, scroll, 0)
+	assert.Equal(t, encodeSegments("3456"), frame)
+	frame = segmentFrame(cells, 4, 500*time.Millisecond, scroll, 0)
+	assert.Equal(t, encodeSegments("6   "), frame)
+	// it comes again after 6 chars and 4 blanks
+	frame = segmentFrame(cells, 4, time.Second, scroll, 0)
+	assert.Equal(t, encodeSegments("1234"), frame)
+
+	// 8 digits
+	frame = segmentFrame(encodeSegments("12.345678"), 8, 0, scroll, 0)
+	assert.Equal(t, encodeSegments("12.345678"), frame)
+}
+
+func TestLedSegments(t *testing.T) {
+	// the bits of 74HC595 module are active-low, and abcdefg and dp from the high bit
+	assert.Equal(t, uint8(0x9F), ledSegments(segmentFont['1']))
+	assert.Equal(t, uint8(0x01), ledSegments(segmentFont['8']))
+	assert.Equal(t, uint8(0xFE), ledSegments(segmentFont['.']))
+	assert.Equal(t, uint8(0xFD), ledSegments(segmentFont['-']))
+	assert.Equal(t, uint8(0xFF), ledSegments(segBlank))
+}
+
+func TestFormatLedText(t *testing.T) {
+	assert.Equal(t, "23.46", FormatFloat(23.456, 4))
+	assert.Equal(t, "-1.50", FormatFloat(-1.5, 4))
+	assert.Equal(t, "0.080", FormatFloat(0.08, 4))
+	assert.Equal(t, "1235", FormatFloat(1234.6, 4))
+	assert.Equal(t, "12345", FormatFloat(12345, 4))
+	assert.Equal(t, "1234.568", FormatFloat(1234.5678, 7))
+
+	tm := time.Date(2020, 1, 1, 9, 5, 30, 0, time.UTC)
+	assert.Equal(t, "09.05", FormatClock(tm, true))
+	assert.Equal(t, "0905", FormatClock(tm, false))
+
+	assert.Equal(t, "05.30", FormatDuration(5*time.Minute+30*time.Second))
+	assert.Equal(t, "02.15", FormatDuration(2*time.Hour+15*time.Minute))
+}
@@ -0,0 +1,201 @@
+/*
+Package dev ...
+
+LedMatrix is the 8x8 led matrix modules on cascaded MAX7219, like the 4-in-1 modules.
+The first module on DIN is the leftmost one, the digit registers of a module are the rows from the top,
+and the bit 7 of a row is the leftmost column.
+
+The text is drawn in the 5x7 font with a blank column between the chars,
+so a module shows about 1.3 chars, and "PM2.5 35µg/m³" needs 10 modules to be displayed at once.
+A short text is centered, and a long text scrolls column by column like a marquee.
+Any char not in the font is displayed as '?'.
+
+Spec:
+  - power supply:	5V
+  - pixels:			8x8 per module
+
+Connect to Pi:
+  - VCC:	any 5v pin
+  - GND:	any gnd pin
+  - DIN:	pin 19 (MOSI)
+  - CS:		pin 24 (CE0)
+  - CLK:	pin 23 (SCLK)
+*/
+package dev
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"time"
+)
+
+const (
+	matrixSize = 8
+	// the default speed of scrolling a column
+	defaultMatrixScroll = 40 * time.Millisecond
+)
+
+// LedMatrix ...
+type LedMatrix struct {
+	// the cells are the columns from the left, the bit 0 is the top pixel
+	frameDisplay
+	max   *MAX7219
+	width int
+
+	lastRows [][]byte // the rows sent to the chips, it's guarded by the lock of frameDisplay
+}
+
+// NewLedMatrix creates a matrix of the modules on the spi device, e.g. NewLedMatrix("", 4)
+func NewLedMatrix(dev string, modules int) (*LedMatrix, error) {
+	m, err := NewMAX7219(dev, modules)
+	if err != nil {
+		return nil, err
+	}
+	return newLedMatrix(m), nil
+}
+
+func newLedMatrix(m *MAX7219) *LedMatrix {
+	l := &LedMatrix{
+		max:   m,
+		width: m.Chips() * matrixSize,
+	}
+	l.frameDisplay = frameDisplay{
+		tag:      "ledmatrix",
+		interval: maxFrame,
+		render: func(cols []byte, elapsed, scroll, blink time.Duration) []byte {
+			return matrixFrame(cols, l.width, elapsed, scroll, blink)
+		},
+		write:  l.writeRows,
+		start:  time.Now(),
+		scroll: defaultMatrixScroll,
+	}
+	return l
+}
+
+// SetIntensity sets the brightness level in [0, 15]
+func (l *LedMatrix) SetIntensity(level int) {
+	if err := l.max.SetIntensity(level); err != nil {
+		log.Printf("[ledmatrix]failed to set intensity, error: %v", err)
+	}
+}
+
+// SetBrightness sets the brightness in [0, 1], it's rounded to the 16 levels
+func (l *LedMatrix) SetBrightness(b float64) {
+	l.SetIntensity(int(math.Round(math.Max(0, math.Min(1, b)) * (maxLevels - 1))))
+}
+
+// Display displays the text, a short text is centered, and a long text scrolls
+func (l *LedMatrix) Display(text string) {
+	l.setCells(matrixColumns(text))
+}
+
+// Bounds returns the size of the matrix
+func (l *LedMatrix) Bounds() image.Rectangle {
+	return image.Rect(0, 0, l.width, matrixSize)
+}
+
+// DrawImage draws the image on the matrix instead of the text, the pixels brighter than 50% gray are lit
+func (l *LedMatrix) DrawImage(img image.Image) error {
+	cols := make([]byte, l.width)
+	b := img.Bounds().Intersect(l.Bounds())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y >= 0x80 {
+				cols[x] |= 1 << uint(y)
+			}
+		}
+	}
+	l.setCells(cols)
+	return nil
+}
+
+// Open turns on the matrix
+func (l *LedMatrix) Open() {
+	l.open(func() {
+		if err := l.max.On(); err != nil {
+			log.Printf("[ledmatrix]failed to turn on, error: %v", err)
+		}
+		l.lastRows = nil
+	})
+}
+
+// Close clears and turns off the matrix
+func (l *LedMatrix) Close() {
+	l.close(func() {
+		l.max.Clear()
+		l.max.Off()
+	})
+}
+
+// writeRows writes the changed rows of the frame to the chips
+func (l *LedMatrix) writeRows(frame []byte) error {
+	rows := matrixRows(frame, l.max.Chips())
+	for r, row := range rows {
+		if l.lastRows != nil && string(row) == string(l.lastRows[r]) {
+			continue
+		}
+		if err := l.max.Write(maxRegDigit0+byte(r), row); err != nil {
+			l.lastRows = nil
+			return err
+		}
+	}
+	l.lastRows = rows
+	return nil
+}
+
+// matrixColumns draws the text in the 5x7 font
+func matrixColumns(text string) []byte {
+	var cols []byte
+	for _, r := range text {
+		glyph, ok := font5x7[r]
+		if !ok {
+			glyph = font5x7['?']
+		}
+		if len(cols) > 0 {
+			cols = append(cols, 0)
+		}
+		cols = append(cols, glyph[:]...)
+	}
+	return cols
+}
+
+// matrixFrame returns the columns of the width to display at the elapsed time since the columns were set,
+// the short columns are centered, and the long ones scroll in the interval of scroll.
+func matrixFrame(cols []byte, width int, elapsed, scroll, blink time.Duration) []byte {
+	frame := make([]byte, width)
+	if blink > 0 && (elapsed/blink)%2 == 1 {
+		return frame
+	}
+	if len(cols) <= width {
+		copy(frame[(width-len(cols))/2:], cols)
+		return frame
+	}
+
+	// scroll the columns with the blanks of the width at the end, so they go out before coming again
+	offset := 0
+	if scroll > 0 {
+		offset = int(elapsed/scroll) % (len(cols) + width)
+	}
+	for i := range frame {
+		if j := offset + i; j < len(cols) {
+			frame[i] = cols[j]
+		}
+	}
+	return frame
+}
+
+// matrixRows converts the columns to the rows of the chips, rows[r][c] is the row r of the chip c
+func matrixRows(frame []byte, chips int) [][]byte {
+	rows := make([][]byte, matrixSize)
+	for r := range rows {
+		rows[r] = make([]byte, chips)
+		for x, col := range frame {
+			if col&(1<<uint(r)) != 0 {
+				rows[r][x/matrixSize] |= 0x80 >> uint(x%matrixSize)
+			}
+		}
+	}
+	return rows
+}
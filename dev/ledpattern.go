@@ -0,0 +1,222 @@
+/*
+Package dev ...
+
+LedPattern is a sequence of colors for LedController, there are some builtin patterns:
+  - solid:		keeps a color
+  - blink:		turns on and off
+  - breathe:	fades in and out
+  - heartbeat:	two quick pulses and a rest
+  - morse:		blinks a text in Morse code
+
+and a custom pattern can be made of LedSteps.
+A single color led shows the brightness of the color, e.g. a half White is half bright.
+*/
+package dev
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	heartbeatPulse = 100 * time.Millisecond
+	heartbeatRest  = 700 * time.Millisecond
+)
+
+// Color is a color of rgb led, each channel is in [0, 1]
+type Color struct {
+	R, G, B float64
+}
+
+// Colors
+var (
+	Black   = Color{0, 0, 0}
+	White   = Color{1, 1, 1}
+	Red     = Color{1, 0, 0}
+	Green   = Color{0, 1, 0}
+	Blue    = Color{0, 0, 1}
+	Yellow  = Color{1, 1, 0}
+	Cyan    = Color{0, 1, 1}
+	Magenta = Color{1, 0, 1}
+	Orange  = Color{1, 0.5, 0}
+)
+
+// Scale returns the color with the brightness scaled by b in [0, 1]
+func (c Color) Scale(b float64) Color {
+	return Color{c.R * b, c.G * b, c.B * b}
+}
+
+// Brightness returns the brightness of the color, it's the max of the channels
+func (c Color) Brightness() float64 {
+	return math.Max(c.R, math.Max(c.G, c.B))
+}
+
+// mix returns the color at t in [0, 1] from c to d
+func (c Color) mix(d Color, t float64) Color {
+	return Color{
+		R: c.R + (d.R-c.R)*t,
+		G: c.G + (d.G-c.G)*t,
+		B: c.B + (d.B-c.B)*t,
+	}
+}
+
+// LedStep is a step of LedPattern
+type LedStep struct {
+	Color    Color
+	Duration time.Duration
+	// Fade changes the color from the last step gradually in the duration
+	Fade bool
+}
+
+// LedPattern ...
+type LedPattern struct {
+	Steps []LedStep
+	// Loop repeats the steps until the pattern is stopped or expired,
+	// or the pattern stops after the steps.
+	Loop bool
+}
+
+// Duration returns the duration of one round of the steps
+func (p LedPattern) Duration() time.Duration {
+	var d time.Duration
+	for _, s := range p.Steps {
+		d += s.Duration
+	}
+	return d
+}
+
+// Repeat returns a pattern which plays the steps n times and stops
+func (p LedPattern) Repeat(n int) LedPattern {
+	r := LedPattern{}
+	for i := 0; i < n; i++ {
+		r.Steps = append(r.Steps, p.Steps...)
+	}
+	return r
+}
+
+// colorAt returns the color at the time since the pattern started,
+// done is true if a pattern without loop is over.
+func (p LedPattern) colorAt(elapsed time.Duration) (c Color, done bool) {
+	total := p.Duration()
+	if len(p.Steps) == 0 || total <= 0 {
+		return Black, true
+	}
+	if elapsed >= total {
+		if !p.Loop {
+			return p.Steps[len(p.Steps)-1].Color, true
+		}
+		elapsed %= total
+	}
+
+	for i, s := range p.Steps {
+		if elapsed >= s.Duration {
+			elapsed -= s.Duration
+			continue
+		}
+		if !s.Fade {
+			return s.Color, false
+		}
+		from := Black
+		if i > 0 {
+			from = p.Steps[i-1].Color
+		} else if p.Loop {
+			from = p.Steps[len(p.Steps)-1].Color
+		}
+		return from.mix(s.Color, float64(elapsed)/float64(s.Duration)), false
+	}
+	return p.Steps[len(p.Steps)-1].Color, false
+}
+
+// SolidPattern keeps the color
+func SolidPattern(c Color) LedPattern {
+	return LedPattern{
+		Steps: []LedStep{{Color: c, Duration: time.Second}},
+		Loop:  true,
+	}
+}
+
+// BlinkPattern turns on in the color for on, and turns off for off
+func BlinkPattern(c Color, on, off time.Duration) LedPattern {
+	return LedPattern{
+		Steps: []LedStep{
+			{Color: c, Duration: on},
+			{Color: Black, Duration: off},
+		},
+		Loop: true,
+	}
+}
+
+// BreathePattern fades in and out in the period
+func BreathePattern(c Color, period time.Duration) LedPattern {
+	return LedPattern{
+		Steps: []LedStep{
+			{Color: c, Duration: period / 2, Fade: true},
+			{Color: Black, Duration: period / 2, Fade: true},
+		},
+		Loop: true,
+	}
+}
+
+// HeartbeatPattern pulses twice quickly and rests, like a heart beating
+func HeartbeatPattern(c Color) LedPattern {
+	return LedPattern{
+		Steps: []LedStep{
+			{Color: c, Duration: heartbeatPulse},
+			{Color: Black, Duration: heartbeatPulse},
+			{Color: c, Duration: heartbeatPulse},
+			{Color: Black, Duration: heartbeatRest},
+		},
+		Loop: true,
+	}
+}
+
+var morseCodes = map[rune]string{
+	'A': ".-", 'B': "-...", 'C': "-.-.", 'D': "-..", 'E': ".", 'F': "..-.",
+	'G': "--.", 'H': "....", 'I': "..", 'J': ".---", 'K': "-.-", 'L': ".-..",
+	'M': "--", 'N': "-.", 'O': "---", 'P': ".--.", 'Q': "--.-", 'R': ".-.",
+	'S': "...", 'T': "-", 'U': "..-", 'V': "...-", 'W': ".--", 'X': "-..-",
+	'Y': "-.--", 'Z': "--..",
+	'0': "-----", '1': ".----", '2': "..---", '3': "...--", '4': "....-",
+	'5': ".....", '6': "-....", '7': "--...", '8': "---..", '9': "----.",
+}
+
+// MorsePattern blinks the text in Morse code, unit is the length of a dot.
+// A dash is 3 units, the gap is 1 unit between the symbols, 3 units between the letters,
+// and 7 units between the words. The characters not in Morse code are skipped.
+func MorsePattern(c Color, text string, unit time.Duration) LedPattern {
+	p := LedPattern{
+		Loop: true,
+	}
+	gap := func(units int) {
+		// extend the gap after a symbol
+		p.Steps[len(p.Steps)-1].Duration = time.Duration(units) * unit
+	}
+	for _, word := range strings.Fields(strings.ToUpper(text)) {
+		letters := 0
+		for _, r := range word {
+			code, ok := morseCodes[r]
+			if !ok {
+				continue
+			}
+			if letters > 0 {
+				gap(3)
+			}
+			letters++
+			for _, sym := range code {
+				on := unit
+				if sym == '-' {
+					on = 3 * unit
+				}
+				p.Steps = append(p.Steps,
+					LedStep{Color: c, Duration: on},
+					LedStep{Color: Black, Duration: unit},
+				)
+			}
+		}
+		if letters > 0 {
+			gap(7)
+		}
+	}
+	return p
+}
@@ -0,0 +1,308 @@
+/*
+Package dev ...
+
+MAX7219 is the driver of the MAX7219 led driver chip on spi, it drives 8 digits of 7-segment leds,
+or an 8x8 led matrix. The chips can be cascaded by DOUT -> DIN, and a register of every chip
+is written in one transfer, the data of the farthest chip goes first.
+There are two displays on it:
+  - MAX7219Display:	the 8-digit 7-segment modules, it shares the font with LedDisplay
+  - LedMatrix:		the 8x8 led matrix modules, see ledmatrix.go
+
+Spec:
+  - power supply:	5V
+  - digits:			8 per chip
+  - brightness:		16 levels
+  - spi:			mode 0, up to 10MHz
+
+Connect to Pi:
+  - VCC:	any 5v pin
+  - GND:	any gnd pin
+  - DIN:	pin 19 (MOSI)
+  - CS:		pin 24 (CE0)
+  - CLK:	pin 23 (SCLK)
+*/
+package dev
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/io/spi"
+)
+
+const (
+	maxRegNoop        = 0x00
+	maxRegDigit0      = 0x01
+	maxRegDecodeMode  = 0x09
+	maxRegIntensity   = 0x0A
+	maxRegScanLimit   = 0x0B
+	maxRegShutdown    = 0x0C
+	maxRegDisplayTest = 0x0F
+
+	maxDigits   = 8
+	maxLevels   = 16
+	maxSPISpeed = 1000000
+	// the interval of updating the digits for scrolling and blinking
+	maxFrame = 50 * time.Millisecond
+)
+
+// MAX7219 is the chips cascaded on a spi device, the chip 0 is the first one on DIN
+type MAX7219 struct {
+	dev   spiDevice
+	chips int
+	mu    sync.Mutex
+}
+
+// NewMAX7219 creates the cascaded chips on the spi device, e.g. NewMAX7219(SPIDev(0, 0), 4)
+func NewMAX7219(dev string, chips int) (*MAX7219, error) {
+	d, err := openSPI(dev, spi.Mode0, maxSPISpeed)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newMAX7219(d, chips)
+	if err != nil {
+		d.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// newMAX7219 resets the chips to the shutdown mode without decoding, with all the digits scanned and cleared
+func newMAX7219(dev spiDevice, chips int) (*MAX7219, error) {
+	if chips <= 0 {
+		return nil, fmt.Errorf("invalid chips: %v", chips)
+	}
+	m := &MAX7219{
+		dev:   dev,
+		chips: chips,
+	}
+	for _, reg := range [][2]byte{
+		{maxRegDisplayTest, 0},
+		{maxRegShutdown, 0},
+		{maxRegDecodeMode, 0},
+		{maxRegScanLimit, maxDigits - 1},
+		{maxRegIntensity, maxLevels / 2},
+	} {
+		if err := m.WriteAll(reg[0], reg[1]); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.Clear(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Chips returns the number of the cascaded chips
+func (m *MAX7219) Chips() int {
+	return m.chips
+}
+
+// Write writes data[i] to the register of the chip i, the chips without data get a no-op
+func (m *MAX7219) Write(reg byte, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	buf := make([]byte, 0, 2*m.chips)
+	for i := m.chips - 1; i >= 0; i-- {
+		if i < len(data) {
+			buf = append(buf, reg, data[i])
+		} else {
+			buf = append(buf, maxRegNoop, 0)
+		}
+	}
+	return m.dev.Tx(buf, nil)
+}
+
+// WriteAll writes the same value to the register of all the chips
+func (m *MAX7219) WriteAll(reg, v byte) error {
+	data := make([]byte, m.chips)
+	for i := range data {
+		data[i] = v
+	}
+	return m.Write(reg, data)
+}
+
+// SetDigit writes the digit register in 0-7 of a chip
+func (m *MAX7219) SetDigit(chip, digit int, v byte) error {
+	if chip < 0 || chip >= m.chips || digit < 0 || digit >= maxDigits {
+		return fmt.Errorf("invalid digit %v of chip %v", digit, chip)
+	}
+	// only the chip gets the register, the others get the no-ops
+	buf := make([]byte, 0, 2*m.chips)
+	for i := m.chips - 1; i >= 0; i-- {
+		if i == chip {
+			buf = append(buf, maxRegDigit0+byte(digit), v)
+		} else {
+			buf = append(buf, maxRegNoop, 0)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.dev.Tx(buf, nil)
+}
+
+// SetIntensity sets the brightness level in [0, 15] of all the chips
+func (m *MAX7219) SetIntensity(level int) error {
+	if level < 0 {
+		level = 0
+	}
+	if level >= maxLevels {
+		level = maxLevels - 1
+	}
+	return m.WriteAll(maxRegIntensity, byte(level))
+}
+
+// SetScanLimit sets the number of the digits to display in [1, 8]
+func (m *MAX7219) SetScanLimit(digits int) error {
+	if digits < 1 || digits > maxDigits {
+		return fmt.Errorf("invalid digits: %v", digits)
+	}
+	return m.WriteAll(maxRegScanLimit, byte(digits-1))
+}
+
+// SetDecodeMode sets the digits using the BCD code B font, a bit is for a digit,
+// e.g. 0xFF decodes all the digits, and 0x00 means the raw segments or the matrix rows.
+func (m *MAX7219) SetDecodeMode(mask byte) error {
+	return m.WriteAll(maxRegDecodeMode, mask)
+}
+
+// Test lights all the leds at full brightness if on, the registers are kept
+func (m *MAX7219) Test(on bool) error {
+	var v byte
+	if on {
+		v = 1
+	}
+	return m.WriteAll(maxRegDisplayTest, v)
+}
+
+// On turns on the leds
+func (m *MAX7219) On() error {
+	return m.WriteAll(maxRegShutdown, 1)
+}
+
+// Off turns off the leds, the registers are kept
+func (m *MAX7219) Off() error {
+	return m.WriteAll(maxRegShutdown, 0)
+}
+
+// Clear clears all the digits
+func (m *MAX7219) Clear() error {
+	for d := 0; d < maxDigits; d++ {
+		if err := m.WriteAll(maxRegDigit0+byte(d), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close clears and turns off the leds
+func (m *MAX7219) Close() error {
+	m.Clear()
+	m.Off()
+	return m.dev.Close()
+}
+
+// MAX7219Display is the 7-segment modules with 8 digits on cascaded MAX7219,
+// the first module on DIN is the leftmost one, and the digit 0 of a module is the rightmost one.
+type MAX7219Display struct {
+	frameDisplay
+	max *MAX7219
+}
+
+// NewMAX7219Display creates a display of the modules on the spi device, e.g. NewMAX7219Display("", 1)
+func NewMAX7219Display(dev string, modules int) (*MAX7219Display, error) {
+	m, err := NewMAX7219(dev, modules)
+	if err != nil {
+		return nil, err
+	}
+	return newMAX7219Display(m), nil
+}
+
+func newMAX7219Display(m *MAX7219) *MAX7219Display {
+	d := &MAX7219Display{max: m}
+	n := m.Chips() * maxDigits
+	d.frameDisplay = frameDisplay{
+		tag:      "max7219",
+		interval: maxFrame,
+		render: func(cells []uint8, elapsed, scroll, blink time.Duration) []uint8 {
+			return segmentFrame(cells, n, elapsed, scroll, blink)
+		},
+		write:  d.writeDigits,
+		cells:  encodeSegments("--------"),
+		start:  time.Now(),
+		scroll: defaultScrollInterval,
+	}
+	return d
+}
+
+// SetIntensity sets the brightness level in [0, 15]
+func (d *MAX7219Display) SetIntensity(level int) {
+	if err := d.max.SetIntensity(level); err != nil {
+		log.Printf("[max7219]failed to set intensity, error: %v", err)
+	}
+}
+
+// SetBrightness sets the brightness in [0, 1], it's rounded to the 16 levels
+func (d *MAX7219Display) SetBrightness(b float64) {
+	d.SetIntensity(int(math.Round(math.Max(0, math.Min(1, b)) * (maxLevels - 1))))
+}
+
+// Display displays the text, a short text is aligned to the right, and a long text scrolls
+func (d *MAX7219Display) Display(text string) {
+	d.setCells(encodeSegments(text))
+}
+
+// Open turns on the display
+func (d *MAX7219Display) Open() {
+	d.open(func() {
+		if err := d.max.On(); err != nil {
+			log.Printf("[max7219]failed to turn on, error: %v", err)
+		}
+	})
+}
+
+// Close clears and turns off the display
+func (d *MAX7219Display) Close() {
+	d.close(func() {
+		d.max.Clear()
+		d.max.Off()
+	})
+}
+
+// writeDigits writes the segments of the digits from the left, the unchanged digits are skipped
+func (d *MAX7219Display) writeDigits(frame []uint8) error {
+	chips := d.max.Chips()
+	for digit := 0; digit < maxDigits; digit++ {
+		data := make([]byte, chips)
+		changed := false
+		for chip := 0; chip < chips; chip++ {
+			pos := chip*maxDigits + maxDigits - 1 - digit
+			data[chip] = maxSegments(frame[pos])
+			if d.last == nil || frame[pos] != d.last[pos] {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := d.max.Write(maxRegDigit0+byte(digit), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxSegments converts the segments in the font to the bits of MAX7219,
+// which are dp and abcdefg from the high bit.
+func maxSegments(seg uint8) uint8 {
+	b := seg & segDot
+	for i := uint(0); i < 7; i++ {
+		if seg&(1<<i) != 0 {
+			b |= 0x40 >> i
+		}
+	}
+	return b
+}
@@ -0,0 +1,145 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSPI records the transfers
+type fakeSPI struct {
+	txs [][]byte
+}
+
+func (f *fakeSPI) Tx(w, r []byte) error {
+	f.txs = append(f.txs, append([]byte{}, w...))
+	return nil
+}
+
+func (f *fakeSPI) Close() error {
+	return nil
+}
+
+func TestMAX7219Init(t *testing.T) {
+	bus := &fakeSPI{}
+	_, err := newMAX7219(bus, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{
+		{0x0F, 0, 0x0F, 0},
+		{0x0C, 0, 0x0C, 0},
+		{0x09, 0, 0x09, 0},
+		{0x0B, 7, 0x0B, 7},
+		{0x0A, 8, 0x0A, 8},
+		{0x01, 0, 0x01, 0},
+		{0x02, 0, 0x02, 0},
+		{0x03, 0, 0x03, 0},
+		{0x04, 0, 0x04, 0},
+		{0x05, 0, 0x05, 0},
+		{0x06, 0, 0x06, 0},
+		{0x07, 0, 0x07, 0},
+		{0x08, 0, 0x08, 0},
+	}, bus.txs)
+
+	_, err = newMAX7219(bus, 0)
+	assert.Error(t, err)
+}
+
+func TestMAX7219Write(t *testing.T) {
+	bus := &fakeSPI{}
+	m, err := newMAX7219(bus, 3)
+	assert.NoError(t, err)
+
+	// the data of the farthest chip goes first
+	bus.txs = nil
+	assert.NoError(t, m.Write(0x01, []byte{0xA0, 0xB0}))
+	assert.Equal(t, []byte{0x00, 0, 0x01, 0xB0, 0x01, 0xA0}, bus.txs[0])
+
+	bus.txs = nil
+	assert.NoError(t, m.SetDigit(1, 7, 0x55))
+	assert.Equal(t, []byte{0x00, 0, 0x08, 0x55, 0x00, 0}, bus.txs[0])
+	assert.Error(t, m.SetDigit(3, 0, 0x55))
+	assert.Error(t, m.SetDigit(0, 8, 0x55))
+
+	bus.txs = nil
+	assert.NoError(t, m.SetIntensity(20))
+	assert.NoError(t, m.SetScanLimit(4))
+	assert.Error(t, m.SetScanLimit(9))
+	assert.NoError(t, m.SetDecodeMode(0xFF))
+	assert.NoError(t, m.Test(true))
+	assert.NoError(t, m.On())
+	assert.Equal(t, [][]byte{
+		{0x0A, 15, 0x0A, 15, 0x0A, 15},
+		{0x0B, 3, 0x0B, 3, 0x0B, 3},
+		{0x09, 0xFF, 0x09, 0xFF, 0x09, 0xFF},
+		{0x0F, 1, 0x0F, 1, 0x0F, 1},
+		{0x0C, 1, 0x0C, 1, 0x0C, 1},
+	}, bus.txs)
+}
+
+func TestMAX7219Display(t *testing.T) {
+	assert.Equal(t, uint8(0x7E), maxSegments(segmentFont['0']))
+	assert.Equal(t, uint8(0x30), maxSegments(segmentFont['1']))
+	assert.Equal(t, uint8(0x01), maxSegments(segmentFont['-']))
+	assert.Equal(t, uint8(0xB0), maxSegments(segmentFont['1']|segDot))
+
+	bus := &fakeSPI{}
+	m, err := newMAX7219(bus, 1)
+	assert.NoError(t, err)
+	d := newMAX7219Display(m)
+
+	bus.txs = nil
+	frame := segmentFrame(encodeSegments("12.5"), maxDigits, 0, 0, 0)
+	assert.NoError(t, d.writeDigits(frame))
+	d.last = frame
+	// the digit 0 is the rightmost one
+	assert.Equal(t, 8, len(bus.txs))
+	assert.Equal(t, []byte{0x01, maxSegments(segmentFont['5'])}, bus.txs[0])
+	assert.Equal(t, []byte{0x02, maxSegments(segmentFont['2'] | segDot)}, bus.txs[1])
+	assert.Equal(t, []byte{0x03, maxSegments(segmentFont['1'])}, bus.txs[2])
+	assert.Equal(t, []byte{0x08, 0}, bus.txs[7])
+
+	// only the changed digits are written
+	bus.txs = nil
+	assert.NoError(t, d.writeDigits(segmentFrame(encodeSegments("12.6"), maxDigits, 0, 0, 0)))
+	assert.Equal(t, [][]byte{{0x01, maxSegments(segmentFont['6'])}}, bus.txs)
+}
+
+func TestLedMatrix(t *testing.T) {
+	assert.Equal(t, []byte{0x00, 0x42, 0x7F, 0x40, 0x00}, matrixColumns("1"))
+	assert.Equal(t, 11, len(matrixColumns("1°")))
+	assert.Equal(t, matrixColumns("?"), matrixColumns("€"))
+
+	// a short one is centered
+	cols := []byte{0x01, 0x02}
+	assert.Equal(t, []byte{0, 0, 0, 0x01, 0x02, 0, 0, 0}, matrixFrame(cols, 8, 0, 0, 0))
+	assert.Equal(t, make([]byte, 8), matrixFrame(cols, 8, 1500*time.Millisecond, 0, time.Second))
+
+	// a long one scrolls
+	cols = matrixColumns("ABC")
+	assert.Equal(t, cols[:8], matrixFrame(cols, 8, 0, 10*time.Millisecond, 0))
+	assert.Equal(t, cols[3:11], matrixFrame(cols, 8, 30*time.Millisecond, 10*time.Millisecond, 0))
+	assert.Equal(t, cols[:8], matrixFrame(cols, 8, time.Duration(len(cols)+8)*10*time.Millisecond, 10*time.Millisecond, 0))
+
+	frame := make([]byte, 16)
+	frame[0] = 0x01  // top-left of the chip 0
+	frame[15] = 0x80 // bottom-right of the chip 1
+	rows := matrixRows(frame, 2)
+	assert.Equal(t, []byte{0x80, 0x00}, rows[0])
+	assert.Equal(t, []byte{0x00, 0x01}, rows[7])
+
+	bus := &fakeSPI{}
+	m, err := newMAX7219(bus, 2)
+	assert.NoError(t, err)
+	l := newLedMatrix(m)
+	assert.Equal(t, 16, l.Bounds().Dx())
+
+	bus.txs = nil
+	assert.NoError(t, l.writeRows(frame))
+	assert.Equal(t, 8, len(bus.txs))
+	// the unchanged rows are skipped
+	bus.txs = nil
+	frame[15] = 0
+	assert.NoError(t, l.writeRows(frame))
+	assert.Equal(t, [][]byte{{0x08, 0x00, 0x08, 0x00}}, bus.txs)
+}
@@ -0,0 +1,340 @@
+/*
+Package dev ...
+
+MotionController drives a differential-drive car by the feedback of the encoders on the left and right wheels,
+and the heading of a gyro like IMU if there is one.
+  - Turn:	turns in place by an angle in degree, angle > 0: right, angle < 0: left
+  - Drive:	drives straight for a distance in cm, dist > 0: forward, dist < 0: backward
+
+The encoders only count the ticks, the direction of a wheel is taken from the speed it's driven.
+The distance of a wheel is ticks * pi * wheel diameter / ticks per revolution,
+and the heading is estimated from the difference of the distances if there isn't a gyro:
+	heading = (left - right) / track width * 180 / pi
+
+Driving straight holds the heading by a PID loop on the difference of the speeds of the wheels,
+which corrects the motors running at different speeds, and turning keeps the center of the car in place in the same way. The speeds slow down near the target,
+and the car stops within the tolerance.
+
+Connect to Pi:
+ - the motors: see L298N
+ - the encoders: see Encoder, one on each wheel
+*/
+package dev
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	motionCtrlInterval = 2 * time.Millisecond
+	// the motion fails if a wheel being driven didn't move in motionStall, e.g. the car got stuck
+	motionStall = time.Second
+	// the speeds slow down in the last slowdownAngle degrees of turning, or slowdownDist cm of driving
+	slowdownAngle = 30.0
+	slowdownDist  = 10.0
+	// the max correction of the heading in percent of duty
+	maxCorrection = 30.0
+)
+
+// ErrMotionCanceled is returned by Turn and Drive if the motion was canceled
+var ErrMotionCanceled = errors.New("motion canceled")
+
+// WheelEncoder counts the ticks of a wheel, like Encoder
+type WheelEncoder interface {
+	Start()
+	Stop()
+	Count1() int
+}
+
+// HeadingSensor returns the heading in degree which increases when turning right, like IMU
+type HeadingSensor interface {
+	Heading() float64
+}
+
+// MotionController ...
+type MotionController struct {
+	drv        MotorDriver
+	encoders   [2]WheelEncoder
+	cmPerTick  float64
+	trackWidth float64
+	interval   time.Duration
+	clock      Clock
+
+	// run is held by the running motion, only one motion runs at a time
+	run sync.Mutex
+
+	mu        sync.Mutex
+	gyro      HeadingSensor
+	speed     float64
+	minSpeed  float64
+	angleTol  float64
+	distTol   float64
+	kp        float64
+	ki        float64
+	kd        float64
+	gen       int // increased by Cancel, the motions started before quit
+	travelled [2]float64
+}
+
+// NewMotionController creates a motion controller,
+// wheelDiameter and trackWidth (the distance between the left and right wheels) are in cm,
+// ticksPerRev is the number of ticks of an encoder in a revolution of the wheel, e.g. 20 for the common slotted disk.
+func NewMotionController(drv MotorDriver, left, right WheelEncoder, wheelDiameter, trackWidth float64, ticksPerRev int) *MotionController {
+	return &MotionController{
+		drv:        drv,
+		encoders:   [2]WheelEncoder{left, right},
+		cmPerTick:  math.Pi * wheelDiameter / float64(ticksPerRev),
+		trackWidth: trackWidth,
+		interval:   motionCtrlInterval,
+		clock:      realClock{},
+		speed:      normalSpeed, // the same as L298N and Car
+		minSpeed:   normalSpeed / 2,
+		angleTol:   3,
+		distTol:    1,
+		kp:         3,
+		ki:         0.5,
+		kd:         0.1,
+	}
+}
+
+// SetClock runs the motions on the clock instead of the wall clock
+func (m *MotionController) SetClock(clock Clock) {
+	m.clock = clock
+}
+
+// SetGyro uses the heading of the gyro instead of the one estimated by the encoders
+func (m *MotionController) SetGyro(gyro HeadingSensor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gyro = gyro
+}
+
+// SetSpeed sets the cruise speed, and the min speed which still moves the car, both are in percent
+func (m *MotionController) SetSpeed(speed, minSpeed float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.speed = math.Max(0, math.Min(100, speed))
+	m.minSpeed = math.Max(0, math.Min(m.speed, minSpeed))
+}
+
+// SetTolerance sets the tolerance of turning in degree, and driving in cm
+func (m *MotionController) SetTolerance(angle, dist float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.angleTol = angle
+	m.distTol = dist
+}
+
+// SetPID sets the gains of the PID loop holding the heading,
+// the output is the difference of the speeds in percent for the error of heading in degree.
+func (m *MotionController) SetPID(kp, ki, kd float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.kp, m.ki, m.kd = kp, ki, kd
+}
+
+// Travelled returns the distances in cm the left and right wheels travelled in the last motion
+func (m *MotionController) Travelled() (left, right float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.travelled[0], m.travelled[1]
+}
+
+// Cancel stops the running motion, Turn or Drive returns ErrMotionCanceled
+func (m *MotionController) Cancel() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gen++
+}
+
+// Turn turns in place by the angle in degree, angle > 0: right, angle < 0: left
+func (m *MotionController) Turn(angle float64) error {
+	return m.turn(angle, m.generation())
+}
+
+// Drive drives straight for the distance in cm, dist > 0: forward, dist < 0: backward,
+// and it drives until canceled if dist is infinite.
+func (m *MotionController) Drive(dist float64) error {
+	return m.drive(dist, m.generation())
+}
+
+// restart cancels the running motion, and returns the generation for the next one
+func (m *MotionController) restart() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gen++
+	return m.gen
+}
+
+func (m *MotionController) generation() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gen
+}
+
+// turn runs if it's still the generation gen, so that a motion started in background can be canceled before it runs
+func (m *MotionController) turn(angle float64, gen int) error {
+	m.mu.Lock()
+	speed, minSpeed, tol := m.speed, m.minSpeed, m.angleTol
+	p := &pid{kp: m.kp, ki: m.ki, kd: m.kd, limit: maxCorrection}
+	m.mu.Unlock()
+
+	return m.move(gen, func(o *odometry, dt float64) (left, right float64, done bool) {
+		remaining := angle - o.heading
+		if math.Abs(remaining) <= tol {
+			return 0, 0, true
+		}
+		s := math.Copysign(slowdown(math.Abs(remaining)/slowdownAngle, speed, minSpeed), remaining)
+		// keep the center of the car in place if the motors run at different speeds
+		c := p.update((o.dist[0]+o.dist[1])/2, dt)
+		return s - c, -s - c, false
+	})
+}
+
+func (m *MotionController) drive(dist float64, gen int) error {
+	m.mu.Lock()
+	speed, minSpeed, tol := m.speed, m.minSpeed, m.distTol
+	p := &pid{kp: m.kp, ki: m.ki, kd: m.kd, limit: maxCorrection}
+	m.mu.Unlock()
+
+	return m.move(gen, func(o *odometry, dt float64) (left, right float64, done bool) {
+		remaining := dist - (o.dist[0]+o.dist[1])/2
+		if math.Abs(remaining) <= tol {
+			return 0, 0, true
+		}
+		s := math.Copysign(slowdown(math.Abs(remaining)/slowdownDist, speed, minSpeed), remaining)
+		// turning right makes the heading > 0, it's corrected by slowing down the left wheel
+		c := p.update(o.heading, dt)
+		return s - c, s + c, false
+	})
+}
+
+// odometry is the state of a motion since it started
+type odometry struct {
+	ticks   [2]int
+	dist    [2]float64 // the signed distances of the wheels in cm
+	heading float64    // the turned angle in degree
+}
+
+// move runs the control loop until the ctrl says it's done,
+// ctrl returns the speeds of the wheels in percent by the odometry and the seconds since the last call.
+func (m *MotionController) move(gen int, ctrl func(o *odometry, dt float64) (left, right float64, done bool)) error {
+	m.run.Lock()
+	defer m.run.Unlock()
+
+	m.mu.Lock()
+	gyro := m.gyro
+	m.travelled = [2]float64{}
+	m.mu.Unlock()
+
+	for _, e := range m.encoders {
+		e.Start()
+		defer e.Stop()
+	}
+
+	var (
+		o        odometry
+		dir      [2]float64
+		lastH    float64
+		lastTime = m.clock.Now()
+		moved    = [2]time.Time{lastTime, lastTime}
+	)
+	if gyro != nil {
+		lastH = gyro.Heading()
+	}
+	defer func() {
+		m.mu.Lock()
+		m.travelled = o.dist
+		m.mu.Unlock()
+	}()
+
+	for {
+		if m.generation() != gen {
+			m.drv.Brake()
+			return ErrMotionCanceled
+		}
+
+		now := m.clock.Now()
+		for i, e := range m.encoders {
+			if n := e.Count1(); n > 0 {
+				o.ticks[i] += n
+				o.dist[i] += dir[i] * float64(n) * m.cmPerTick
+				moved[i] = now
+			}
+		}
+		if gyro != nil {
+			h := gyro.Heading()
+			o.heading += normalizeAngle(h - lastH)
+			lastH = h
+		} else {
+			o.heading = (o.dist[0] - o.dist[1]) / m.trackWidth * 180 / math.Pi
+		}
+
+		left, right, done := ctrl(&o, now.Sub(lastTime).Seconds())
+		lastTime = now
+		if done {
+			m.drv.Brake()
+			return nil
+		}
+
+		for i, s := range [2]float64{left, right} {
+			d := sign(s)
+			if d != dir[i] {
+				// the wheel just started or reversed, give it time to move
+				moved[i] = now
+			}
+			dir[i] = d
+			if d != 0 && now.Sub(moved[i]) > motionStall {
+				m.drv.Brake()
+				return fmt.Errorf("the %v wheel got stuck", [2]string{"left", "right"}[i])
+			}
+		}
+		m.drv.SetSpeeds(left, right)
+		m.clock.Sleep(m.interval)
+	}
+}
+
+// slowdown returns the speed in [minSpeed, speed] by the ratio of the remaining to the slowdown range
+func slowdown(ratio, speed, minSpeed float64) float64 {
+	return math.Max(minSpeed, math.Min(speed, speed*ratio))
+}
+
+func sign(v float64) float64 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// pid is a PID controller, the output is limited into [-limit, limit]
+type pid struct {
+	kp, ki, kd float64
+	limit      float64
+	integral   float64
+	last       float64
+	started    bool
+}
+
+// update returns the output for the error e after dt seconds
+func (p *pid) update(e, dt float64) float64 {
+	var d float64
+	if p.started && dt > 0 {
+		p.integral += e * dt
+		d = (e - p.last) / dt
+	}
+	p.started = true
+	p.last = e
+	// anti-windup: the integral alone can't exceed the limit
+	if p.ki != 0 {
+		p.integral = math.Max(-p.limit/math.Abs(p.ki), math.Min(p.limit/math.Abs(p.ki), p.integral))
+	}
+	out := p.kp*e + p.ki*p.integral + p.kd*d
+	return math.Max(-p.limit, math.Min(p.limit, out))
+}
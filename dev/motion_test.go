@@ -0,0 +1,226 @@
+package dev
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testWheelDiameter = 6.5
+	testTrackWidth    = 13.0
+	testTicksPerRev   = 20
+)
+
+// fakeWheels simulates the motors and the encoders of a car,
+// a wheel moves gain ticks for a reading of its encoder at full speed.
+type fakeWheels struct {
+	mu     sync.Mutex
+	gains  [2]float64
+	speeds [2]float64
+	frac   [2]float64
+	ticks  [2]int // the signed ticks the wheels really moved
+	braked bool
+}
+
+func (f *fakeWheels) SetSpeeds(left, right float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.speeds = [2]float64{left, right}
+	f.braked = false
+}
+
+func (f *fakeWheels) Brake() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.speeds = [2]float64{}
+	f.braked = true
+}
+
+func (f *fakeWheels) Coast() {
+	f.Brake()
+}
+
+// heading returns the real heading in degree
+func (f *fakeWheels) heading() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cm := math.Pi * testWheelDiameter / testTicksPerRev
+	return float64(f.ticks[0]-f.ticks[1]) * cm / testTrackWidth * 180 / math.Pi
+}
+
+// dist returns the real distance in cm
+func (f *fakeWheels) dist() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cm := math.Pi * testWheelDiameter / testTicksPerRev
+	return float64(f.ticks[0]+f.ticks[1]) / 2 * cm
+}
+
+func (f *fakeWheels) encoder(i int) *fakeWheelEncoder {
+	return &fakeWheelEncoder{wheels: f, i: i}
+}
+
+type fakeWheelEncoder struct {
+	wheels *fakeWheels
+	i      int
+}
+
+func (e *fakeWheelEncoder) Start() {}
+
+func (e *fakeWheelEncoder) Stop() {}
+
+func (e *fakeWheelEncoder) Count1() int {
+	f := e.wheels
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.speeds[e.i]
+	f.frac[e.i] += math.Abs(s) / 100 * f.gains[e.i]
+	if f.frac[e.i] < 1 {
+		return 0
+	}
+	f.frac[e.i]--
+	f.ticks[e.i] += int(sign(s))
+	return 1
+}
+
+// fakeGyro reads the real heading of the wheels
+type fakeGyro struct {
+	wheels *fakeWheels
+}
+
+func (g *fakeGyro) Heading() float64 {
+	h := math.Mod(g.wheels.heading(), 360)
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+func newTestMotion(left, right float64) (*MotionController, *fakeWheels) {
+	w := &fakeWheels{gains: [2]float64{left, right}}
+	m := NewMotionController(w, w.encoder(0), w.encoder(1), testWheelDiameter, testTrackWidth, testTicksPerRev)
+	m.interval = 0
+	return m, w
+}
+
+func TestMotionTurn(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		angle float64
+		gyro  bool
+	}{
+		{
+			desc:  "right",
+			angle: 90,
+		},
+		{
+			desc:  "left",
+			angle: -37,
+		},
+		{
+			desc:  "around",
+			angle: 360,
+		},
+		{
+			desc:  "right by gyro",
+			angle: 90,
+			gyro:  true,
+		},
+		{
+			desc:  "left by gyro",
+			angle: -135,
+			gyro:  true,
+		},
+	}
+	for _, test := range testCases {
+		m, w := newTestMotion(0.2, 0.15)
+		if test.gyro {
+			m.SetGyro(&fakeGyro{wheels: w})
+		}
+		assert.NoError(t, m.Turn(test.angle), test.desc)
+		assert.InDelta(t, test.angle, w.heading(), 3+4.6, test.desc) // the tolerance plus a tick
+		assert.InDelta(t, 0, w.dist(), 3, test.desc)
+		assert.True(t, w.braked, test.desc)
+	}
+}
+
+func TestMotionDrive(t *testing.T) {
+	testCases := []struct {
+		desc string
+		dist float64
+		gyro bool
+	}{
+		{
+			desc: "forward",
+			dist: 100,
+		},
+		{
+			desc: "backward",
+			dist: -50,
+		},
+		{
+			desc: "forward by gyro",
+			dist: 100,
+			gyro: true,
+		},
+	}
+	for _, test := range testCases {
+		// the right motor is much weaker than the left one
+		m, w := newTestMotion(0.2, 0.14)
+		if test.gyro {
+			m.SetGyro(&fakeGyro{wheels: w})
+		}
+		assert.NoError(t, m.Drive(test.dist), test.desc)
+		assert.InDelta(t, test.dist, w.dist(), 2, test.desc)
+		assert.InDelta(t, 0, w.heading(), 10, test.desc)
+		l, r := m.Travelled()
+		assert.InDelta(t, test.dist, (l+r)/2, 1.5, test.desc)
+	}
+
+	// it drifts without holding the heading
+	m, w := newTestMotion(0.2, 0.14)
+	m.SetPID(0, 0, 0)
+	assert.NoError(t, m.Drive(100))
+	assert.True(t, w.heading() > 45)
+}
+
+func TestMotionCancel(t *testing.T) {
+	m, w := newTestMotion(0.2, 0.2)
+
+	// a motion canceled before it started
+	gen := m.restart()
+	m.Cancel()
+	assert.Equal(t, ErrMotionCanceled, m.drive(10, gen))
+	assert.Equal(t, 0.0, w.dist())
+
+	chErr := make(chan error)
+	go func() {
+		chErr <- m.Drive(math.Inf(1))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	m.Cancel()
+	assert.Equal(t, ErrMotionCanceled, <-chErr)
+	assert.True(t, w.braked)
+	assert.True(t, w.dist() > 0)
+}
+
+func TestMotionStall(t *testing.T) {
+	// the right wheel got stuck
+	m, w := newTestMotion(0.2, 0)
+	m.interval = time.Millisecond
+	assert.Error(t, m.Drive(100))
+	assert.True(t, w.braked)
+}
+
+func TestPID(t *testing.T) {
+	p := &pid{kp: 2, ki: 1, kd: 0.5, limit: 10}
+	assert.InDelta(t, 2, p.update(1, 0.1), 1e-6)
+	// p: 2*2, i: 1*0.2, d: 0.5*1/0.1
+	assert.InDelta(t, 9.2, p.update(2, 0.1), 1e-6)
+	assert.InDelta(t, 10, p.update(100, 0.1), 1e-6)
+	assert.InDelta(t, -10, p.update(-100, 0.1), 1e-6)
+}
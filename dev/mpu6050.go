@@ -0,0 +1,248 @@
+/*
+Package dev ...
+
+MPU6050 is the driver of MPU6050, a 3-axis accelerometer and 3-axis gyroscope over i2c.
+
+Spec:
+  - power supply:	3.3V - 5V (with the on-board regulator)
+  - address:		0x68(AD0->GND), 0x69(AD0->VCC)
+  - gyroscope:		+/-250, 500, 1000, 2000 degree/s
+  - accelerometer:	+/-2, 4, 8, 16 g
+
+Connect to Pi:
+  - VCC:	any 3.3v pin
+  - GND:	any gnd pin
+  - SCL:	pin 5 (SCL)
+  - SDA:	pin 3 (SDA)
+  - AD0:	any gnd pin for address 0x68
+
+Please mount it flat with the z-axis pointing up,
+and the x-axis pointing to the front of the car.
+*/
+package dev
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	mpuRegSmplrtDiv   = 0x19
+	mpuRegConfig      = 0x1A
+	mpuRegGyroConfig  = 0x1B
+	mpuRegAccelConfig = 0x1C
+	mpuRegAccelXOutH  = 0x3B
+	mpuRegPwrMgmt1    = 0x6B
+	mpuRegWhoAmI      = 0x75
+
+	mpuWhoAmI = 0x68
+)
+
+var (
+	// LSB per degree/s
+	mpuGyroScales = map[int]float64{
+		250:  131.0,
+		500:  65.5,
+		1000: 32.8,
+		2000: 16.4,
+	}
+	mpuGyroRanges = map[int]byte{
+		250:  0,
+		500:  1,
+		1000: 2,
+		2000: 3,
+	}
+	// LSB per g
+	mpuAccelScales = map[int]float64{
+		2:  16384.0,
+		4:  8192.0,
+		8:  4096.0,
+		16: 2048.0,
+	}
+	mpuAccelRanges = map[int]byte{
+		2:  0,
+		4:  1,
+		8:  2,
+		16: 3,
+	}
+)
+
+// IMUSample is a sample of the accelerometer in g and the gyroscope in degree/s
+type IMUSample struct {
+	Ax, Ay, Az float64
+	Gx, Gy, Gz float64
+	Temp       float64
+}
+
+// MPU6050 ...
+type MPU6050 struct {
+	dev        i2cDevice
+	mu         sync.Mutex
+	gyroScale  float64
+	accelScale float64
+	bias       [3]float64
+}
+
+// NewMPU6050 ...
+func NewMPU6050(addr uint8) (*MPU6050, error) {
+	d, err := openI2C(defaultI2CBus, addr)
+	if err != nil {
+		return nil, err
+	}
+	m := &MPU6050{
+		dev: d,
+	}
+	if err := m.init(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *MPU6050) init() error {
+	var id [1]byte
+	if err := m.dev.ReadReg(mpuRegWhoAmI, id[:]); err != nil {
+		return err
+	}
+	if id[0] != mpuWhoAmI {
+		return fmt.Errorf("unknown device id: 0x%02x, expected 0x%02x", id[0], mpuWhoAmI)
+	}
+	// wake up and use the pll with x-axis gyroscope as the clock
+	if err := m.dev.WriteReg(mpuRegPwrMgmt1, []byte{0x01}); err != nil {
+		return err
+	}
+	time.Sleep(100 * time.Millisecond)
+	// dlpf 44Hz, and sample rate = 1kHz / (1 + 9) = 100Hz
+	if err := m.dev.WriteReg(mpuRegConfig, []byte{0x03}); err != nil {
+		return err
+	}
+	if err := m.dev.WriteReg(mpuRegSmplrtDiv, []byte{9}); err != nil {
+		return err
+	}
+	if err := m.SetGyroRange(500); err != nil {
+		return err
+	}
+	return m.SetAccelRange(4)
+}
+
+// SetGyroRange sets the full-scale range in degree/s, must be 250, 500, 1000 or 2000
+func (m *MPU6050) SetGyroRange(dps int) error {
+	r, ok := mpuGyroRanges[dps]
+	if !ok {
+		return fmt.Errorf("invalid gyro range: %v", dps)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.dev.WriteReg(mpuRegGyroConfig, []byte{r << 3}); err != nil {
+		return err
+	}
+	m.gyroScale = mpuGyroScales[dps]
+	return nil
+}
+
+// SetAccelRange sets the full-scale range in g, must be 2, 4, 8 or 16
+func (m *MPU6050) SetAccelRange(g int) error {
+	r, ok := mpuAccelRanges[g]
+	if !ok {
+		return fmt.Errorf("invalid accel range: %v", g)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.dev.WriteReg(mpuRegAccelConfig, []byte{r << 3}); err != nil {
+		return err
+	}
+	m.accelScale = mpuAccelScales[g]
+	return nil
+}
+
+// Read reads a sample, the gyro bias has been removed from it
+func (m *MPU6050) Read() (*IMUSample, error) {
+	s, err := m.readRaw()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	s.Gx -= m.bias[0]
+	s.Gy -= m.bias[1]
+	s.Gz -= m.bias[2]
+	m.mu.Unlock()
+	return s, nil
+}
+
+// CalibrateGyro measures the bias of the gyroscope with n samples,
+// the sensor must keep still during calibrating.
+func (m *MPU6050) CalibrateGyro(n int) error {
+	if n <= 0 {
+		return errors.New("invalid number of samples")
+	}
+	var sum, sqsum [3]float64
+	for i := 0; i < n; i++ {
+		s, err := m.readRaw()
+		if err != nil {
+			return err
+		}
+		g := [3]float64{s.Gx, s.Gy, s.Gz}
+		for j := 0; j < 3; j++ {
+			sum[j] += g[j]
+			sqsum[j] += g[j] * g[j]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var bias [3]float64
+	for j := 0; j < 3; j++ {
+		bias[j] = sum[j] / float64(n)
+		stddev := math.Sqrt(math.Max(0, sqsum[j]/float64(n)-bias[j]*bias[j]))
+		if stddev > 2 {
+			return errors.New("the sensor was moving during calibrating")
+		}
+	}
+	m.SetGyroBias(bias)
+	return nil
+}
+
+// GyroBias ...
+func (m *MPU6050) GyroBias() [3]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bias
+}
+
+// SetGyroBias sets the bias from a previous calibration
+func (m *MPU6050) SetGyroBias(bias [3]float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bias = bias
+}
+
+// Close ...
+func (m *MPU6050) Close() {
+	// sleep mode
+	m.dev.WriteReg(mpuRegPwrMgmt1, []byte{0x40})
+	m.dev.Close()
+}
+
+func (m *MPU6050) readRaw() (*IMUSample, error) {
+	var buf [14]byte
+	if err := m.dev.ReadReg(mpuRegAccelXOutH, buf[:]); err != nil {
+		return nil, err
+	}
+	v := func(i int) float64 {
+		return float64(int16(uint16(buf[i])<<8 | uint16(buf[i+1])))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &IMUSample{
+		Ax:   v(0) / m.accelScale,
+		Ay:   v(2) / m.accelScale,
+		Az:   v(4) / m.accelScale,
+		Temp: v(6)/340.0 + 36.53,
+		Gx:   v(8) / m.gyroScale,
+		Gy:   v(10) / m.gyroScale,
+		Gz:   v(12) / m.gyroScale,
+	}, nil
+}
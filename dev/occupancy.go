@@ -0,0 +1,149 @@
+/*
+Package dev ...
+
+Occupancy tells whether a room is occupied or vacant from a motion sensor.
+A person sitting still doesn't trigger a PIR sensor,
+so the room is only taken as vacant after no motion for the hold-off time.
+It also counts the motion episodes, e.g. how many times somebody passed by the door.
+*/
+package dev
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Occupancy ...
+type Occupancy struct {
+	sensor  MotionSensor
+	holdOff time.Duration
+	now     func() time.Time
+
+	mu         sync.Mutex
+	occupied   bool
+	motion     bool
+	lastMotion time.Time
+	changedAt  time.Time
+	episodes   int
+	handlers   []func(occupied bool)
+	chQuit     chan bool
+}
+
+// NewOccupancy creates an occupancy tracker,
+// the room is vacant after no motion for holdOff.
+func NewOccupancy(sensor MotionSensor, holdOff time.Duration) *Occupancy {
+	return &Occupancy{
+		sensor:  sensor,
+		holdOff: holdOff,
+		now:     time.Now,
+	}
+}
+
+// SetClock replaces the clock, it's useful for testing
+func (o *Occupancy) SetClock(now func() time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.now = now
+}
+
+// OnChange registers a handler which will be called when the room became occupied or vacant
+func (o *Occupancy) OnChange(handler func(occupied bool)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.handlers = append(o.handlers, handler)
+}
+
+// Start keeps checking the sensor in background
+func (o *Occupancy) Start(interval time.Duration) {
+	o.chQuit = make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-o.chQuit:
+				return
+			case <-time.After(interval):
+				o.Update()
+			}
+		}
+	}()
+}
+
+// Stop ...
+func (o *Occupancy) Stop() {
+	if o.chQuit != nil {
+		close(o.chQuit)
+	}
+}
+
+// Update checks the sensor once, and returns whether the room is occupied
+func (o *Occupancy) Update() bool {
+	motion := o.sensor.Motion()
+
+	o.mu.Lock()
+	now := o.now()
+	if motion {
+		if !o.motion {
+			o.episodes++
+		}
+		o.lastMotion = now
+	}
+	o.motion = motion
+
+	occupied := o.occupied
+	if motion {
+		occupied = true
+	} else if o.occupied && now.Sub(o.lastMotion) >= o.holdOff {
+		occupied = false
+	}
+	changed := occupied != o.occupied
+	if changed {
+		o.occupied = occupied
+		o.changedAt = now
+	}
+	handlers := o.handlers
+	o.mu.Unlock()
+
+	if changed {
+		log.Printf("[occupancy]occupied: %v", occupied)
+		for _, h := range handlers {
+			h(occupied)
+		}
+	}
+	return occupied
+}
+
+// Occupied ...
+func (o *Occupancy) Occupied() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.occupied
+}
+
+// Since returns the time when the room became occupied or vacant
+func (o *Occupancy) Since() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.changedAt
+}
+
+// LastMotion ...
+func (o *Occupancy) LastMotion() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.lastMotion
+}
+
+// Episodes returns the number of motion episodes
+func (o *Occupancy) Episodes() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.episodes
+}
+
+// ResetEpisodes ...
+func (o *Occupancy) ResetEpisodes() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.episodes = 0
+}
@@ -1,8 +1,8 @@
 /*
 Package dev ...
 
-OLED is the driver of an oled screen.
-Please NOTE that current version only supports the oled module with ssd1306 driver.
+OLED is the driver of an oled screen for displaying text.
+It works with the oled modules on SSD1306 or SH1106 over i2c or spi, see SSD1306.
 
 connect to raspberry pi:
 VCC: pin 1 or any 3.3v pin
@@ -71,8 +71,7 @@ import (
 
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
-	"github.com/mdp/monochromeoled"
-	"golang.org/x/exp/io/i2c"
+	"github.com/shanghuiyang/rpi-devices/base"
 )
 
 const (
@@ -81,31 +80,35 @@ const (
 
 // OLED ...
 type OLED struct {
-	oled   *monochromeoled.OLED
-	width  int
-	height int
-	font   *truetype.Font
+	*SSD1306
+	font *truetype.Font
 }
 
-// NewOLED ...
+// NewOLED creates an oled on SSD1306 with i2c at 0x3c
 func NewOLED(width, heigth int) (*OLED, error) {
-	oled, err := monochromeoled.Open(&i2c.Devfs{Dev: "/dev/i2c-1"}, 0x3c, width, heigth)
+	return NewOLEDFromConfig(&base.OLEDConfig{
+		Width:  width,
+		Height: heigth,
+	})
+}
+
+// NewOLEDFromConfig creates an oled from the config, see NewSSD1306
+func NewOLEDFromConfig(cfg *base.OLEDConfig) (*OLED, error) {
+	a, err := ioutil.ReadFile(fontFile)
 	if err != nil {
 		return nil, err
 	}
-	a, err := ioutil.ReadFile(fontFile)
+	font, err := truetype.Parse(a)
 	if err != nil {
 		return nil, err
 	}
-	font, err := truetype.Parse(a)
+	oled, err := NewSSD1306(cfg)
 	if err != nil {
 		return nil, err
 	}
 	return &OLED{
-		oled:   oled,
-		width:  width,
-		height: heigth,
-		font:   font,
+		SSD1306: oled,
+		font:    font,
 	}, nil
 }
 
@@ -115,37 +118,22 @@ func (o *OLED) Display(text string, fontSize float64, x, y int) error {
 	if err != nil {
 		return err
 	}
-	if err := o.oled.SetImage(0, 0, image); err != nil {
-		return err
-	}
-	if err := o.oled.Draw(); err != nil {
-		return err
-	}
-	return nil
-}
-
-// Clear ...
-func (o *OLED) Clear() error {
-	if err := o.oled.Clear(); err != nil {
-		return err
-	}
-	return nil
+	return o.DrawImage(image)
 }
 
 // Close ...
 func (o *OLED) Close() {
-	o.oled.Clear()
-	o.oled.Close()
+	o.SSD1306.Close()
 }
 
 // Off ...
 func (o *OLED) Off() {
-	o.oled.Clear()
-	o.oled.Off()
+	o.Clear()
+	o.SSD1306.Off()
 }
 
 func (o *OLED) drawText(text string, size float64, x, y int) (image.Image, error) {
-	dst := image.NewRGBA(image.Rect(0, 0, o.width, o.height))
+	dst := image.NewRGBA(o.Bounds())
 	draw.Draw(dst, dst.Bounds(), image.Transparent, image.ZP, draw.Src)
 
 	c := freetype.NewContext()
@@ -0,0 +1,265 @@
+/*
+Package dev ...
+
+PCA9685 is the driver of PCA9685, a 16-channel 12-bit pwm driver over i2c.
+Pi has only 2 hardware pwm channels, PCA9685 can drive 16 servos or leds with one i2c bus.
+All the channels share the same frequency, please use 50Hz for servos.
+
+Spec:
+  - power supply:	VCC 3.3V - 5V for the chip, V+ up to 6V for the servos
+  - address:		0x40 - 0x7F, 0x40 in default
+  - frequency:		24Hz - 1526Hz
+  - resolution:		12-bit, 4096 ticks per cycle
+
+Connect to Pi:
+  - VCC:	any 3.3v pin
+  - GND:	any gnd pin
+  - SCL:	pin 5 (SCL)
+  - SDA:	pin 3 (SDA)
+  - V+:		an external 5v power for the servos
+*/
+package dev
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	pcaRegMode1     = 0x00
+	pcaRegMode2     = 0x01
+	pcaRegLed0OnL   = 0x06
+	pcaRegAllLedOnL = 0xFA
+	pcaRegPrescale  = 0xFE
+
+	pcaMode1Restart = 0x80
+	pcaMode1AI      = 0x20
+	pcaMode1Sleep   = 0x10
+	pcaMode1AllCall = 0x01
+	pcaMode2OutDrv  = 0x04
+
+	// the full on/off bit in LEDn_ON_H/LEDn_OFF_H
+	pcaFull = 0x1000
+
+	pcaOscillator = 25000000
+	pcaTicks      = 4096
+	pcaChannels   = 16
+)
+
+// PWMWriter is a pwm output, like a channel of PCA9685
+type PWMWriter interface {
+	// SetDuty sets the duty cycle in [0, 1]
+	SetDuty(duty float64) error
+}
+
+// PCA9685 ...
+type PCA9685 struct {
+	dev  i2cDevice
+	mu   sync.Mutex
+	freq float64
+}
+
+// NewPCA9685 creates a PCA9685 with the pwm frequency in Hz
+func NewPCA9685(addr uint8, freq float64) (*PCA9685, error) {
+	d, err := openI2C(defaultI2CBus, addr)
+	if err != nil {
+		return nil, err
+	}
+	p := newPCA9685(d)
+	if err := p.init(freq); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func newPCA9685(d i2cDevice) *PCA9685 {
+	return &PCA9685{
+		dev: d,
+	}
+}
+
+func (p *PCA9685) init(freq float64) error {
+	if err := p.AllOff(); err != nil {
+		return err
+	}
+	if err := p.dev.WriteReg(pcaRegMode2, []byte{pcaMode2OutDrv}); err != nil {
+		return err
+	}
+	if err := p.dev.WriteReg(pcaRegMode1, []byte{pcaMode1AI | pcaMode1AllCall}); err != nil {
+		return err
+	}
+	// wait for the oscillator
+	time.Sleep(5 * time.Millisecond)
+	return p.SetFreq(freq)
+}
+
+// SetFreq sets the pwm frequency of all the channels in Hz
+func (p *PCA9685) SetFreq(freq float64) error {
+	prescale := math.Round(pcaOscillator/(pcaTicks*freq)) - 1
+	if prescale < 3 || prescale > 255 {
+		return fmt.Errorf("invalid frequency: %v", freq)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	mode1, err := p.mode1()
+	if err != nil {
+		return err
+	}
+	// the prescaler can only be set in sleep mode
+	if err := p.dev.WriteReg(pcaRegMode1, []byte{mode1&^pcaMode1Restart | pcaMode1Sleep}); err != nil {
+		return err
+	}
+	if err := p.dev.WriteReg(pcaRegPrescale, []byte{byte(prescale)}); err != nil {
+		return err
+	}
+	if err := p.dev.WriteReg(pcaRegMode1, []byte{mode1 &^ pcaMode1Sleep}); err != nil {
+		return err
+	}
+	time.Sleep(500 * time.Microsecond)
+	if err := p.dev.WriteReg(pcaRegMode1, []byte{mode1&^pcaMode1Sleep | pcaMode1Restart}); err != nil {
+		return err
+	}
+	p.freq = pcaOscillator / (pcaTicks * (prescale + 1))
+	return nil
+}
+
+// Freq returns the actual frequency in Hz
+func (p *PCA9685) Freq() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.freq
+}
+
+// SetTicks sets the tick in [0, 4095] when the output of a channel turns on and off in a cycle
+func (p *PCA9685) SetTicks(ch int, on, off uint16) error {
+	if ch < 0 || ch >= pcaChannels {
+		return fmt.Errorf("invalid channel: %v", ch)
+	}
+	return p.writeTicks(byte(pcaRegLed0OnL+4*ch), on, off)
+}
+
+// SetDuty sets the duty cycle of a channel in [0, 1]
+func (p *PCA9685) SetDuty(ch int, duty float64) error {
+	switch {
+	case duty <= 0:
+		return p.SetTicks(ch, 0, pcaFull)
+	case duty >= 1:
+		return p.SetTicks(ch, pcaFull, 0)
+	}
+	// a duty close to 1 mustn't round to 4096, which is the full off bit
+	off := math.Min(math.Round(duty*pcaTicks), pcaTicks-1)
+	return p.SetTicks(ch, 0, uint16(off))
+}
+
+// SetPulse sets the width of the pulse of a channel in microsecond
+func (p *PCA9685) SetPulse(ch int, us float64) error {
+	return p.SetDuty(ch, us*p.Freq()/1000000)
+}
+
+// AllOff turns off all the channels
+func (p *PCA9685) AllOff() error {
+	return p.writeTicks(pcaRegAllLedOnL, 0, pcaFull)
+}
+
+// Sleep makes the chip into low power mode, all the outputs are off
+func (p *PCA9685) Sleep() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	mode1, err := p.mode1()
+	if err != nil {
+		return err
+	}
+	return p.dev.WriteReg(pcaRegMode1, []byte{mode1 | pcaMode1Sleep})
+}
+
+// Wake wakes the chip up, the outputs are restored
+func (p *PCA9685) Wake() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	mode1, err := p.mode1()
+	if err != nil {
+		return err
+	}
+	if err := p.dev.WriteReg(pcaRegMode1, []byte{mode1 &^ pcaMode1Sleep}); err != nil {
+		return err
+	}
+	if mode1&pcaMode1Restart == 0 {
+		return nil
+	}
+	time.Sleep(500 * time.Microsecond)
+	return p.dev.WriteReg(pcaRegMode1, []byte{mode1&^pcaMode1Sleep | pcaMode1Restart})
+}
+
+// Channel returns a channel as a pwm output
+func (p *PCA9685) Channel(ch int) *PCA9685Channel {
+	return &PCA9685Channel{
+		pca: p,
+		ch:  ch,
+	}
+}
+
+// Close ...
+func (p *PCA9685) Close() {
+	p.AllOff()
+	p.Sleep()
+	p.dev.Close()
+}
+
+func (p *PCA9685) mode1() (byte, error) {
+	var buf [1]byte
+	if err := p.dev.ReadReg(pcaRegMode1, buf[:]); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+func (p *PCA9685) writeTicks(reg byte, on, off uint16) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// the registers are little-endian, and auto-increment is on
+	return p.dev.WriteReg(reg, []byte{byte(on), byte(on >> 8), byte(off), byte(off >> 8)})
+}
+
+// PCA9685Channel is a channel of PCA9685
+type PCA9685Channel struct {
+	pca *PCA9685
+	ch  int
+}
+
+// SetDuty sets the duty cycle in [0, 1]
+func (c *PCA9685Channel) SetDuty(duty float64) error {
+	return c.pca.SetDuty(c.ch, duty)
+}
+
+// SetPulse sets the width of the pulse in microsecond
+func (c *PCA9685Channel) SetPulse(us float64) error {
+	return c.pca.SetPulse(c.ch, us)
+}
+
+// NewPCA9685Servo creates a servo on a channel of PCA9685,
+// the frequency of the PCA9685 must be 50Hz.
+func NewPCA9685Servo(pca *PCA9685, ch int, spec ServoSpec) *Servo {
+	return newServo(&pcaServoOutput{pca.Channel(ch)}, spec)
+}
+
+// pcaServoOutput makes a channel of PCA9685 to be the output of a servo
+type pcaServoOutput struct {
+	ch *PCA9685Channel
+}
+
+func (o *pcaServoOutput) SetPulse(us float64) {
+	if err := o.ch.SetPulse(us); err != nil {
+		log.Printf("[pca9685]failed to set pulse on channel %v, error: %v", o.ch.ch, err)
+	}
+}
+
+func (o *pcaServoOutput) Off() {
+	if err := o.ch.SetDuty(0); err != nil {
+		log.Printf("[pca9685]failed to turn off channel %v, error: %v", o.ch.ch, err)
+	}
+}
@@ -0,0 +1,44 @@
+package dev
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPCA9685(t *testing.T) {
+	bus := newFakeI2C()
+	p := newPCA9685(bus)
+	assert.NoError(t, p.SetFreq(50))
+	assert.Equal(t, []byte{121}, bus.regs[pcaRegPrescale])
+	assert.InDelta(t, 50.0, p.Freq(), 0.5)
+	assert.Equal(t, byte(0), bus.regs[pcaRegMode1][0]&pcaMode1Sleep)
+
+	// channel 3, on at 0, off at 410 of 4096
+	assert.NoError(t, p.SetDuty(3, 0.1))
+	assert.Equal(t, []byte{0x00, 0x00, 0x9A, 0x01}, bus.regs[pcaRegLed0OnL+12])
+
+	assert.NoError(t, p.SetDuty(3, 0.9999))
+	assert.Equal(t, []byte{0x00, 0x00, 0xFF, 0x0F}, bus.regs[pcaRegLed0OnL+12])
+
+	assert.NoError(t, p.SetDuty(15, 1))
+	assert.Equal(t, []byte{0x00, 0x10, 0x00, 0x00}, bus.regs[pcaRegLed0OnL+60])
+	assert.NoError(t, p.AllOff())
+	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x10}, bus.regs[pcaRegAllLedOnL])
+
+	assert.Error(t, p.SetTicks(16, 0, 0))
+	assert.Error(t, p.SetFreq(2000))
+
+	// 1.5ms of 20ms
+	servo := NewPCA9685Servo(p, 0, SG90Spec)
+	servo.SetPulse(1500)
+	v := bus.regs[pcaRegLed0OnL]
+	off := uint16(v[2]) | uint16(v[3])<<8
+	assert.InDelta(t, 1500.0/1000000*p.Freq()*4096, float64(off), 1)
+
+	led := NewPWMLed(p.Channel(1))
+	assert.NoError(t, led.SetBrightness(0.5))
+	v = bus.regs[pcaRegLed0OnL+4]
+	off = uint16(v[2]) | uint16(v[3])<<8
+	assert.Equal(t, uint16(891), off)
+}
@@ -0,0 +1,201 @@
+/*
+Package dev ...
+
+HC-SR501 is a PIR(passive infrared) motion sensor,
+it covers a cone of about 110 degree and 3 - 7 meters.
+
+The output keeps high for the delay time after a motion was detected.
+In the repeat trigger mode(jumper on H), the delay time restarts on every motion.
+In the single trigger mode(jumper on L), the output goes low after the delay time even if the motion continues.
+Either way, the output is blocked for about 2.5 seconds after it went low,
+so the driver takes the pulses separated by less than the block time as one motion.
+
+Spec:
+  - power supply:	4.5V - 20V
+  - output:			3.3V high, 0V low
+  - delay time:		0.3s - 5min, adjusted by the potentiometer Tx
+  - block time:		2.5s
+  - warm up:		about 1min after power on
+
+Connect to Pi:
+  - VCC:	any 5v pin
+  - GND:	any gnd pin
+  - OUT:	any data pin
+*/
+package dev
+
+import (
+	"sync"
+	"time"
+
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	pirInterval = 10 * time.Millisecond
+	pirDebounce = 50 * time.Millisecond
+	// a little longer than the block time of the module
+	pirBlockTime = 3 * time.Second
+	pirWarmUp    = 60 * time.Second
+)
+
+// MotionSensor is a sensor which tells whether there is a motion, like HC-SR501
+type MotionSensor interface {
+	Motion() bool
+}
+
+// PIREvent is raised when a motion started or ended
+type PIREvent struct {
+	Motion bool
+	Time   time.Time
+}
+
+// HCSR501 ...
+type HCSR501 struct {
+	pin     rpio.Pin
+	created time.Time
+
+	mu         sync.Mutex
+	filter     *pirFilter
+	motion     bool
+	lastMotion time.Time
+	events     chan PIREvent
+	chQuit     chan bool
+}
+
+// NewHCSR501 ...
+func NewHCSR501(pin uint8) *HCSR501 {
+	p := &HCSR501{
+		pin:     rpio.Pin(pin),
+		created: time.Now(),
+		filter:  newPIRFilter(pirDebounce, pirBlockTime),
+		events:  make(chan PIREvent, chSize),
+	}
+	p.pin.Input()
+	p.pin.PullDown()
+	return p
+}
+
+// SetDebounce sets the time the output must keep stable to be taken as a change
+func (p *HCSR501) SetDebounce(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.filter.debounce = d
+}
+
+// SetBlockTime sets the max gap between two pulses of one motion
+func (p *HCSR501) SetBlockTime(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.filter.block = d
+}
+
+// Start keeps sampling the output in background
+func (p *HCSR501) Start() {
+	p.chQuit = make(chan bool)
+	go p.start()
+}
+
+// Stop ...
+func (p *HCSR501) Stop() {
+	if p.chQuit != nil {
+		close(p.chQuit)
+	}
+}
+
+func (p *HCSR501) start() {
+	for {
+		select {
+		case <-p.chQuit:
+			return
+		case <-time.After(pirInterval):
+			// sample
+		}
+
+		now := time.Now()
+		high := p.pin.Read() == rpio.High
+
+		p.mu.Lock()
+		motion := p.filter.update(high, now)
+		if motion {
+			p.lastMotion = now
+		}
+		changed := motion != p.motion
+		p.motion = motion
+		p.mu.Unlock()
+
+		if changed {
+			select {
+			case p.events <- PIREvent{Motion: motion, Time: now}:
+			default:
+				// nobody is listening
+			}
+		}
+	}
+}
+
+// Events returns the channel of the motion events,
+// the events will be dropped if nobody reads them.
+func (p *HCSR501) Events() <-chan PIREvent {
+	return p.events
+}
+
+// Motion returns true if there is a motion
+func (p *HCSR501) Motion() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.motion
+}
+
+// LastMotion returns the last time a motion was seen
+func (p *HCSR501) LastMotion() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastMotion
+}
+
+// Ready returns false in the first minute after power on,
+// the output of the module isn't reliable when it is warming up.
+func (p *HCSR501) Ready() bool {
+	return time.Since(p.created) > pirWarmUp
+}
+
+// pirFilter debounces the output, and merges the pulses separated by the block time
+type pirFilter struct {
+	debounce time.Duration
+	block    time.Duration
+
+	raw      bool
+	rawSince time.Time
+	level    bool
+	fellAt   time.Time
+	motion   bool
+}
+
+func newPIRFilter(debounce, block time.Duration) *pirFilter {
+	return &pirFilter{
+		debounce: debounce,
+		block:    block,
+	}
+}
+
+// update takes a sample of the output, and returns whether there is a motion
+func (f *pirFilter) update(high bool, now time.Time) bool {
+	if high != f.raw {
+		f.raw = high
+		f.rawSince = now
+	}
+	if f.raw != f.level && now.Sub(f.rawSince) >= f.debounce {
+		f.level = f.raw
+		if !f.level {
+			f.fellAt = now
+		}
+	}
+
+	if f.level {
+		f.motion = true
+	} else if f.motion && now.Sub(f.fellAt) >= f.block {
+		f.motion = false
+	}
+	return f.motion
+}
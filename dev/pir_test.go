@@ -0,0 +1,85 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMotion struct {
+	motion bool
+}
+
+func (f *fakeMotion) Motion() bool {
+	return f.motion
+}
+
+func TestPIRFilter(t *testing.T) {
+	f := newPIRFilter(50*time.Millisecond, 3*time.Second)
+	t0 := time.Now()
+	at := func(ms int) time.Time {
+		return t0.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	// a glitch shorter than the debounce time
+	assert.False(t, f.update(true, at(0)))
+	assert.False(t, f.update(true, at(30)))
+	assert.False(t, f.update(false, at(40)))
+	assert.False(t, f.update(false, at(200)))
+
+	// a real pulse
+	assert.False(t, f.update(true, at(1000)))
+	assert.True(t, f.update(true, at(1050)))
+	assert.True(t, f.update(false, at(2000)))
+	assert.True(t, f.update(false, at(2050)))
+
+	// retriggered right after the block time, it's the same motion
+	assert.True(t, f.update(true, at(4600)))
+	assert.True(t, f.update(true, at(4650)))
+	assert.True(t, f.update(false, at(5000)))
+
+	// no more pulses
+	assert.True(t, f.update(false, at(7000)))
+	assert.True(t, f.update(false, at(9000)))
+	assert.False(t, f.update(false, at(10000)))
+}
+
+func TestOccupancy(t *testing.T) {
+	sensor := &fakeMotion{}
+	now := time.Now()
+	o := NewOccupancy(sensor, 5*time.Minute)
+	o.SetClock(func() time.Time { return now })
+
+	var changes []bool
+	o.OnChange(func(occupied bool) {
+		changes = append(changes, occupied)
+	})
+
+	assert.False(t, o.Update())
+
+	sensor.motion = true
+	assert.True(t, o.Update())
+	now = now.Add(10 * time.Second)
+	assert.True(t, o.Update())
+
+	sensor.motion = false
+	now = now.Add(4 * time.Minute)
+	assert.True(t, o.Update())
+
+	// another episode resets the hold-off
+	sensor.motion = true
+	assert.True(t, o.Update())
+	sensor.motion = false
+	now = now.Add(4 * time.Minute)
+	assert.True(t, o.Update())
+
+	now = now.Add(1 * time.Minute)
+	assert.False(t, o.Update())
+	assert.Equal(t, now, o.Since())
+
+	assert.Equal(t, 2, o.Episodes())
+	assert.Equal(t, []bool{true, false}, changes)
+	o.ResetEpisodes()
+	assert.Equal(t, 0, o.Episodes())
+}
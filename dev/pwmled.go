@@ -0,0 +1,83 @@
+/*
+Package dev ...
+
+PWMLed is a dimmable led on a pwm output, like a channel of PCA9685.
+The brightness is gamma corrected, so it looks linear to eyes.
+
+Connect to PCA9685:
+  - positive(the longer pin):	the PWM pin of a channel, with a 220 ohm resistor
+  - negative(she shorter pin):	the GND pin of the channel
+*/
+package dev
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	ledGamma     = 2.2
+	ledFadeFrame = 20 * time.Millisecond
+)
+
+// PWMLed ...
+type PWMLed struct {
+	out        PWMWriter
+	mu         sync.Mutex
+	brightness float64
+}
+
+// NewPWMLed ...
+func NewPWMLed(out PWMWriter) *PWMLed {
+	return &PWMLed{
+		out: out,
+	}
+}
+
+// SetBrightness sets the brightness in [0, 1]
+func (l *PWMLed) SetBrightness(b float64) error {
+	b = math.Max(0, math.Min(1, b))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.out.SetDuty(math.Pow(b, ledGamma)); err != nil {
+		return err
+	}
+	l.brightness = b
+	return nil
+}
+
+// Brightness ...
+func (l *PWMLed) Brightness() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.brightness
+}
+
+// On ...
+func (l *PWMLed) On() error {
+	return l.SetBrightness(1)
+}
+
+// Off ...
+func (l *PWMLed) Off() error {
+	return l.SetBrightness(0)
+}
+
+// SetColor sets the brightness to the brightness of the color
+func (l *PWMLed) SetColor(c Color) error {
+	return l.SetBrightness(c.Brightness())
+}
+
+// FadeTo changes the brightness to b gradually in d
+func (l *PWMLed) FadeTo(b float64, d time.Duration) error {
+	from := l.Brightness()
+	n := int(d / ledFadeFrame)
+	for i := 1; i <= n; i++ {
+		if err := l.SetBrightness(from + (b-from)*float64(i)/float64(n)); err != nil {
+			return err
+		}
+		time.Sleep(ledFadeFrame)
+	}
+	return l.SetBrightness(b)
+}
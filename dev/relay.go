@@ -1,6 +1,9 @@
 /*
 Package dev ...
 
+Most relay boards are active-low, the relay is on when the input is low,
+please use NewActiveLowRelay for them.
+
 Connect to Pi:
  - vcc: any 5v pin
  - gnd: any gnd pin
@@ -17,11 +20,12 @@ import (
 
 // Relay ...
 type Relay struct {
-	pin  rpio.Pin
-	isOn bool
+	pin       rpio.Pin
+	activeLow bool
+	isOn      bool
 }
 
-// NewRelay ...
+// NewRelay creates an active-high relay
 func NewRelay(pin uint8) *Relay {
 	r := &Relay{
 		pin:  rpio.Pin(pin),
@@ -31,10 +35,24 @@ func NewRelay(pin uint8) *Relay {
 	return r
 }
 
+// NewActiveLowRelay creates an active-low relay, it's off after created
+func NewActiveLowRelay(pin uint8) *Relay {
+	r := &Relay{
+		pin:       rpio.Pin(pin),
+		activeLow: true,
+		isOn:      false,
+	}
+	// keep it off before setting the pin as output
+	r.pin.High()
+	r.pin.Output()
+	r.pin.High()
+	return r
+}
+
 // On ...
 func (r *Relay) On() {
 	if !r.isOn {
-		r.pin.High()
+		r.write(true)
 		r.isOn = true
 	}
 }
@@ -42,7 +60,20 @@ func (r *Relay) On() {
 // Off ...
 func (r *Relay) Off() {
 	if r.isOn {
-		r.pin.Low()
+		r.write(false)
 		r.isOn = false
 	}
 }
+
+// IsOn ...
+func (r *Relay) IsOn() bool {
+	return r.isOn
+}
+
+func (r *Relay) write(on bool) {
+	if on != r.activeLow {
+		r.pin.High()
+	} else {
+		r.pin.Low()
+	}
+}
@@ -0,0 +1,498 @@
+/*
+Package dev ...
+
+RelayManager manages the channels of a relay board, it protects the devices on the relays:
+  - min on/off time:	a compressor or a pump shouldn't be switched too often(anti-short-cycle),
+						the channel refuses to turn on/off before the min time is reached
+  - interlocks:			the channels in an interlock can't be on at the same time,
+						e.g. the up and down of a motor
+  - pulses:				a channel can be turned on for a while, e.g. watering for 10 minutes
+
+The states are saved in a json file, so the channels can be restored after reboot,
+or they are kept off which is the safe default. The min off time still applies after reboot,
+a channel which was on before reboot is taken as just turned off, and it's restored after the min off time.
+It also tracks the cumulative on time of every channel.
+*/
+package dev
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+)
+
+// Switch is an output which can be turned on and off, like Relay
+type Switch interface {
+	On()
+	Off()
+}
+
+// RelayOptions ...
+type RelayOptions struct {
+	MinOn  time.Duration
+	MinOff time.Duration
+	// Restore restores the state after reboot, or the channel is kept off
+	Restore bool
+}
+
+// RelayStatus is the status of a channel
+type RelayStatus struct {
+	Name string
+	On   bool
+	// Since is the time when the channel was turned on or off
+	Since time.Time
+	// OnTime is the cumulative on time
+	OnTime time.Duration
+	// PulseUntil is the time when a pulse ends, it's zero if there isn't a pulse
+	PulseUntil time.Time
+}
+
+// relayState is the state of a channel saved in the file
+type relayState struct {
+	On         bool          `json:"on"`
+	Since      time.Time     `json:"since"`
+	OnTime     time.Duration `json:"on_time"`
+	PulseUntil time.Time     `json:"pulse_until"`
+}
+
+type relayStates struct {
+	Updated  time.Time              `json:"updated"`
+	Channels map[string]*relayState `json:"channels"`
+}
+
+type relayChannel struct {
+	name   string
+	sw     Switch
+	opts   RelayOptions
+	on     bool
+	since  time.Time
+	onTime time.Duration
+	// the pulse of the channel, pulse is increased to cancel the timer of the last one
+	pulseUntil time.Time
+	pulse      int
+	timer      *time.Timer
+	// the saved state to restore after the min off time, it's cancelled like a pulse
+	restoring *relayState
+}
+
+// RelayManager ...
+type RelayManager struct {
+	file string
+	now  func() time.Time
+
+	mu         sync.Mutex
+	channels   map[string]*relayChannel
+	interlocks map[string][]string
+	saved      *relayStates
+}
+
+// NewRelayManager creates a relay manager which saves the states in the file,
+// the states aren't saved if file is empty.
+func NewRelayManager(file string) *RelayManager {
+	m := &RelayManager{
+		file:       file,
+		now:        time.Now,
+		channels:   map[string]*relayChannel{},
+		interlocks: map[string][]string{},
+		saved:      &relayStates{Channels: map[string]*relayState{}},
+	}
+	if file == "" {
+		return m
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[relay]failed to read %v, all channels will be off, error: %v", file, err)
+		}
+		return m
+	}
+	saved := &relayStates{}
+	if err := json.Unmarshal(data, saved); err != nil || saved.Channels == nil {
+		log.Printf("[relay]invalid states in %v, all channels will be off, error: %v", file, err)
+		return m
+	}
+	m.saved = saved
+	return m
+}
+
+// SetClock replaces the clock, it's useful for testing
+func (m *RelayManager) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+// AddChannel adds a channel, it's off after added
+func (m *RelayManager) AddChannel(name string, sw Switch, opts RelayOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.channels[name]; ok {
+		return fmt.Errorf("relay channel %v already exists", name)
+	}
+	ch := &relayChannel{
+		name: name,
+		sw:   sw,
+		opts: opts,
+	}
+	if s, ok := m.saved.Channels[name]; ok {
+		ch.onTime = s.OnTime
+		if s.On && !m.saved.Updated.IsZero() {
+			// it was on until the last save at least
+			ch.onTime += m.saved.Updated.Sub(s.Since)
+		}
+		ch.since = s.Since
+		if s.On || ch.since.IsZero() {
+			// it's unknown when it was turned off, e.g. it crashed while it was on,
+			// so it's taken as just turned off to keep the min off time.
+			ch.since = m.now()
+		}
+	}
+	sw.Off()
+	m.channels[name] = ch
+	return nil
+}
+
+// AddFromConfig adds a relay from the config as a channel
+func (m *RelayManager) AddFromConfig(cfg *base.RelayConfig) error {
+	var r *Relay
+	if cfg.ActiveLow {
+		r = NewActiveLowRelay(cfg.Pin)
+	} else {
+		r = NewRelay(cfg.Pin)
+	}
+	return m.AddChannel(cfg.Name, r, RelayOptions{
+		MinOn:   time.Duration(cfg.MinOn) * time.Second,
+		MinOff:  time.Duration(cfg.MinOff) * time.Second,
+		Restore: cfg.Restore,
+	})
+}
+
+// Interlock makes the channels mutually exclusive, only one of them can be on at a time
+func (m *RelayManager) Interlock(names ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, name := range names {
+		if _, ok := m.channels[name]; !ok {
+			return fmt.Errorf("relay channel %v doesn't exist", name)
+		}
+	}
+	for _, a := range names {
+		for _, b := range names {
+			if a != b {
+				m.interlocks[a] = append(m.interlocks[a], b)
+			}
+		}
+	}
+	return nil
+}
+
+// Restore restores the channels with Restore option to the saved states,
+// an unfinished pulse continues for the rest of the time.
+// The channels with the min off time are restored after it in background,
+// and they are saved as on until then.
+func (m *RelayManager) Restore() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var firstErr error
+	for _, name := range m.names() {
+		ch := m.channels[name]
+		s, ok := m.saved.Channels[name]
+		if !ok || !s.On || !ch.opts.Restore {
+			continue
+		}
+		if at := ch.since.Add(ch.opts.MinOff); !ch.on && now.Before(at) {
+			m.restoreAt(ch, s, at.Sub(now))
+			continue
+		}
+		if err := m.restore(ch, s, now); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	m.save(now)
+	return firstErr
+}
+
+// On turns on the channel, it cancels the pulse if the channel is in a pulse
+func (m *RelayManager) On(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch, err := m.channel(name)
+	if err != nil {
+		return err
+	}
+	now := m.now()
+	if err := m.on(ch, now); err != nil {
+		return err
+	}
+	m.save(now)
+	return nil
+}
+
+// Off turns off the channel
+func (m *RelayManager) Off(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch, err := m.channel(name)
+	if err != nil {
+		return err
+	}
+	now := m.now()
+	if ch.on && now.Sub(ch.since) < ch.opts.MinOn {
+		return fmt.Errorf("relay channel %v has been on for %v, min on time is %v", name, now.Sub(ch.since), ch.opts.MinOn)
+	}
+	m.off(ch, now)
+	m.save(now)
+	return nil
+}
+
+// Pulse turns on the channel for the duration, it's extended to the min on time if it's shorter
+func (m *RelayManager) Pulse(name string, d time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch, err := m.channel(name)
+	if err != nil {
+		return err
+	}
+	now := m.now()
+	if err := m.pulseOn(ch, d, now); err != nil {
+		return err
+	}
+	m.save(now)
+	return nil
+}
+
+// AllOff turns off all the channels immediately regardless of the min on time
+func (m *RelayManager) AllOff() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for _, ch := range m.channels {
+		m.off(ch, now)
+	}
+	m.save(now)
+}
+
+// Status returns the status of the channel
+func (m *RelayManager) Status(name string) (RelayStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch, err := m.channel(name)
+	if err != nil {
+		return RelayStatus{}, err
+	}
+	return m.status(ch, m.now()), nil
+}
+
+// Statuses returns the status of all channels sorted by name
+func (m *RelayManager) Statuses() []RelayStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var statuses []RelayStatus
+	for _, name := range m.names() {
+		statuses = append(statuses, m.status(m.channels[name], now))
+	}
+	return statuses
+}
+
+// Save saves the states to the file, the states are saved on every change,
+// call it periodically to keep the on time of a long running channel.
+func (m *RelayManager) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.save(m.now())
+}
+
+// Close stops the pulses and saves the states, the channels are kept as they are
+func (m *RelayManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ch := range m.channels {
+		m.stopPulse(ch)
+	}
+	return m.save(m.now())
+}
+
+func (m *RelayManager) channel(name string) (*relayChannel, error) {
+	ch, ok := m.channels[name]
+	if !ok {
+		return nil, fmt.Errorf("relay channel %v doesn't exist", name)
+	}
+	return ch, nil
+}
+
+func (m *RelayManager) names() []string {
+	var names []string
+	for name := range m.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *RelayManager) on(ch *relayChannel, now time.Time) error {
+	if ch.on {
+		m.stopPulse(ch)
+		return nil
+	}
+	for _, name := range m.interlocks[ch.name] {
+		if m.channels[name].on {
+			return fmt.Errorf("relay channel %v is interlocked with %v which is on", ch.name, name)
+		}
+	}
+	if !ch.since.IsZero() && now.Sub(ch.since) < ch.opts.MinOff {
+		return fmt.Errorf("relay channel %v has been off for %v, min off time is %v", ch.name, now.Sub(ch.since), ch.opts.MinOff)
+	}
+	ch.sw.On()
+	ch.on = true
+	ch.since = now
+	return nil
+}
+
+func (m *RelayManager) off(ch *relayChannel, now time.Time) {
+	m.stopPulse(ch)
+	if !ch.on {
+		return
+	}
+	ch.sw.Off()
+	ch.on = false
+	ch.onTime += now.Sub(ch.since)
+	ch.since = now
+}
+
+func (m *RelayManager) pulseOn(ch *relayChannel, d time.Duration, now time.Time) error {
+	if err := m.on(ch, now); err != nil {
+		return err
+	}
+	// keep it on for the min on time at least
+	if minEnd := ch.since.Add(ch.opts.MinOn); now.Add(d).Before(minEnd) {
+		d = minEnd.Sub(now)
+	}
+	m.stopPulse(ch)
+	ch.pulseUntil = now.Add(d)
+	pulse := ch.pulse
+	ch.timer = time.AfterFunc(d, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if ch.pulse != pulse {
+			// the pulse was cancelled
+			return
+		}
+		now := m.now()
+		m.off(ch, now)
+		m.save(now)
+	})
+	return nil
+}
+
+func (m *RelayManager) stopPulse(ch *relayChannel) {
+	if ch.timer != nil {
+		ch.timer.Stop()
+		ch.timer = nil
+	}
+	ch.pulse++
+	ch.pulseUntil = time.Time{}
+	ch.restoring = nil
+}
+
+func (m *RelayManager) restore(ch *relayChannel, s *relayState, now time.Time) error {
+	if s.PulseUntil.IsZero() {
+		return m.on(ch, now)
+	}
+	if s.PulseUntil.After(now) {
+		return m.pulseOn(ch, s.PulseUntil.Sub(now), now)
+	}
+	return nil
+}
+
+// restoreAt restores the channel to the saved state after d,
+// it's cancelled by turning the channel on or off like a pulse.
+func (m *RelayManager) restoreAt(ch *relayChannel, s *relayState, d time.Duration) {
+	m.stopPulse(ch)
+	ch.restoring = s
+	pulse := ch.pulse
+	ch.timer = time.AfterFunc(d, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if ch.pulse != pulse {
+			// the restore was cancelled
+			return
+		}
+		ch.timer = nil
+		ch.restoring = nil
+		now := m.now()
+		if err := m.restore(ch, s, now); err != nil {
+			log.Printf("[relay]failed to restore %v, error: %v", ch.name, err)
+		}
+		m.save(now)
+	})
+}
+
+func (m *RelayManager) status(ch *relayChannel, now time.Time) RelayStatus {
+	s := RelayStatus{
+		Name:       ch.name,
+		On:         ch.on,
+		Since:      ch.since,
+		OnTime:     ch.onTime,
+		PulseUntil: ch.pulseUntil,
+	}
+	if ch.on {
+		s.OnTime += now.Sub(ch.since)
+	}
+	return s
+}
+
+func (m *RelayManager) save(now time.Time) error {
+	if m.file == "" {
+		return nil
+	}
+	states := &relayStates{
+		Updated:  now,
+		Channels: map[string]*relayState{},
+	}
+	// keep the states of the channels which aren't added
+	for name, s := range m.saved.Channels {
+		states.Channels[name] = s
+	}
+	for name, ch := range m.channels {
+		if s := ch.restoring; s != nil {
+			// it's still on to be restored after reboot, since now adds nothing to the on time
+			states.Channels[name] = &relayState{
+				On:         true,
+				Since:      now,
+				OnTime:     ch.onTime,
+				PulseUntil: s.PulseUntil,
+			}
+			continue
+		}
+		states.Channels[name] = &relayState{
+			On:         ch.on,
+			Since:      ch.since,
+			OnTime:     ch.onTime,
+			PulseUntil: ch.pulseUntil,
+		}
+	}
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temp file first, so a power cut won't leave a broken file
+	tmp := m.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("[relay]failed to save states, error: %v", err)
+		return err
+	}
+	if err := os.Rename(tmp, m.file); err != nil {
+		log.Printf("[relay]failed to save states, error: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package dev
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSwitch struct {
+	on       bool
+	switches int
+}
+
+func (f *fakeSwitch) On() {
+	f.on = true
+	f.switches++
+}
+
+func (f *fakeSwitch) Off() {
+	f.on = false
+	f.switches++
+}
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) now() time.Time {
+	return f.t
+}
+
+func (f *fakeClock) add(d time.Duration) {
+	f.t = f.t.Add(d)
+}
+
+func TestRelayMinOnOff(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewRelayManager("")
+	m.SetClock(clock.now)
+
+	sw := &fakeSwitch{}
+	assert.NoError(t, m.AddChannel("compressor", sw, RelayOptions{MinOn: 5 * time.Minute, MinOff: 3 * time.Minute}))
+	assert.Error(t, m.AddChannel("compressor", sw, RelayOptions{}))
+	assert.Error(t, m.On("nothing"))
+
+	assert.NoError(t, m.On("compressor"))
+	assert.True(t, sw.on)
+
+	// it can't be off before the min on time
+	clock.add(time.Minute)
+	assert.Error(t, m.Off("compressor"))
+	assert.True(t, sw.on)
+	clock.add(4 * time.Minute)
+	assert.NoError(t, m.Off("compressor"))
+	assert.False(t, sw.on)
+
+	// it can't be on before the min off time
+	clock.add(2 * time.Minute)
+	assert.Error(t, m.On("compressor"))
+	clock.add(time.Minute)
+	assert.NoError(t, m.On("compressor"))
+
+	clock.add(10 * time.Minute)
+	s, err := m.Status("compressor")
+	assert.NoError(t, err)
+	assert.True(t, s.On)
+	assert.Equal(t, 15*time.Minute, s.OnTime)
+
+	// all off regardless of the min on time
+	assert.NoError(t, m.On("compressor"))
+	m.AllOff()
+	assert.False(t, sw.on)
+}
+
+func TestRelayInterlock(t *testing.T) {
+	m := NewRelayManager("")
+	up, down := &fakeSwitch{}, &fakeSwitch{}
+	assert.NoError(t, m.AddChannel("up", up, RelayOptions{}))
+	assert.NoError(t, m.AddChannel("down", down, RelayOptions{}))
+	assert.Error(t, m.Interlock("up", "nothing"))
+	assert.NoError(t, m.Interlock("up", "down"))
+
+	assert.NoError(t, m.On("up"))
+	assert.Error(t, m.On("down"))
+	assert.Error(t, m.Pulse("down", time.Second))
+	assert.False(t, down.on)
+
+	assert.NoError(t, m.Off("up"))
+	assert.NoError(t, m.On("down"))
+	assert.True(t, down.on)
+}
+
+func TestRelayPulse(t *testing.T) {
+	m := NewRelayManager("")
+	assert.NoError(t, m.AddChannel("pump", &fakeSwitch{}, RelayOptions{MinOn: 40 * time.Millisecond}))
+
+	// the pulse is extended to the min on time
+	assert.NoError(t, m.Pulse("pump", 10*time.Millisecond))
+	s, _ := m.Status("pump")
+	assert.False(t, s.PulseUntil.IsZero())
+	time.Sleep(20 * time.Millisecond)
+	s, _ = m.Status("pump")
+	assert.True(t, s.On)
+	time.Sleep(60 * time.Millisecond)
+	s, _ = m.Status("pump")
+	assert.False(t, s.On)
+	assert.True(t, s.PulseUntil.IsZero())
+
+	// turning on cancels the pulse
+	assert.NoError(t, m.Pulse("pump", 50*time.Millisecond))
+	assert.NoError(t, m.On("pump"))
+	time.Sleep(80 * time.Millisecond)
+	s, _ = m.Status("pump")
+	assert.True(t, s.On)
+}
+
+func TestRelayRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relay")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "relays.json")
+
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewRelayManager(file)
+	m.SetClock(clock.now)
+	assert.NoError(t, m.AddChannel("heater", &fakeSwitch{}, RelayOptions{Restore: true}))
+	assert.NoError(t, m.AddChannel("pump", &fakeSwitch{}, RelayOptions{}))
+	assert.NoError(t, m.On("heater"))
+	assert.NoError(t, m.On("pump"))
+	clock.add(time.Hour)
+	assert.NoError(t, m.Save())
+
+	// reboot
+	heater, pump := &fakeSwitch{}, &fakeSwitch{}
+	m = NewRelayManager(file)
+	m.SetClock(clock.now)
+	assert.NoError(t, m.AddChannel("heater", heater, RelayOptions{Restore: true}))
+	assert.NoError(t, m.AddChannel("pump", pump, RelayOptions{}))
+	assert.False(t, heater.on)
+	assert.NoError(t, m.Restore())
+	assert.True(t, heater.on)
+	assert.False(t, pump.on)
+
+	// the on time is kept
+	statuses := m.Statuses()
+	assert.Len(t, statuses, 2)
+	assert.Equal(t, "heater", statuses[0].Name)
+	assert.Equal(t, time.Hour, statuses[0].OnTime)
+	assert.Equal(t, time.Hour, statuses[1].OnTime)
+
+	// a broken file resets the channels
+	assert.NoError(t, ioutil.WriteFile(file, []byte("{"), 0644))
+	heater = &fakeSwitch{}
+	m = NewRelayManager(file)
+	assert.NoError(t, m.AddChannel("heater", heater, RelayOptions{Restore: true}))
+	assert.NoError(t, m.Restore())
+	assert.False(t, heater.on)
+}
+
+func TestRelayRestoreMinOff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relay")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "relays.json")
+	opts := RelayOptions{MinOff: 200 * time.Millisecond, Restore: true}
+
+	m := NewRelayManager(file)
+	assert.NoError(t, m.AddChannel("compressor", &fakeSwitch{}, opts))
+	assert.NoError(t, m.AddChannel("fan", &fakeSwitch{}, opts))
+	assert.NoError(t, m.On("compressor"))
+	assert.NoError(t, m.On("fan"))
+	assert.NoError(t, m.Off("fan"))
+
+	// crashed and restarted
+	m = NewRelayManager(file)
+	assert.NoError(t, m.AddChannel("compressor", &fakeSwitch{}, opts))
+	assert.NoError(t, m.AddChannel("fan", &fakeSwitch{}, opts))
+	assert.NoError(t, m.Restore())
+	s, err := m.Status("compressor")
+	assert.NoError(t, err)
+	assert.False(t, s.On)
+	assert.Error(t, m.On("fan"))
+
+	// it's still on in the file until it's restored
+	assert.True(t, NewRelayManager(file).saved.Channels["compressor"].On)
+	assert.Eventually(t, func() bool {
+		s, _ := m.Status("compressor")
+		return s.On
+	}, time.Second, 10*time.Millisecond)
+	s, _ = m.Status("fan")
+	assert.False(t, s.On)
+
+	// the restore is cancelled by turning it off
+	m.Close()
+	m = NewRelayManager(file)
+	assert.NoError(t, m.AddChannel("compressor", &fakeSwitch{}, opts))
+	assert.NoError(t, m.Restore())
+	assert.NoError(t, m.Off("compressor"))
+	assert.False(t, NewRelayManager(file).saved.Channels["compressor"].On)
+	time.Sleep(300 * time.Millisecond)
+	s, _ = m.Status("compressor")
+	assert.False(t, s.On)
+}
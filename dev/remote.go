@@ -0,0 +1,279 @@
+/*
+Package dev ...
+
+Remote turns the outputs of a 433MHz receiver like RX480E4 into button gestures,
+and dispatches the gestures to named actions by a mapping table.
+Several apps can share one receiver, each one handles the actions it cares about.
+
+The module can learn the remotes in 3 modes,
+please set the mode of Remote the same as the module:
+  - momentary:	the output is high while the button is held
+  - toggle:		the output flips on every press
+  - latched:	the output of the pressed button goes high, and the others go low
+
+All the gestures are supported in momentary mode,
+long press isn't supported in toggle mode since the release can't be seen,
+and only click is supported in latched mode since pressing the same button again changes nothing.
+*/
+package dev
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+)
+
+const (
+	remoteInterval = 20 * time.Millisecond
+	// the second click must come within doubleClickWindow after the first one
+	doubleClickWindow = 400 * time.Millisecond
+	longPressTime     = 800 * time.Millisecond
+)
+
+// RXMode is the output mode of RX480E4
+type RXMode string
+
+// RX480E4 modes
+const (
+	RXMomentary RXMode = "momentary"
+	RXToggle    RXMode = "toggle"
+	RXLatched   RXMode = "latched"
+)
+
+// Gesture ...
+type Gesture string
+
+// Gestures
+const (
+	Click       Gesture = "click"
+	DoubleClick Gesture = "doubleclick"
+	LongPress   Gesture = "longpress"
+)
+
+// RemoteEvent is a gesture on a button of a remote
+type RemoteEvent struct {
+	Remote  string
+	Button  RXButton
+	Gesture Gesture
+	Action  string
+	Time    time.Time
+}
+
+// RemoteHandler ...
+type RemoteHandler func(e RemoteEvent)
+
+type remoteKey struct {
+	button  RXButton
+	gesture Gesture
+}
+
+// buttonSource reads the outputs of the buttons, it's RX480E4 in the real world
+type buttonSource interface {
+	Pressed(b RXButton) bool
+}
+
+// Remote ...
+type Remote struct {
+	name string
+	rx   buttonSource
+	mode RXMode
+
+	mu       sync.Mutex
+	buttons  [4]*gestureDetector
+	actions  map[remoteKey]string
+	handlers map[string][]RemoteHandler
+	chQuit   chan bool
+}
+
+// NewRemote creates a remote over a receiver in the mode as the module was set
+func NewRemote(name string, rx *RX480E4, mode RXMode) *Remote {
+	return newRemote(name, rx, mode)
+}
+
+// NewRemoteFromConfig creates a remote and its receiver with the config
+func NewRemoteFromConfig(cfg *base.RemoteConfig) (*Remote, error) {
+	switch RXMode(cfg.Mode) {
+	case RXMomentary, RXToggle, RXLatched:
+	default:
+		return nil, fmt.Errorf("invalid mode: %v", cfg.Mode)
+	}
+	rx := NewRX480E4(cfg.D0, cfg.D1, cfg.D2, cfg.D3)
+	r := NewRemote(cfg.Name, rx, RXMode(cfg.Mode))
+	if err := r.LoadMappings(cfg.Mappings); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func newRemote(name string, rx buttonSource, mode RXMode) *Remote {
+	r := &Remote{
+		name:     name,
+		rx:       rx,
+		mode:     mode,
+		actions:  map[remoteKey]string{},
+		handlers: map[string][]RemoteHandler{},
+	}
+	for i := range r.buttons {
+		r.buttons[i] = &gestureDetector{mode: mode}
+	}
+	return r
+}
+
+// Map maps a gesture on a button to an action
+func (r *Remote) Map(b RXButton, g Gesture, action string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.actions[remoteKey{button: b, gesture: g}] = action
+}
+
+// LoadMappings loads the mappings of this remote, the mappings of other remotes are skipped
+func (r *Remote) LoadMappings(mappings []*base.RemoteMapping) error {
+	for _, m := range mappings {
+		if m.Remote != r.name {
+			continue
+		}
+		b, err := ParseRXButton(m.Button)
+		if err != nil {
+			return err
+		}
+		g := Gesture(m.Gesture)
+		switch g {
+		case Click, DoubleClick, LongPress:
+		default:
+			return fmt.Errorf("invalid gesture: %v", m.Gesture)
+		}
+		r.Map(b, g, m.Action)
+	}
+	return nil
+}
+
+// Handle registers a handler of an action,
+// an action can have several handlers, they're called in order.
+func (r *Remote) Handle(action string, h RemoteHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[action] = append(r.handlers[action], h)
+}
+
+// Start keeps polling the receiver in background
+func (r *Remote) Start() {
+	r.chQuit = make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-r.chQuit:
+				return
+			case <-time.After(remoteInterval):
+				r.update(time.Now())
+			}
+		}
+	}()
+}
+
+// Stop ...
+func (r *Remote) Stop() {
+	if r.chQuit != nil {
+		close(r.chQuit)
+	}
+}
+
+func (r *Remote) update(now time.Time) {
+	for i, d := range r.buttons {
+		b := RXButton(i)
+		for _, g := range d.update(r.rx.Pressed(b), now) {
+			r.dispatch(RemoteEvent{
+				Remote:  r.name,
+				Button:  b,
+				Gesture: g,
+				Time:    now,
+			})
+		}
+	}
+}
+
+func (r *Remote) dispatch(e RemoteEvent) {
+	r.mu.Lock()
+	action, ok := r.actions[remoteKey{button: e.Button, gesture: e.Gesture}]
+	handlers := r.handlers[action]
+	r.mu.Unlock()
+
+	if !ok {
+		log.Printf("[remote]%v: %v on %v isn't mapped", r.name, e.Gesture, e.Button)
+		return
+	}
+	log.Printf("[remote]%v: %v on %v -> %v", r.name, e.Gesture, e.Button, action)
+	e.Action = action
+	for _, h := range handlers {
+		h(e)
+	}
+}
+
+// gestureDetector detects the gestures from the output of a button
+type gestureDetector struct {
+	mode      RXMode
+	inited    bool
+	level     bool
+	pressedAt time.Time
+	longFired bool
+	clicks    int
+	clickedAt time.Time
+}
+
+func (d *gestureDetector) update(level bool, now time.Time) []Gesture {
+	if !d.inited {
+		// the output may be high already in toggle and latched mode
+		d.inited = true
+		d.level = level
+		return nil
+	}
+
+	var gestures []Gesture
+	rising := level && !d.level
+	falling := !level && d.level
+	d.level = level
+
+	switch d.mode {
+	case RXMomentary:
+		if rising {
+			d.pressedAt = now
+			d.longFired = false
+		}
+		if level && !d.longFired && now.Sub(d.pressedAt) >= longPressTime {
+			d.longFired = true
+			d.clicks = 0
+			gestures = append(gestures, LongPress)
+		}
+		if falling && !d.longFired {
+			gestures = append(gestures, d.click(now)...)
+		}
+	case RXToggle:
+		if rising || falling {
+			gestures = append(gestures, d.click(now)...)
+		}
+	case RXLatched:
+		if rising {
+			gestures = append(gestures, Click)
+		}
+		return gestures
+	}
+
+	// it's a single click if no second click came in time
+	if d.clicks == 1 && now.Sub(d.clickedAt) >= doubleClickWindow {
+		d.clicks = 0
+		gestures = append(gestures, Click)
+	}
+	return gestures
+}
+
+func (d *gestureDetector) click(now time.Time) []Gesture {
+	d.clicks++
+	if d.clicks == 2 {
+		d.clicks = 0
+		return []Gesture{DoubleClick}
+	}
+	d.clickedAt = now
+	return nil
+}
@@ -0,0 +1,88 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeButtons [4]bool
+
+func (f *fakeButtons) Pressed(b RXButton) bool {
+	return f[b]
+}
+
+func TestRemoteMomentary(t *testing.T) {
+	rx := &fakeButtons{}
+	r := newRemote("door", rx, RXMomentary)
+	assert.NoError(t, r.LoadMappings([]*base.RemoteMapping{
+		{Remote: "door", Button: "A", Gesture: "click", Action: "light.toggle"},
+		{Remote: "door", Button: "A", Gesture: "doubleclick", Action: "light.blink"},
+		{Remote: "door", Button: "b", Gesture: "longpress", Action: "alarm.off"},
+		{Remote: "garage", Button: "A", Gesture: "click", Action: "door.open"},
+	}))
+
+	var actions []string
+	for _, a := range []string{"light.toggle", "light.blink", "alarm.off", "door.open"} {
+		r.Handle(a, func(e RemoteEvent) {
+			actions = append(actions, e.Action)
+		})
+	}
+
+	now := time.Now()
+	step := func(a, b bool, ms int) {
+		rx[RXButtonA], rx[RXButtonB] = a, b
+		now = now.Add(time.Duration(ms) * time.Millisecond)
+		r.update(now)
+	}
+
+	step(false, false, 0)
+	// click on A
+	step(true, false, 20)
+	step(false, false, 100)
+	step(false, false, 500)
+	assert.Equal(t, []string{"light.toggle"}, actions)
+
+	// double click on A
+	actions = nil
+	step(true, false, 20)
+	step(false, false, 100)
+	step(true, false, 150)
+	step(false, false, 100)
+	step(false, false, 500)
+	assert.Equal(t, []string{"light.blink"}, actions)
+
+	// long press on B, and nothing on releasing it
+	actions = nil
+	step(false, true, 20)
+	step(false, true, 500)
+	assert.Empty(t, actions)
+	step(false, true, 400)
+	assert.Equal(t, []string{"alarm.off"}, actions)
+	step(false, false, 100)
+	step(false, false, 500)
+	assert.Equal(t, []string{"alarm.off"}, actions)
+
+	assert.Error(t, r.LoadMappings([]*base.RemoteMapping{
+		{Remote: "door", Button: "E", Gesture: "click", Action: "x"},
+	}))
+}
+
+func TestRemoteToggleAndLatched(t *testing.T) {
+	d := &gestureDetector{mode: RXToggle}
+	now := time.Now()
+	assert.Empty(t, d.update(true, now))
+	// every flip is a press
+	assert.Empty(t, d.update(false, now.Add(100*time.Millisecond)))
+	assert.Equal(t, []Gesture{Click}, d.update(false, now.Add(600*time.Millisecond)))
+	assert.Empty(t, d.update(true, now.Add(1000*time.Millisecond)))
+	assert.Equal(t, []Gesture{DoubleClick}, d.update(false, now.Add(1200*time.Millisecond)))
+
+	d = &gestureDetector{mode: RXLatched}
+	assert.Empty(t, d.update(false, now))
+	assert.Equal(t, []Gesture{Click}, d.update(true, now.Add(100*time.Millisecond)))
+	assert.Empty(t, d.update(true, now.Add(2000*time.Millisecond)))
+	assert.Empty(t, d.update(false, now.Add(2100*time.Millisecond)))
+}
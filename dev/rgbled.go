@@ -0,0 +1,204 @@
+/*
+Package dev ...
+
+RGBLed is the driver of a rgb led, which has a red, a green and a blue led in one package.
+  - common cathode:	the 3 leds share the negative pin, a channel is on when its pin is high
+  - common anode:	the 3 leds share the positive pin, a channel is on when its pin is low
+
+Each channel is driven by a pwm output, like a channel of PCA9685,
+or a SoftPWM on any data pin since pi has only 2 hardware pwm channels.
+
+Connect to Pi:
+  - R:			any data pin, with a 220 ohm resistor
+  - G:			any data pin, with a 220 ohm resistor
+  - B:			any data pin, with a 220 ohm resistor
+  - common pin:	any gnd pin for common cathode, or any 3.3v pin for common anode
+*/
+package dev
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	// the default frequency of SoftPWM, it's fast enough for the eyes
+	softPWMFreq = 100 // Hz
+)
+
+// RGBLed ...
+type RGBLed struct {
+	r, g, b     PWMWriter
+	commonAnode bool
+
+	mu    sync.Mutex
+	color Color
+}
+
+// NewRGBLed creates a rgb led with the pwm outputs of the channels
+func NewRGBLed(r, g, b PWMWriter, commonAnode bool) *RGBLed {
+	return &RGBLed{
+		r:           r,
+		g:           g,
+		b:           b,
+		commonAnode: commonAnode,
+	}
+}
+
+// NewSoftRGBLed creates a rgb led on 3 data pins with SoftPWM
+func NewSoftRGBLed(r, g, b uint8, commonAnode bool) *RGBLed {
+	return NewRGBLed(
+		NewSoftPWM(r, softPWMFreq, commonAnode),
+		NewSoftPWM(g, softPWMFreq, commonAnode),
+		NewSoftPWM(b, softPWMFreq, commonAnode),
+		commonAnode,
+	)
+}
+
+// SetColor ...
+func (l *RGBLed) SetColor(c Color) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, ch := range []struct {
+		out PWMWriter
+		v   float64
+	}{{l.r, c.R}, {l.g, c.G}, {l.b, c.B}} {
+		duty := math.Pow(math.Max(0, math.Min(1, ch.v)), ledGamma)
+		if l.commonAnode {
+			duty = 1 - duty
+		}
+		if err := ch.out.SetDuty(duty); err != nil {
+			return err
+		}
+	}
+	l.color = c
+	return nil
+}
+
+// Color returns the current color
+func (l *RGBLed) Color() Color {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.color
+}
+
+// On ...
+func (l *RGBLed) On() error {
+	return l.SetColor(White)
+}
+
+// Off ...
+func (l *RGBLed) Off() error {
+	return l.SetColor(Black)
+}
+
+// FadeTo cross-fades to the color gradually in d
+func (l *RGBLed) FadeTo(c Color, d time.Duration) error {
+	from := l.Color()
+	n := int(d / ledFadeFrame)
+	for i := 1; i <= n; i++ {
+		if err := l.SetColor(from.mix(c, float64(i)/float64(n))); err != nil {
+			return err
+		}
+		time.Sleep(ledFadeFrame)
+	}
+	return l.SetColor(c)
+}
+
+// SoftPWM generates pwm on any data pin in software,
+// the timing is not accurate, it's good for leds but not for servos.
+type SoftPWM struct {
+	pin      rpio.Pin
+	period   time.Duration
+	idleHigh bool
+
+	mu     sync.Mutex
+	duty   float64
+	chDuty chan bool
+	chQuit chan bool
+}
+
+// NewSoftPWM creates a SoftPWM with the frequency in Hz,
+// the pin is kept high before the first duty and after closed if idleHigh, e.g. for a common anode led.
+func NewSoftPWM(pin uint8, freq float64, idleHigh bool) *SoftPWM {
+	s := &SoftPWM{
+		pin:      rpio.Pin(pin),
+		period:   time.Duration(float64(time.Second) / freq),
+		idleHigh: idleHigh,
+		chDuty:   make(chan bool, 1),
+		chQuit:   make(chan bool),
+	}
+	if idleHigh {
+		s.duty = 1
+	}
+	s.pin.Output()
+	s.idle()
+	go s.start()
+	return s
+}
+
+// SetDuty sets the duty cycle in [0, 1]
+func (s *SoftPWM) SetDuty(duty float64) error {
+	s.mu.Lock()
+	s.duty = math.Max(0, math.Min(1, duty))
+	s.mu.Unlock()
+	// wake up the loop if it's idle
+	select {
+	case s.chDuty <- true:
+	default:
+	}
+	return nil
+}
+
+// Close stops the pwm and turns the pin to the idle level
+func (s *SoftPWM) Close() {
+	close(s.chQuit)
+}
+
+// idle turns the pin to the level the led is off
+func (s *SoftPWM) idle() {
+	if s.idleHigh {
+		s.pin.High()
+		return
+	}
+	s.pin.Low()
+}
+
+func (s *SoftPWM) start() {
+	for {
+		s.mu.Lock()
+		duty := s.duty
+		s.mu.Unlock()
+
+		// keep the level without toggling if it's fully on or off
+		if duty <= 0 || duty >= 1 {
+			if duty <= 0 {
+				s.pin.Low()
+			} else {
+				s.pin.High()
+			}
+			select {
+			case <-s.chQuit:
+				s.idle()
+				return
+			case <-s.chDuty:
+				continue
+			}
+		}
+
+		on := time.Duration(duty * float64(s.period))
+		s.pin.High()
+		time.Sleep(on)
+		s.pin.Low()
+		select {
+		case <-s.chQuit:
+			s.idle()
+			return
+		case <-time.After(s.period - on):
+			// next period
+		}
+	}
+}
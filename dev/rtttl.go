@@ -0,0 +1,212 @@
+/*
+Package dev ...
+
+RTTTL(Ring Tone Text Transfer Language) is the format of the ringtones of the old Nokia phones.
+A ringtone has three sections separated by colons: the name, the defaults and the notes.
+e.g.
+	doorbell:d=4,o=5,b=100:e6,c6
+
+The defaults are:
+  - d:	the duration of a note, 4 means a quarter note, 4 in default
+  - o:	the octave of a note, 6 in default
+  - b:	the beats per minute, 63 in default
+
+A note is [duration]note[#][.][octave][.], e.g. 8c#6 is an eighth C#6,
+p is a pause, and a dot makes the note 1.5 times long.
+*/
+package dev
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The names of the alerts
+const (
+	AlertAlarm      = "alarm"
+	AlertDoorbell   = "doorbell"
+	AlertSuccess    = "success"
+	AlertFailure    = "failure"
+	AlertLowBattery = "lowbattery"
+)
+
+// Alerts are the named alert patterns in RTTTL
+var Alerts = map[string]string{
+	AlertAlarm:      "alarm:d=16,o=6,b=180:c,g5,c,g5,c,g5,c,g5,c,g5,c,g5",
+	AlertDoorbell:   "doorbell:d=4,o=5,b=100:e6,2c6",
+	AlertSuccess:    "success:d=16,o=6,b=200:c,e,g,8c7",
+	AlertFailure:    "failure:d=8,o=5,b=120:g,e,4c",
+	AlertLowBattery: "lowbattery:d=8,o=5,b=100:c6,p,a,p,f,p,4c",
+}
+
+// semitones of the notes from c
+var semitones = map[byte]int{
+	'c': 0,
+	'd': 2,
+	'e': 4,
+	'f': 5,
+	'g': 7,
+	'a': 9,
+	'b': 11,
+	'h': 11, // b in german notation
+}
+
+// Note is a note of a melody
+type Note struct {
+	// Freq is the frequency in Hz, 0 means a pause
+	Freq     float64
+	Duration time.Duration
+}
+
+// Melody ...
+type Melody struct {
+	Name  string
+	Notes []Note
+}
+
+// Duration returns the total duration of the melody
+func (m *Melody) Duration() time.Duration {
+	var d time.Duration
+	for _, n := range m.Notes {
+		d += n.Duration
+	}
+	return d
+}
+
+// ParseRTTTL parses a ringtone in RTTTL
+func ParseRTTTL(s string) (*Melody, error) {
+	sections := strings.Split(s, ":")
+	if len(sections) != 3 {
+		return nil, fmt.Errorf("invalid rtttl: want 3 sections, got %v", len(sections))
+	}
+
+	dur, oct, bpm := 4, 6, 63
+	for _, kv := range strings.Split(sections[1], ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid rtttl default: %v", kv)
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rtttl default: %v", kv)
+		}
+		switch strings.ToLower(strings.TrimSpace(parts[0])) {
+		case "d":
+			dur = v
+		case "o":
+			oct = v
+		case "b":
+			bpm = v
+		default:
+			return nil, fmt.Errorf("invalid rtttl default: %v", kv)
+		}
+	}
+	if !validDuration(dur) {
+		return nil, fmt.Errorf("invalid rtttl duration: %v", dur)
+	}
+	if bpm <= 0 {
+		return nil, fmt.Errorf("invalid rtttl bpm: %v", bpm)
+	}
+
+	// a whole note is 4 beats
+	whole := 4 * time.Minute / time.Duration(bpm)
+	m := &Melody{
+		Name: strings.TrimSpace(sections[0]),
+	}
+	for _, tok := range strings.Split(sections[2], ",") {
+		tok = strings.ToLower(strings.TrimSpace(tok))
+		if tok == "" {
+			continue
+		}
+		n, err := parseNote(tok, dur, oct, whole)
+		if err != nil {
+			return nil, err
+		}
+		m.Notes = append(m.Notes, n)
+	}
+	return m, nil
+}
+
+func parseNote(tok string, defDur, defOct int, whole time.Duration) (Note, error) {
+	i := 0
+	num := func() (int, bool) {
+		j := i
+		for i < len(tok) && tok[i] >= '0' && tok[i] <= '9' {
+			i++
+		}
+		if i == j {
+			return 0, false
+		}
+		v, _ := strconv.Atoi(tok[j:i])
+		return v, true
+	}
+
+	dur, ok := num()
+	if !ok {
+		dur = defDur
+	}
+	if !validDuration(dur) {
+		return Note{}, fmt.Errorf("invalid rtttl note: %v", tok)
+	}
+	if i >= len(tok) {
+		return Note{}, fmt.Errorf("invalid rtttl note: %v", tok)
+	}
+
+	pause := tok[i] == 'p'
+	semi, isNote := semitones[tok[i]]
+	if !pause && !isNote {
+		return Note{}, fmt.Errorf("invalid rtttl note: %v", tok)
+	}
+	i++
+	if i < len(tok) && tok[i] == '#' {
+		semi++
+		i++
+	}
+	dotted := false
+	if i < len(tok) && tok[i] == '.' {
+		dotted = true
+		i++
+	}
+	oct, ok := num()
+	if !ok {
+		oct = defOct
+	}
+	if i < len(tok) && tok[i] == '.' {
+		dotted = true
+		i++
+	}
+	if i != len(tok) || oct < 0 || oct > 8 {
+		return Note{}, fmt.Errorf("invalid rtttl note: %v", tok)
+	}
+
+	n := Note{
+		Duration: whole / time.Duration(dur),
+	}
+	if dotted {
+		n.Duration += n.Duration / 2
+	}
+	if !pause {
+		n.Freq = noteFreq(semi, oct)
+	}
+	return n, nil
+}
+
+// noteFreq returns the frequency of a note, A4 is 440Hz
+func noteFreq(semitone, octave int) float64 {
+	return 440 * math.Pow(2, float64(semitone-9)/12+float64(octave-4))
+}
+
+func validDuration(d int) bool {
+	switch d {
+	case 1, 2, 4, 8, 16, 32:
+		return true
+	}
+	return false
+}
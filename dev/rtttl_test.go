@@ -0,0 +1,73 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRTTTL(t *testing.T) {
+	m, err := ParseRTTTL("test:d=4,o=5,b=120:a,8c#6,p,4e.,16g5.,a4")
+	assert.NoError(t, err)
+	assert.Equal(t, "test", m.Name)
+	assert.Len(t, m.Notes, 6)
+
+	// a quarter note is a beat, 500ms at 120bpm
+	assert.InDelta(t, 880, m.Notes[0].Freq, 1e-6)
+	assert.Equal(t, 500*time.Millisecond, m.Notes[0].Duration)
+
+	assert.InDelta(t, 1108.73, m.Notes[1].Freq, 0.01)
+	assert.Equal(t, 250*time.Millisecond, m.Notes[1].Duration)
+
+	assert.Equal(t, float64(0), m.Notes[2].Freq)
+	assert.Equal(t, 500*time.Millisecond, m.Notes[2].Duration)
+
+	assert.InDelta(t, 659.26, m.Notes[3].Freq, 0.01)
+	assert.Equal(t, 750*time.Millisecond, m.Notes[3].Duration)
+
+	assert.InDelta(t, 783.99, m.Notes[4].Freq, 0.01)
+	assert.Equal(t, 187500*time.Microsecond, m.Notes[4].Duration)
+
+	assert.InDelta(t, 440, m.Notes[5].Freq, 1e-6)
+	assert.Equal(t, 2687500*time.Microsecond, m.Duration())
+}
+
+func TestParseRTTTLDefaults(t *testing.T) {
+	m, err := ParseRTTTL(":d=,o=,b=:c")
+	assert.Error(t, err)
+
+	m, err = ParseRTTTL("::c")
+	assert.NoError(t, err)
+	assert.Equal(t, "", m.Name)
+	assert.Len(t, m.Notes, 1)
+	// d=4, o=6, b=63
+	assert.InDelta(t, 1046.50, m.Notes[0].Freq, 0.01)
+	assert.Equal(t, time.Minute/63, m.Notes[0].Duration)
+}
+
+func TestParseRTTTLErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"a:b",
+		"x:d=3:c",
+		"x:b=0:c",
+		"x:q=1:c",
+		"x:d=4:x",
+		"x:d=4:4",
+		"x:d=4:c9",
+		"x:d=4:c#5x",
+	} {
+		_, err := ParseRTTTL(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	for name, s := range Alerts {
+		m, err := ParseRTTTL(s)
+		assert.NoError(t, err, name)
+		assert.Equal(t, name, m.Name)
+		assert.NotEmpty(t, m.Notes, name)
+	}
+}
@@ -14,9 +14,45 @@ Connect to Pi:
 package dev
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/stianeikeland/go-rpio"
 )
 
+// RXButton is a button of the remote
+type RXButton int
+
+// RX480E4 buttons
+const (
+	RXButtonA RXButton = iota
+	RXButtonB
+	RXButtonC
+	RXButtonD
+)
+
+func (b RXButton) String() string {
+	if b < RXButtonA || b > RXButtonD {
+		return fmt.Sprintf("unknown(%d)", int(b))
+	}
+	return string(rune('A' + int(b)))
+}
+
+// ParseRXButton parses "A", "B", "C" or "D"
+func ParseRXButton(s string) (RXButton, error) {
+	switch strings.ToUpper(s) {
+	case "A":
+		return RXButtonA, nil
+	case "B":
+		return RXButtonB, nil
+	case "C":
+		return RXButtonC, nil
+	case "D":
+		return RXButtonD, nil
+	}
+	return 0, fmt.Errorf("invalid button: %v", s)
+}
+
 // RX480E4 ...
 type RX480E4 struct {
 	d0 rpio.Pin
@@ -67,3 +103,25 @@ func (r *RX480E4) PressC() bool {
 func (r *RX480E4) PressD() bool {
 	return r.d0.EdgeDetected()
 }
+
+// Pressed returns the level of the output of a button,
+// what it means depends on the mode of the module:
+// high while the button is held in momentary mode,
+// flips on every press in toggle mode,
+// and high since the button was pressed until another one was pressed in latched mode.
+func (r *RX480E4) Pressed(b RXButton) bool {
+	var pin rpio.Pin
+	switch b {
+	case RXButtonA:
+		pin = r.d3
+	case RXButtonB:
+		pin = r.d2
+	case RXButtonC:
+		pin = r.d1
+	case RXButtonD:
+		pin = r.d0
+	default:
+		return false
+	}
+	return pin.Read() == rpio.High
+}
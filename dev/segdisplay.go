@@ -0,0 +1,334 @@
+/*
+Package dev ...
+
+TextDisplay is the common interface of the displays showing text:
+  - LedDisplay:	the 7-segment modules on 74HC595
+  - TM1637:		the 7-segment modules on TM1637
+  - HD44780:	the character lcd modules with a PCF8574 i2c backpack
+  - MAX7219Display:	the 8-digit 7-segment modules on MAX7219
+  - LedMatrix:		the 8x8 led matrix modules on MAX7219
+
+The 7-segment display modules share the same font below.
+
+A char is encoded in a byte, a bit is on for a lit segment:
+
+	bit:	7   6   5   4   3   2   1   0
+	seg:	dp  g   f   e   d   c   b   a
+
+	   --a--
+	  |     |
+	  f     b
+	  |     |
+	   --g--
+	  |     |
+	  e     c
+	  |     |
+	   --d--  .dp
+*/
+package dev
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+)
+
+const (
+	segBlank = 0x00
+	segDot   = 0x80
+)
+
+// TextDisplay is a display showing text, like LedDisplay, TM1637, HD44780 and LedMatrix
+type TextDisplay interface {
+	Open()
+	Display(text string)
+	Close()
+}
+
+// Blinker is a display which can blink, like LedDisplay, TM1637 and LedMatrix
+type Blinker interface {
+	// SetBlink makes the display blink in the interval, 0 means no blinking
+	SetBlink(interval time.Duration)
+}
+
+// frameDisplay keeps the text of a display, and draws it frame by frame in background for scrolling and blinking,
+// it's embedded by TM1637, MAX7219Display and LedMatrix.
+type frameDisplay struct {
+	tag      string
+	interval time.Duration
+	// render returns the frame of the cells at the elapsed time, and write sends the frame to the display,
+	// they're called with the lock held.
+	render func(cells []uint8, elapsed, scroll, blink time.Duration) []uint8
+	write  func(frame []uint8) error
+
+	mu     sync.Mutex
+	cells  []uint8
+	start  time.Time
+	scroll time.Duration
+	blink  time.Duration
+	last   []uint8 // the frame written, it's nil if nothing was written
+
+	chQuit chan bool
+	chDone chan bool
+	opened bool
+}
+
+// SetScroll sets the interval of scrolling a char, or a column of LedMatrix, for the long text
+func (f *frameDisplay) SetScroll(interval time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scroll = interval
+}
+
+// SetBlink makes the display blink in the interval, 0 means no blinking
+func (f *frameDisplay) SetBlink(interval time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.blink == interval {
+		return
+	}
+	f.blink = interval
+	f.start = time.Now()
+}
+
+// setCells sets the cells to display, the scrolling and blinking restart if they changed
+func (f *frameDisplay) setCells(cells []uint8) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if equalSegments(cells, f.cells) {
+		return
+	}
+	f.cells = cells
+	f.start = time.Now()
+}
+
+// open turns on the display by on, and starts drawing the frames, on is called with the lock held
+func (f *frameDisplay) open(on func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.opened {
+		return
+	}
+	on()
+	f.last = nil
+	f.chQuit = make(chan bool)
+	f.chDone = make(chan bool)
+	go f.draw()
+	f.opened = true
+}
+
+// close stops drawing the frames, and clears and turns off the display by off, off is called with the lock held
+func (f *frameDisplay) close(off func()) {
+	f.mu.Lock()
+	if !f.opened {
+		f.mu.Unlock()
+		return
+	}
+	f.opened = false
+	f.mu.Unlock()
+
+	close(f.chQuit)
+	<-f.chDone
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	off()
+}
+
+func (f *frameDisplay) draw() {
+	defer close(f.chDone)
+	for {
+		f.mu.Lock()
+		frame := f.render(f.cells, time.Since(f.start), f.scroll, f.blink)
+		if !equalSegments(frame, f.last) {
+			if err := f.write(frame); err != nil {
+				log.Printf("[%v]failed to display, error: %v", f.tag, err)
+				frame = nil
+			}
+			f.last = frame
+		}
+		f.mu.Unlock()
+
+		select {
+		case <-f.chQuit:
+			return
+		case <-time.After(f.interval):
+			// next frame
+		}
+	}
+}
+
+// NewTextDisplayFromConfig creates a display from the config
+func NewTextDisplayFromConfig(cfg *base.DisplayConfig) (TextDisplay, error) {
+	switch cfg.Type {
+	case "", "leddisplay":
+		return NewLedDisplay(cfg.DIO, cfg.RCLK, cfg.SCLK), nil
+	case "tm1637":
+		return NewTM1637(cfg.CLK, cfg.DIO), nil
+	case "lcd":
+		cols, rows := cfg.Cols, cfg.Rows
+		if cols == 0 {
+			cols = 16
+		}
+		if rows == 0 {
+			rows = 2
+		}
+		lcd, err := NewHD44780(cfg.Bus, cfg.Addr, cols, rows)
+		if err != nil {
+			// don't wrap a nil pointer in a non-nil TextDisplay
+			return nil, err
+		}
+		return lcd, nil
+	case "max7219":
+		d, err := NewMAX7219Display(cfg.Bus, moduleCount(cfg))
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	case "matrix":
+		d, err := NewLedMatrix(cfg.Bus, moduleCount(cfg))
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("invalid display type: %v", cfg.Type)
+}
+
+// moduleCount returns the cascaded modules in the config, 1 by default
+func moduleCount(cfg *base.DisplayConfig) int {
+	if cfg.Modules == 0 {
+		return 1
+	}
+	return cfg.Modules
+}
+
+var segmentFont = map[byte]uint8{
+	'0': 0x3F,
+	'1': 0x06,
+	'2': 0x5B,
+	'3': 0x4F,
+	'4': 0x66,
+	'5': 0x6D,
+	'6': 0x7D,
+	'7': 0x27,
+	'8': 0x7F,
+	'9': 0x6F,
+
+	'A': 0x77,
+	'B': 0x7F,
+	'C': 0x39,
+	'D': 0x3F,
+	'E': 0x79,
+	'F': 0x71,
+	'G': 0x3D,
+	'H': 0x76,
+	'I': 0x06,
+	'J': 0x0E,
+	'L': 0x38,
+	'O': 0x3F,
+	'P': 0x73,
+	'R': 0x77,
+	'S': 0x6D,
+	'U': 0x3E,
+	'Y': 0x6E,
+	'Z': 0x5B,
+
+	'a': 0x5F,
+	'b': 0x7C,
+	'c': 0x61,
+	'd': 0x5E,
+	'g': 0x6F,
+	'h': 0x74,
+	'i': 0x02,
+	'j': 0x42,
+	'l': 0x20,
+	'n': 0x54,
+	'o': 0x63,
+	'p': 0x73,
+	'q': 0x67,
+	'r': 0x50,
+	's': 0x6D,
+	't': 0x78,
+	'u': 0x62,
+	'y': 0x6E,
+
+	'.': 0x80,
+	'-': 0x40,
+	'_': 0x08,
+	'=': 0x41,
+
+	' ': 0x00,
+}
+
+// encodeSegments encodes the text to the segments of digits,
+// a dot is merged into the char before it if the char doesn't have a dot.
+func encodeSegments(text string) []uint8 {
+	var cells []uint8
+	dotted := true
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c == '.' && !dotted {
+			cells[len(cells)-1] |= segDot
+			dotted = true
+			continue
+		}
+		cells = append(cells, segmentChar(c))
+		dotted = c == '.'
+	}
+	return cells
+}
+
+// segmentChar returns the segments of a char,
+// the other case is used if it isn't in the font, or it's '-'.
+func segmentChar(c byte) uint8 {
+	if seg, ok := segmentFont[c]; ok {
+		return seg
+	}
+	for _, s := range []string{strings.ToUpper(string(c)), strings.ToLower(string(c))} {
+		if seg, ok := segmentFont[s[0]]; ok {
+			return seg
+		}
+	}
+	return segmentFont['-']
+}
+
+// segmentFrame returns the segments of n digits to display at the elapsed time since the text was set,
+// a short text is aligned to the right, and a long text scrolls in the interval of scroll.
+func segmentFrame(cells []uint8, n int, elapsed, scroll, blink time.Duration) []uint8 {
+	frame := make([]uint8, n)
+	if blink > 0 && (elapsed/blink)%2 == 1 {
+		return frame
+	}
+	if len(cells) <= n {
+		copy(frame[n-len(cells):], cells)
+		return frame
+	}
+
+	// scroll the text with n blanks at the end, so it goes out before coming again
+	offset := 0
+	if scroll > 0 {
+		offset = int(elapsed/scroll) % (len(cells) + n)
+	}
+	for i := range frame {
+		if j := offset + i; j < len(cells) {
+			frame[i] = cells[j]
+		}
+	}
+	return frame
+}
+
+func equalSegments(a, b []uint8) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
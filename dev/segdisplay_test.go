@@ -0,0 +1,58 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameDisplay(t *testing.T) {
+	var frames [][]uint8
+	f := &frameDisplay{
+		interval: time.Millisecond,
+		render: func(cells []uint8, elapsed, scroll, blink time.Duration) []uint8 {
+			return segmentFrame(cells, 4, elapsed, scroll, blink)
+		},
+		write: func(frame []uint8) error {
+			frames = append(frames, frame)
+			return nil
+		},
+		start: time.Now(),
+	}
+	// the frames are written with the lock held
+	last := func() ([]uint8, int) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if len(frames) == 0 {
+			return nil, 0
+		}
+		return frames[len(frames)-1], len(frames)
+	}
+
+	f.open(func() {})
+	f.open(func() { t.Error("it's opened twice") })
+	f.setCells(encodeSegments("12"))
+	shown := segmentFrame(encodeSegments("12"), 4, 0, 0, 0)
+	assert.Eventually(t, func() bool {
+		frame, _ := last()
+		return equalSegments(frame, shown)
+	}, time.Second, time.Millisecond)
+
+	// the same frame isn't written again
+	_, n := last()
+	time.Sleep(20 * time.Millisecond)
+	_, m := last()
+	assert.Equal(t, n, m)
+
+	f.SetBlink(5 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		frame, _ := last()
+		return equalSegments(frame, make([]uint8, 4))
+	}, time.Second, time.Millisecond)
+
+	closed := false
+	f.close(func() { closed = true })
+	f.close(func() { t.Error("it's closed twice") })
+	assert.True(t, closed)
+}
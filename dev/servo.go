@@ -0,0 +1,337 @@
+/*
+Package dev ...
+
+Servo is the driver of the hobby servos like SG90, MG90S and MG996R.
+The angle of a servo is controlled by the width of the pulse in every 20ms,
+the pulse width for each servo is a little different, so it can be calibrated.
+
+The servo is driven by the hardware pwm with a 200kHz clock and 4000 cycles,
+which gives 50Hz pwm and 5us per step, about 0.5 degree for a 180-degree servo.
+
+Connect to Pi:
+  - the red line:		any 5v pin, or an external 5v/6v power for the big servos like MG996R
+  - the brown line:		any gnd pin
+  - the yellow line:	any pwm pin(must be one of gpio 12, 13, 18, 19)
+*/
+package dev
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	servoPWMClock  = 200000
+	servoPWMCycle  = 4000
+	servoPulseStep = 1000000.0 / servoPWMClock // us
+	// a servo updates its position once per frame
+	servoFrame = 20 * time.Millisecond
+	// the time for the servo to reach the target after the last pulse
+	servoSettle = 50 * time.Millisecond
+	// go-rpio calculates the clock divisor from 19.2MHz,
+	// but the pwm clock of rpi4 is 54MHz.
+	rpi3PWMSource = 19200000
+	rpi4PWMSource = 54000000
+)
+
+// ServoSpec is the spec of a servo
+type ServoSpec struct {
+	// MinPulse and MaxPulse are the pulse widths in microsecond at the both ends
+	MinPulse float64
+	MaxPulse float64
+	// Range is the range of angle in degree
+	Range float64
+	// Speed is the max speed in degree/s
+	Speed float64
+}
+
+// The specs of servos, the pulse widths are typical values,
+// please calibrate your servo for the accurate angles.
+var (
+	SG90Spec = ServoSpec{
+		MinPulse: 500,
+		MaxPulse: 2400,
+		Range:    180,
+		Speed:    600, // 0.1s/60 degree
+	}
+	MG90SSpec = ServoSpec{
+		MinPulse: 500,
+		MaxPulse: 2400,
+		Range:    180,
+		Speed:    600, // 0.1s/60 degree
+	}
+	MG996RSpec = ServoSpec{
+		MinPulse: 500,
+		MaxPulse: 2500,
+		Range:    180,
+		Speed:    352, // 0.17s/60 degree
+	}
+)
+
+// Easing maps the progress of time in [0, 1] to the progress of the move in [0, 1]
+type Easing func(t float64) float64
+
+// Easings
+var (
+	Linear Easing = func(t float64) float64 {
+		return t
+	}
+	EaseIn Easing = func(t float64) float64 {
+		return t * t * t
+	}
+	EaseOut Easing = func(t float64) float64 {
+		t = 1 - t
+		return 1 - t*t*t
+	}
+	EaseInOut Easing = func(t float64) float64 {
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		t = 2 - 2*t
+		return 1 - t*t*t/2
+	}
+)
+
+// servoOutput generates the pulses for a servo
+type servoOutput interface {
+	// SetPulse sets the pulse width in microsecond
+	SetPulse(us float64)
+	// Off stops the pulses, the servo will be free
+	Off()
+}
+
+// Servo ...
+type Servo struct {
+	out  servoOutput
+	spec ServoSpec
+
+	mu       sync.Mutex
+	minPulse float64
+	maxPulse float64
+	angle    float64
+	hold     bool
+	motion   *ServoMotion
+}
+
+// NewServo creates a servo on a pwm pin
+func NewServo(pin uint8, spec ServoSpec) *Servo {
+	return newServo(newPWMServoOutput(pin), spec)
+}
+
+func newServo(out servoOutput, spec ServoSpec) *Servo {
+	return &Servo{
+		out:      out,
+		spec:     spec,
+		minPulse: spec.MinPulse,
+		maxPulse: spec.MaxPulse,
+	}
+}
+
+// Calibrate sets the pulse widths in microsecond at the both ends of the range
+func (s *Servo) Calibrate(minPulse, maxPulse float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.minPulse = minPulse
+	s.maxPulse = maxPulse
+}
+
+// SetHold keeps the pulses after moving if hold is true,
+// or stops the pulses to avoid jittering, this is the default.
+func (s *Servo) SetHold(hold bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hold = hold
+}
+
+// SetPulse sets the pulse width in microsecond directly
+func (s *Servo) SetPulse(us float64) {
+	s.Stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	us = math.Max(s.minPulse, math.Min(s.maxPulse, us))
+	s.angle = s.toAngle(us)
+	s.out.SetPulse(us)
+}
+
+// Angle returns the current angle
+func (s *Servo) Angle() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.angle
+}
+
+// Roll rolls the servo to the angle at full speed.
+// angle: [-90, 90]
+// angle < 0: left
+// angel = 0: ahead
+// angle > 0: right
+// e.g.
+//
+//	-30  0   30
+//	  \  |  /
+//	   \ | /
+//	    \|/
+//	     *
+//	    eye
+func (s *Servo) Roll(angle int) {
+	s.Move(float64(angle), 0, Linear)
+}
+
+// Move moves the servo to the angle, and returns after it arrived.
+// speed is in degree/s, the max speed of the servo is used if speed <= 0.
+func (s *Servo) Move(angle, speed float64, easing Easing) {
+	s.MoveTo(angle, speed, easing).Wait()
+}
+
+// MoveTo moves the servo to the angle in background,
+// the current motion will be cancelled.
+func (s *Servo) MoveTo(angle, speed float64, easing Easing) *ServoMotion {
+	m := &ServoMotion{
+		chQuit: make(chan bool),
+		done:   make(chan bool),
+	}
+	s.mu.Lock()
+	old := s.motion
+	s.motion = m
+	s.mu.Unlock()
+	if old != nil {
+		old.Cancel()
+	}
+
+	go func() {
+		defer close(m.done)
+		s.move(angle, speed, easing, m.chQuit)
+	}()
+	return m
+}
+
+// Stop stops the current motion, the servo stays where it is
+func (s *Servo) Stop() {
+	s.mu.Lock()
+	m := s.motion
+	s.motion = nil
+	s.mu.Unlock()
+	if m != nil {
+		m.Cancel()
+	}
+}
+
+// Off stops the pulses, the servo will be free
+func (s *Servo) Off() {
+	s.Stop()
+	s.out.Off()
+}
+
+func (s *Servo) move(angle, speed float64, easing Easing, chQuit chan bool) {
+	half := s.spec.Range / 2
+	angle = math.Max(-half, math.Min(half, angle))
+	if speed <= 0 || speed > s.spec.Speed {
+		speed = s.spec.Speed
+	}
+	if easing == nil {
+		easing = Linear
+	}
+
+	from := s.Angle()
+	duration := time.Duration(math.Abs(angle-from) / speed * float64(time.Second))
+	start := time.Now()
+	for {
+		t := 1.0
+		if duration > 0 {
+			t = math.Min(1, float64(time.Since(start))/float64(duration))
+		}
+		s.mu.Lock()
+		s.angle = from + (angle-from)*easing(t)
+		s.out.SetPulse(s.toPulse(s.angle))
+		s.mu.Unlock()
+		if t >= 1 {
+			break
+		}
+		select {
+		case <-chQuit:
+			return
+		case <-time.After(servoFrame):
+			// next step
+		}
+	}
+
+	select {
+	case <-chQuit:
+		return
+	case <-time.After(servoSettle):
+		// arrived
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.hold {
+		s.out.Off()
+	}
+}
+
+// toPulse converts an angle to the pulse width,
+// a positive angle turns right, which is a shorter pulse.
+func (s *Servo) toPulse(angle float64) float64 {
+	half := s.spec.Range / 2
+	return s.minPulse + (half-angle)/s.spec.Range*(s.maxPulse-s.minPulse)
+}
+
+func (s *Servo) toAngle(us float64) float64 {
+	half := s.spec.Range / 2
+	return half - (us-s.minPulse)/(s.maxPulse-s.minPulse)*s.spec.Range
+}
+
+// ServoMotion is a motion of servo in background
+type ServoMotion struct {
+	chQuit chan bool
+	done   chan bool
+	once   sync.Once
+}
+
+// Cancel cancels the motion and waits for it quit
+func (m *ServoMotion) Cancel() {
+	m.once.Do(func() {
+		close(m.chQuit)
+	})
+	<-m.done
+}
+
+// Wait waits for the motion done
+func (m *ServoMotion) Wait() {
+	<-m.done
+}
+
+// Done returns a channel which will be closed when the motion is done
+func (m *ServoMotion) Done() <-chan bool {
+	return m.done
+}
+
+// pwmServoOutput generates the pulses with the hardware pwm of pi
+type pwmServoOutput struct {
+	pin rpio.Pin
+}
+
+func newPWMServoOutput(pin uint8) *pwmServoOutput {
+	o := &pwmServoOutput{
+		pin: rpio.Pin(pin),
+	}
+	clock := servoPWMClock
+	if base.GetRpiModel() == base.Rpi4 {
+		clock = servoPWMClock * rpi3PWMSource / rpi4PWMSource
+	}
+	o.pin.Pwm()
+	o.pin.Freq(clock)
+	o.pin.DutyCycle(0, servoPWMCycle)
+	return o
+}
+
+func (o *pwmServoOutput) SetPulse(us float64) {
+	o.pin.DutyCycle(uint32(math.Round(us/servoPulseStep)), servoPWMCycle)
+}
+
+func (o *pwmServoOutput) Off() {
+	o.pin.DutyCycle(0, servoPWMCycle)
+}
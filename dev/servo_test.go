@@ -0,0 +1,106 @@
+package dev
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeServoOutput struct {
+	mu     sync.Mutex
+	pulses []float64
+	off    bool
+}
+
+func (f *fakeServoOutput) SetPulse(us float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pulses = append(f.pulses, us)
+	f.off = false
+}
+
+func (f *fakeServoOutput) Off() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.off = true
+}
+
+func (f *fakeServoOutput) last() (float64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pulses[len(f.pulses)-1], f.off
+}
+
+func TestServoPulse(t *testing.T) {
+	s := newServo(&fakeServoOutput{}, SG90Spec)
+	assert.InDelta(t, 1450, s.toPulse(0), 1e-6)
+	assert.InDelta(t, 500, s.toPulse(90), 1e-6)
+	assert.InDelta(t, 2400, s.toPulse(-90), 1e-6)
+
+	s.Calibrate(600, 2300)
+	assert.InDelta(t, 1450, s.toPulse(0), 1e-6)
+	assert.InDelta(t, 600, s.toPulse(90), 1e-6)
+	assert.InDelta(t, 45, s.toAngle(1025), 1e-6)
+
+	s.SetPulse(3000)
+	assert.InDelta(t, -90, s.Angle(), 1e-6)
+}
+
+func TestEasing(t *testing.T) {
+	for _, e := range []Easing{Linear, EaseIn, EaseOut, EaseInOut} {
+		assert.InDelta(t, 0, e(0), 1e-9)
+		assert.InDelta(t, 1, e(1), 1e-9)
+	}
+	assert.InDelta(t, 0.5, EaseInOut(0.5), 1e-9)
+}
+
+func TestServoMove(t *testing.T) {
+	out := &fakeServoOutput{}
+	s := newServo(out, SG90Spec)
+
+	s.Move(30, 600, EaseInOut)
+	p, off := out.last()
+	assert.InDelta(t, s.toPulse(30), p, 1e-6)
+	assert.True(t, off)
+	assert.InDelta(t, 30, s.Angle(), 1e-6)
+
+	// 90 degree/s takes 1 second from 30 to -60
+	m := s.MoveTo(-60, 90, Linear)
+	time.Sleep(200 * time.Millisecond)
+	m.Cancel()
+	a := s.Angle()
+	assert.True(t, a < 30 && a > -60)
+
+	select {
+	case <-m.Done():
+	default:
+		t.Error("the motion should be done after cancelled")
+	}
+}
+
+func TestServoMoveConcurrently(t *testing.T) {
+	s := newServo(&fakeServoOutput{}, SG90Spec)
+
+	var wg sync.WaitGroup
+	motions := make([]*ServoMotion, 8)
+	for i := range motions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			motions[i] = s.MoveTo(float64(10*i-40), 10, Linear)
+		}(i)
+	}
+	wg.Wait()
+
+	// all of motions should be cancelled but the last one
+	s.Stop()
+	for i, m := range motions {
+		select {
+		case <-m.Done():
+		case <-time.After(time.Second):
+			t.Errorf("motion %v is still running", i)
+		}
+	}
+}
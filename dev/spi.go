@@ -0,0 +1,52 @@
+/*
+Package dev ...
+
+SPI devices share the bus helpers in this file.
+The device file of a chip select on a spi bus is /dev/spidev<bus>.<cs>,
+e.g. /dev/spidev0.0 is CE0(pin 24) and /dev/spidev0.1 is CE1(pin 26) on the bus 0.
+
+Config Your Pi:
+1. $ sudo raspi-config
+2. 	-> [5 interface options] -> [p4 spi] ->[yes] -> [ok]
+3. $ sudo reboot now
+4. check: $ ls /dev/spidev*
+	the device files of the spi buses should be listed
+*/
+package dev
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/io/spi"
+)
+
+const (
+	defaultSPIDev = "/dev/spidev0.0"
+)
+
+// spiDevice is the subset of *spi.Device used by the spi drivers,
+// it makes the drivers possible to work on a fake bus in tests.
+type spiDevice interface {
+	Tx(w, r []byte) error
+	Close() error
+}
+
+// SPIDev returns the device file of the chip select on the spi bus, e.g. SPIDev(0, 1) is "/dev/spidev0.1"
+func SPIDev(bus, cs int) string {
+	return fmt.Sprintf("/dev/spidev%v.%v", bus, cs)
+}
+
+func openSPI(dev string, mode spi.Mode, speed int64) (spiDevice, error) {
+	if dev == "" {
+		dev = defaultSPIDev
+	}
+	d, err := spi.Open(&spi.Devfs{
+		Dev:      dev,
+		Mode:     mode,
+		MaxSpeed: speed,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,378 @@
+/*
+Package dev ...
+
+SSD1306 is the driver of the monochrome oled modules on SSD1306 or SH1106.
+SH1106 is almost the same as SSD1306, but it has 132 columns in its ram,
+so the 128-pixel panel starts from the column 2.
+
+The pixels are kept in a framebuffer, and only the changed columns of the changed pages
+(8 rows in a page) are sent, so a clock ticking on a 128x64 oled only sends a few bytes
+instead of the whole 1KB frame, it's important on the slow i2c of Pi Zero.
+
+Spec:
+  - resolution:	128x64 or 128x32
+  - bus:		i2c(address 0x3c or 0x3d), or 4-wire spi
+
+Connect to Pi with i2c:
+  - VCC:	any 3.3v pin
+  - GND:	any gnd pin
+  - SDA:	pin 3 (SDA)
+  - SCL:	pin 5 (SCL)
+
+Connect to Pi with spi:
+  - VCC:	any 3.3v pin
+  - GND:	any gnd pin
+  - D0:		pin 23 (SCLK)
+  - D1:		pin 19 (MOSI)
+  - CS:		pin 24 (CE0)
+  - DC:		any data pin
+  - RES:	any data pin, or 3.3v
+*/
+package dev
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/stianeikeland/go-rpio"
+	"golang.org/x/exp/io/spi"
+)
+
+const (
+	// ControllerSSD1306 ...
+	ControllerSSD1306 = "ssd1306"
+	// ControllerSH1106 ...
+	ControllerSH1106 = "sh1106"
+
+	defaultOLEDAddr     = 0x3c
+	defaultOLEDWidth    = 128
+	defaultOLEDHeight   = 64
+	defaultOLEDContrast = 0x7F
+	oledSPISpeed        = 8000000
+
+	sh1106ColumnOffset = 2
+
+	oledCmdDisplayOff = 0xAE
+	oledCmdDisplayOn  = 0xAF
+	oledCmdContrast   = 0x81
+	oledCmdPage       = 0xB0
+	oledCmdColumnLow  = 0x00
+	oledCmdColumnHigh = 0x10
+)
+
+// oledBus sends the commands and the pixels to the controller
+type oledBus interface {
+	Command(cmds ...byte) error
+	Data(data []byte) error
+	Close() error
+}
+
+// SSD1306 ...
+type SSD1306 struct {
+	bus      oledBus
+	sh1106   bool
+	width    int // the width of the panel
+	height   int // the height of the panel
+	rotation int
+
+	mu   sync.Mutex
+	buf  []byte // the framebuffer, a byte is 8 vertical pixels in a page
+	sent []byte // what the controller has, nil means unknown
+}
+
+// NewSSD1306 creates the oled from the config, nil means an 128x64 SSD1306 on i2c at 0x3c
+func NewSSD1306(cfg *base.OLEDConfig) (*SSD1306, error) {
+	if cfg == nil {
+		cfg = &base.OLEDConfig{}
+	}
+	var (
+		bus oledBus
+		err error
+	)
+	switch cfg.Bus {
+	case "", "i2c":
+		bus, err = newOLEDI2C(cfg.Dev, cfg.Addr)
+	case "spi":
+		bus, err = newOLEDSPI(cfg.Dev, cfg.DC, cfg.RST)
+	default:
+		err = fmt.Errorf("invalid bus: %v", cfg.Bus)
+	}
+	if err != nil {
+		return nil, err
+	}
+	o, err := newSSD1306(bus, cfg)
+	if err != nil {
+		bus.Close()
+		return nil, err
+	}
+	return o, nil
+}
+
+func newSSD1306(bus oledBus, cfg *base.OLEDConfig) (*SSD1306, error) {
+	o := &SSD1306{
+		bus:      bus,
+		width:    cfg.Width,
+		height:   cfg.Height,
+		rotation: cfg.Rotation,
+	}
+	if o.width == 0 {
+		o.width = defaultOLEDWidth
+	}
+	if o.height == 0 {
+		o.height = defaultOLEDHeight
+	}
+	if o.height%8 != 0 || o.height > 64 || o.width > 128 {
+		return nil, fmt.Errorf("invalid size: %vx%v", o.width, o.height)
+	}
+	switch cfg.Controller {
+	case "", ControllerSSD1306:
+	case ControllerSH1106:
+		o.sh1106 = true
+	default:
+		return nil, fmt.Errorf("invalid controller: %v", cfg.Controller)
+	}
+	switch o.rotation {
+	case 0, 90, 180, 270:
+	default:
+		return nil, fmt.Errorf("invalid rotation: %v", o.rotation)
+	}
+	contrast := cfg.Contrast
+	if contrast == 0 {
+		contrast = defaultOLEDContrast
+	}
+
+	o.buf = make([]byte, o.width*o.height/8)
+	if err := o.bus.Command(o.initCmds(contrast)...); err != nil {
+		return nil, err
+	}
+	if err := o.Clear(); err != nil {
+		return nil, err
+	}
+	if err := o.bus.Command(oledCmdDisplayOn); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *SSD1306) initCmds(contrast uint8) []byte {
+	// the panel is upside down if it's rotated by 180 degrees in hardware
+	segRemap, comScan := byte(0xA1), byte(0xC8)
+	if o.rotation == 180 || o.rotation == 270 {
+		segRemap, comScan = 0xA0, 0xC0
+	}
+	comPins := byte(0x12)
+	if o.height == 32 {
+		comPins = 0x02
+	}
+
+	cmds := []byte{
+		oledCmdDisplayOff,
+		0xD5, 0x80, // clock
+		0xA8, byte(o.height - 1), // multiplex
+		0xD3, 0x00, // display offset
+		0x40, // start line
+	}
+	if o.sh1106 {
+		cmds = append(cmds,
+			0xAD, 0x8B, // dc-dc on
+			0xD9, 0x22, // pre-charge
+		)
+	} else {
+		cmds = append(cmds,
+			0x8D, 0x14, // charge pump on
+			0x20, 0x02, // page addressing mode, the same as SH1106
+			0xD9, 0xF1, // pre-charge
+		)
+	}
+	return append(cmds,
+		segRemap,
+		comScan,
+		0xDA, comPins,
+		oledCmdContrast, contrast,
+		0xDB, 0x40, // vcomh
+		0xA4, // show the ram
+		0xA6, // not inverted
+	)
+}
+
+// Bounds returns the size of the screen after the rotation
+func (o *SSD1306) Bounds() image.Rectangle {
+	if o.rotation == 90 || o.rotation == 270 {
+		return image.Rect(0, 0, o.height, o.width)
+	}
+	return image.Rect(0, 0, o.width, o.height)
+}
+
+// DrawImage draws the image on the screen, the pixels brighter than 50% gray are lit,
+// and only the changes are sent.
+func (o *SSD1306) DrawImage(img image.Image) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := range o.buf {
+		o.buf[i] = 0
+	}
+	b := img.Bounds().Intersect(o.Bounds())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y >= 0x80 {
+				o.setPixel(x, y)
+			}
+		}
+	}
+	return o.flush()
+}
+
+// Clear turns off all the pixels
+func (o *SSD1306) Clear() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := range o.buf {
+		o.buf[i] = 0
+	}
+	return o.flush()
+}
+
+// SetContrast sets the contrast in 1-255
+func (o *SSD1306) SetContrast(contrast uint8) error {
+	return o.bus.Command(oledCmdContrast, contrast)
+}
+
+// On turns on the screen
+func (o *SSD1306) On() error {
+	return o.bus.Command(oledCmdDisplayOn)
+}
+
+// Off turns off the screen, the pixels are kept
+func (o *SSD1306) Off() error {
+	return o.bus.Command(oledCmdDisplayOff)
+}
+
+// Close clears and turns off the screen
+func (o *SSD1306) Close() error {
+	o.Clear()
+	o.Off()
+	return o.bus.Close()
+}
+
+// setPixel lits the pixel at the point after the rotation
+func (o *SSD1306) setPixel(x, y int) {
+	if o.rotation == 90 || o.rotation == 270 {
+		x, y = o.width-1-y, x
+	}
+	o.buf[(y/8)*o.width+x] |= 1 << uint(y%8)
+}
+
+// flush sends the changed columns of every page
+func (o *SSD1306) flush() error {
+	for page := 0; page < o.height/8; page++ {
+		row := o.buf[page*o.width : (page+1)*o.width]
+		start, end := 0, o.width
+		if o.sent != nil {
+			sent := o.sent[page*o.width : (page+1)*o.width]
+			for start < end && row[start] == sent[start] {
+				start++
+			}
+			for end > start && row[end-1] == sent[end-1] {
+				end--
+			}
+			if start == end {
+				continue
+			}
+		}
+
+		col := start
+		if o.sh1106 {
+			col += sh1106ColumnOffset
+		}
+		if err := o.bus.Command(
+			oledCmdPage|byte(page),
+			oledCmdColumnLow|byte(col&0x0F),
+			oledCmdColumnHigh|byte(col>>4),
+		); err != nil {
+			o.sent = nil
+			return err
+		}
+		if err := o.bus.Data(row[start:end]); err != nil {
+			o.sent = nil
+			return err
+		}
+	}
+	if o.sent == nil {
+		o.sent = make([]byte, len(o.buf))
+	}
+	copy(o.sent, o.buf)
+	return nil
+}
+
+// oledI2C sends the commands and the data with a control byte on i2c
+type oledI2C struct {
+	dev i2cDevice
+}
+
+func newOLEDI2C(bus string, addr uint8) (*oledI2C, error) {
+	if addr == 0 {
+		addr = defaultOLEDAddr
+	}
+	dev, err := openI2C(bus, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &oledI2C{dev: dev}, nil
+}
+
+func (b *oledI2C) Command(cmds ...byte) error {
+	return b.dev.Write(append([]byte{0x00}, cmds...))
+}
+
+func (b *oledI2C) Data(data []byte) error {
+	return b.dev.Write(append([]byte{0x40}, data...))
+}
+
+func (b *oledI2C) Close() error {
+	return b.dev.Close()
+}
+
+// oledSPI sends the commands with DC low, and the data with DC high on spi
+type oledSPI struct {
+	dev spiDevice
+	dc  rpio.Pin
+}
+
+func newOLEDSPI(dev string, dc, rst uint8) (*oledSPI, error) {
+	d, err := openSPI(dev, spi.Mode0, oledSPISpeed)
+	if err != nil {
+		return nil, err
+	}
+	b := &oledSPI{
+		dev: d,
+		dc:  rpio.Pin(dc),
+	}
+	b.dc.Output()
+	if rst > 0 {
+		p := rpio.Pin(rst)
+		p.Output()
+		p.Low()
+		time.Sleep(10 * time.Millisecond)
+		p.High()
+		time.Sleep(10 * time.Millisecond)
+	}
+	return b, nil
+}
+
+func (b *oledSPI) Command(cmds ...byte) error {
+	b.dc.Low()
+	return b.dev.Tx(cmds, nil)
+}
+
+func (b *oledSPI) Data(data []byte) error {
+	b.dc.High()
+	return b.dev.Tx(data, nil)
+}
+
+func (b *oledSPI) Close() error {
+	return b.dev.Close()
+}
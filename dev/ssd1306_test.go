@@ -0,0 +1,153 @@
+package dev
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/stretchr/testify/assert"
+)
+
+// oledWrite is a page write: the page, the start column in ram, and the data
+type oledWrite struct {
+	page int
+	col  int
+	data []byte
+}
+
+type fakeOLEDBus struct {
+	cmds   [][]byte
+	writes []oledWrite
+}
+
+func (b *fakeOLEDBus) Command(cmds ...byte) error {
+	b.cmds = append(b.cmds, append([]byte(nil), cmds...))
+	return nil
+}
+
+func (b *fakeOLEDBus) Data(data []byte) error {
+	// the last command is the page address
+	c := b.cmds[len(b.cmds)-1]
+	b.writes = append(b.writes, oledWrite{
+		page: int(c[0] & 0x0F),
+		col:  int(c[1]&0x0F) | int(c[2]&0x0F)<<4,
+		data: append([]byte(nil), data...),
+	})
+	return nil
+}
+
+func (b *fakeOLEDBus) Close() error {
+	return nil
+}
+
+func (b *fakeOLEDBus) reset() {
+	b.cmds = nil
+	b.writes = nil
+}
+
+func TestSSD1306Config(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		cfg    *base.OLEDConfig
+		bounds image.Rectangle
+		err    bool
+	}{
+		{"default", &base.OLEDConfig{}, image.Rect(0, 0, 128, 64), false},
+		{"128x32", &base.OLEDConfig{Height: 32}, image.Rect(0, 0, 128, 32), false},
+		{"sh1106", &base.OLEDConfig{Controller: "sh1106"}, image.Rect(0, 0, 128, 64), false},
+		{"rotation 90", &base.OLEDConfig{Rotation: 90}, image.Rect(0, 0, 64, 128), false},
+		{"rotation 180", &base.OLEDConfig{Rotation: 180}, image.Rect(0, 0, 128, 64), false},
+		{"invalid rotation", &base.OLEDConfig{Rotation: 45}, image.Rectangle{}, true},
+		{"invalid controller", &base.OLEDConfig{Controller: "st7735"}, image.Rectangle{}, true},
+		{"invalid height", &base.OLEDConfig{Height: 60}, image.Rectangle{}, true},
+	}
+	for _, test := range testCases {
+		o, err := newSSD1306(&fakeOLEDBus{}, test.cfg)
+		if test.err {
+			assert.Error(t, err, test.desc)
+			continue
+		}
+		assert.NoError(t, err, test.desc)
+		assert.Equal(t, test.bounds, o.Bounds(), test.desc)
+	}
+}
+
+func TestSSD1306Init(t *testing.T) {
+	bus := &fakeOLEDBus{}
+	_, err := newSSD1306(bus, &base.OLEDConfig{Contrast: 0x30})
+	assert.NoError(t, err)
+	assert.Equal(t, byte(oledCmdDisplayOff), bus.cmds[0][0])
+	assert.Contains(t, string(bus.cmds[0]), string([]byte{oledCmdContrast, 0x30}))
+	assert.Contains(t, string(bus.cmds[0]), string([]byte{0x8D, 0x14}))
+	assert.Equal(t, []byte{oledCmdDisplayOn}, bus.cmds[len(bus.cmds)-1])
+
+	// all the pages are cleared at first
+	assert.Equal(t, 8, len(bus.writes))
+	for i, w := range bus.writes {
+		assert.Equal(t, i, w.page)
+		assert.Equal(t, 0, w.col)
+		assert.Equal(t, make([]byte, 128), w.data)
+	}
+
+	// sh1106 starts from the column 2
+	bus = &fakeOLEDBus{}
+	_, err = newSSD1306(bus, &base.OLEDConfig{Controller: "sh1106"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(bus.cmds[0]), string([]byte{0xAD, 0x8B}))
+	assert.Equal(t, 2, bus.writes[0].col)
+}
+
+func TestSSD1306Diff(t *testing.T) {
+	bus := &fakeOLEDBus{}
+	o, err := newSSD1306(bus, &base.OLEDConfig{})
+	assert.NoError(t, err)
+
+	img := image.NewGray(image.Rect(0, 0, 128, 64))
+	img.SetGray(10, 9, color.Gray{Y: 0xFF})
+	img.SetGray(12, 15, color.Gray{Y: 0xFF})
+	// dark pixels are off
+	img.SetGray(50, 50, color.Gray{Y: 0x40})
+
+	bus.reset()
+	assert.NoError(t, o.DrawImage(img))
+	assert.Equal(t, []oledWrite{{page: 1, col: 10, data: []byte{0x02, 0x00, 0x80}}}, bus.writes)
+
+	// nothing changed
+	bus.reset()
+	assert.NoError(t, o.DrawImage(img))
+	assert.Empty(t, bus.writes)
+
+	// only the changed page is sent
+	img.SetGray(10, 9, color.Gray{Y: 0x00})
+	img.SetGray(127, 63, color.Gray{Y: 0xFF})
+	bus.reset()
+	assert.NoError(t, o.DrawImage(img))
+	assert.Equal(t, []oledWrite{
+		{page: 1, col: 10, data: []byte{0x00}},
+		{page: 7, col: 127, data: []byte{0x80}},
+	}, bus.writes)
+
+	bus.reset()
+	assert.NoError(t, o.Clear())
+	assert.Equal(t, 2, len(bus.writes))
+}
+
+func TestSSD1306Rotation(t *testing.T) {
+	bus := &fakeOLEDBus{}
+	o, err := newSSD1306(bus, &base.OLEDConfig{Rotation: 90})
+	assert.NoError(t, err)
+
+	// the top left of the rotated screen is the top right of the panel
+	img := image.NewGray(o.Bounds())
+	img.SetGray(0, 0, color.Gray{Y: 0xFF})
+	bus.reset()
+	assert.NoError(t, o.DrawImage(img))
+	assert.Equal(t, []oledWrite{{page: 0, col: 127, data: []byte{0x01}}}, bus.writes)
+
+	// the rotation of 180 is done by the controller
+	bus = &fakeOLEDBus{}
+	_, err = newSSD1306(bus, &base.OLEDConfig{Rotation: 180})
+	assert.NoError(t, err)
+	assert.Contains(t, string(bus.cmds[0]), string([]byte{0xA0, 0xC0}))
+}
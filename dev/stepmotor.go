@@ -1,6 +1,9 @@
 /*
 Package dev ...
 
+StepMotor is a 28BYJ-48 stepper with ULN2003 in wave mode,
+it's kept for compatibility, please use Stepper for the new code.
+
 Connect to Pi:
  - vcc: any 5v pin
  - gnd: any gnd pin
@@ -14,52 +17,25 @@ package dev
 
 import (
 	"log"
-	"time"
-
-	"github.com/stianeikeland/go-rpio"
 )
 
 const (
-	angleEachStep = 0.087
-)
-
-var (
-	clockwise = [4][4]uint8{
-		{1, 0, 0, 0},
-		{0, 1, 0, 0},
-		{0, 0, 1, 0},
-		{0, 0, 0, 1},
-	}
-
-	anticlockwise = [4][4]uint8{
-		{0, 0, 0, 1},
-		{0, 0, 1, 0},
-		{0, 1, 0, 0},
-		{1, 0, 0, 0},
-	}
+	// 28BYJ-48 in wave mode
+	stepsPerRev28BYJ48 = 2048
 )
 
 // StepMotor ...
 type StepMotor struct {
-	pins     [4]rpio.Pin
+	*Stepper
 	chAngles chan float32
 }
 
 // NewStepMotor ...
 func NewStepMotor(in1, in2, in3, in4 uint8) *StepMotor {
 	s := &StepMotor{
-		pins: [4]rpio.Pin{
-			rpio.Pin(in1),
-			rpio.Pin(in2),
-			rpio.Pin(in3),
-			rpio.Pin(in4),
-		},
+		Stepper:  NewStepper(NewULN2003(in1, in2, in3, in4, WaveDrive), stepsPerRev28BYJ48),
 		chAngles: make(chan float32, 8),
 	}
-	for i := 0; i < 4; i++ {
-		s.pins[i].Output()
-		s.pins[i].Low()
-	}
 	go s.start()
 	return s
 }
@@ -68,41 +44,16 @@ func NewStepMotor(in1, in2, in3, in4 uint8) *StepMotor {
 func (s *StepMotor) start() {
 	log.Printf("[stepmotor]start working")
 	for angle := range s.chAngles {
-		s.roll(angle)
-	}
-}
-
-func (s *StepMotor) roll(angle float32) {
-	var matrix [4][4]uint8
-	if angle > 0 {
-		matrix = clockwise
-	} else {
-		matrix = anticlockwise
-		angle = angle * (-1)
-	}
-	n := int(angle / angleEachStep / 8.0)
-	for i := 0; i < n; i++ {
-		for j := 0; j < 4; j++ {
-			for k := 0; k < 4; k++ {
-				if matrix[j][k] == 1 {
-					s.pins[k].High()
-				} else {
-					s.pins[k].Low()
-				}
-			}
-			time.Sleep(2 * time.Millisecond)
-		}
+		s.Rotate(float64(angle)).Wait()
 	}
 }
 
-// Roll ...
+// Roll queues a rotation by the angle, clockwise if angle > 0
 func (s *StepMotor) Roll(angle float32) {
 	s.chAngles <- angle
 }
 
 // Stop ...
 func (s *StepMotor) Stop() {
-	for i := 0; i < 4; i++ {
-		s.pins[i].Low()
-	}
+	s.Release()
 }
@@ -0,0 +1,356 @@
+/*
+Package dev ...
+
+Stepper controls the position of a stepper motor with a trapezoidal speed profile,
+it accelerates to the max speed, cruises, and decelerates to stop at the target.
+The position is counted in steps from the home, clockwise is positive.
+
+A stepper is driven by a StepDriver, there are two of them:
+  - ULN2003:	drives a unipolar stepper like 28BYJ-48 with 4 pins, in wave, full-step or half-step mode
+  - A4988:		drives a bipolar stepper like NEMA17 with STEP/DIR pins, also works for DRV8825
+
+28BYJ-48 has 2048 steps per revolution in wave and full-step mode, and 4096 in half-step mode.
+*/
+package dev
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	defaultStepperSpeed = 500 // steps/s
+	// the speed for homing, slow enough to stop at the limit switch
+	homingSpeed = 200 // steps/s
+)
+
+// StepMode is the step mode of ULN2003
+type StepMode int
+
+// Step modes
+const (
+	// WaveDrive energizes one coil at a time, it's the weakest and uses the least power
+	WaveDrive StepMode = iota
+	// FullStep energizes two coils at a time, it has the most torque
+	FullStep
+	// HalfStep alternates one and two coils, it doubles the steps per revolution
+	HalfStep
+)
+
+var stepSequences = map[StepMode][][4]bool{
+	WaveDrive: {
+		{true, false, false, false},
+		{false, true, false, false},
+		{false, false, true, false},
+		{false, false, false, true},
+	},
+	FullStep: {
+		{true, true, false, false},
+		{false, true, true, false},
+		{false, false, true, true},
+		{true, false, false, true},
+	},
+	HalfStep: {
+		{true, false, false, false},
+		{true, true, false, false},
+		{false, true, false, false},
+		{false, true, true, false},
+		{false, false, true, false},
+		{false, false, true, true},
+		{false, false, false, true},
+		{true, false, false, true},
+	},
+}
+
+// StepDriver makes a stepper motor move one step at a time
+type StepDriver interface {
+	// Step moves one step, clockwise if cw is true
+	Step(cw bool)
+	// Release de-energizes the coils, the motor can be turned by hand
+	Release()
+}
+
+// LimitSwitch is a switch at the end of the travel, like CollisionSwitch
+type LimitSwitch interface {
+	Collided() bool
+}
+
+// ULN2003 ...
+type ULN2003 struct {
+	pins  [4]rpio.Pin
+	seq   [][4]bool
+	index int
+}
+
+// NewULN2003 ...
+func NewULN2003(in1, in2, in3, in4 uint8, mode StepMode) *ULN2003 {
+	u := &ULN2003{
+		pins: [4]rpio.Pin{
+			rpio.Pin(in1),
+			rpio.Pin(in2),
+			rpio.Pin(in3),
+			rpio.Pin(in4),
+		},
+		seq: stepSequences[mode],
+	}
+	for _, p := range u.pins {
+		p.Output()
+		p.Low()
+	}
+	return u
+}
+
+// Step ...
+func (u *ULN2003) Step(cw bool) {
+	n := len(u.seq)
+	if cw {
+		u.index = (u.index + 1) % n
+	} else {
+		u.index = (u.index + n - 1) % n
+	}
+	for i, on := range u.seq[u.index] {
+		if on {
+			u.pins[i].High()
+		} else {
+			u.pins[i].Low()
+		}
+	}
+}
+
+// Release ...
+func (u *ULN2003) Release() {
+	for _, p := range u.pins {
+		p.Low()
+	}
+}
+
+// Stepper ...
+type Stepper struct {
+	drv         StepDriver
+	stepsPerRev int
+
+	mu       sync.Mutex
+	maxSpeed float64
+	accel    float64
+	position int
+	move     *StepperMove
+}
+
+// NewStepper creates a stepper with the steps per revolution
+func NewStepper(drv StepDriver, stepsPerRev int) *Stepper {
+	return &Stepper{
+		drv:         drv,
+		stepsPerRev: stepsPerRev,
+		maxSpeed:    defaultStepperSpeed,
+	}
+}
+
+// SetMaxSpeed sets the max speed in steps/s
+func (s *Stepper) SetMaxSpeed(speed float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxSpeed = speed
+}
+
+// SetAccel sets the acceleration in steps/s^2, accel <= 0 means running at the max speed all the way
+func (s *Stepper) SetAccel(accel float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accel = accel
+}
+
+// Position returns the position in steps
+func (s *Stepper) Position() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.position
+}
+
+// SetPosition sets current position without moving
+func (s *Stepper) SetPosition(pos int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.position = pos
+}
+
+// Angle returns the position in degree
+func (s *Stepper) Angle() float64 {
+	return float64(s.Position()) * 360 / float64(s.stepsPerRev)
+}
+
+// MoveTo moves to the absolute position in steps in background,
+// the current move will be cancelled.
+func (s *Stepper) MoveTo(pos int) *StepperMove {
+	return s.start(func() int {
+		return pos
+	}, nil)
+}
+
+// Move moves by the steps relative to current position, clockwise if steps > 0
+func (s *Stepper) Move(steps int) *StepperMove {
+	return s.start(func() int {
+		return s.Position() + steps
+	}, nil)
+}
+
+// Rotate rotates by the angle in degree, clockwise if angle > 0
+func (s *Stepper) Rotate(angle float64) *StepperMove {
+	return s.Move(int(math.Round(angle * float64(s.stepsPerRev) / 360)))
+}
+
+// RotateTo rotates to the absolute angle in degree
+func (s *Stepper) RotateTo(angle float64) *StepperMove {
+	return s.MoveTo(int(math.Round(angle * float64(s.stepsPerRev) / 360)))
+}
+
+// Home moves to the limit switch slowly in the direction, and makes the position there to be 0.
+// It fails if the switch wasn't hit in maxSteps.
+func (s *Stepper) Home(limit LimitSwitch, cw bool, maxSteps int) error {
+	target := func() int {
+		if cw {
+			return s.Position() + maxSteps
+		}
+		return s.Position() - maxSteps
+	}
+	m := s.start(target, limit)
+	m.Wait()
+	if !m.hit {
+		return errors.New("limit switch wasn't hit")
+	}
+	s.SetPosition(0)
+	return nil
+}
+
+// Stop stops the current move immediately
+func (s *Stepper) Stop() {
+	s.mu.Lock()
+	m := s.move
+	s.move = nil
+	s.mu.Unlock()
+	if m != nil {
+		m.Cancel()
+	}
+}
+
+// Release stops and de-energizes the coils
+func (s *Stepper) Release() {
+	s.Stop()
+	s.drv.Release()
+}
+
+func (s *Stepper) start(target func() int, limit LimitSwitch) *StepperMove {
+	m := &StepperMove{
+		chQuit: make(chan bool),
+		done:   make(chan bool),
+	}
+	s.mu.Lock()
+	old := s.move
+	s.move = m
+	speed := s.maxSpeed
+	if limit != nil {
+		speed = math.Min(speed, homingSpeed)
+	}
+	profile := newTrapezoid(speed, s.accel)
+	s.mu.Unlock()
+	if old != nil {
+		old.Cancel()
+	}
+
+	go func() {
+		defer close(m.done)
+		pos := target()
+		for {
+			cur := s.Position()
+			remaining := pos - cur
+			if remaining == 0 {
+				return
+			}
+			if limit != nil && limit.Collided() {
+				m.hit = true
+				return
+			}
+
+			cw := remaining > 0
+			s.drv.Step(cw)
+			s.mu.Lock()
+			if cw {
+				s.position++
+			} else {
+				s.position--
+			}
+			s.mu.Unlock()
+
+			n := remaining
+			if n < 0 {
+				n = -n
+			}
+			select {
+			case <-m.chQuit:
+				return
+			case <-time.After(profile.next(n - 1)):
+				// next step
+			}
+		}
+	}()
+	return m
+}
+
+// StepperMove is a move of stepper in background
+type StepperMove struct {
+	chQuit chan bool
+	done   chan bool
+	once   sync.Once
+	hit    bool
+}
+
+// Cancel cancels the move and waits for it quit
+func (m *StepperMove) Cancel() {
+	m.once.Do(func() {
+		close(m.chQuit)
+	})
+	<-m.done
+}
+
+// Wait waits for the move done
+func (m *StepperMove) Wait() {
+	<-m.done
+}
+
+// Done returns a channel which will be closed when the move is done
+func (m *StepperMove) Done() <-chan bool {
+	return m.done
+}
+
+// trapezoid generates the delays between steps for a trapezoidal speed profile
+type trapezoid struct {
+	maxSpeed float64
+	accel    float64
+	speed    float64
+}
+
+func newTrapezoid(maxSpeed, accel float64) *trapezoid {
+	return &trapezoid{
+		maxSpeed: maxSpeed,
+		accel:    accel,
+	}
+}
+
+// next returns the delay before the next step, remaining is the steps left after this one
+func (t *trapezoid) next(remaining int) time.Duration {
+	if t.accel <= 0 {
+		t.speed = t.maxSpeed
+	} else {
+		// v^2 = v0^2 + 2*a*s, s is one step
+		stopping := t.speed * t.speed / (2 * t.accel)
+		if float64(remaining) < stopping {
+			t.speed = math.Sqrt(math.Max(t.speed*t.speed-2*t.accel, 2*t.accel))
+		} else {
+			t.speed = math.Min(t.maxSpeed, math.Sqrt(t.speed*t.speed+2*t.accel))
+		}
+	}
+	return time.Duration(float64(time.Second) / t.speed)
+}
@@ -0,0 +1,106 @@
+package dev
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStepDriver struct {
+	mu    sync.Mutex
+	steps int
+}
+
+func (f *fakeStepDriver) Step(cw bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cw {
+		f.steps++
+	} else {
+		f.steps--
+	}
+}
+
+func (f *fakeStepDriver) Release() {}
+
+type fakeLimit struct {
+	drv *fakeStepDriver
+	at  int
+}
+
+func (f *fakeLimit) Collided() bool {
+	f.drv.mu.Lock()
+	defer f.drv.mu.Unlock()
+	return f.drv.steps <= f.at
+}
+
+func TestTrapezoid(t *testing.T) {
+	p := newTrapezoid(1000, 20000)
+	var delays []time.Duration
+	for remaining := 199; remaining >= 0; remaining-- {
+		delays = append(delays, p.next(remaining))
+	}
+	// accelerate
+	assert.True(t, delays[0] > delays[10])
+	// cruise at the max speed
+	assert.Equal(t, time.Millisecond, delays[100])
+	// decelerate
+	assert.True(t, delays[199] > delays[180])
+
+	p = newTrapezoid(500, 0)
+	assert.Equal(t, 2*time.Millisecond, p.next(10))
+}
+
+func TestStepper(t *testing.T) {
+	drv := &fakeStepDriver{}
+	s := NewStepper(drv, 2048)
+	s.SetMaxSpeed(100000)
+
+	s.MoveTo(100).Wait()
+	assert.Equal(t, 100, s.Position())
+	s.Rotate(-90).Wait()
+	assert.Equal(t, 100-512, s.Position())
+	assert.InDelta(t, -72.42, s.Angle(), 0.01)
+	assert.Equal(t, drv.steps, s.Position())
+
+	// the switch is at -500 steps
+	assert.NoError(t, s.Home(&fakeLimit{drv: drv, at: -500}, false, 1000))
+	assert.Equal(t, 0, s.Position())
+	assert.Error(t, s.Home(&fakeLimit{drv: drv, at: -10000}, false, 10))
+
+	s.SetMaxSpeed(100)
+	m := s.Move(1000)
+	time.Sleep(50 * time.Millisecond)
+	m.Cancel()
+	assert.True(t, s.Position() < 1000)
+}
+
+func TestStepperMoveConcurrently(t *testing.T) {
+	drv := &fakeStepDriver{}
+	s := NewStepper(drv, 2048)
+	s.SetMaxSpeed(100)
+
+	var wg sync.WaitGroup
+	moves := make([]*StepperMove, 8)
+	for i := range moves {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			moves[i] = s.MoveTo(1000 * (i - 4))
+		}(i)
+	}
+	wg.Wait()
+
+	// all of moves should be cancelled but the last one
+	s.Stop()
+	for i, m := range moves {
+		select {
+		case <-m.Done():
+		case <-time.After(time.Second):
+			t.Errorf("move %v is still running", i)
+		}
+	}
+	assert.Equal(t, drv.steps, s.Position())
+}
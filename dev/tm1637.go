@@ -0,0 +1,223 @@
+/*
+Package dev ...
+
+TM1637 is the driver of the 4-digit 7-segment display modules on TM1637 chip.
+It uses a two-wire protocol like i2c but without address, and the bits are sent from the lowest one.
+The chip keeps the digits, so it doesn't need refreshing like LedDisplay.
+The colon of a clock module is the dot of the second digit.
+
+Spec:
+  - power supply:	3.3V - 5V
+  - digits:			4
+  - brightness:		8 levels
+
+Connect to Pi:
+  - VCC:	any 3.3v or 5v pin
+  - GND:	any gnd pin
+  - CLK:	any data pin
+  - DIO:	any data pin
+*/
+package dev
+
+import (
+	"math"
+	"time"
+
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	tmCmdData    = 0x40 // write data with auto-increment address
+	tmCmdAddress = 0xC0 // the address of the first digit
+	tmCmdDisplay = 0x80
+	tmDisplayOn  = 0x08
+
+	tmDigits   = 4
+	tmColonPos = 1
+	tmLevels   = 8
+	tmBitDelay = 5 * time.Microsecond
+	// the interval of updating the digits for scrolling and blinking
+	tmFrame = 50 * time.Millisecond
+)
+
+// TM1637 ...
+type TM1637 struct {
+	frameDisplay
+	clk rpio.Pin
+	dio rpio.Pin
+
+	// they're guarded by the lock of frameDisplay
+	level int
+	colon bool
+}
+
+// NewTM1637 ...
+func NewTM1637(clk, dio uint8) *TM1637 {
+	t := &TM1637{
+		clk:   rpio.Pin(clk),
+		dio:   rpio.Pin(dio),
+		level: tmLevels - 1,
+	}
+	t.frameDisplay = frameDisplay{
+		tag:      "tm1637",
+		interval: tmFrame,
+		render:   t.render,
+		write: func(frame []uint8) error {
+			t.writeDigits(frame)
+			return nil
+		},
+		cells:  encodeSegments("----"),
+		start:  time.Now(),
+		scroll: defaultScrollInterval,
+	}
+	t.clk.Output()
+	t.clk.High()
+	t.dio.Output()
+	t.dio.High()
+	return t
+}
+
+// SetLevel sets the brightness level in [0, 7]
+func (t *TM1637) SetLevel(level int) {
+	if level < 0 {
+		level = 0
+	}
+	if level >= tmLevels {
+		level = tmLevels - 1
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.level = level
+	if t.opened {
+		t.writeCmd(tmDisplayCmd(true, t.level))
+	}
+}
+
+// SetBrightness sets the brightness in [0, 1], it's rounded to the 8 levels
+func (t *TM1637) SetBrightness(b float64) {
+	t.SetLevel(int(math.Round(math.Max(0, math.Min(1, b)) * (tmLevels - 1))))
+}
+
+// SetColon turns on or off the colon of a clock module
+func (t *TM1637) SetColon(on bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.colon = on
+}
+
+// Display displays the text, a short text is aligned to the right, and a long text scrolls
+func (t *TM1637) Display(text string) {
+	t.setCells(encodeSegments(text))
+}
+
+// SetSegments writes the raw segments from the digit at pos, the bits are dp-g-f-e-d-c-b-a,
+// they will be replaced by Display.
+func (t *TM1637) SetSegments(pos int, segs []uint8) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cells := segmentFrame(t.cells, tmDigits, 0, 0, 0)
+	for i, s := range segs {
+		if pos+i >= 0 && pos+i < tmDigits {
+			cells[pos+i] = s
+		}
+	}
+	t.cells = cells
+	t.start = time.Now()
+}
+
+// Open turns on the display
+func (t *TM1637) Open() {
+	t.open(func() {
+		t.writeCmd(tmDisplayCmd(true, t.level))
+	})
+}
+
+// Close clears and turns off the display
+func (t *TM1637) Close() {
+	t.close(func() {
+		t.writeDigits(make([]uint8, tmDigits))
+		t.writeCmd(tmDisplayCmd(false, 0))
+	})
+}
+
+// render returns the digits with the colon
+func (t *TM1637) render(cells []uint8, elapsed, scroll, blink time.Duration) []uint8 {
+	frame := segmentFrame(cells, tmDigits, elapsed, scroll, blink)
+	if t.colon {
+		frame[tmColonPos] |= segDot
+	}
+	return frame
+}
+
+// tmDisplayCmd returns the command of turning on/off the display with the brightness level
+func tmDisplayCmd(on bool, level int) byte {
+	if !on {
+		return tmCmdDisplay
+	}
+	return tmCmdDisplay | tmDisplayOn | byte(level)
+}
+
+func (t *TM1637) writeCmd(cmd byte) {
+	t.startBit()
+	t.writeByte(cmd)
+	t.stopBit()
+}
+
+func (t *TM1637) writeDigits(segs []uint8) {
+	t.writeCmd(tmCmdData)
+	t.startBit()
+	t.writeByte(tmCmdAddress)
+	for _, s := range segs {
+		t.writeByte(s)
+	}
+	t.stopBit()
+}
+
+// startBit: DIO goes low while CLK is high
+func (t *TM1637) startBit() {
+	t.dio.High()
+	t.clk.High()
+	time.Sleep(tmBitDelay)
+	t.dio.Low()
+	time.Sleep(tmBitDelay)
+}
+
+// stopBit: DIO goes high while CLK is high
+func (t *TM1637) stopBit() {
+	t.clk.Low()
+	time.Sleep(tmBitDelay)
+	t.dio.Low()
+	time.Sleep(tmBitDelay)
+	t.clk.High()
+	time.Sleep(tmBitDelay)
+	t.dio.High()
+	time.Sleep(tmBitDelay)
+}
+
+// writeByte sends a byte from the lowest bit, and returns whether the chip acked
+func (t *TM1637) writeByte(b byte) bool {
+	for i := uint(0); i < 8; i++ {
+		t.clk.Low()
+		if b&(1<<i) != 0 {
+			t.dio.High()
+		} else {
+			t.dio.Low()
+		}
+		time.Sleep(tmBitDelay)
+		t.clk.High()
+		time.Sleep(tmBitDelay)
+	}
+
+	// the chip pulls DIO low for ack at the 9th clock
+	t.clk.Low()
+	t.dio.Input()
+	t.dio.PullUp()
+	time.Sleep(tmBitDelay)
+	t.clk.High()
+	time.Sleep(tmBitDelay)
+	ack := t.dio.Read() == rpio.Low
+	t.clk.Low()
+	t.dio.Output()
+	t.dio.Low()
+	return ack
+}
@@ -0,0 +1,23 @@
+package dev
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTM1637DisplayCmd(t *testing.T) {
+	assert.Equal(t, byte(0x8F), tmDisplayCmd(true, 7))
+	assert.Equal(t, byte(0x88), tmDisplayCmd(true, 0))
+	assert.Equal(t, byte(0x80), tmDisplayCmd(false, 7))
+}
+
+func TestTM1637Segments(t *testing.T) {
+	tm := &TM1637{frameDisplay: frameDisplay{cells: encodeSegments("12")}}
+	tm.SetSegments(0, []uint8{0x01, 0x02})
+	assert.Equal(t, []uint8{0x01, 0x02, segmentFont['1'], segmentFont['2']}, tm.cells)
+
+	// out of range segments are ignored
+	tm.SetSegments(3, []uint8{0x08, 0x08})
+	assert.Equal(t, []uint8{0x01, 0x02, segmentFont['1'], 0x08}, tm.cells)
+}
@@ -0,0 +1,227 @@
+// +build linux
+
+package dev
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	v4l2BufTypeVideoCapture = 1
+	v4l2MemoryMmap          = 1
+	v4l2FieldAny            = 0
+	v4l2PixFmtMJPEG         = 'M' | 'J'<<8 | 'P'<<16 | 'G'<<24
+
+	v4l2Buffers = 2
+	// the frames dropped after starting, the auto exposure of a webcam needs a few frames
+	v4l2WarmupFrames = 5
+	v4l2Timeout      = 5 * time.Second
+)
+
+// the structs in linux/videodev2.h, the sizes and the paddings are the same as the ones in c
+
+type v4l2PixFormat struct {
+	Width        uint32
+	Height       uint32
+	PixelFormat  uint32
+	Field        uint32
+	BytesPerLine uint32
+	SizeImage    uint32
+	ColorSpace   uint32
+	Priv         uint32
+	Flags        uint32
+	Enc          uint32
+	Quantization uint32
+	XferFunc     uint32
+}
+
+type v4l2Format struct {
+	Type uint32
+	_    [unsafe.Sizeof(uintptr(0)) - 4]byte // the union is aligned to the pointers in it
+	Pix  v4l2PixFormat
+	_    [200 - unsafe.Sizeof(v4l2PixFormat{})]byte
+}
+
+type v4l2RequestBuffers struct {
+	Count        uint32
+	Type         uint32
+	Memory       uint32
+	Capabilities uint32
+	Flags        uint32
+}
+
+type v4l2Buffer struct {
+	Index     uint32
+	Type      uint32
+	BytesUsed uint32
+	Flags     uint32
+	Field     uint32
+	Timestamp syscall.Timeval
+	Timecode  [16]byte
+	Sequence  uint32
+	Memory    uint32
+	Offset    uintptr // the union of offset, userptr, planes and fd
+	Length    uint32
+	Reserved2 uint32
+	RequestFD uint32
+}
+
+// the ioctl requests, see _IOWR in linux/ioctl.h
+var (
+	vidiocSFmt      = iowr('V', 5, unsafe.Sizeof(v4l2Format{}))
+	vidiocReqBufs   = iowr('V', 8, unsafe.Sizeof(v4l2RequestBuffers{}))
+	vidiocQueryBuf  = iowr('V', 9, unsafe.Sizeof(v4l2Buffer{}))
+	vidiocQBuf      = iowr('V', 15, unsafe.Sizeof(v4l2Buffer{}))
+	vidiocDQBuf     = iowr('V', 17, unsafe.Sizeof(v4l2Buffer{}))
+	vidiocStreamOn  = iow('V', 18, unsafe.Sizeof(int32(0)))
+	vidiocStreamOff = iow('V', 19, unsafe.Sizeof(int32(0)))
+)
+
+func iow(t, nr, size uintptr) uintptr {
+	return 1<<30 | size<<16 | t<<8 | nr
+}
+
+func iowr(t, nr, size uintptr) uintptr {
+	return 3<<30 | size<<16 | t<<8 | nr
+}
+
+// V4L2Camera captures the photos in mjpeg from a v4l2 device, most usb webcams support it.
+// The device is opened for every photo, so it can be shared with the other apps.
+type V4L2Camera struct {
+	dev    string
+	width  int
+	height int
+}
+
+// NewV4L2Camera creates a camera on the device, and the size of the photos,
+// the empty device means /dev/video0, and 0 means 1280x720.
+// The driver may adjust the size to the nearest one it supports.
+func NewV4L2Camera(dev string, width, height int) *V4L2Camera {
+	if dev == "" {
+		dev = defaultV4L2Device
+	}
+	if width == 0 || height == 0 {
+		width, height = defaultPhotoWidth, defaultPhotoHeight
+	}
+	return &V4L2Camera{
+		dev:    dev,
+		width:  width,
+		height: height,
+	}
+}
+
+// TakePhoto ...
+func (c *V4L2Camera) TakePhoto() (*Photo, error) {
+	f, err := os.OpenFile(c.dev, os.O_RDWR|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fd := f.Fd()
+
+	format := v4l2Format{
+		Type: v4l2BufTypeVideoCapture,
+		Pix: v4l2PixFormat{
+			Width:       uint32(c.width),
+			Height:      uint32(c.height),
+			PixelFormat: v4l2PixFmtMJPEG,
+			Field:       v4l2FieldAny,
+		},
+	}
+	if err := ioctl(fd, vidiocSFmt, unsafe.Pointer(&format)); err != nil {
+		return nil, fmt.Errorf("failed to set format, error: %v", err)
+	}
+	if format.Pix.PixelFormat != v4l2PixFmtMJPEG {
+		return nil, fmt.Errorf("mjpeg isn't supported by %v", c.dev)
+	}
+
+	req := v4l2RequestBuffers{
+		Count:  v4l2Buffers,
+		Type:   v4l2BufTypeVideoCapture,
+		Memory: v4l2MemoryMmap,
+	}
+	if err := ioctl(fd, vidiocReqBufs, unsafe.Pointer(&req)); err != nil {
+		return nil, fmt.Errorf("failed to request buffers, error: %v", err)
+	}
+	defer func() {
+		// free the buffers
+		req.Count = 0
+		ioctl(fd, vidiocReqBufs, unsafe.Pointer(&req))
+	}()
+
+	bufs := make([][]byte, req.Count)
+	for i := range bufs {
+		buf := v4l2Buffer{
+			Index:  uint32(i),
+			Type:   v4l2BufTypeVideoCapture,
+			Memory: v4l2MemoryMmap,
+		}
+		if err := ioctl(fd, vidiocQueryBuf, unsafe.Pointer(&buf)); err != nil {
+			return nil, fmt.Errorf("failed to query buffer, error: %v", err)
+		}
+		mem, err := syscall.Mmap(int(fd), int64(buf.Offset), int(buf.Length), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mmap, error: %v", err)
+		}
+		defer syscall.Munmap(mem)
+		bufs[i] = mem
+		if err := ioctl(fd, vidiocQBuf, unsafe.Pointer(&buf)); err != nil {
+			return nil, fmt.Errorf("failed to queue buffer, error: %v", err)
+		}
+	}
+
+	typ := int32(v4l2BufTypeVideoCapture)
+	if err := ioctl(fd, vidiocStreamOn, unsafe.Pointer(&typ)); err != nil {
+		return nil, fmt.Errorf("failed to start streaming, error: %v", err)
+	}
+	defer ioctl(fd, vidiocStreamOff, unsafe.Pointer(&typ))
+
+	deadline := time.Now().Add(v4l2Timeout)
+	for frames := 0; time.Now().Before(deadline); {
+		buf := v4l2Buffer{
+			Type:   v4l2BufTypeVideoCapture,
+			Memory: v4l2MemoryMmap,
+		}
+		err := ioctl(fd, vidiocDQBuf, unsafe.Pointer(&buf))
+		if err == syscall.EAGAIN {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to dequeue buffer, error: %v", err)
+		}
+
+		frames++
+		if frames > v4l2WarmupFrames {
+			// some webcams pad the frames with zeros
+			data := bytes.TrimRight(bufs[buf.Index][:buf.BytesUsed], "\x00")
+			data = append([]byte{}, data...)
+			if p, err := newPhoto(data, time.Now(), c.dev); err == nil {
+				return p, nil
+			}
+			// a broken frame, try the next one
+		}
+		if err := ioctl(fd, vidiocQBuf, unsafe.Pointer(&buf)); err != nil {
+			return nil, fmt.Errorf("failed to queue buffer, error: %v", err)
+		}
+	}
+	return nil, fmt.Errorf("timeout capturing from %v", c.dev)
+}
+
+func ioctl(fd, req uintptr, arg unsafe.Pointer) error {
+	for {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+}
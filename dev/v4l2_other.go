@@ -0,0 +1,20 @@
+// +build !linux
+
+package dev
+
+import (
+	"errors"
+)
+
+// V4L2Camera isn't supported on the os except linux
+type V4L2Camera struct{}
+
+// NewV4L2Camera ...
+func NewV4L2Camera(dev string, width, height int) *V4L2Camera {
+	return &V4L2Camera{}
+}
+
+// TakePhoto ...
+func (c *V4L2Camera) TakePhoto() (*Photo, error) {
+	return nil, errors.New("v4l2 isn't supported")
+}
@@ -0,0 +1,24 @@
+// +build linux
+
+package dev
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestV4L2Structs(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		assert.Equal(t, uintptr(208), unsafe.Sizeof(v4l2Format{}))
+		assert.Equal(t, uintptr(88), unsafe.Sizeof(v4l2Buffer{}))
+		assert.Equal(t, uintptr(0xC0D05605), vidiocSFmt)
+		assert.Equal(t, uintptr(0xC0585611), vidiocDQBuf)
+	} else {
+		assert.Equal(t, uintptr(204), unsafe.Sizeof(v4l2Format{}))
+		assert.Equal(t, uintptr(68), unsafe.Sizeof(v4l2Buffer{}))
+	}
+	assert.Equal(t, uintptr(20), unsafe.Sizeof(v4l2RequestBuffers{}))
+	assert.Equal(t, uintptr(0x40045612), vidiocStreamOn)
+}
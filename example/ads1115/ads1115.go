@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/dev"
+)
+
+func main() {
+	ads, err := dev.NewADS1115(0x48)
+	if err != nil {
+		log.Printf("failed to new an ads1115, error: %v", err)
+		return
+	}
+	defer ads.Close()
+
+	if err := ads.SetGain(dev.ADSGain1); err != nil {
+		log.Printf("failed to set gain, error: %v", err)
+		return
+	}
+
+	// a 30k/10k divider on A0 for the battery
+	// a capacitive soil moisture probe on A1
+	// a GL5528 ldr with a 10k resistor on A2
+	battery := dev.NewVoltageDivider(ads.Channel(dev.ADSA0), 30000, 10000)
+	soil := dev.NewSoilMoisture(ads.Channel(dev.ADSA1), 2.8, 1.2)
+	ldr := dev.NewLDR(ads.Channel(dev.ADSA2), 3.3, 10000, 10000, 0.6)
+
+	for {
+		v, err := battery.Volts()
+		if err != nil {
+			log.Printf("failed to read battery, error: %v", err)
+		}
+		p, err := soil.Percent()
+		if err != nil {
+			log.Printf("failed to read soil moisture, error: %v", err)
+		}
+		lux, err := ldr.Lux()
+		if err != nil {
+			log.Printf("failed to read ldr, error: %v", err)
+		}
+		log.Printf("battery: %.2fV, soil moisture: %.0f%%, light: %.0f lux", v, p, lux)
+		time.Sleep(1 * time.Second)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	p18 = 18 // passive buzzer on a pwm pin
+)
+
+const tetris = "tetris:d=4,o=5,b=160:e6,8b,8c6,8d6,16e6,16d6,8c6,8b,a,8a,8c6,e6,8d6,8c6,b,8b,8c6,d6,e6,c6,a,2a"
+
+func main() {
+	if err := rpio.Open(); err != nil {
+		log.Fatalf("failed to open rpio, error: %v", err)
+		return
+	}
+	defer rpio.Close()
+
+	bzr := dev.NewPassiveBuzzer(p18)
+
+	var op string
+	for {
+		fmt.Printf(">>op: ")
+		if n, err := fmt.Scanf("%s", &op); n != 1 || err != nil {
+			log.Printf("invalid operator, error: %v", err)
+			continue
+		}
+		switch op {
+		case "beep":
+			bzr.Beep(3, 100)
+		case "tone":
+			bzr.Tone(440, 500*time.Millisecond)
+		case "song":
+			m, err := dev.ParseRTTTL(tetris)
+			if err != nil {
+				log.Printf("failed to parse the song, error: %v", err)
+				continue
+			}
+			bzr.Play(m)
+		case "stop":
+			bzr.Stop()
+		case "q":
+			bzr.Stop()
+			log.Printf("quit\n")
+			return
+		default:
+			if _, ok := dev.Alerts[op]; ok {
+				bzr.Alert(op)
+				continue
+			}
+			names := []string{}
+			for name := range dev.Alerts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Printf("invalid operator, should be: beep, tone, song, stop, %v or q\n", strings.Join(names, ", "))
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/shanghuiyang/rpi-devices/dev"
+)
+
+const (
+	photoFile = "photo.jpg"
+)
+
+// usage: camera [motion|libcamera|raspistill|v4l2]
+func main() {
+	cfg := &base.CameraConfig{}
+	if len(os.Args) > 1 {
+		cfg.Type = os.Args[1]
+	}
+	cam, err := dev.NewCameraFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to create camera, error: %v", err)
+		return
+	}
+
+	photo, err := cam.TakePhoto()
+	if err != nil {
+		log.Fatalf("failed to take photo, error: %v", err)
+		return
+	}
+	if err := photo.Save(photoFile); err != nil {
+		log.Fatalf("failed to save photo, error: %v", err)
+		return
+	}
+	log.Printf("saved %vx%v photo taken by %v at %v to %v", photo.Width, photo.Height, photo.Source, photo.Time.Format("15:04:05"), photoFile)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/dev"
+)
+
+const (
+	cols = 16
+	rows = 2
+)
+
+func main() {
+	lcd, err := dev.NewHD44780("", dev.DefaultHD44780Addr, cols, rows)
+	if err != nil {
+		log.Fatalf("failed to create hd44780, error: %v", err)
+		return
+	}
+	lcd.Open()
+	lcd.Display("23.5°C 45%\n35µg/m³")
+
+	fmt.Printf("input a text to display, '|' for a new line, or a command:\n")
+	fmt.Printf("  :light on|off\tturn on or off the backlight\n")
+	fmt.Printf("  :clock\tdisplay the time\n")
+	fmt.Printf("  q!\t\tquit\n")
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf(">>input: ")
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			log.Printf("invalid input, error: %v", err)
+			break
+		}
+		input = strings.Trim(input, "\n")
+		if input == "q!" {
+			log.Printf("quit")
+			break
+		}
+		args := strings.Fields(input)
+		switch {
+		case len(args) == 2 && args[0] == ":light":
+			if err := lcd.SetBacklight(args[1] == "on"); err != nil {
+				log.Printf("failed to set backlight, error: %v", err)
+			}
+		case len(args) == 1 && args[0] == ":clock":
+			now := time.Now()
+			lcd.Display(now.Format("2006-01-02") + "\n" + now.Format("15:04:05"))
+		default:
+			lcd.Display(strings.Replace(input, "|", "\n", -1))
+		}
+	}
+	lcd.Close()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/dev"
+)
+
+func main() {
+	// a 0.1 ohm shunt, and 3.2A at most
+	ina, err := dev.NewINA219(0x40, 0.1, 3.2)
+	if err != nil {
+		log.Printf("failed to new an ina219, error: %v", err)
+		return
+	}
+	defer ina.Close()
+
+	// a 2S li-ion pack
+	battery, err := dev.NewBattery(ina, dev.LiIon, 2, 20, 5)
+	if err != nil {
+		log.Printf("failed to new a battery, error: %v", err)
+		return
+	}
+
+	for {
+		if _, err := battery.Update(); err != nil {
+			log.Printf("failed to update battery, error: %v", err)
+		}
+		a, err := ina.Current()
+		if err != nil {
+			log.Printf("failed to read current, error: %v", err)
+		}
+		w, err := ina.Power()
+		if err != nil {
+			log.Printf("failed to read power, error: %v", err)
+		}
+		log.Printf("battery: %.2fV, %.0f%%(%v), current: %.3fA, power: %.2fW",
+			battery.Volts(), battery.SoC(), battery.State(), a, w)
+		time.Sleep(1 * time.Second)
+	}
+}
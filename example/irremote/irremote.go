@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	pin = 24
+)
+
+func main() {
+	if err := rpio.Open(); err != nil {
+		log.Fatalf("failed to open rpio, error: %v", err)
+		return
+	}
+	defer rpio.Close()
+
+	ir := dev.NewIRReceiver(pin)
+	ir.Start()
+	base.WaitQuit(func() {
+		ir.Stop()
+		rpio.Close()
+	})
+
+	keys := dev.NewCarMP3KeyMap()
+	for code := range ir.Codes() {
+		key, repeat, ok := keys.Key(code)
+		if !ok {
+			// print the unknown codes for adding your own remote
+			log.Printf("code: %v", code)
+			continue
+		}
+		log.Printf("key: %v, repeat: %v", key, repeat)
+	}
+}
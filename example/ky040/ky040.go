@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	pinCLK = 17
+	pinDT  = 27
+	pinSW  = 22
+)
+
+func main() {
+	if err := rpio.Open(); err != nil {
+		log.Fatalf("failed to open rpio, error: %v", err)
+		return
+	}
+	defer rpio.Close()
+
+	count := 0
+	k := dev.NewKY040(pinCLK, pinDT, pinSW)
+	k.OnTurn(func(steps int) {
+		count += steps
+		log.Printf("turned %v, count: %v", steps, count)
+	})
+	k.OnPress(func() {
+		count = 0
+		log.Printf("pressed, count: %v", count)
+	})
+	k.OnLongPress(func() {
+		log.Printf("long pressed")
+	})
+	k.Start()
+
+	base.WaitQuit(func() {
+		k.Stop()
+		rpio.Close()
+	})
+	select {}
+}
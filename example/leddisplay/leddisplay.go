@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/shanghuiyang/rpi-devices/dev"
 	"github.com/stianeikeland/go-rpio"
@@ -27,9 +29,14 @@ func main() {
 
 	d := dev.NewLedDisplay(dioPin, rclkPin, sclkPin)
 	d.Open()
+	fmt.Printf("input a text to display, or a command:\n")
+	fmt.Printf("  :b <0-1>\tbrightness\n")
+	fmt.Printf("  :blink <ms>\tblink, 0 for no blinking\n")
+	fmt.Printf("  :clock\tdisplay the time\n")
+	fmt.Printf("  q!\t\tquit\n")
+	reader := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Printf(">>input: ")
-		reader := bufio.NewReader(os.Stdin)
 		input, err := reader.ReadString('\n')
 		if err != nil {
 			log.Printf("invalid input, error: %v", err)
@@ -40,8 +47,27 @@ func main() {
 			log.Printf("quit")
 			break
 		}
-		d.Display(input)
-
+		args := strings.Fields(input)
+		switch {
+		case len(args) == 2 && args[0] == ":b":
+			b, err := strconv.ParseFloat(args[1], 64)
+			if err != nil {
+				log.Printf("invalid brightness, error: %v", err)
+				continue
+			}
+			d.SetBrightness(b)
+		case len(args) == 2 && args[0] == ":blink":
+			ms, err := strconv.Atoi(args[1])
+			if err != nil {
+				log.Printf("invalid interval, error: %v", err)
+				continue
+			}
+			d.SetBlink(time.Duration(ms) * time.Millisecond)
+		case len(args) == 1 && args[0] == ":clock":
+			d.Display(dev.FormatClock(time.Now(), true))
+		default:
+			d.Display(input)
+		}
 	}
 	d.Close()
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shanghuiyang/rpi-devices/dev"
+)
+
+const (
+	// the 4-in-1 led matrix module
+	modules = 4
+)
+
+func main() {
+	m, err := dev.NewLedMatrix(dev.SPIDev(0, 0), modules)
+	if err != nil {
+		log.Fatalf("failed to create led matrix, error: %v", err)
+		return
+	}
+	m.Open()
+	m.Display("PM2.5 35µg/m³")
+
+	fmt.Printf("input a text to display, or a command:\n")
+	fmt.Printf("  :l <0-15>\tintensity level\n")
+	fmt.Printf("  q!\t\tquit\n")
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf(">>input: ")
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			log.Printf("invalid input, error: %v", err)
+			break
+		}
+		input = strings.Trim(input, "\n")
+		if input == "q!" {
+			log.Printf("quit")
+			break
+		}
+		args := strings.Fields(input)
+		if len(args) == 2 && args[0] == ":l" {
+			l, err := strconv.Atoi(args[1])
+			if err != nil {
+				log.Printf("invalid level, error: %v", err)
+				continue
+			}
+			m.SetIntensity(l)
+			continue
+		}
+		m.Display(input)
+	}
+	m.Close()
+}
@@ -1,26 +1,47 @@
 package main
 
 import (
+	"image"
 	"log"
+	"math"
 	"time"
 
 	"github.com/shanghuiyang/rpi-devices/base"
 	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/shanghuiyang/rpi-devices/ui"
 )
 
 func main() {
-	oled, err := dev.NewOLED(128, 32)
+	oled, err := dev.NewOLED(128, 64)
 	if err != nil {
 		log.Printf("failed to create an oled, error: %v", err)
 		return
 	}
 
-	base.WaitQuit(oled.Close)
-	for {
-		t := time.Now().Format("15:04:05")
-		if err := oled.Display(t, 19, 0, 25); err != nil {
-			log.Printf("failed to display time, error: %v", err)
-			break
+	clock := ui.NewLabel(image.Rect(0, 0, 128, 13), ui.DefaultFace, ui.AlignCenter)
+	wave := ui.NewReadout(image.Rect(0, 16, 128, 29), ui.DefaultFace, "Sin", "", 2)
+	second := ui.NewProgressBar(image.Rect(0, 32, 128, 40))
+	chart := ui.NewSparkline(image.Rect(0, 44, 128, 64))
+	hist := base.NewHistory(128)
+
+	screen := ui.NewScreen(oled)
+	screen.AddPage("home", clock, wave, second, chart)
+	screen.Start(200 * time.Millisecond)
+
+	base.WaitQuit(func() {
+		screen.Close()
+		oled.Close()
+	})
+	for i := 0; ; i++ {
+		now := time.Now()
+		v := math.Sin(float64(i) / 10)
+		clock.SetText(now.Format("15:04:05"))
+		wave.SetValue(v)
+		wave.SetInverse(v > 0.9)
+		second.SetValue(float64(now.Second()) / 59)
+		hist.Add(v)
+		if err := chart.SetHistory(hist); err != nil {
+			log.Printf("failed to draw the chart, error: %v", err)
 		}
 		time.Sleep(1 * time.Second)
 	}
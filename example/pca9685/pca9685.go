@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/dev"
+)
+
+func main() {
+	// 50Hz for servos
+	pca, err := dev.NewPCA9685(0x40, 50)
+	if err != nil {
+		log.Printf("failed to new a pca9685, error: %v", err)
+		return
+	}
+	defer pca.Close()
+
+	// a sg90 on channel 0, and a led on channel 15
+	servo := dev.NewPCA9685Servo(pca, 0, dev.SG90Spec)
+	led := dev.NewPWMLed(pca.Channel(15))
+
+	for {
+		servo.Move(-60, 90, dev.EaseInOut)
+		if err := led.FadeTo(1, 1*time.Second); err != nil {
+			log.Printf("failed to fade led, error: %v", err)
+		}
+		servo.Move(60, 90, dev.EaseInOut)
+		if err := led.FadeTo(0, 1*time.Second); err != nil {
+			log.Printf("failed to fade led, error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	pin = 25
+)
+
+func main() {
+	if err := rpio.Open(); err != nil {
+		log.Fatalf("failed to open rpio, error: %v", err)
+		return
+	}
+	defer rpio.Close()
+
+	pir := dev.NewHCSR501(pin)
+	pir.Start()
+	occ := dev.NewOccupancy(pir, 5*time.Minute)
+	occ.OnChange(func(occupied bool) {
+		if occupied {
+			log.Printf("the room is occupied")
+			return
+		}
+		log.Printf("the room is vacant, %v motions", occ.Episodes())
+	})
+	occ.Start(100 * time.Millisecond)
+
+	base.WaitQuit(func() {
+		occ.Stop()
+		pir.Stop()
+		rpio.Close()
+	})
+	for e := range pir.Events() {
+		if !pir.Ready() {
+			log.Printf("warming up, ignore the motion")
+			continue
+		}
+		log.Printf("motion: %v", e.Motion)
+	}
+}
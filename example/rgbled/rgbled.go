@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	pinR = 17
+	pinG = 27
+	pinB = 22
+)
+
+func main() {
+	if err := rpio.Open(); err != nil {
+		log.Fatalf("failed to open rpio, error: %v", err)
+		return
+	}
+	defer rpio.Close()
+
+	rgb := dev.NewSoftRGBLed(pinR, pinG, pinB, false)
+	leds := dev.NewLedController(rgb)
+	defer leds.Close()
+	leds.Play("idle", dev.BreathePattern(dev.Green, 3*time.Second), 0, 0)
+
+	var op string
+	for {
+		fmt.Printf(">>op: ")
+		if n, err := fmt.Scanf("%s", &op); n != 1 || err != nil {
+			log.Printf("invalid operator, error: %v", err)
+			continue
+		}
+		switch op {
+		case "blink":
+			leds.Play("op", dev.BlinkPattern(dev.Red, 200*time.Millisecond, 200*time.Millisecond), 1, 5*time.Second)
+		case "heartbeat":
+			leds.Play("op", dev.HeartbeatPattern(dev.Magenta), 1, 10*time.Second)
+		case "sos":
+			leds.Play("op", dev.MorsePattern(dev.Orange, "sos", 150*time.Millisecond), 1, 0)
+		case "rainbow":
+			p := dev.LedPattern{Loop: true}
+			for _, c := range []dev.Color{dev.Red, dev.Yellow, dev.Green, dev.Cyan, dev.Blue, dev.Magenta} {
+				p.Steps = append(p.Steps, dev.LedStep{Color: c, Duration: time.Second, Fade: true})
+			}
+			leds.Play("op", p, 1, 0)
+		case "stop":
+			leds.Stop("op")
+		case "q":
+			log.Printf("quit\n")
+			return
+		default:
+			fmt.Printf("invalid operator, should be: blink, heartbeat, sos, rainbow, stop or q\n")
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/shanghuiyang/rpi-devices/sim"
+)
+
+const (
+	// run 10 times faster than the real time
+	scale   = 10
+	timeout = 2 * time.Minute
+)
+
+// usage: go run sim.go [world.json] [trace.svg]
+func main() {
+	worldf, svgf := "sim/testdata/corridor.json", "trace.svg"
+	if len(os.Args) > 1 {
+		worldf = os.Args[1]
+	}
+	if len(os.Args) > 2 {
+		svgf = os.Args[2]
+	}
+
+	w, err := sim.LoadWorld(worldf)
+	if err != nil {
+		log.Printf("failed to load the world, error: %v", err)
+		return
+	}
+	s := sim.New(w, sim.DefaultSpec, scale)
+	car := dev.NewCar(s.Options()...)
+	car.Start()
+	defer car.Stop()
+
+	if err := car.Do(dev.CarOp("selfdrivingon")); err != nil {
+		log.Printf("failed to start self-driving, error: %v", err)
+		return
+	}
+	for s.Clock().Elapsed() < timeout && !s.Escaped() {
+		s.Clock().Sleep(time.Second)
+	}
+	car.Do(dev.CarOp("selfdrivingoff"))
+	log.Printf("escaped: %v, time: %v, bumps: %v", s.Escaped(), s.Clock().Elapsed().Round(time.Second), len(s.Bumps()))
+
+	f, err := os.Create(svgf)
+	if err != nil {
+		log.Printf("failed to create %v, error: %v", svgf, err)
+		return
+	}
+	defer f.Close()
+	if err := s.WriteSVG(f); err != nil {
+		log.Printf("failed to write the trace, error: %v", err)
+		return
+	}
+	log.Printf("the trace was written to %v", svgf)
+}
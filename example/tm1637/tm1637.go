@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/stianeikeland/go-rpio"
+)
+
+const (
+	clkPin = 2
+	dioPin = 3
+)
+
+func main() {
+
+	if err := rpio.Open(); err != nil {
+		log.Fatalf("failed to open rpio, error: %v", err)
+		return
+	}
+	defer rpio.Close()
+
+	d := dev.NewTM1637(clkPin, dioPin)
+	d.Open()
+	fmt.Printf("input a text to display, or a command:\n")
+	fmt.Printf("  :l <0-7>\tbrightness level\n")
+	fmt.Printf("  :blink <ms>\tblink, 0 for no blinking\n")
+	fmt.Printf("  :clock\tdisplay the time\n")
+	fmt.Printf("  q!\t\tquit\n")
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf(">>input: ")
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			log.Printf("invalid input, error: %v", err)
+			break
+		}
+		input = strings.Trim(input, "\n")
+		if input == "q!" {
+			log.Printf("quit")
+			break
+		}
+		args := strings.Fields(input)
+		switch {
+		case len(args) == 2 && args[0] == ":l":
+			l, err := strconv.Atoi(args[1])
+			if err != nil {
+				log.Printf("invalid level, error: %v", err)
+				continue
+			}
+			d.SetLevel(l)
+		case len(args) == 2 && args[0] == ":blink":
+			ms, err := strconv.Atoi(args[1])
+			if err != nil {
+				log.Printf("invalid interval, error: %v", err)
+				continue
+			}
+			d.SetBlink(time.Duration(ms) * time.Millisecond)
+		case len(args) == 1 && args[0] == ":clock":
+			now := time.Now()
+			d.SetColon(now.Second()%2 == 0)
+			d.Display(dev.FormatClock(now, false))
+		default:
+			d.SetColon(false)
+			d.Display(input)
+		}
+	}
+	d.Close()
+}
@@ -0,0 +1,38 @@
+package sim
+
+import (
+	"time"
+)
+
+// Clock runs faster than the wall clock by the scale, so that a minute of driving is simulated in seconds.
+// The car and the world share the clock, the timings of the car keep the same in the simulated time.
+type Clock struct {
+	scale float64
+	start time.Time
+}
+
+// NewClock creates a clock, scale is how many times faster it runs than the wall clock
+func NewClock(scale float64) *Clock {
+	if scale <= 0 {
+		scale = 1
+	}
+	return &Clock{
+		scale: scale,
+		start: time.Now(),
+	}
+}
+
+// Now ...
+func (c *Clock) Now() time.Time {
+	return c.start.Add(c.Elapsed())
+}
+
+// Sleep ...
+func (c *Clock) Sleep(d time.Duration) {
+	time.Sleep(time.Duration(float64(d) / c.scale))
+}
+
+// Elapsed returns the simulated time since the clock was created
+func (c *Clock) Elapsed() time.Duration {
+	return time.Duration(float64(time.Since(c.start)) * c.scale)
+}
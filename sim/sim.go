@@ -0,0 +1,483 @@
+package sim
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/dev"
+)
+
+const (
+	// the step of integrating the motion of the car
+	simStep = 2 * time.Millisecond
+	// the interval of recording the trace
+	traceInterval = 100 * time.Millisecond
+	// the depth of the bumpers in front of the car in cm
+	bumperDepth = 1.0
+	// the rays cast in the beam of the ultrasonic sensor
+	beamRays = 5
+	// the min distance the ultrasonic sensor measures in cm
+	minRange = 2.0
+	// the speed of the servo in degree/s, 0.1s/60° of SG90
+	servoSpeed = 600.0
+)
+
+// the wheels, the encoders and the bumpers
+const (
+	Left = iota
+	Right
+)
+
+// Spec is the spec of the simulated car
+type Spec struct {
+	Length float64 // the length of the body in cm
+	Width  float64 // the width of the body in cm
+	// the effective distance between the wheels in cm,
+	// it's larger than the real one since the wheels skid when turning in place.
+	Track         float64
+	WheelDiameter float64 // cm
+	EncoderTicks  int     // the ticks of an encoder in a revolution of the wheel
+	MaxSpeed      float64 // the speed of a wheel at 100% duty in cm/s
+	MinDuty       float64 // the motors don't move under the duty in percent
+	Range         float64 // the max distance the ultrasonic sensor measures in cm
+	BeamWidth     float64 // the beam width of the ultrasonic sensor in degree
+}
+
+// DefaultSpec is close to the car in app/car
+var DefaultSpec = Spec{
+	Length:        20,
+	Width:         14,
+	Track:         22,
+	WheelDiameter: 6.5,
+	EncoderTicks:  20,
+	MaxSpeed:      100,
+	MinDuty:       10,
+	Range:         450,
+	BeamWidth:     15,
+}
+
+// Sim simulates a differential-drive car in the world,
+// the motion is integrated lazily up to the time of the clock whenever a device is used.
+type Sim struct {
+	world *World
+	spec  Spec
+	clock *Clock
+
+	mu       sync.Mutex
+	pose     Pose
+	last     time.Time
+	in       [2]float64 // the directions of the motors set by the input pins of the engine
+	en       [2]float64 // the duties of the motors in percent set by the enable pins of the engine
+	rotated  [2]float64 // the distances the wheels rotated in cm, including skidding
+	servo    float64
+	bumped   [2]bool
+	blocked  bool
+	trace    []Pose
+	traceAt  time.Time
+	bumps    []Point
+	engine   *Engine
+	encoders [2]*Encoder
+}
+
+// New creates a simulation of a car in the world, the car is at the start of the world,
+// scale is how many times faster the simulation runs than the real time.
+func New(world *World, spec Spec, scale float64) *Sim {
+	clock := NewClock(scale)
+	now := clock.Now()
+	s := &Sim{
+		world:   world,
+		spec:    spec,
+		clock:   clock,
+		pose:    world.Start,
+		last:    now,
+		trace:   []Pose{world.Start},
+		traceAt: now,
+	}
+	s.engine = &Engine{sim: s}
+	s.engine.Speed(30) // the same as L298N
+	s.encoders = [2]*Encoder{{sim: s, wheel: Left}, {sim: s, wheel: Right}}
+	return s
+}
+
+// Options returns the options of dev.Car with the fake devices and the clock,
+// the encoder is on the left wheel, and the collision switches are on the left and right of the front.
+// Append dev.WithMotion(s.MotionController()) for the car with the encoders on both wheels.
+func (s *Sim) Options() []dev.Option {
+	return []dev.Option{
+		dev.WithEngine(s.Engine()),
+		dev.WithServo(s.Servo()),
+		dev.WithUlt(s.Ultrasonic()),
+		dev.WithEncoder(s.Encoder(Left)),
+		dev.WithCSwitchs([]dev.CollisionDetector{s.Bumper(Left), s.Bumper(Right)}),
+		dev.WithClock(s.clock),
+	}
+}
+
+// MotionController creates a motion controller on the engine and the encoders of both wheels
+func (s *Sim) MotionController() *dev.MotionController {
+	m := dev.NewMotionController(s.Engine(), s.Encoder(Left), s.Encoder(Right), s.spec.WheelDiameter, s.spec.Track, s.spec.EncoderTicks)
+	m.SetClock(s.clock)
+	return m
+}
+
+// Clock ...
+func (s *Sim) Clock() *Clock {
+	return s.clock
+}
+
+// World ...
+func (s *Sim) World() *World {
+	return s.world
+}
+
+// Pose ...
+func (s *Sim) Pose() Pose {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+	return s.pose
+}
+
+// Escaped returns true if the car is in the exit of the world
+func (s *Sim) Escaped() bool {
+	p := s.Pose()
+	return s.world.Exit != nil && s.world.Exit.Contains(p.Point())
+}
+
+// Trace returns the poses of the car recorded in every 100ms
+func (s *Sim) Trace() []Pose {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+	return append([]Pose{}, s.trace...)
+}
+
+// Bumps returns the points where the car bumped into the walls or the obstacles
+func (s *Sim) Bumps() []Point {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Point{}, s.bumps...)
+}
+
+// advance integrates the motion up to now, it must be called with the lock held
+func (s *Sim) advance() {
+	now := s.clock.Now()
+	for s.last.Before(now) {
+		dt := now.Sub(s.last)
+		if dt > simStep {
+			dt = simStep
+		}
+		s.step(dt.Seconds())
+		s.last = s.last.Add(dt)
+		if s.last.Sub(s.traceAt) >= traceInterval {
+			s.trace = append(s.trace, s.pose)
+			s.traceAt = s.last
+		}
+	}
+}
+
+// step moves the car by the speeds of the wheels in dt seconds,
+// the car stays if it would hit something, while the wheels keep rotating.
+func (s *Sim) step(dt float64) {
+	var v [2]float64
+	for i := range v {
+		if s.en[i] >= s.spec.MinDuty {
+			v[i] = s.in[i] * s.spec.MaxSpeed * math.Min(100, s.en[i]) / 100
+		}
+		s.rotated[i] += math.Abs(v[i]) * dt
+	}
+
+	turn := (v[Left] - v[Right]) / s.spec.Track * dt * 180 / math.Pi
+	dist := (v[Left] + v[Right]) / 2 * dt
+	mid := (s.pose.Heading + turn/2) * math.Pi / 180
+	moved := Pose{
+		X:       s.pose.X + dist*math.Cos(mid),
+		Y:       s.pose.Y + dist*math.Sin(mid),
+		Heading: math.Mod(s.pose.Heading+turn, 360),
+	}
+
+	blocked := false
+	if moved != s.pose {
+		// slide by turning only if moving is blocked
+		turned := Pose{s.pose.X, s.pose.Y, moved.Heading}
+		switch {
+		case !s.world.Hits(s.body(moved)):
+			s.pose = moved
+		case !s.world.Hits(s.body(turned)):
+			s.pose = turned
+			blocked = dist != 0
+		default:
+			blocked = true
+		}
+	}
+	if blocked && !s.blocked {
+		s.bumps = append(s.bumps, s.pose.Point())
+	}
+	s.blocked = blocked
+	s.bumped = [2]bool{
+		s.world.Hits(s.bumper(Left)),
+		s.world.Hits(s.bumper(Right)),
+	}
+}
+
+// body returns the corners of the car at the pose
+func (s *Sim) body(p Pose) []Point {
+	l, w := s.spec.Length/2, s.spec.Width/2
+	return corners(p, -l, l, -w, w)
+}
+
+// bumper returns the corners of the bumper in front of the car
+func (s *Sim) bumper(side int) []Point {
+	l, w := s.spec.Length/2, s.spec.Width/2
+	if side == Left {
+		return corners(s.pose, l, l+bumperDepth, -w, 0)
+	}
+	return corners(s.pose, l, l+bumperDepth, 0, w)
+}
+
+// corners returns the corners of a rectangle in the frame of the car,
+// x is along the heading, and y is to the right of the car.
+func corners(p Pose, x0, x1, y0, y1 float64) []Point {
+	rad := p.Heading * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	at := func(x, y float64) Point {
+		return Point{p.X + x*cos - y*sin, p.Y + x*sin + y*cos}
+	}
+	return []Point{at(x0, y0), at(x1, y0), at(x1, y1), at(x0, y1)}
+}
+
+// setPins sets the input pins and the enable duties of the motors, and integrates the motion before,
+// the directions or the duties are kept if they're nil.
+func (s *Sim) setPins(in *[2]float64, en *[2]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+	if in != nil {
+		s.in = *in
+	}
+	if en != nil {
+		s.en = *en
+	}
+}
+
+// Engine is the fake L298N, it's also a dev.MotorDriver for the motion controller.
+// Like L298N, the directions are set by the input pins, and the speeds by the duties of the enable pins,
+// Forward, Backward, Left and Right run at the duty set by Speed.
+type Engine struct {
+	sim *Sim
+
+	mu   sync.Mutex
+	duty float64 // the duty set by Speed
+}
+
+// Engine ...
+func (s *Sim) Engine() *Engine {
+	return s.engine
+}
+
+// Forward ...
+func (e *Engine) Forward() {
+	e.lockstep(1, 1)
+}
+
+// Backward ...
+func (e *Engine) Backward() {
+	e.lockstep(-1, -1)
+}
+
+// Left ...
+func (e *Engine) Left() {
+	e.lockstep(-1, 1)
+}
+
+// Right ...
+func (e *Engine) Right() {
+	e.lockstep(1, -1)
+}
+
+// Stop turns the input pins low, the duties are kept
+func (e *Engine) Stop() {
+	e.sim.setPins(&[2]float64{}, nil)
+}
+
+// Speed sets the duty in percent of both motors
+func (e *Engine) Speed(speed uint32) {
+	e.mu.Lock()
+	e.duty = float64(speed)
+	e.mu.Unlock()
+	e.sim.setPins(nil, &[2]float64{e.speed(), e.speed()})
+}
+
+// SetSpeeds sets the signed duties in percent of the left and right wheels
+func (e *Engine) SetSpeeds(left, right float64) {
+	e.sim.setPins(
+		&[2]float64{sign(left), sign(right)},
+		&[2]float64{math.Min(100, math.Abs(left)), math.Min(100, math.Abs(right))},
+	)
+}
+
+// Brake turns the input pins low at full duty
+func (e *Engine) Brake() {
+	e.sim.setPins(&[2]float64{}, &[2]float64{100, 100})
+}
+
+// Coast turns the duties to 0, the input pins are kept
+func (e *Engine) Coast() {
+	e.sim.setPins(nil, &[2]float64{})
+}
+
+// lockstep sets the directions of the motors, and restores the duty set by Speed
+func (e *Engine) lockstep(left, right float64) {
+	d := e.speed()
+	e.sim.setPins(&[2]float64{left, right}, &[2]float64{d, d})
+}
+
+func (e *Engine) speed() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.duty
+}
+
+func sign(v float64) float64 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// Servo is the fake SG90 turning the ultrasonic sensor, angle > 0 is to the right
+type Servo struct {
+	sim *Sim
+}
+
+// Servo ...
+func (s *Sim) Servo() *Servo {
+	return &Servo{sim: s}
+}
+
+// Roll returns after the servo arrived at the angle
+func (v *Servo) Roll(angle int) {
+	v.Move(float64(angle), servoSpeed, dev.Linear)
+}
+
+// Move moves the servo at the speed in degree/s
+func (v *Servo) Move(angle, speed float64, easing dev.Easing) {
+	s := v.sim
+	angle = math.Max(-90, math.Min(90, angle))
+	s.mu.Lock()
+	d := math.Abs(angle - s.servo)
+	s.mu.Unlock()
+	if speed > 0 {
+		s.clock.Sleep(time.Duration(d / speed * float64(time.Second)))
+	}
+	s.mu.Lock()
+	s.servo = angle
+	s.mu.Unlock()
+}
+
+// Ultrasonic is the fake US100 on the servo in the front of the car
+type Ultrasonic struct {
+	sim *Sim
+}
+
+// Ultrasonic ...
+func (s *Sim) Ultrasonic() *Ultrasonic {
+	return &Ultrasonic{sim: s}
+}
+
+// Dist returns the distance to the nearest thing in the beam
+func (u *Ultrasonic) Dist() float64 {
+	s := u.sim
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+
+	rad := s.pose.Heading * math.Pi / 180
+	p := Point{
+		X: s.pose.X + s.spec.Length/2*math.Cos(rad),
+		Y: s.pose.Y + s.spec.Length/2*math.Sin(rad),
+	}
+	angle := s.pose.Heading + s.servo
+	d := s.spec.Range
+	for i := 0; i < beamRays; i++ {
+		a := angle - s.spec.BeamWidth/2 + s.spec.BeamWidth*float64(i)/(beamRays-1)
+		d = math.Min(d, s.world.Raycast(p, a, s.spec.Range))
+	}
+	return math.Max(minRange, d)
+}
+
+// Encoder is the fake encoder on a wheel,
+// unlike Encoder, it doesn't miss the ticks if it isn't read in time, since the simulated time runs much faster.
+type Encoder struct {
+	sim       *Sim
+	wheel     int
+	detecting bool
+	ticks     int
+}
+
+// Encoder returns the encoder on the wheel, Left or Right
+func (s *Sim) Encoder(wheel int) *Encoder {
+	return s.encoders[wheel]
+}
+
+// Start ...
+func (e *Encoder) Start() {
+	s := e.sim
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+	e.detecting = true
+	e.ticks = e.count()
+}
+
+// Stop ...
+func (e *Encoder) Stop() {
+	s := e.sim
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.detecting = false
+}
+
+// Count1 returns the ticks the wheel rotated since the last reading
+func (e *Encoder) Count1() int {
+	s := e.sim
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+	if !e.detecting {
+		return 0
+	}
+	n := e.count()
+	ticks := n - e.ticks
+	e.ticks = n
+	return ticks
+}
+
+func (e *Encoder) count() int {
+	s := e.sim
+	return int(s.rotated[e.wheel] / (math.Pi * s.spec.WheelDiameter / float64(s.spec.EncoderTicks)))
+}
+
+// Bumper is the fake collision switch on the front of the car
+type Bumper struct {
+	sim  *Sim
+	side int
+}
+
+// Bumper returns the bumper on the side, Left or Right
+func (s *Sim) Bumper(side int) *Bumper {
+	return &Bumper{sim: s, side: side}
+}
+
+// Collided returns true if the bumper is touching something
+func (b *Bumper) Collided() bool {
+	s := b.sim
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance()
+	return s.bumped[b.side]
+}
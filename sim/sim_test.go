@@ -0,0 +1,249 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/dev"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testScale = 10
+
+const testWorld = `{
+	"walls": [[[0, 0], [200, 0], [200, 100], [0, 100], [0, 0]]],
+	"boxes": [{"x": 150, "y": 40, "w": 20, "h": 20}],
+	"start": {"x": 50, "y": 50, "heading": 0}
+}`
+
+func newTestSim(t *testing.T) *Sim {
+	w, err := ParseWorld([]byte(testWorld))
+	require.NoError(t, err)
+	return New(w, DefaultSpec, testScale)
+}
+
+// waitFor polls the cond until it's true or the simulated timeout
+func waitFor(s *Sim, timeout time.Duration, cond func() bool) bool {
+	start := s.Clock().Now()
+	for s.Clock().Now().Sub(start) < timeout {
+		if cond() {
+			return true
+		}
+		s.Clock().Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+func TestLoadWorld(t *testing.T) {
+	w, err := LoadWorld("testdata/corridor.json")
+	require.NoError(t, err)
+	assert.Len(t, w.Walls, 2)
+	assert.Len(t, w.Circles, 1)
+	assert.Equal(t, Pose{40, 30, 0}, w.Start)
+	require.NotNil(t, w.Exit)
+	assert.True(t, w.Exit.Contains(Point{370, 300}))
+
+	min, max := w.Bounds()
+	assert.Equal(t, Point{0, 0}, min)
+	assert.Equal(t, Point{400, 320}, max)
+
+	_, err = LoadWorld("testdata/nothing.json")
+	assert.Error(t, err)
+	_, err = ParseWorld([]byte(`{"walls": [[[0, 0]]]}`))
+	assert.Error(t, err)
+	_, err = ParseWorld([]byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestRaycast(t *testing.T) {
+	w, err := ParseWorld([]byte(testWorld))
+	require.NoError(t, err)
+	testCases := []struct {
+		desc  string
+		angle float64
+		dist  float64
+	}{
+		{
+			desc:  "to the box",
+			angle: 0,
+			dist:  100,
+		},
+		{
+			desc:  "to the bottom",
+			angle: 90,
+			dist:  50,
+		},
+		{
+			desc:  "to the left",
+			angle: 180,
+			dist:  50,
+		},
+		{
+			desc:  "to the corner",
+			angle: -45,
+			dist:  50 * math.Sqrt2,
+		},
+	}
+	for _, test := range testCases {
+		assert.InDelta(t, test.dist, w.Raycast(Point{50, 50}, test.angle, 450), 1e-6, test.desc)
+	}
+	assert.Equal(t, 30.0, w.Raycast(Point{50, 50}, 0, 30))
+
+	assert.True(t, w.Hits([]Point{{140, 45}, {155, 45}, {155, 55}, {140, 55}}))
+	assert.True(t, w.Hits([]Point{{150, 30}, {180, 30}, {180, 70}, {150, 70}}), "the box is inside")
+	assert.False(t, w.Hits([]Point{{40, 40}, {60, 40}, {60, 60}, {40, 60}}))
+}
+
+func TestSimDrive(t *testing.T) {
+	s := newTestSim(t)
+	e := s.Engine()
+	e.Speed(50)
+	e.Forward()
+	s.Clock().Sleep(time.Second)
+	assert.InDelta(t, 100, s.Pose().X, 5) // 50% of 100cm/s
+	s.Clock().Sleep(time.Second)
+	e.Stop()
+	p := s.Pose()
+	assert.InDelta(t, 140, p.X, 1) // blocked by the box
+	assert.InDelta(t, 50, p.Y, 1e-6)
+	assert.True(t, s.Bumper(Left).Collided())
+	assert.True(t, s.Bumper(Right).Collided())
+	assert.Len(t, s.Bumps(), 1)
+	assert.InDelta(t, 2, s.Ultrasonic().Dist(), 2)
+
+	// the wheels skid after it was blocked, the encoders still count
+	enc := s.Encoder(Left)
+	enc.Start()
+	e.Forward()
+	assert.True(t, waitFor(s, time.Second, func() bool { return enc.Count1() > 0 }))
+	e.Stop()
+	assert.InDelta(t, p.X, s.Pose().X, 0.5)
+
+	// back off and turn around in place
+	e.Backward()
+	s.Clock().Sleep(200 * time.Millisecond)
+	e.Stop()
+	p = s.Pose()
+	e.Speed(20)
+	e.Right()
+	assert.True(t, waitFor(s, 5*time.Second, func() bool { return s.Pose().Heading >= 170 }))
+	e.Stop()
+	assert.InDelta(t, 180, s.Pose().Heading, 30)
+	assert.InDelta(t, p.X, s.Pose().X, 1e-6)
+	assert.True(t, p.X < 135)
+	assert.False(t, s.Bumper(Left).Collided())
+	assert.True(t, s.Ultrasonic().Dist() > 50)
+
+	// the motors don't move under the min duty
+	p = s.Pose()
+	e.SetSpeeds(5, 5)
+	s.Clock().Sleep(500 * time.Millisecond)
+	assert.Equal(t, p, s.Pose())
+}
+
+func TestSimEngine(t *testing.T) {
+	s := newTestSim(t)
+	e := s.Engine()
+	e.Speed(40)
+	pins := func() (in, en [2]float64) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.in, s.en
+	}
+
+	// like L298N, the duty set by Speed is restored after braking
+	e.SetSpeeds(80, -20)
+	in, en := pins()
+	assert.Equal(t, [2]float64{1, -1}, in)
+	assert.Equal(t, [2]float64{80, 20}, en)
+	e.Brake()
+	in, en = pins()
+	assert.Equal(t, [2]float64{0, 0}, in)
+	assert.Equal(t, [2]float64{100, 100}, en)
+	e.Forward()
+	in, en = pins()
+	assert.Equal(t, [2]float64{1, 1}, in)
+	assert.Equal(t, [2]float64{40, 40}, en)
+
+	// and after coasting
+	e.Coast()
+	_, en = pins()
+	assert.Equal(t, [2]float64{0, 0}, en)
+	p := s.Pose()
+	s.Clock().Sleep(200 * time.Millisecond)
+	assert.Equal(t, p, s.Pose())
+	e.Backward()
+	in, en = pins()
+	assert.Equal(t, [2]float64{-1, -1}, in)
+	assert.Equal(t, [2]float64{40, 40}, en)
+	s.Clock().Sleep(200 * time.Millisecond)
+	e.Stop()
+	assert.True(t, s.Pose().X < p.X)
+}
+
+func TestSimServo(t *testing.T) {
+	s := newTestSim(t)
+	u := s.Ultrasonic()
+	assert.InDelta(t, 90, u.Dist(), 1) // 100 - the half of the car
+	s.Servo().Roll(90)
+	assert.InDelta(t, 50, u.Dist(), 1)
+	s.Servo().Roll(-90)
+	assert.InDelta(t, 50, u.Dist(), 1)
+}
+
+func TestSimMotion(t *testing.T) {
+	s := newTestSim(t)
+	m := s.MotionController()
+	assert.NoError(t, m.Turn(90))
+	assert.InDelta(t, 90, s.Pose().Heading, 10)
+	assert.NoError(t, m.Turn(-90))
+	assert.InDelta(t, 0, s.Pose().Heading, 10)
+	assert.NoError(t, m.Drive(-30))
+	assert.InDelta(t, 20, s.Pose().X, 3)
+}
+
+func TestSimCarEscapesCorridor(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		motion bool
+	}{
+		{
+			desc: "by the left encoder",
+		},
+		{
+			desc:   "by the motion controller",
+			motion: true,
+		},
+	}
+	for _, test := range testCases {
+		w, err := LoadWorld("testdata/corridor.json")
+		require.NoError(t, err)
+		s := New(w, DefaultSpec, testScale)
+		opts := s.Options()
+		if test.motion {
+			opts = append(opts, dev.WithMotion(s.MotionController()))
+		}
+		car := dev.NewCar(opts...)
+		require.NoError(t, car.Start())
+
+		require.NoError(t, car.Do(dev.CarOp("selfdrivingon")), test.desc)
+		escaped := waitFor(s, 60*time.Second, s.Escaped)
+		car.Stop()
+
+		var buf bytes.Buffer
+		require.NoError(t, s.WriteSVG(&buf))
+		assert.NoError(t, xml.Unmarshal(buf.Bytes(), new(struct{})), test.desc)
+		if !escaped {
+			f := filepath.Join(os.TempDir(), "corridor.svg")
+			ioutil.WriteFile(f, buf.Bytes(), 0644)
+			t.Errorf("the car didn't escape the corridor in 60s %v, pose: %+v, trace: %v", test.desc, s.Pose(), f)
+		}
+	}
+}
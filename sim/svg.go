@@ -0,0 +1,66 @@
+package sim
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// the margin around the world in the svg in cm
+	svgMargin = 20.0
+)
+
+// WriteSVG draws the world, the trace of the car and the points it bumped into things,
+// the car is drawn in green at the start and in blue at the end.
+func (s *Sim) WriteSVG(w io.Writer) error {
+	trace := s.Trace()
+	bumps := s.Bumps()
+	world := s.world
+
+	min, max := world.Bounds()
+	min.X, min.Y = min.X-svgMargin, min.Y-svgMargin
+	max.X, max.Y = max.X+svgMargin, max.Y+svgMargin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="%.1f %.1f %.1f %.1f" width="%.0f" height="%.0f">`+"\n",
+		min.X, min.Y, max.X-min.X, max.Y-min.Y, (max.X-min.X)*2, (max.Y-min.Y)*2)
+	fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="white"/>`+"\n",
+		min.X, min.Y, max.X-min.X, max.Y-min.Y)
+	if e := world.Exit; e != nil {
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#e0ffe0" stroke="green" stroke-dasharray="4"/>`+"\n",
+			e.X, e.Y, e.W, e.H)
+	}
+	for _, wall := range world.Walls {
+		fmt.Fprintf(&b, `<polyline points="%v" fill="none" stroke="black" stroke-width="2"/>`+"\n", points(wall))
+	}
+	for _, r := range world.Boxes {
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="gray"/>`+"\n", r.X, r.Y, r.W, r.H)
+	}
+	for _, c := range world.Circles {
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="gray"/>`+"\n", c.X, c.Y, c.R)
+	}
+
+	var path []Point
+	for _, p := range trace {
+		path = append(path, p.Point())
+	}
+	fmt.Fprintf(&b, `<polyline points="%v" fill="none" stroke="blue" stroke-width="1"/>`+"\n", points(path))
+	for _, p := range bumps {
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="red"/>`+"\n", p.X, p.Y)
+	}
+	fmt.Fprintf(&b, `<polygon points="%v" fill="none" stroke="green"/>`+"\n", points(s.body(trace[0])))
+	fmt.Fprintf(&b, `<polygon points="%v" fill="none" stroke="blue"/>`+"\n", points(s.body(trace[len(trace)-1])))
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func points(ps []Point) string {
+	var strs []string
+	for _, p := range ps {
+		strs = append(strs, fmt.Sprintf("%.1f,%.1f", p.X, p.Y))
+	}
+	return strings.Join(strs, " ")
+}
@@ -0,0 +1,250 @@
+/*
+Package sim is a headless simulator of the car, it runs the real logic of dev.Car on the fake devices in a 2D world,
+so that self-driving can be tested without putting the car on the floor.
+
+The world is in cm, x goes right and y goes down like svg,
+and the heading is in degree, 0 is +x and it increases when turning right (clockwise).
+
+The world is loaded from a json file, e.g.
+	{
+		"walls": [[[0, 0], [400, 0], [400, 300], [0, 300], [0, 0]]],
+		"boxes": [{"x": 200, "y": 100, "w": 40, "h": 40}],
+		"circles": [{"x": 300, "y": 200, "r": 15}],
+		"start": {"x": 50, "y": 150, "heading": 0},
+		"exit": {"x": 350, "y": 0, "w": 50, "h": 300}
+	}
+ - walls:	the polylines of the walls
+ - boxes:	the rectangle obstacles
+ - circles:	the round obstacles, like the legs of a chair
+ - start:	the pose of the car at the beginning
+ - exit:	the area the car is expected to reach, it's optional
+
+*/
+package sim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+)
+
+const (
+	// the sides of the polygons the circles are approximated by
+	circleSides = 24
+)
+
+// Point ...
+type Point struct {
+	X float64
+	Y float64
+}
+
+// UnmarshalJSON reads a point in [x, y]
+func (p *Point) UnmarshalJSON(data []byte) error {
+	var xy [2]float64
+	if err := json.Unmarshal(data, &xy); err != nil {
+		return err
+	}
+	p.X, p.Y = xy[0], xy[1]
+	return nil
+}
+
+// Rect ...
+type Rect struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// Contains ...
+func (r Rect) Contains(p Point) bool {
+	return p.X >= r.X && p.X <= r.X+r.W && p.Y >= r.Y && p.Y <= r.Y+r.H
+}
+
+// Circle ...
+type Circle struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	R float64 `json:"r"`
+}
+
+// Pose is the position of the center of the car and its heading in degree
+type Pose struct {
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Heading float64 `json:"heading"`
+}
+
+// Point ...
+func (p Pose) Point() Point {
+	return Point{p.X, p.Y}
+}
+
+type segment struct {
+	a, b Point
+}
+
+// World ...
+type World struct {
+	Walls   [][]Point `json:"walls"`
+	Boxes   []Rect    `json:"boxes"`
+	Circles []Circle  `json:"circles"`
+	Start   Pose      `json:"start"`
+	Exit    *Rect     `json:"exit"`
+
+	segs []segment
+}
+
+// LoadWorld loads a world from the json file
+func LoadWorld(file string) (*World, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	w, err := ParseWorld(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid world %v, error: %v", file, err)
+	}
+	return w, nil
+}
+
+// ParseWorld ...
+func ParseWorld(data []byte) (*World, error) {
+	var w World
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, err
+	}
+	for _, wall := range w.Walls {
+		if len(wall) < 2 {
+			return nil, errors.New("a wall needs 2 points at least")
+		}
+	}
+	w.init()
+	if len(w.segs) == 0 {
+		return nil, errors.New("empty world")
+	}
+	return &w, nil
+}
+
+// init splits the walls and the obstacles into segments
+func (w *World) init() {
+	w.segs = nil
+	for _, wall := range w.Walls {
+		for i := 1; i < len(wall); i++ {
+			w.segs = append(w.segs, segment{wall[i-1], wall[i]})
+		}
+	}
+	for _, r := range w.Boxes {
+		w.addPolygon([]Point{{r.X, r.Y}, {r.X + r.W, r.Y}, {r.X + r.W, r.Y + r.H}, {r.X, r.Y + r.H}})
+	}
+	for _, c := range w.Circles {
+		var ps []Point
+		for i := 0; i < circleSides; i++ {
+			a := 2 * math.Pi * float64(i) / circleSides
+			ps = append(ps, Point{c.X + c.R*math.Cos(a), c.Y + c.R*math.Sin(a)})
+		}
+		w.addPolygon(ps)
+	}
+}
+
+func (w *World) addPolygon(ps []Point) {
+	for i := range ps {
+		w.segs = append(w.segs, segment{ps[i], ps[(i+1)%len(ps)]})
+	}
+}
+
+// Bounds returns the top-left and bottom-right corners of the world
+func (w *World) Bounds() (min, max Point) {
+	min = Point{math.Inf(1), math.Inf(1)}
+	max = Point{math.Inf(-1), math.Inf(-1)}
+	for _, s := range w.segs {
+		for _, p := range []Point{s.a, s.b} {
+			min.X, min.Y = math.Min(min.X, p.X), math.Min(min.Y, p.Y)
+			max.X, max.Y = math.Max(max.X, p.X), math.Max(max.Y, p.Y)
+		}
+	}
+	return min, max
+}
+
+// Raycast returns the distance from p to the nearest wall or obstacle in the direction of angle in degree,
+// it returns maxRange if nothing is in the range.
+func (w *World) Raycast(p Point, angle, maxRange float64) float64 {
+	rad := angle * math.Pi / 180
+	d := Point{math.Cos(rad), math.Sin(rad)}
+	nearest := maxRange
+	for _, s := range w.segs {
+		if t, ok := raySegment(p, d, s); ok && t < nearest {
+			nearest = t
+		}
+	}
+	return nearest
+}
+
+// Hits returns true if the convex polygon touches any wall or obstacle
+func (w *World) Hits(polygon []Point) bool {
+	for _, s := range w.segs {
+		if inPolygon(s.a, polygon) || inPolygon(s.b, polygon) {
+			return true
+		}
+		for i := range polygon {
+			if intersect(s, segment{polygon[i], polygon[(i+1)%len(polygon)]}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// raySegment returns the distance t from p along the unit direction d to the segment
+func raySegment(p, d Point, s segment) (float64, bool) {
+	e := Point{s.b.X - s.a.X, s.b.Y - s.a.Y}
+	denom := cross(d, e)
+	if denom == 0 {
+		return 0, false
+	}
+	ap := Point{s.a.X - p.X, s.a.Y - p.Y}
+	t := cross(ap, e) / denom
+	u := cross(ap, d) / denom
+	if t < 0 || u < 0 || u > 1 {
+		return 0, false
+	}
+	return t, true
+}
+
+func intersect(s1, s2 segment) bool {
+	d1 := orient(s2.a, s2.b, s1.a)
+	d2 := orient(s2.a, s2.b, s1.b)
+	d3 := orient(s1.a, s1.b, s2.a)
+	d4 := orient(s1.a, s1.b, s2.b)
+	return d1*d2 <= 0 && d3*d4 <= 0 && !(d1 == 0 && d2 == 0 && !overlap(s1, s2))
+}
+
+// overlap checks the collinear segments
+func overlap(s1, s2 segment) bool {
+	return math.Max(s1.a.X, s1.b.X) >= math.Min(s2.a.X, s2.b.X) &&
+		math.Max(s2.a.X, s2.b.X) >= math.Min(s1.a.X, s1.b.X) &&
+		math.Max(s1.a.Y, s1.b.Y) >= math.Min(s2.a.Y, s2.b.Y) &&
+		math.Max(s2.a.Y, s2.b.Y) >= math.Min(s1.a.Y, s1.b.Y)
+}
+
+// inPolygon returns true if p is inside the convex polygon
+func inPolygon(p Point, polygon []Point) bool {
+	var pos, neg bool
+	for i := range polygon {
+		o := orient(polygon[i], polygon[(i+1)%len(polygon)], p)
+		pos = pos || o > 0
+		neg = neg || o < 0
+	}
+	return !(pos && neg)
+}
+
+func orient(a, b, c Point) float64 {
+	return cross(Point{b.X - a.X, b.Y - a.Y}, Point{c.X - a.X, c.Y - a.Y})
+}
+
+func cross(a, b Point) float64 {
+	return a.X*b.Y - a.Y*b.X
+}
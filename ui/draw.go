@@ -0,0 +1,101 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+)
+
+var (
+	on  = color.Gray{Y: 0xFF}
+	off = color.Gray{Y: 0x00}
+)
+
+// DefaultFace is a 7x13 bitmap font, there are 4 lines and 18 chars in a line on a 128x64 oled
+var DefaultFace font.Face = basicfont.Face7x13
+
+// LoadFace loads a truetype font in the size, like the casio-fx-9860gii.ttf for the big digits
+func LoadFace(file string, size float64) (font.Face, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	f, err := truetype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return truetype.NewFace(f, &truetype.Options{
+		Size:    size,
+		Hinting: font.HintingFull,
+	}), nil
+}
+
+func fillRect(dst *image.Gray, r image.Rectangle, lit bool) {
+	c := off
+	if lit {
+		c = on
+	}
+	r = r.Intersect(dst.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dst.SetGray(x, y, c)
+		}
+	}
+}
+
+func invertRect(dst *image.Gray, r image.Rectangle) {
+	r = r.Intersect(dst.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = ^dst.Pix[i]
+		}
+	}
+}
+
+// drawRect draws the border of the rectangle
+func drawRect(dst *image.Gray, r image.Rectangle) {
+	x0, y0, x1, y1 := r.Min.X, r.Min.Y, r.Max.X-1, r.Max.Y-1
+	drawLine(dst, x0, y0, x1, y0)
+	drawLine(dst, x0, y1, x1, y1)
+	drawLine(dst, x0, y0, x0, y1)
+	drawLine(dst, x1, y0, x1, y1)
+}
+
+// drawLine draws a line with the Bresenham's algorithm
+func drawLine(dst *image.Gray, x0, y0, x1, y1 int) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		dst.SetGray(x0, y0, on)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if 2*e >= dy {
+			e += dy
+			x0 += sx
+		}
+		if 2*e <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
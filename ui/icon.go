@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+)
+
+// the built-in 8x8 icons
+var (
+	// IconTemp is a thermometer
+	IconTemp = ParseIcon(
+		"...##...",
+		"..#..#..",
+		"..#..#..",
+		"..#..#..",
+		"..####..",
+		".######.",
+		".######.",
+		"..####..",
+	)
+	// IconHumidity is a drop
+	IconHumidity = ParseIcon(
+		"...#....",
+		"...##...",
+		"..####..",
+		".######.",
+		".######.",
+		"########",
+		".######.",
+		"..####..",
+	)
+	// IconAir is a cloud
+	IconAir = ParseIcon(
+		"........",
+		"...###..",
+		"..#...#.",
+		".##...##",
+		"#.......",
+		"#.......",
+		".#######",
+		"........",
+	)
+	// IconAlert is a warning sign
+	IconAlert = ParseIcon(
+		"...##...",
+		"...##...",
+		"..#..#..",
+		"..#..#..",
+		".#.##.#.",
+		".#....#.",
+		"#..##..#",
+		"########",
+	)
+)
+
+// ParseIcon creates an icon from the rows, '#' is a lit pixel and the others are not
+func ParseIcon(rows ...string) *image.Alpha {
+	w := 0
+	for _, row := range rows {
+		if len(row) > w {
+			w = len(row)
+		}
+	}
+	icon := image.NewAlpha(image.Rect(0, 0, w, len(rows)))
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				icon.SetAlpha(x, y, color.Alpha{A: 0xFF})
+			}
+		}
+	}
+	return icon
+}
@@ -0,0 +1,161 @@
+package ui
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/shanghuiyang/rpi-devices/dev"
+)
+
+const (
+	buttonInterval = 50 * time.Millisecond
+)
+
+// the actions of a remote for the menu, see base.RemoteMapping
+const (
+	ActionMenuUp    = "menu.up"
+	ActionMenuDown  = "menu.down"
+	ActionMenuEnter = "menu.enter"
+	ActionMenuBack  = "menu.back"
+)
+
+// MenuInputs feeds the keys to the menu from the buttons, a KY-040 rotary encoder and a remote
+type MenuInputs struct {
+	menu *Menu
+
+	mu      sync.Mutex
+	buttons map[Key]*dev.Button
+	encoder *dev.KY040
+	remote  *dev.Remote
+	chQuit  chan bool
+}
+
+// NewMenuInputs ...
+func NewMenuInputs(m *Menu) *MenuInputs {
+	return &MenuInputs{
+		menu:    m,
+		buttons: map[Key]*dev.Button{},
+	}
+}
+
+// NewMenuInputsFromConfig creates the inputs in the menu config, the remote is from the remote config
+func NewMenuInputsFromConfig(m *Menu, cfg *base.Config) (*MenuInputs, error) {
+	in := NewMenuInputs(m)
+	if cfg == nil || cfg.Menu == nil {
+		return in, nil
+	}
+	for k, pin := range map[Key]uint8{
+		KeyUp:    cfg.Menu.Up,
+		KeyDown:  cfg.Menu.Down,
+		KeyEnter: cfg.Menu.Enter,
+		KeyBack:  cfg.Menu.Back,
+	} {
+		if pin > 0 {
+			in.AddButton(k, dev.NewButton(pin))
+		}
+	}
+	if e := cfg.Menu.Encoder; e != nil {
+		in.SetEncoder(dev.NewKY040(e.CLK, e.DT, e.SW))
+	}
+	if cfg.Menu.Remote && cfg.Remote != nil {
+		r, err := dev.NewRemoteFromConfig(cfg.Remote)
+		if err != nil {
+			return nil, err
+		}
+		in.SetRemote(r)
+	}
+	return in, nil
+}
+
+// AddButton uses the button for the key
+func (in *MenuInputs) AddButton(k Key, b *dev.Button) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.buttons[k] = b
+}
+
+// SetEncoder uses the rotary encoder, turning moves in the menu or changes the value,
+// pressing is KeyEnter, and holding is KeyBack.
+func (in *MenuInputs) SetEncoder(e *dev.KY040) {
+	e.OnTurn(in.menu.Turn)
+	e.OnPress(func() { in.menu.Handle(KeyEnter) })
+	e.OnLongPress(func() { in.menu.Handle(KeyBack) })
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.encoder = e
+}
+
+// SetRemote uses the menu.* actions of the remote
+func (in *MenuInputs) SetRemote(r *dev.Remote) {
+	for action, k := range map[string]Key{
+		ActionMenuUp:    KeyUp,
+		ActionMenuDown:  KeyDown,
+		ActionMenuEnter: KeyEnter,
+		ActionMenuBack:  KeyBack,
+	} {
+		k := k
+		r.Handle(action, func(e dev.RemoteEvent) {
+			in.menu.Handle(k)
+		})
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.remote = r
+}
+
+// Start starts reading the inputs in background
+func (in *MenuInputs) Start() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.encoder != nil {
+		in.encoder.Start()
+	}
+	if in.remote != nil {
+		in.remote.Start()
+	}
+	if len(in.buttons) == 0 {
+		return
+	}
+	in.chQuit = make(chan bool)
+	go in.pollButtons(in.chQuit)
+}
+
+// Stop ...
+func (in *MenuInputs) Stop() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.encoder != nil {
+		in.encoder.Stop()
+	}
+	if in.remote != nil {
+		in.remote.Stop()
+	}
+	if in.chQuit != nil {
+		close(in.chQuit)
+		in.chQuit = nil
+	}
+}
+
+func (in *MenuInputs) pollButtons(chQuit chan bool) {
+	for {
+		select {
+		case <-chQuit:
+			return
+		case <-time.After(buttonInterval):
+		}
+		in.mu.Lock()
+		var keys []Key
+		for k, b := range in.buttons {
+			if b.Pressed() {
+				keys = append(keys, k)
+			}
+		}
+		in.mu.Unlock()
+		for _, k := range keys {
+			log.Printf("[%v]key: %v", logTagUI, k)
+			in.menu.Handle(k)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/font"
+)
+
+// Key is an input of the menu
+type Key int
+
+// Keys
+const (
+	KeyUp Key = iota
+	KeyDown
+	KeyEnter
+	KeyBack
+)
+
+func (k Key) String() string {
+	switch k {
+	case KeyUp:
+		return "up"
+	case KeyDown:
+		return "down"
+	case KeyEnter:
+		return "enter"
+	case KeyBack:
+		return "back"
+	}
+	return "unknown"
+}
+
+// MenuItem is an entry of the menu, it's one of:
+//   - a submenu with Items
+//   - a setting with an Editor
+//   - an Action
+// Confirm asks before saving the setting or doing the action.
+type MenuItem struct {
+	Title   string
+	Items   []*MenuItem
+	Editor  Editor
+	Action  func() error
+	Confirm bool
+}
+
+// Editor edits a setting in the menu
+type Editor interface {
+	// Begin starts editing from the current value
+	Begin()
+	// Step changes the value being edited by n steps
+	Step(n int)
+	// Text returns the value being edited, or the current value if it isn't being edited
+	Text() string
+	// Commit applies the value being edited, and ends editing
+	Commit() error
+	// Cancel ends editing without applying
+	Cancel()
+}
+
+// menuLevel is an opened submenu
+type menuLevel struct {
+	item     *MenuItem
+	selected int
+	top      int // the first visible entry
+}
+
+// Menu is a widget of hierarchical menus, value editors and confirmations.
+// It's driven by the keys, every submenu has a "< Back" entry at the end,
+// so it works with the inputs without a back key, like a rotary encoder.
+// The setters and the actions are called with the menu locked, they mustn't call the menu.
+type Menu struct {
+	widget
+	face font.Face
+	root *MenuItem
+
+	opened     bool
+	stack      []*menuLevel
+	editing    *MenuItem
+	confirming *MenuItem
+	yes        bool
+	status     string
+	onOpen     func()
+	onClose    func()
+}
+
+// NewMenu ...
+func NewMenu(r image.Rectangle, face font.Face, root *MenuItem) *Menu {
+	return &Menu{
+		widget: newWidget(r),
+		face:   face,
+		root:   root,
+	}
+}
+
+// OnOpen sets the handler called when the menu is opened, e.g. showing the page of the menu
+func (m *Menu) OnOpen(h func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onOpen = h
+}
+
+// OnClose sets the handler called when the menu is closed
+func (m *Menu) OnClose(h func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onClose = h
+}
+
+// IsOpened ...
+func (m *Menu) IsOpened() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.opened
+}
+
+// Open opens the menu at the root
+func (m *Menu) Open() {
+	m.mu.Lock()
+	h := m.open()
+	m.mu.Unlock()
+	if h != nil {
+		h()
+	}
+}
+
+// Close closes the menu and cancels the editing
+func (m *Menu) Close() {
+	m.mu.Lock()
+	h := m.close()
+	m.mu.Unlock()
+	if h != nil {
+		h()
+	}
+}
+
+// Handle handles a key, KeyEnter opens the menu if it's closed
+func (m *Menu) Handle(k Key) {
+	m.mu.Lock()
+	h := m.handle(k)
+	m.dirty = true
+	m.mu.Unlock()
+	if h != nil {
+		h()
+	}
+}
+
+// Turn handles a rotary encoder, turning clockwise moves down the entries and increases the value
+func (m *Menu) Turn(steps int) {
+	m.mu.Lock()
+	forward, backward := KeyDown, KeyUp
+	if m.editing != nil && m.confirming == nil {
+		forward, backward = KeyUp, KeyDown
+	}
+	m.mu.Unlock()
+
+	k := forward
+	if steps < 0 {
+		k, steps = backward, -steps
+	}
+	for i := 0; i < steps; i++ {
+		m.Handle(k)
+	}
+}
+
+func (m *Menu) handle(k Key) func() {
+	if !m.opened {
+		if k == KeyEnter {
+			return m.open()
+		}
+		return nil
+	}
+	m.status = ""
+
+	if m.confirming != nil {
+		switch k {
+		case KeyUp, KeyDown:
+			m.yes = !m.yes
+		case KeyEnter:
+			item := m.confirming
+			m.confirming = nil
+			if m.yes {
+				m.apply(item)
+			} else if item.Editor != nil {
+				item.Editor.Cancel()
+				m.editing = nil
+			}
+		case KeyBack:
+			if m.confirming.Editor != nil {
+				m.confirming.Editor.Cancel()
+				m.editing = nil
+			}
+			m.confirming = nil
+		}
+		return nil
+	}
+
+	if m.editing != nil {
+		switch k {
+		case KeyUp:
+			m.editing.Editor.Step(1)
+		case KeyDown:
+			m.editing.Editor.Step(-1)
+		case KeyEnter:
+			if m.editing.Confirm {
+				m.confirming, m.yes = m.editing, false
+				return nil
+			}
+			m.apply(m.editing)
+		case KeyBack:
+			m.editing.Editor.Cancel()
+			m.editing = nil
+		}
+		return nil
+	}
+
+	level := m.level()
+	n := len(level.item.Items) + 1 // with the back entry
+	switch k {
+	case KeyUp:
+		level.selected = (level.selected + n - 1) % n
+	case KeyDown:
+		level.selected = (level.selected + 1) % n
+	case KeyBack:
+		return m.back()
+	case KeyEnter:
+		if level.selected == n-1 {
+			return m.back()
+		}
+		item := level.item.Items[level.selected]
+		switch {
+		case len(item.Items) > 0:
+			m.stack = append(m.stack, &menuLevel{item: item})
+		case item.Editor != nil:
+			item.Editor.Begin()
+			m.editing = item
+		case item.Action != nil:
+			if item.Confirm {
+				m.confirming, m.yes = item, false
+				return nil
+			}
+			m.apply(item)
+		}
+	}
+	return nil
+}
+
+// apply saves the setting or does the action of the item
+func (m *Menu) apply(item *MenuItem) {
+	var err error
+	if item.Editor != nil {
+		err = item.Editor.Commit()
+		m.editing = nil
+	} else if item.Action != nil {
+		err = item.Action()
+	}
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.status = "Done"
+}
+
+func (m *Menu) back() func() {
+	if len(m.stack) == 1 {
+		return m.close()
+	}
+	m.stack = m.stack[:len(m.stack)-1]
+	return nil
+}
+
+func (m *Menu) open() func() {
+	if m.opened {
+		return nil
+	}
+	m.opened = true
+	m.stack = []*menuLevel{{item: m.root}}
+	m.status = ""
+	m.dirty = true
+	return m.onOpen
+}
+
+func (m *Menu) close() func() {
+	if !m.opened {
+		return nil
+	}
+	if m.editing != nil {
+		m.editing.Editor.Cancel()
+	}
+	m.opened = false
+	m.stack = nil
+	m.editing = nil
+	m.confirming = nil
+	m.dirty = true
+	return m.onClose
+}
+
+func (m *Menu) level() *menuLevel {
+	return m.stack[len(m.stack)-1]
+}
+
+// Draw draws the title in inverse video at the top, and the entries below it
+func (m *Menu) Draw(dst *image.Gray) {
+	m.draw(dst, func(dst *image.Gray) {
+		if !m.opened {
+			return
+		}
+		lineHeight := m.face.Metrics().Height.Ceil()
+		ascent := m.face.Metrics().Ascent.Ceil()
+		line := func(i int) image.Rectangle {
+			y := m.rect.Min.Y + i*lineHeight
+			return image.Rect(m.rect.Min.X, y, m.rect.Max.X, y+lineHeight)
+		}
+		text := func(i int, s string, align Align) {
+			drawText(dst, m.face, s, line(i), line(i).Min.Y+ascent, align)
+		}
+
+		level := m.level()
+		title := level.item.Title
+		switch {
+		case m.status != "":
+			title = m.status
+		case m.confirming != nil:
+			title = m.confirming.Title + "?"
+		case m.editing != nil:
+			title = m.editing.Title
+		}
+		text(0, title, AlignLeft)
+		invertRect(dst, line(0))
+
+		switch {
+		case m.confirming != nil:
+			text(2, "No", AlignCenter)
+			text(3, "Yes", AlignCenter)
+			if m.yes {
+				invertRect(dst, line(3))
+			} else {
+				invertRect(dst, line(2))
+			}
+		case m.editing != nil:
+			text(2, "< "+m.editing.Editor.Text()+" >", AlignCenter)
+		default:
+			rows := m.rect.Dy()/lineHeight - 1
+			if rows < 1 {
+				return
+			}
+			// scroll to keep the selected one visible
+			if level.selected < level.top {
+				level.top = level.selected
+			}
+			if level.selected >= level.top+rows {
+				level.top = level.selected - rows + 1
+			}
+			for i := 0; i < rows && level.top+i <= len(level.item.Items); i++ {
+				idx := level.top + i
+				if idx == len(level.item.Items) {
+					back := "< Back"
+					if len(m.stack) == 1 {
+						back = "< Exit"
+					}
+					text(i+1, back, AlignLeft)
+				} else {
+					item := level.item.Items[idx]
+					title := item.Title
+					if len(item.Items) > 0 {
+						title += " >"
+					}
+					text(i+1, title, AlignLeft)
+					if item.Editor != nil {
+						text(i+1, item.Editor.Text(), AlignRight)
+					}
+				}
+				if idx == level.selected {
+					invertRect(dst, line(i+1))
+				}
+			}
+		}
+	})
+}
+
+// Number is an editor of a number in [Min, Max]
+type Number struct {
+	Get  func() float64
+	Set  func(v float64) error
+	Min  float64
+	Max  float64
+	Inc  float64 // the change of a step
+	Prec int
+	Unit string
+
+	editing bool
+	value   float64
+}
+
+// Begin ...
+func (n *Number) Begin() {
+	n.editing = true
+	n.value = n.Get()
+}
+
+// Step ...
+func (n *Number) Step(steps int) {
+	v := n.value + float64(steps)*n.Inc
+	n.value = math.Max(n.Min, math.Min(n.Max, v))
+}
+
+// Text ...
+func (n *Number) Text() string {
+	v := n.value
+	if !n.editing {
+		v = n.Get()
+	}
+	return strconv.FormatFloat(v, 'f', n.Prec, 64) + n.Unit
+}
+
+// Commit ...
+func (n *Number) Commit() error {
+	n.editing = false
+	return n.Set(n.value)
+}
+
+// Cancel ...
+func (n *Number) Cancel() {
+	n.editing = false
+}
+
+// Choice is an editor choosing one of the options
+type Choice struct {
+	Options []string
+	Get     func() string
+	Set     func(v string) error
+
+	editing bool
+	index   int
+}
+
+// NewToggle creates a choice of "off" and "on"
+func NewToggle(get func() bool, set func(on bool) error) *Choice {
+	return &Choice{
+		Options: []string{"off", "on"},
+		Get: func() string {
+			if get() {
+				return "on"
+			}
+			return "off"
+		},
+		Set: func(v string) error {
+			return set(v == "on")
+		},
+	}
+}
+
+// Begin ...
+func (c *Choice) Begin() {
+	c.editing = true
+	c.index = 0
+	cur := c.Get()
+	for i, o := range c.Options {
+		if o == cur {
+			c.index = i
+		}
+	}
+}
+
+// Step ...
+func (c *Choice) Step(n int) {
+	if len(c.Options) == 0 {
+		return
+	}
+	c.index = ((c.index+n)%len(c.Options) + len(c.Options)) % len(c.Options)
+}
+
+// Text ...
+func (c *Choice) Text() string {
+	if !c.editing {
+		return c.Get()
+	}
+	if len(c.Options) == 0 {
+		return ""
+	}
+	return c.Options[c.index]
+}
+
+// Commit ...
+func (c *Choice) Commit() error {
+	c.editing = false
+	if len(c.Options) == 0 {
+		return fmt.Errorf("no options")
+	}
+	return c.Set(c.Options[c.index])
+}
+
+// Cancel ...
+func (c *Choice) Cancel() {
+	c.editing = false
+}
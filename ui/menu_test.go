@@ -0,0 +1,203 @@
+package ui
+
+import (
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMenuNavigation(t *testing.T) {
+	var on, off float64 = 120, 100
+	mode := "normal"
+	restarted := 0
+	root := &MenuItem{
+		Title: "Settings",
+		Items: []*MenuItem{
+			{
+				Title: "Thresholds",
+				Items: []*MenuItem{
+					{
+						Title: "On",
+						Editor: &Number{
+							Get: func() float64 { return on },
+							Set: func(v float64) error {
+								if v <= off {
+									return errors.New("too low")
+								}
+								on = v
+								return nil
+							},
+							Min: 0, Max: 500, Inc: 10,
+						},
+					},
+					{
+						Title: "Off",
+						Editor: &Number{
+							Get: func() float64 { return off },
+							Set: func(v float64) error { off = v; return nil },
+							Min: 0, Max: 500, Inc: 10,
+						},
+					},
+				},
+			},
+			{
+				Title: "Mode",
+				Editor: &Choice{
+					Options: []string{"normal", "baby"},
+					Get:     func() string { return mode },
+					Set:     func(v string) error { mode = v; return nil },
+				},
+				Confirm: true,
+			},
+			{
+				Title:   "Restart",
+				Action:  func() error { restarted++; return nil },
+				Confirm: true,
+			},
+		},
+	}
+
+	m := NewMenu(image.Rect(0, 0, 128, 64), DefaultFace, root)
+	opened, closed := 0, 0
+	m.OnOpen(func() { opened++ })
+	m.OnClose(func() { closed++ })
+
+	// the keys are ignored except enter when it's closed
+	m.Handle(KeyDown)
+	assert.False(t, m.IsOpened())
+	m.Handle(KeyEnter)
+	assert.True(t, m.IsOpened())
+	assert.Equal(t, 1, opened)
+
+	// Thresholds > On: 120 -> 150
+	m.Handle(KeyEnter)
+	m.Handle(KeyEnter)
+	m.Handle(KeyUp)
+	m.Handle(KeyUp)
+	m.Handle(KeyUp)
+	assert.Equal(t, "150", root.Items[0].Items[0].Editor.Text())
+	assert.Equal(t, float64(120), on)
+	m.Handle(KeyEnter)
+	assert.Equal(t, float64(150), on)
+
+	// On: 150 -> 90 fails since it's lower than Off
+	m.Handle(KeyEnter)
+	m.Turn(-6)
+	m.Handle(KeyEnter)
+	assert.Equal(t, float64(150), on)
+	assert.Equal(t, "too low", m.status)
+
+	// Off: cancel the editing
+	m.Handle(KeyDown)
+	m.Handle(KeyEnter)
+	m.Handle(KeyUp)
+	m.Handle(KeyBack)
+	assert.Equal(t, float64(100), off)
+	assert.Equal(t, "100", root.Items[0].Items[1].Editor.Text())
+
+	// < Back
+	m.Handle(KeyDown)
+	m.Handle(KeyEnter)
+	assert.Equal(t, root, m.level().item)
+
+	// Mode: baby, it asks before saving
+	m.Handle(KeyDown)
+	m.Handle(KeyEnter)
+	m.Turn(1)
+	m.Handle(KeyEnter)
+	assert.Equal(t, "normal", mode)
+	assert.NotNil(t, m.confirming)
+	// No
+	m.Handle(KeyEnter)
+	assert.Equal(t, "normal", mode)
+	assert.Nil(t, m.editing)
+	// Yes
+	m.Handle(KeyEnter)
+	m.Handle(KeyDown)
+	m.Handle(KeyEnter)
+	m.Handle(KeyDown)
+	m.Handle(KeyEnter)
+	assert.Equal(t, "baby", mode)
+
+	// Restart
+	m.Turn(1)
+	m.Handle(KeyEnter)
+	assert.Equal(t, 0, restarted)
+	m.Handle(KeyUp)
+	m.Handle(KeyEnter)
+	assert.Equal(t, 1, restarted)
+	assert.Equal(t, "Done", m.status)
+
+	// < Exit wraps around from the first entry
+	m.Handle(KeyDown)
+	m.Handle(KeyDown)
+	assert.Equal(t, 0, m.level().selected)
+	m.Handle(KeyUp)
+	m.Handle(KeyEnter)
+	assert.False(t, m.IsOpened())
+	assert.Equal(t, 1, closed)
+}
+
+func TestMenuDraw(t *testing.T) {
+	root := &MenuItem{Title: "Menu"}
+	for i := 0; i < 10; i++ {
+		root.Items = append(root.Items, &MenuItem{Title: "item"})
+	}
+	m := NewMenu(image.Rect(0, 0, 128, 64), DefaultFace, root)
+
+	img := image.NewGray(image.Rect(0, 0, 128, 64))
+	m.Draw(img)
+	assert.Equal(t, 0, lit(img, img.Rect))
+
+	m.Open()
+	m.Draw(img)
+	// the title is in inverse video
+	assert.True(t, lit(img, image.Rect(0, 0, 128, 13)) > 128*13/2)
+	assert.False(t, m.Dirty())
+
+	// scroll to the last one
+	m.Turn(-1)
+	assert.True(t, m.Dirty())
+	m.Draw(img)
+	assert.Equal(t, 10, m.level().selected)
+	assert.Equal(t, 8, m.level().top)
+}
+
+func TestNumber(t *testing.T) {
+	v := 0.5
+	n := &Number{
+		Get:  func() float64 { return v },
+		Set:  func(f float64) error { v = f; return nil },
+		Min:  0,
+		Max:  1,
+		Inc:  0.1,
+		Prec: 1,
+		Unit: "mg",
+	}
+	assert.Equal(t, "0.5mg", n.Text())
+	n.Begin()
+	n.Step(3)
+	assert.Equal(t, "0.8mg", n.Text())
+	n.Step(10)
+	assert.Equal(t, "1.0mg", n.Text())
+	n.Step(-20)
+	assert.Equal(t, "0.0mg", n.Text())
+	assert.NoError(t, n.Commit())
+	assert.Equal(t, float64(0), v)
+}
+
+func TestToggle(t *testing.T) {
+	on := false
+	c := NewToggle(
+		func() bool { return on },
+		func(v bool) error { on = v; return nil },
+	)
+	assert.Equal(t, "off", c.Text())
+	c.Begin()
+	c.Step(-1)
+	assert.Equal(t, "on", c.Text())
+	assert.NoError(t, c.Commit())
+	assert.True(t, on)
+}
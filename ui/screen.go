@@ -0,0 +1,223 @@
+/*
+Package ui ...
+
+ui is a small retained-mode ui for the monochrome displays like the oled.
+A screen has pages of widgets, and one page is shown at a time.
+The widgets keep their content, the callers update them from any goroutine,
+and the screen only redraws the changed widgets.
+
+e.g.
+	temp := ui.NewReadout(image.Rect(0, 0, 128, 13), ui.DefaultFace, "Temp", "C", 1)
+	chart := ui.NewSparkline(image.Rect(0, 16, 128, 40))
+
+	screen := ui.NewScreen(oled)
+	screen.AddPage("home", temp, chart)
+	screen.Start(time.Second)
+
+	temp.SetValue(23.5)
+	chart.SetValues([]float64{22.9, 23.1, 23.5})
+*/
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	logTagUI = "ui"
+)
+
+// Output is where the screen is drawn, like dev.OLED
+type Output interface {
+	Bounds() image.Rectangle
+	DrawImage(img image.Image) error
+}
+
+// Page is a group of widgets shown together
+type Page struct {
+	Name    string
+	Widgets []Widget
+}
+
+// Screen ...
+type Screen struct {
+	out    Output
+	canvas *image.Gray
+	now    func() time.Time
+
+	mu      sync.Mutex
+	pages   []*Page
+	current int
+	rotate  time.Duration
+	shownAt time.Time
+	redraw  bool // redraw all the widgets of the page in the next update
+	chQuit  chan bool
+	chDone  chan bool
+}
+
+// NewScreen ...
+func NewScreen(out Output) *Screen {
+	return newScreen(out, time.Now)
+}
+
+func newScreen(out Output, now func() time.Time) *Screen {
+	return &Screen{
+		out:     out,
+		canvas:  image.NewGray(out.Bounds()),
+		now:     now,
+		shownAt: now(),
+		redraw:  true,
+	}
+}
+
+// AddPage adds a page of the widgets, the first page is shown by default
+func (s *Screen) AddPage(name string, widgets ...Widget) *Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := &Page{
+		Name:    name,
+		Widgets: widgets,
+	}
+	s.pages = append(s.pages, p)
+	if len(s.pages) == 1 {
+		s.redraw = true
+	}
+	return p
+}
+
+// SetRotation shows the pages in turn in the interval, 0 means no rotation
+func (s *Screen) SetRotation(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotate = interval
+	s.shownAt = s.now()
+}
+
+// ShowPage shows the page with the name
+func (s *Screen) ShowPage(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, p := range s.pages {
+		if p.Name == name {
+			s.show(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("page %v not found", name)
+}
+
+// NextPage shows the next page
+func (s *Screen) NextPage() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pages) == 0 {
+		return
+	}
+	s.show((s.current + 1) % len(s.pages))
+}
+
+// Page returns the name of the page being shown
+func (s *Screen) Page() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pages) == 0 {
+		return ""
+	}
+	return s.pages[s.current].Name
+}
+
+// Update redraws the changed widgets of the current page,
+// and it draws the output only if there are changes.
+func (s *Screen) Update() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pages) == 0 {
+		return nil
+	}
+	if s.rotate > 0 && len(s.pages) > 1 && s.now().Sub(s.shownAt) >= s.rotate {
+		s.show((s.current + 1) % len(s.pages))
+	}
+
+	changed := s.redraw
+	if s.redraw {
+		draw.Draw(s.canvas, s.canvas.Bounds(), image.Black, image.Point{}, draw.Src)
+	}
+	for _, w := range s.pages[s.current].Widgets {
+		if s.redraw || w.Dirty() {
+			w.Draw(s.canvas)
+			changed = true
+		}
+	}
+	s.redraw = false
+	if !changed {
+		return nil
+	}
+	if err := s.out.DrawImage(s.canvas); err != nil {
+		// try again in the next update
+		s.redraw = true
+		return err
+	}
+	return nil
+}
+
+// Image returns a copy of what is shown
+func (s *Screen) Image() *image.Gray {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	img := image.NewGray(s.canvas.Bounds())
+	copy(img.Pix, s.canvas.Pix)
+	return img
+}
+
+// Start updates the screen in the interval in background
+func (s *Screen) Start(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.chQuit != nil {
+		return
+	}
+	s.chQuit = make(chan bool)
+	s.chDone = make(chan bool)
+	go s.start(interval, s.chQuit, s.chDone)
+}
+
+// Close stops updating the screen
+func (s *Screen) Close() {
+	s.mu.Lock()
+	chQuit, chDone := s.chQuit, s.chDone
+	s.chQuit, s.chDone = nil, nil
+	s.mu.Unlock()
+	if chQuit == nil {
+		return
+	}
+	close(chQuit)
+	<-chDone
+}
+
+func (s *Screen) start(interval time.Duration, chQuit, chDone chan bool) {
+	defer close(chDone)
+	for {
+		if err := s.Update(); err != nil {
+			log.Printf("[%v]failed to update the screen, error: %v", logTagUI, err)
+		}
+		select {
+		case <-chQuit:
+			return
+		case <-time.After(interval):
+			// next update
+		}
+	}
+}
+
+func (s *Screen) show(i int) {
+	if i != s.current {
+		s.redraw = true
+	}
+	s.current = i
+	s.shownAt = s.now()
+}
@@ -0,0 +1,120 @@
+package ui
+
+import (
+	"errors"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOutput struct {
+	rect  image.Rectangle
+	draws int
+	err   error
+}
+
+func (o *fakeOutput) Bounds() image.Rectangle {
+	return o.rect
+}
+
+func (o *fakeOutput) DrawImage(img image.Image) error {
+	if o.err != nil {
+		return o.err
+	}
+	o.draws++
+	return nil
+}
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) add(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestScreenPartialRedraw(t *testing.T) {
+	out := &fakeOutput{rect: image.Rect(0, 0, 128, 64)}
+	clk := &fakeClock{t: time.Now()}
+	s := newScreen(out, clk.now)
+
+	a := NewLabel(image.Rect(0, 0, 128, 13), DefaultFace, AlignLeft)
+	b := NewLabel(image.Rect(0, 16, 128, 29), DefaultFace, AlignLeft)
+	s.AddPage("home", a, b)
+
+	assert.NoError(t, s.Update())
+	assert.Equal(t, 1, out.draws)
+	assert.False(t, a.Dirty())
+	assert.False(t, b.Dirty())
+
+	// nothing changed
+	assert.NoError(t, s.Update())
+	assert.Equal(t, 1, out.draws)
+
+	// only the changed widget is redrawn
+	b.SetText("hello")
+	assert.True(t, b.Dirty())
+	assert.False(t, a.Dirty())
+	assert.NoError(t, s.Update())
+	assert.Equal(t, 2, out.draws)
+	assert.False(t, b.Dirty())
+
+	// the same text doesn't change the widget
+	b.SetText("hello")
+	assert.False(t, b.Dirty())
+}
+
+func TestScreenRetryAfterError(t *testing.T) {
+	out := &fakeOutput{rect: image.Rect(0, 0, 128, 64), err: errors.New("i2c error")}
+	s := newScreen(out, time.Now)
+	s.AddPage("home", NewLabel(image.Rect(0, 0, 128, 13), DefaultFace, AlignLeft))
+
+	assert.Error(t, s.Update())
+	out.err = nil
+	assert.NoError(t, s.Update())
+	assert.Equal(t, 1, out.draws)
+}
+
+func TestScreenPages(t *testing.T) {
+	out := &fakeOutput{rect: image.Rect(0, 0, 128, 64)}
+	clk := &fakeClock{t: time.Now()}
+	s := newScreen(out, clk.now)
+	assert.Equal(t, "", s.Page())
+	assert.NoError(t, s.Update())
+
+	s.AddPage("temp", NewLabel(image.Rect(0, 0, 128, 13), DefaultFace, AlignLeft))
+	s.AddPage("air", NewLabel(image.Rect(0, 0, 128, 13), DefaultFace, AlignLeft))
+	s.AddPage("clock", NewLabel(image.Rect(0, 0, 128, 13), DefaultFace, AlignLeft))
+	assert.Equal(t, "temp", s.Page())
+
+	assert.NoError(t, s.ShowPage("clock"))
+	assert.Equal(t, "clock", s.Page())
+	assert.Error(t, s.ShowPage("none"))
+
+	s.NextPage()
+	assert.Equal(t, "temp", s.Page())
+
+	s.SetRotation(5 * time.Second)
+	assert.NoError(t, s.Update())
+	assert.Equal(t, "temp", s.Page())
+	draws := out.draws
+
+	clk.add(5 * time.Second)
+	assert.NoError(t, s.Update())
+	assert.Equal(t, "air", s.Page())
+	// a new page is fully redrawn
+	assert.Equal(t, draws+1, out.draws)
+
+	clk.add(4 * time.Second)
+	assert.NoError(t, s.Update())
+	assert.Equal(t, "air", s.Page())
+	clk.add(1 * time.Second)
+	assert.NoError(t, s.Update())
+	assert.Equal(t, "clock", s.Page())
+}
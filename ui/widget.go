@@ -0,0 +1,422 @@
+package ui
+
+import (
+	"image"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// Align is the horizontal alignment of the text
+type Align int
+
+const (
+	// AlignLeft ...
+	AlignLeft Align = iota
+	// AlignCenter ...
+	AlignCenter
+	// AlignRight ...
+	AlignRight
+)
+
+// Widget is an element on the screen
+type Widget interface {
+	// Bounds returns the area of the widget on the screen
+	Bounds() image.Rectangle
+	// Dirty returns whether the widget is changed since it was drawn
+	Dirty() bool
+	// Draw draws the widget in its bounds
+	Draw(dst *image.Gray)
+}
+
+// widget is the common part of the widgets
+type widget struct {
+	mu      sync.Mutex
+	rect    image.Rectangle
+	inverse bool
+	dirty   bool
+}
+
+func newWidget(r image.Rectangle) widget {
+	return widget{
+		rect:  r,
+		dirty: true,
+	}
+}
+
+// Bounds ...
+func (w *widget) Bounds() image.Rectangle {
+	return w.rect
+}
+
+// Dirty ...
+func (w *widget) Dirty() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dirty
+}
+
+// SetInverse shows the widget in inverse video for highlighting
+func (w *widget) SetInverse(on bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.inverse == on {
+		return
+	}
+	w.inverse = on
+	w.dirty = true
+}
+
+// draw clears the bounds, draws the content with fn and clips it to the bounds
+func (w *widget) draw(dst *image.Gray, fn func(dst *image.Gray)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	sub, ok := dst.SubImage(w.rect).(*image.Gray)
+	if !ok || sub.Rect.Empty() {
+		w.dirty = false
+		return
+	}
+	fillRect(sub, sub.Rect, false)
+	fn(sub)
+	if w.inverse {
+		invertRect(sub, sub.Rect)
+	}
+	w.dirty = false
+}
+
+// Label is a text in one or more lines
+type Label struct {
+	widget
+	face  font.Face
+	align Align
+	wrap  bool
+	text  string
+}
+
+// NewLabel ...
+func NewLabel(r image.Rectangle, face font.Face, align Align) *Label {
+	return &Label{
+		widget: newWidget(r),
+		face:   face,
+		align:  align,
+	}
+}
+
+// SetWrap wraps the long lines at the words
+func (l *Label) SetWrap(wrap bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.wrap = wrap
+	l.dirty = true
+}
+
+// SetText ...
+func (l *Label) SetText(text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.text == text {
+		return
+	}
+	l.text = text
+	l.dirty = true
+}
+
+// Text ...
+func (l *Label) Text() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.text
+}
+
+// Draw ...
+func (l *Label) Draw(dst *image.Gray) {
+	l.draw(dst, func(dst *image.Gray) {
+		var lines []string
+		for _, line := range strings.Split(l.text, "\n") {
+			if l.wrap {
+				lines = append(lines, wrapText(l.face, line, l.rect.Dx())...)
+				continue
+			}
+			lines = append(lines, line)
+		}
+		m := l.face.Metrics()
+		for i, line := range lines {
+			y := l.rect.Min.Y + m.Ascent.Ceil() + i*m.Height.Ceil()
+			drawText(dst, l.face, line, l.rect, y, l.align)
+		}
+	})
+}
+
+// Readout shows a number with the label and the unit, e.g. "PM2.5    35ug/m3"
+type Readout struct {
+	widget
+	face  font.Face
+	label string
+	unit  string
+	prec  int
+	value float64
+}
+
+// NewReadout creates a readout which shows the value with the prec decimals,
+// it shows "--" until the value is set.
+func NewReadout(r image.Rectangle, face font.Face, label, unit string, prec int) *Readout {
+	return &Readout{
+		widget: newWidget(r),
+		face:   face,
+		label:  label,
+		unit:   unit,
+		prec:   prec,
+		value:  math.NaN(),
+	}
+}
+
+// SetValue sets the value, NaN means invalid
+func (r *Readout) SetValue(v float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v == r.value || (math.IsNaN(v) && math.IsNaN(r.value)) {
+		return
+	}
+	r.value = v
+	r.dirty = true
+}
+
+// Text returns the value with the unit
+func (r *Readout) Text() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.text()
+}
+
+func (r *Readout) text() string {
+	if math.IsNaN(r.value) {
+		return "--" + r.unit
+	}
+	return strconv.FormatFloat(r.value, 'f', r.prec, 64) + r.unit
+}
+
+// Draw ...
+func (r *Readout) Draw(dst *image.Gray) {
+	r.draw(dst, func(dst *image.Gray) {
+		y := r.rect.Min.Y + r.face.Metrics().Ascent.Ceil()
+		drawText(dst, r.face, r.label, r.rect, y, AlignLeft)
+		drawText(dst, r.face, r.text(), r.rect, y, AlignRight)
+	})
+}
+
+// ProgressBar ...
+type ProgressBar struct {
+	widget
+	value float64
+}
+
+// NewProgressBar ...
+func NewProgressBar(r image.Rectangle) *ProgressBar {
+	return &ProgressBar{
+		widget: newWidget(r),
+	}
+}
+
+// SetValue sets the progress in [0, 1]
+func (p *ProgressBar) SetValue(v float64) {
+	v = math.Max(0, math.Min(1, v))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if v == p.value {
+		return
+	}
+	p.value = v
+	p.dirty = true
+}
+
+// Draw ...
+func (p *ProgressBar) Draw(dst *image.Gray) {
+	p.draw(dst, func(dst *image.Gray) {
+		r := p.rect
+		drawRect(dst, r)
+		inner := r.Inset(2)
+		if inner.Empty() {
+			return
+		}
+		inner.Max.X = inner.Min.X + int(math.Round(float64(inner.Dx())*p.value))
+		fillRect(dst, inner, true)
+	})
+}
+
+// Sparkline is a mini line chart of the values without axes
+type Sparkline struct {
+	widget
+	values   []float64
+	min, max float64
+}
+
+// NewSparkline ...
+func NewSparkline(r image.Rectangle) *Sparkline {
+	return &Sparkline{
+		widget: newWidget(r),
+	}
+}
+
+// SetRange fixes the range of the values, the range is from the values if min == max
+func (s *Sparkline) SetRange(min, max float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.min, s.max = min, max
+	s.dirty = true
+}
+
+// SetValues sets the values, only the latest ones are shown if they are more than the width
+func (s *Sparkline) SetValues(values []float64) {
+	if n := s.rect.Dx(); len(values) > n {
+		values = values[len(values)-n:]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = append([]float64(nil), values...)
+	s.dirty = true
+}
+
+// SetHistory sets the values from the history
+func (s *Sparkline) SetHistory(h *base.History) error {
+	values, err := h.Values()
+	if err != nil {
+		return err
+	}
+	s.SetValues(values)
+	return nil
+}
+
+// Draw ...
+func (s *Sparkline) Draw(dst *image.Gray) {
+	s.draw(dst, func(dst *image.Gray) {
+		if len(s.values) == 0 {
+			return
+		}
+		min, max := s.min, s.max
+		if min == max {
+			min, max = s.values[0], s.values[0]
+			for _, v := range s.values {
+				min, max = math.Min(min, v), math.Max(max, v)
+			}
+		}
+		r := s.rect
+		y := func(v float64) int {
+			if max == min {
+				return r.Min.Y + (r.Dy()-1)/2
+			}
+			v = math.Max(min, math.Min(max, v))
+			return r.Max.Y - 1 - int(math.Round((v-min)/(max-min)*float64(r.Dy()-1)))
+		}
+		x := func(i int) int {
+			if len(s.values) == 1 {
+				return r.Max.X - 1
+			}
+			return r.Min.X + i*(r.Dx()-1)/(len(s.values)-1)
+		}
+		x0, y0 := x(0), y(s.values[0])
+		dst.SetGray(x0, y0, on)
+		for i := 1; i < len(s.values); i++ {
+			x1, y1 := x(i), y(s.values[i])
+			drawLine(dst, x0, y0, x1, y1)
+			x0, y0 = x1, y1
+		}
+	})
+}
+
+// Icon is a small bitmap, see ParseIcon
+type Icon struct {
+	widget
+	icon *image.Alpha
+}
+
+// NewIcon creates an icon at the point
+func NewIcon(pt image.Point, icon *image.Alpha) *Icon {
+	return &Icon{
+		widget: newWidget(icon.Bounds().Add(pt)),
+		icon:   icon,
+	}
+}
+
+// SetIcon changes the icon, it should have the same size
+func (i *Icon) SetIcon(icon *image.Alpha) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.icon == icon {
+		return
+	}
+	i.icon = icon
+	i.dirty = true
+}
+
+// Draw ...
+func (i *Icon) Draw(dst *image.Gray) {
+	i.draw(dst, func(dst *image.Gray) {
+		b := i.icon.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if i.icon.AlphaAt(x, y).A > 0 {
+					dst.SetGray(i.rect.Min.X+x-b.Min.X, i.rect.Min.Y+y-b.Min.Y, on)
+				}
+			}
+		}
+	})
+}
+
+// drawText draws a line of text at the baseline y with the alignment in r
+func drawText(dst *image.Gray, face font.Face, text string, r image.Rectangle, y int, align Align) {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.White,
+		Face: face,
+	}
+	x := r.Min.X
+	switch align {
+	case AlignCenter:
+		x += (r.Dx() - d.MeasureString(text).Ceil()) / 2
+	case AlignRight:
+		x = r.Max.X - d.MeasureString(text).Ceil()
+	}
+	d.Dot = fixed.P(x, y)
+	d.DrawString(text)
+}
+
+// wrapText breaks the text into lines in the width at the spaces,
+// a word longer than the width is broken at any char.
+func wrapText(face font.Face, text string, width int) []string {
+	fits := func(s string) bool {
+		return font.MeasureString(face, s).Ceil() <= width
+	}
+	var (
+		lines []string
+		line  string
+	)
+	for _, word := range strings.Fields(text) {
+		if line != "" && fits(line+" "+word) {
+			line += " " + word
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+			line = ""
+		}
+		for !fits(word) {
+			n := 1
+			runes := []rune(word)
+			for n < len(runes) && fits(string(runes[:n+1])) {
+				n++
+			}
+			lines = append(lines, string(runes[:n]))
+			word = string(runes[n:])
+		}
+		line = word
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,163 @@
+package ui
+
+import (
+	"image"
+	"testing"
+
+	"github.com/shanghuiyang/rpi-devices/base"
+	"github.com/stretchr/testify/assert"
+)
+
+// lit counts the lit pixels in r
+func lit(img *image.Gray, r image.Rectangle) int {
+	n := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.GrayAt(x, y).Y > 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestLabelAlign(t *testing.T) {
+	r := image.Rect(0, 0, 128, 13)
+	testCases := []struct {
+		align Align
+		lit   image.Rectangle // where the text is
+	}{
+		{AlignLeft, image.Rect(0, 0, 14, 13)},
+		{AlignCenter, image.Rect(57, 0, 71, 13)},
+		{AlignRight, image.Rect(114, 0, 128, 13)},
+	}
+	for _, test := range testCases {
+		img := image.NewGray(image.Rect(0, 0, 128, 64))
+		l := NewLabel(r, DefaultFace, test.align)
+		l.SetText("OK")
+		l.Draw(img)
+		assert.True(t, lit(img, test.lit) > 0)
+		assert.Equal(t, lit(img, img.Rect), lit(img, test.lit))
+	}
+}
+
+func TestLabelClip(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 128, 64))
+	r := image.Rect(0, 0, 20, 13)
+	l := NewLabel(r, DefaultFace, AlignLeft)
+	l.SetText("a long text out of the bounds")
+	l.Draw(img)
+	assert.Equal(t, lit(img, img.Rect), lit(img, r))
+}
+
+func TestLabelInverse(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 128, 64))
+	r := image.Rect(0, 0, 128, 13)
+	l := NewLabel(r, DefaultFace, AlignLeft)
+	l.SetInverse(true)
+	assert.True(t, l.Dirty())
+	l.Draw(img)
+	assert.Equal(t, r.Dx()*r.Dy(), lit(img, r))
+	assert.Equal(t, 0, lit(img, image.Rect(0, 13, 128, 64)))
+}
+
+func TestWrapText(t *testing.T) {
+	// the chars of the default face are 7 pixels wide
+	testCases := []struct {
+		text     string
+		width    int
+		expected []string
+	}{
+		{"", 70, []string{""}},
+		{"hello", 70, []string{"hello"}},
+		{"hello world", 70, []string{"hello", "world"}},
+		{"a b c d", 35, []string{"a b c", "d"}},
+		{"abcdefgh", 35, []string{"abcde", "fgh"}},
+		{"pm2.5 is good", 56, []string{"pm2.5 is", "good"}},
+	}
+	for _, test := range testCases {
+		assert.Equal(t, test.expected, wrapText(DefaultFace, test.text, test.width), test.text)
+	}
+}
+
+func TestReadout(t *testing.T) {
+	r := NewReadout(image.Rect(0, 0, 128, 13), DefaultFace, "PM2.5", "ug", 0)
+	assert.Equal(t, "--ug", r.Text())
+	r.Draw(image.NewGray(image.Rect(0, 0, 128, 64)))
+	assert.False(t, r.Dirty())
+
+	r.SetValue(35.4)
+	assert.True(t, r.Dirty())
+	assert.Equal(t, "35ug", r.Text())
+
+	t2 := NewReadout(image.Rect(0, 0, 128, 13), DefaultFace, "Temp", "C", 1)
+	t2.SetValue(23.45)
+	assert.Equal(t, "23.4C", t2.Text())
+}
+
+func TestProgressBar(t *testing.T) {
+	r := image.Rect(0, 0, 104, 8)
+	testCases := []struct {
+		value float64
+		lit   int
+	}{
+		{0, 2*104 + 2*6},
+		{0.5, 2*104 + 2*6 + 50*4},
+		{1, 2*104 + 2*6 + 100*4},
+		{2, 2*104 + 2*6 + 100*4},
+	}
+	for _, test := range testCases {
+		img := image.NewGray(image.Rect(0, 0, 128, 64))
+		p := NewProgressBar(r)
+		p.SetValue(test.value)
+		p.Draw(img)
+		assert.Equal(t, test.lit, lit(img, img.Rect), test.value)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	r := image.Rect(0, 0, 10, 5)
+	img := image.NewGray(image.Rect(0, 0, 128, 64))
+	s := NewSparkline(r)
+	s.SetValues([]float64{0, 4})
+	s.Draw(img)
+	// a diagonal from the bottom left to the top right
+	assert.Equal(t, uint8(0xFF), img.GrayAt(0, 4).Y)
+	assert.Equal(t, uint8(0xFF), img.GrayAt(9, 0).Y)
+	assert.Equal(t, lit(img, img.Rect), lit(img, r))
+
+	// flat
+	img = image.NewGray(image.Rect(0, 0, 128, 64))
+	s.SetValues([]float64{3, 3, 3})
+	s.Draw(img)
+	assert.Equal(t, 10, lit(img, image.Rect(0, 2, 10, 3)))
+	assert.Equal(t, 10, lit(img, img.Rect))
+
+	// from history, only the latest values in the width are kept
+	h := base.NewHistory(20)
+	for i := 0; i < 20; i++ {
+		h.Add(i)
+	}
+	assert.NoError(t, s.SetHistory(h))
+	assert.Equal(t, 10, len(s.values))
+	assert.Equal(t, float64(10), s.values[0])
+
+	h.Add("bad")
+	assert.Error(t, s.SetHistory(h))
+}
+
+func TestIcon(t *testing.T) {
+	icon := ParseIcon(
+		"#.",
+		".#",
+	)
+	img := image.NewGray(image.Rect(0, 0, 128, 64))
+	i := NewIcon(image.Pt(10, 20), icon)
+	assert.Equal(t, image.Rect(10, 20, 12, 22), i.Bounds())
+	i.Draw(img)
+	assert.Equal(t, uint8(0xFF), img.GrayAt(10, 20).Y)
+	assert.Equal(t, uint8(0xFF), img.GrayAt(11, 21).Y)
+	assert.Equal(t, 2, lit(img, img.Rect))
+
+	assert.Equal(t, image.Rect(0, 0, 8, 8), IconTemp.Bounds())
+}